@@ -0,0 +1,136 @@
+// Copyright 2023 IAC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/engine/trancode"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+	"github.com/spf13/cobra"
+)
+
+var version = "1.0.0"
+
+func main() {
+	var (
+		dbType     string
+		connection string
+		outputFile string
+		logLevel   string
+		timeout    time.Duration
+	)
+
+	rootCmd := &cobra.Command{
+		Use:   "trancodetest [directory]",
+		Short: "IAC Transaction Code Test Runner",
+		Long: `IAC Transaction Code Test Runner
+
+Runs the test data of all transaction code files (*.json) in the directory,
+the trancodes directory by default. Every test runs in a database transaction
+that is rolled back, functions are stubbed with the mocks of the test data.
+The command fails if a test fails or a transaction code cannot be loaded.`,
+		Version: version,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "trancodes"
+			if len(args) > 0 {
+				dir = args[0]
+			}
+
+			logger.Init(map[string]interface{}{"adapter": "console", "level": logLevel})
+
+			dbconn.DatabaseType = dbType
+			dbconn.DatabaseConnection = connection
+			if err := dbconn.ConnectDB(); err != nil {
+				return fmt.Errorf("failed to connect to the database: %v", err)
+			}
+			if dbconn.DB == nil {
+				return fmt.Errorf("failed to connect to the %s database", dbType)
+			}
+			defer dbconn.DB.Close()
+
+			runner := trancode.NewTestRunner()
+			runner.DB = dbconn.DB
+			runner.Timeout = timeout
+
+			suite, err := runner.RunDirectory(dir)
+			if err != nil {
+				return err
+			}
+			printReport(&suite)
+
+			if outputFile != "" {
+				data, err := json.MarshalIndent(suite, "", "  ")
+				if err != nil {
+					return err
+				}
+				if err := ioutil.WriteFile(outputFile, data, 0644); err != nil {
+					return err
+				}
+			}
+
+			if !suite.Succeeded() {
+				return fmt.Errorf("%d tests failed, %d transaction codes could not be tested", suite.Failed, suite.Errors)
+			}
+			return nil
+		},
+	}
+
+	rootCmd.Flags().StringVar(&dbType, "type", "mysql", "database type (mysql, postgres, mssql, oracle)")
+	rootCmd.Flags().StringVar(&connection, "connection", os.Getenv("IAC_TEST_DB_CONNECTION"), "database connection string, IAC_TEST_DB_CONNECTION by default")
+	rootCmd.Flags().StringVar(&outputFile, "output", "", "file to write the JSON test report to")
+	rootCmd.Flags().StringVar(&logLevel, "log-level", "error", "log level of the engine")
+	rootCmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "timeout of each test")
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printReport prints the result of every test and the function group coverage of every transaction code
+func printReport(suite *types.TestSuiteReport) {
+	for _, report := range suite.TranCodes {
+		if report.Error != "" {
+			fmt.Printf("ERROR %s: %s\n", report.TranCode, report.Error)
+			continue
+		}
+		fmt.Printf("%s %s: %d passed, %d failed, coverage %.0f%% of %d function groups\n",
+			report.TranCode, report.Version, report.Passed, report.Failed, report.Coverage*100, report.FuncGroups)
+		for _, result := range report.Results {
+			fmt.Printf("  %s %s (%s)\n", result.Result, result.Name, result.Duration.Round(time.Millisecond))
+			for _, mismatch := range result.Mismatches {
+				fmt.Printf("      %s %s\n", mismatch.Path, mismatch.Message)
+			}
+			if result.ActualError != "" && result.Result == types.TestFail {
+				fmt.Printf("      error: %s\n", result.ActualError)
+			}
+			if len(result.UnmockedFunctions) > 0 {
+				fmt.Printf("      not mocked: %v\n", result.UnmockedFunctions)
+			}
+		}
+		if len(report.MissedFuncGroups) > 0 {
+			fmt.Printf("  not covered: %v\n", report.MissedFuncGroups)
+		}
+	}
+	fmt.Printf("\n%d passed, %d failed, %d errors in %s\n", suite.Passed, suite.Failed, suite.Errors, suite.Duration.Round(time.Millisecond))
+}
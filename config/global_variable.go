@@ -36,21 +36,38 @@ var OpenAiModel string
 var GlobalConfiguration *GlobalConfig
 
 type GlobalConfig struct {
-	Instance           string                   `json:"instance"`
-	InstanceType       string                   `json:"type"`
-	InstanceName       string                   `json:"name"`
-	SingalRConfig      map[string]interface{}   `json:"singalrconfig"`
-	LogConfig          map[string]interface{}   `json:"log"`
-	DocumentConfig     map[string]interface{}   `json:"documentdb"`
-	DatabaseConfig     map[string]interface{}   `json:"database"`
-	AltDatabasesConfig []map[string]interface{} `json:"altdatabases"`
-	CacheConfig        map[string]interface{}   `json:"cache"`
-	TranslationConfig  map[string]interface{}   `json:"translation"`
-	WebServerConfig    map[string]interface{}   `json:"webserver"`
-	Transaction        map[string]interface{}   `json:"transaction"`
-	AppServer          map[string]interface{}   `json:"appserver"`
-	Services           []map[string]interface{} `json:"services"`
-	JobsConfig         JobsConfiguration        `json:"jobs"`
+	Instance           string                     `json:"instance"`
+	InstanceType       string                     `json:"type"`
+	InstanceName       string                     `json:"name"`
+	SingalRConfig      map[string]interface{}     `json:"singalrconfig"`
+	LogConfig          map[string]interface{}     `json:"log"`
+	DocumentConfig     map[string]interface{}     `json:"documentdb"`
+	DatabaseConfig     map[string]interface{}     `json:"database"`
+	AltDatabasesConfig []map[string]interface{}   `json:"altdatabases"`
+	CacheConfig        map[string]interface{}     `json:"cache"`
+	TranslationConfig  map[string]interface{}     `json:"translation"`
+	WebServerConfig    map[string]interface{}     `json:"webserver"`
+	Transaction        map[string]interface{}     `json:"transaction"`
+	AppServer          map[string]interface{}     `json:"appserver"`
+	Services           []map[string]interface{}   `json:"services"`
+	JobsConfig         JobsConfiguration          `json:"jobs"`
+	PythonWorkers      PythonWorkersConfiguration `json:"pythonworkers"`
+	WorkflowConfig     WorkflowConfiguration      `json:"workflow"`
+}
+
+// WorkflowConfiguration holds the business calendar of the workflow timers and the workflow administrators
+type WorkflowConfiguration struct {
+	WorkDays   []int    `json:"workdays"`   // days of the week, 0 is Sunday, Monday to Friday without a value
+	Holidays   []string `json:"holidays"`   // dates as 2006-01-02
+	AdminRoles []string `json:"adminroles"` // roles that manage every workflow entity, Administrator without a value
+}
+
+// PythonWorkersConfiguration holds the configuration of the Python interpreters running the Python functions
+type PythonWorkersConfiguration struct {
+	PythonPath     string   `json:"pythonpath"`
+	PoolSize       int      `json:"poolsize"`
+	MaxCalls       int      `json:"maxcalls"`
+	AllowedModules []string `json:"allowedmodules"`
 }
 
 // JobsConfiguration holds the configuration for the background job system
@@ -63,4 +80,6 @@ type JobsConfiguration struct {
 	UseRedis                bool `json:"use_redis"`
 	JobHistoryRetentionDays int  `json:"job_history_retention_days"`
 	EnableMetrics           bool `json:"enable_metrics"`
+	OutboxPollInterval      int  `json:"outbox_poll_interval"`
+	WorkflowTimerInterval   int  `json:"workflow_timer_interval"`
 }
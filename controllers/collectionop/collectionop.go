@@ -17,6 +17,7 @@ import (
 	"github.com/mdaxf/iac/logger"
 
 	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/trancode"
 	"github.com/mdaxf/iac/services"
 
 	"github.com/mdaxf/iac/controllers/common"
@@ -640,6 +641,7 @@ func (c *CollectionController) UpdateCollectionData(ctx *gin.Context) {
 			return
 		}
 		id = insertResult.InsertedID.(primitive.ObjectID).Hex()
+		trancode.InvalidateTranCodeDocument(collectionName, list)
 		//	list["_id"] = id
 
 	} else if list != nil {
@@ -664,12 +666,16 @@ func (c *CollectionController) UpdateCollectionData(ctx *gin.Context) {
 
 		iLog.Debug(fmt.Sprintf("Update transaction code to respository with data: %s", logger.ConvertJson(idata)))
 
+		stored := storedTranCode(collectionName, id)
 		err = documents.DocDBCon.UpdateCollection(collectionName, filter, nil, list)
 		if err != nil {
 			iLog.Error(fmt.Sprintf("failed to update collection: %v", err))
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		// the update may rename the transaction code, the cache of the old and the new name is stale
+		trancode.InvalidateTranCodeDocument(collectionName, stored)
+		trancode.InvalidateTranCodeDocument(collectionName, list)
 	}
 	rdata := make(map[string]interface{})
 	rdata["id"] = id
@@ -757,12 +763,14 @@ func (c *CollectionController) DeleteCollectionDatabyID(ctx *gin.Context) {
 		return
 	}
 
+	stored := storedTranCode(collectionName, value)
 	err = documents.DocDBCon.DeleteItemFromCollection(collectionName, value)
 
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Delete item from collection error!"})
 		return
 	}
+	trancode.InvalidateTranCodeDocument(collectionName, stored)
 
 	rdata := make(map[string]interface{})
 	rdata["id"] = value
@@ -950,6 +958,7 @@ func (c *CollectionController) CollectionObjectRevision(ctx *gin.Context) {
 		return
 	}
 	id = insertResult.InsertedID.(primitive.ObjectID).Hex()
+	trancode.InvalidateTranCodeDocument(collectionname, tcitem)
 
 	tcitem["_id"] = id
 	result := map[string]interface{}{
@@ -961,6 +970,23 @@ func (c *CollectionController) CollectionObjectRevision(ctx *gin.Context) {
 
 }
 
+// storedTranCode returns the stored document with the id if the collection keeps the transaction codes, so a
+// write can drop the cached definitions of the transaction code it replaces. It is nil for other collections.
+func storedTranCode(collectionName string, id string) map[string]interface{} {
+	if collectionName != "Transaction_Code" {
+		return nil
+	}
+	objectid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+	items, err := documents.DocDBCon.QueryCollection(collectionName, bson.M{"_id": objectid}, nil)
+	if err != nil || len(items) == 0 {
+		return nil
+	}
+	return items[0]
+}
+
 // buildProjectionFromJSON parses the given JSON data into a Go map and builds a projection based on the map.
 // It takes the JSON data as a byte slice and the convert type as a string.
 // The function returns the built projection as a bson.M map and an error if any occurred during parsing or building.
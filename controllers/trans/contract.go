@@ -0,0 +1,53 @@
+package trans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mdaxf/iac/controllers/common"
+	"github.com/mdaxf/iac/engine/trancode"
+	"github.com/mdaxf/iac/logger"
+)
+
+// GetTranCodeSchema returns the JSON Schemas of the inputs and outputs of the transaction code in the request.
+// Without a version the schemas of the default version are returned.
+func (e *TranCodeController) GetTranCodeSchema(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeSchema"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetTranCodeSchema", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var tcdata TranCodeData
+	if err := ctx.BindJSON(&tcdata); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tcode, err := trancode.GetTranCodeDatabyVersion(tcdata.TranCode, tcdata.Version)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Get transaction code %s's error: %v", tcdata.TranCode, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": gin.H{
+		"trancodename": tcode.Name,
+		"version":      tcode.Version,
+		"inputs":       tcode.InputSchema(),
+		"outputs":      tcode.OutputSchema(),
+	}})
+}
@@ -0,0 +1,64 @@
+package trans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mdaxf/iac/controllers/common"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/trancode"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// ExecutionData is the request of the execution endpoints
+type ExecutionData struct {
+	ExecutionID   string `json:"executionid"`
+	CorrelationID string `json:"correlationid"`
+}
+
+// GetChildExecutions returns the sub transaction codes an execution ran outside of its transaction.
+// With a correlation id instead of an execution id it returns those of all executions of the correlation.
+func (e *TranCodeController) GetChildExecutions(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeExecution"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetChildExecutions", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data ExecutionData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var executions []types.ChildExecution
+	switch {
+	case data.ExecutionID != "":
+		executions, err = trancode.GetChildExecutions(data.ExecutionID, documents.DocDBCon)
+	case data.CorrelationID != "":
+		executions, err = trancode.GetCorrelatedExecutions(data.CorrelationID, documents.DocDBCon)
+	default:
+		err = fmt.Errorf("the request has neither an execution id nor a correlation id")
+	}
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the child executions of %s%s: %v", data.ExecutionID, data.CorrelationID, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": executions})
+}
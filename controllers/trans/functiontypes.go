@@ -0,0 +1,34 @@
+package trans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mdaxf/iac/controllers/common"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/logger"
+)
+
+// GetFunctionTypes returns the descriptors of the registered function types for the designer.
+func (e *TranCodeController) GetFunctionTypes(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeFunctionTypes"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetFunctionTypes", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": gin.H{"functiontypes": funcs.ListFunctionTypes()}})
+}
@@ -0,0 +1,97 @@
+package trans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mdaxf/iac/controllers/common"
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/logger"
+	"github.com/mdaxf/iac/services"
+)
+
+// OutboxData is the request of the outbox endpoints
+type OutboxData struct {
+	MessageID      string `json:"messageid"`
+	PendingSeconds int    `json:"pendingseconds"` // a pending message older than this is stuck, 300 without a value
+	Limit          int    `json:"limit"`
+}
+
+// GetStuckOutboxMessages returns the dead lettered outbox messages and the messages pending for too long
+func (e *TranCodeController) GetStuckOutboxMessages(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "Outbox"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetStuckOutboxMessages", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data OutboxData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if data.PendingSeconds <= 0 {
+		data.PendingSeconds = 300
+	}
+	if data.Limit <= 0 {
+		data.Limit = 100
+	}
+
+	pendingSince := time.Now().Add(-time.Duration(data.PendingSeconds) * time.Second)
+	messages, err := services.NewOutboxService(dbconn.DB).GetStuckOutboxMessages(ctx, pendingSince, data.Limit)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the stuck outbox messages: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": messages})
+}
+
+// RequeueOutboxMessage puts a dead lettered or stuck outbox message back for the relay
+func (e *TranCodeController) RequeueOutboxMessage(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "Outbox"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.RequeueOutboxMessage", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data OutboxData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	iLog.Info(fmt.Sprintf("Requeue the outbox message %s", data.MessageID))
+
+	if err := services.NewOutboxService(dbconn.DB).RequeueOutboxMessage(ctx, data.MessageID); err != nil {
+		iLog.Error(fmt.Sprintf("failed to requeue the outbox message %s: %v", data.MessageID, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": data})
+}
@@ -0,0 +1,211 @@
+package trans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mdaxf/iac/controllers/common"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/trancode"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// RecordingData is the request of the recording endpoints
+type RecordingData struct {
+	TranCode    string `json:"code"`
+	RecordingID string `json:"recordingid"`
+	SessionID   string `json:"sessionid"` // debug session of a replay
+	Name        string `json:"name"`      // name of the test data created from a recording
+}
+
+// GetTranCodeRecordingPolicy returns the recording policy of the transaction code in the request.
+// The Outputs are empty if the transaction code has no recording policy.
+func (e *TranCodeController) GetTranCodeRecordingPolicy(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRecording"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetTranCodeRecordingPolicy", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data RecordingData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := trancode.GetTranCodeRecordingPolicy(data.TranCode, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the recording policy of transaction code %s: %v", data.TranCode, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": policy})
+}
+
+// UpdateTranCodeRecordingPolicy inserts or replaces the recording policy of a transaction code
+func (e *TranCodeController) UpdateTranCodeRecordingPolicy(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRecording"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.UpdateTranCodeRecordingPolicy", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var policy types.TranCodeRecordingPolicy
+	if err := ctx.BindJSON(&policy); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy.ModifiedBy = userno
+	policy.ModifiedOn = time.Now().UTC()
+
+	iLog.Info(fmt.Sprintf("Update the recording policy of transaction code %s: %s", policy.TranCodeName, logger.ConvertJson(policy)))
+
+	if err := trancode.SaveTranCodeRecordingPolicy(&policy, documents.DocDBCon); err != nil {
+		iLog.Error(fmt.Sprintf("failed to update the recording policy of transaction code %s: %v", policy.TranCodeName, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": policy})
+}
+
+// GetTranCodeRecordings returns the recorded executions of the transaction code in the request
+func (e *TranCodeController) GetTranCodeRecordings(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRecording"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetTranCodeRecordings", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data RecordingData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordings, err := trancode.GetTranCodeRecordings(data.TranCode, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the recordings of transaction code %s: %v", data.TranCode, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": recordings})
+}
+
+// ReplayTranCodeRecording replays the recording in the request and returns the comparison with the recorded outcome
+func (e *TranCodeController) ReplayTranCodeRecording(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRecording"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.ReplayTranCodeRecording", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data RecordingData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recording, err := trancode.GetTranCodeRecording(data.RecordingID, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the recording %s: %v", data.RecordingID, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := trancode.ReplayTranCodeRecording(recording, data.SessionID, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to replay the recording %s: %v", data.RecordingID, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": result})
+}
+
+// AddRecordingAsTestData adds the recording in the request as test data to its transaction code
+func (e *TranCodeController) AddRecordingAsTestData(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRecording"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.AddRecordingAsTestData", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data RecordingData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recording, err := trancode.GetTranCodeRecording(data.RecordingID, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the recording %s: %v", data.RecordingID, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	testdata, err := trancode.AddRecordingAsTestData(recording, data.Name, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to add the recording %s as test data: %v", data.RecordingID, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": testdata})
+}
@@ -0,0 +1,134 @@
+package trans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mdaxf/iac/controllers/common"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/trancode"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// GetTranCodeRoutingPolicy returns the routing policy of the transaction code in the request.
+// The Outputs are empty if the transaction code has no routing policy.
+func (e *TranCodeController) GetTranCodeRoutingPolicy(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRouting"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetTranCodeRoutingPolicy", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var tcdata TranCodeData
+	if err := ctx.BindJSON(&tcdata); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := trancode.GetTranCodeRoutingPolicy(tcdata.TranCode, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the routing policy of transaction code %s: %v", tcdata.TranCode, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": policy})
+}
+
+// UpdateTranCodeRoutingPolicy inserts or replaces the routing policy of a transaction code.
+// Disabling the policy reverts all executions without a pinned version to the default version.
+func (e *TranCodeController) UpdateTranCodeRoutingPolicy(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRouting"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.UpdateTranCodeRoutingPolicy", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var policy types.TranCodeRoutingPolicy
+	if err := ctx.BindJSON(&policy); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy.ModifiedBy = userno
+	policy.ModifiedOn = time.Now().UTC()
+
+	iLog.Info(fmt.Sprintf("Update the routing policy of transaction code %s: %s", policy.TranCodeName, logger.ConvertJson(policy)))
+
+	if err := trancode.SaveTranCodeRoutingPolicy(&policy, documents.DocDBCon); err != nil {
+		iLog.Error(fmt.Sprintf("failed to update the routing policy of transaction code %s: %v", policy.TranCodeName, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": policy})
+}
+
+// GetTranCodeVersionStats returns the executions, failures and latency per version of the transaction code in the request
+func (e *TranCodeController) GetTranCodeVersionStats(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCodeRouting"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetTranCodeVersionStats", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var tcdata TranCodeData
+	if err := ctx.BindJSON(&tcdata); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := trancode.GetTranCodeVersionStats(tcdata.TranCode, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the version statistics of transaction code %s: %v", tcdata.TranCode, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	outputs := make([]map[string]interface{}, 0, len(stats))
+	for i := range stats {
+		outputs = append(outputs, map[string]interface{}{
+			"version":         stats[i].Version,
+			"executions":      stats[i].Executions,
+			"failures":        stats[i].Failures,
+			"successrate":     stats[i].SuccessRate(),
+			"averageduration": stats[i].AverageDuration(),
+			"maxduration":     stats[i].MaxDuration,
+			"lastexecuted":    stats[i].LastExecuted,
+		})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": outputs})
+}
@@ -0,0 +1,127 @@
+package trans
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mdaxf/iac/controllers/common"
+	"github.com/mdaxf/iac/documents"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// ScriptLibraryData is the request of the script library endpoints
+type ScriptLibraryData struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// GetScriptLibraries returns all versions of the script library in the request, all libraries without a name
+func (e *TranCodeController) GetScriptLibraries(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "ScriptLibrary"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.GetScriptLibraries", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data ScriptLibraryData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	libraries, err := funcs.GetScriptLibraries(data.Name, documents.DocDBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to retrieve the script libraries %s: %v", data.Name, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": libraries})
+}
+
+// SaveScriptLibrary saves a new version of a script library
+func (e *TranCodeController) SaveScriptLibrary(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "ScriptLibrary"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.SaveScriptLibrary", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var library types.ScriptLibrary
+	if err := ctx.BindJSON(&library); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	library.CreatedBy = userno
+	library.CreatedOn = time.Now().UTC()
+
+	iLog.Info(fmt.Sprintf("Save the script library %s", library.Key()))
+
+	if err := funcs.SaveScriptLibrary(&library, documents.DocDBCon); err != nil {
+		iLog.Error(fmt.Sprintf("failed to save the script library %s: %v", library.Key(), err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": library})
+}
+
+// SetDefaultScriptLibrary makes the version in the request the default version of the script library
+func (e *TranCodeController) SetDefaultScriptLibrary(ctx *gin.Context) {
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "ScriptLibrary"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("controllers.trans.SetDefaultScriptLibrary", elapsed)
+	}()
+
+	_, userno, clientid, err := common.GetRequestUser(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("GetRequestUser error: %s", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err})
+		return
+	}
+	iLog.User = userno
+	iLog.ClientID = clientid
+
+	var data ScriptLibraryData
+	if err := ctx.BindJSON(&data); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	iLog.Info(fmt.Sprintf("Set the default version of script library %s to %s", data.Name, data.Version))
+
+	if err := funcs.SetDefaultScriptLibrary(data.Name, data.Version, documents.DocDBCon); err != nil {
+		iLog.Error(fmt.Sprintf("failed to set the default version of script library %s: %v", data.Name, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"Outputs": data})
+}
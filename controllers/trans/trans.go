@@ -67,35 +67,15 @@ func (e *TranCodeController) ExecuteTranCode(ctx *gin.Context) {
 	iLog.Info(fmt.Sprintf("Start process transaction code %s's %s: %s", tcdata.TranCode, "Execute", tcdata.Inputs))
 
 	//tcode, err := e.getTransCode(tcdata.TranCode)
-	filter := bson.M{"trancodename": tcdata.TranCode, "isdefault": true}
-
-	tcode, err := documents.DocDBCon.QueryCollection("Transaction_Code", filter, nil)
-
+	systemsessions := make(map[string]interface{})
+	systemsessions["UserNo"] = userno
+	systemsessions["ClientID"] = clientid
+	tf, err := trancode.NewRoutedTranFlow(tcdata.TranCode, tcdata.Version, tcdata.Inputs, systemsessions, documents.DocDBCon)
 	if err != nil {
 		iLog.Error(fmt.Sprintf("Get transaction code %s's error", tcdata.TranCode))
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	iLog.Debug(fmt.Sprintf("transaction code %s's data: %s", tcdata.TranCode, tcode))
-	jsonString, err := json.Marshal(tcode[0])
-	if err != nil {
-
-		iLog.Error(fmt.Sprintf("Error marshaling json:", err.Error()))
-		return
-	}
-
-	iLog.Debug(fmt.Sprintf("transaction code %s's json: %s", tcdata.TranCode, string(jsonString)))
-	/*trancode := types.TranCode{}
-	err = json.Unmarshal(jsonString, &trancode)
-	if err != nil {
-		iLog.Error(fmt.Sprintf("Error unmarshaling json:", err.Error()))
-		return
-	} */
-	code, err := trancode.Configtoobj(string(jsonString))
-	if err != nil {
-		iLog.Error(fmt.Sprintf("Error unmarshaling json:", err.Error()))
-		return
-	}
 
 	/*var inputs_json map[string]interface{}
 	err = json.Unmarshal([]byte(tcdata.inputs), &inputs_json)
@@ -105,10 +85,6 @@ func (e *TranCodeController) ExecuteTranCode(ctx *gin.Context) {
 	}
 	iLog.Debug(fmt.Sprintf("transaction code %s's json inputs: %s", tcdata.TranCode, inputs_json))
 	*/
-	systemsessions := make(map[string]interface{})
-	systemsessions["UserNo"] = userno
-	systemsessions["ClientID"] = clientid
-	tf := trancode.NewTranFlow(code, tcdata.Inputs, systemsessions, nil, nil, nil)
 	outputs, err := tf.Execute()
 
 	if err == nil {
@@ -117,6 +93,10 @@ func (e *TranCodeController) ExecuteTranCode(ctx *gin.Context) {
 		return
 	} else {
 		iLog.Error(fmt.Sprintf("End process transaction code %s's %s with error %s", tcdata.TranCode, "Execute", err.Error()))
+		if bpmErr, ok := err.(*types.BPMError); ok && bpmErr.Category == types.ErrorCategoryValidation {
+			ctx.JSON(http.StatusBadRequest, gin.H{"execution failed": err.Error(), "violations": bpmErr.Details})
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, gin.H{"execution failed": err.Error()})
 	}
 }
@@ -465,17 +445,16 @@ func (e *TranCodeController) Execute(Code string, externalinputs map[string]inte
 			return nil, err
 		}
 	*/
-	trancodeobj, err := trancode.GetTranCodeDatabyCode(Code)
+	systemsessions := make(map[string]interface{})
+	systemsessions["UserNo"] = user
+	systemsessions["ClientID"] = clientid
+	tf, err := trancode.NewRoutedTranFlow(Code, "", externalinputs, systemsessions, documents.DocDBCon)
 
 	if err != nil {
 		iLog.Error(fmt.Sprintf("Error unmarshaling json:", err.Error()))
 		return nil, err
 	}
-	iLog.Debug(fmt.Sprintf("transaction code %s's json: %s", Code, trancodeobj))
-	systemsessions := make(map[string]interface{})
-	systemsessions["UserNo"] = user
-	systemsessions["ClientID"] = clientid
-	tf := trancode.NewTranFlow(trancodeobj, externalinputs, systemsessions, nil, nil, nil)
+	iLog.Debug(fmt.Sprintf("transaction code %s's json: %s", Code, logger.ConvertJson(tf.Tcode)))
 	outputs, err := tf.Execute()
 
 	if err == nil {
@@ -717,6 +696,12 @@ func (e *TranCodeController) UpdateTranCodeToRespository(ctx *gin.Context) {
 		return
 	}
 
+	if _, err := trancode.Bytetoobj(jsonData); err != nil {
+		iLog.Error(fmt.Sprintf("invalid transaction code definition: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if name == "" && tData.TranCodeName != "" {
 		name = tData.TranCodeName
 	}
@@ -794,6 +779,8 @@ func (e *TranCodeController) UpdateTranCodeToRespository(ctx *gin.Context) {
 		}
 	}
 
+	trancode.InvalidateTranCode(name)
+
 	result := map[string]interface{}{
 		"id":     id,
 		"status": "Success",
@@ -954,6 +941,8 @@ func (e *TranCodeController) TranCodeRevision(ctx *gin.Context) {
 	}
 	id = insertResult.InsertedID.(primitive.ObjectID).Hex()
 
+	trancode.InvalidateTranCode(newname)
+
 	result := map[string]interface{}{
 		"id":     id,
 		"status": "Success",
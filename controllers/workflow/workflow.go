@@ -516,3 +516,297 @@ func (wf *WorkFlowController) GetPreTaskData(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"data": pretaskdata})
 
 }
+
+func (wf *WorkFlowController) CancelWorkFlow(ctx *gin.Context) {
+	wf.changeWorkFlowStatus(ctx, "CancelWorkFlow", workflow.CancelWorkFlow)
+}
+
+func (wf *WorkFlowController) SuspendWorkFlow(ctx *gin.Context) {
+	wf.changeWorkFlowStatus(ctx, "SuspendWorkFlow", workflow.SuspendWorkFlow)
+}
+
+func (wf *WorkFlowController) ResumeWorkFlow(ctx *gin.Context) {
+	wf.changeWorkFlowStatus(ctx, "ResumeWorkFlow", workflow.ResumeWorkFlow)
+}
+
+// changeWorkFlowStatus reads the workflow entity, reason code and comments of the request and applies the lifecycle operation
+func (wf *WorkFlowController) changeWorkFlowStatus(ctx *gin.Context, name string, operation func(int64, string, string, string) error) {
+
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "workflow"}
+
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("WorkFlowController.workflow."+name, elapsed)
+	}()
+
+	requestbody, clientid, user, err := common.GetRequestBodyandUserbyJson(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Get request information Error: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	iLog.ClientID = clientid
+	iLog.User = user
+	data, ok := requestbody["data"].(map[string]interface{})
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "data is required"})
+		return
+	}
+
+	entityid, ok := data["workflowentityid"].(float64)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "workflowentityid is required"})
+		return
+	}
+	reasoncode, _ := data["reasoncode"].(string)
+	comments, _ := data["comments"].(string)
+
+	err = operation(int64(entityid), reasoncode, comments, user)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to %s the workflow entity %d for the user %s with error: %v", name, int64(entityid), user, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": "OK"})
+}
+
+func (wf *WorkFlowController) RestartWorkFlowFromNode(ctx *gin.Context) {
+
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "workflow"}
+
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("WorkFlowController.workflow.RestartWorkFlowFromNode", elapsed)
+	}()
+
+	requestbody, clientid, user, err := common.GetRequestBodyandUserbyJson(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Get request information Error: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	iLog.ClientID = clientid
+	iLog.User = user
+	data, ok := requestbody["data"].(map[string]interface{})
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "data is required"})
+		return
+	}
+
+	entityid, ok := data["workflowentityid"].(float64)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "workflowentityid is required"})
+		return
+	}
+	nodeid, _ := data["nodeid"].(string)
+	reasoncode, _ := data["reasoncode"].(string)
+	comments, _ := data["comments"].(string)
+	processdata, _ := data["processdata"].(map[string]interface{})
+
+	err = workflow.RestartWorkFlowFromNode(int64(entityid), nodeid, processdata, reasoncode, comments, user)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to restart the workflow entity %d from node %s with error: %v", int64(entityid), nodeid, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": "OK"})
+}
+
+func (wf *WorkFlowController) ClaimTask(ctx *gin.Context) {
+	wf.changeTaskAssignment(ctx, "ClaimTask", func(wft *workflow.WorkFlowTask, version int64, data map[string]interface{}) error {
+		return wft.ClaimTask(version)
+	})
+}
+
+func (wf *WorkFlowController) UnclaimTask(ctx *gin.Context) {
+	wf.changeTaskAssignment(ctx, "UnclaimTask", func(wft *workflow.WorkFlowTask, version int64, data map[string]interface{}) error {
+		return wft.UnclaimTask(version)
+	})
+}
+
+func (wf *WorkFlowController) DelegateTask(ctx *gin.Context) {
+	wf.changeTaskAssignment(ctx, "DelegateTask", func(wft *workflow.WorkFlowTask, version int64, data map[string]interface{}) error {
+		touser, _ := data["touser"].(string)
+		comments, _ := data["comments"].(string)
+		return wft.DelegateTask(version, touser, comments)
+	})
+}
+
+func (wf *WorkFlowController) ApproveTask(ctx *gin.Context) {
+	wf.changeTaskAssignment(ctx, "ApproveTask", func(wft *workflow.WorkFlowTask, version int64, data map[string]interface{}) error {
+		approved, _ := data["approved"].(bool)
+		comments, _ := data["comments"].(string)
+		return wft.ApproveTask(approved, comments)
+	})
+}
+
+// changeTaskAssignment reads the task and the version of the request and applies the assignment operation
+func (wf *WorkFlowController) changeTaskAssignment(ctx *gin.Context, name string, operation func(wft *workflow.WorkFlowTask, version int64, data map[string]interface{}) error) {
+
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "workflow"}
+
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("WorkFlowController.workflow."+name, elapsed)
+	}()
+
+	requestbody, clientid, user, err := common.GetRequestBodyandUserbyJson(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Get request information Error: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	iLog.ClientID = clientid
+	iLog.User = user
+	data, ok := requestbody["data"].(map[string]interface{})
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "data is required"})
+		return
+	}
+
+	taskid, ok := data["taskid"].(float64)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "taskid is required"})
+		return
+	}
+	version, _ := data["version"].(float64)
+
+	wft := workflow.NewWorkFlowTaskType(int64(taskid), user)
+	err = operation(wft, int64(version), data)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to %s %d for the user %s with error: %v", name, int64(taskid), user, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": "OK"})
+}
+
+func (wf *WorkFlowController) GetSubstitutes(ctx *gin.Context) {
+
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "workflow"}
+
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("WorkFlowController.workflow.GetSubstitutes", elapsed)
+	}()
+
+	_, clientid, user, err := common.GetRequestBodyandUserbyJson(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Get request information Error: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	iLog.ClientID = clientid
+	iLog.User = user
+
+	substitutes, err := workflow.GetSubstitutes(user)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to get the substitutes of the user %s with error: %v", user, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": substitutes})
+}
+
+func (wf *WorkFlowController) AddSubstitute(ctx *gin.Context) {
+
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "workflow"}
+
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("WorkFlowController.workflow.AddSubstitute", elapsed)
+	}()
+
+	requestbody, clientid, user, err := common.GetRequestBodyandUserbyJson(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Get request information Error: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	iLog.ClientID = clientid
+	iLog.User = user
+	data, ok := requestbody["data"].(map[string]interface{})
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "data is required"})
+		return
+	}
+
+	substitute, _ := data["substitute"].(string)
+	startdate, err := parseSubstituteDate(data["startdate"])
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid startdate: %v", err)})
+		return
+	}
+	enddate, err := parseSubstituteDate(data["enddate"])
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid enddate: %v", err)})
+		return
+	}
+
+	id, err := workflow.AddSubstitute(substitute, startdate, enddate, user)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to add the substitute %s of the user %s with error: %v", substitute, user, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": id})
+}
+
+func (wf *WorkFlowController) RemoveSubstitute(ctx *gin.Context) {
+
+	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "workflow"}
+
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("WorkFlowController.workflow.RemoveSubstitute", elapsed)
+	}()
+
+	requestbody, clientid, user, err := common.GetRequestBodyandUserbyJson(ctx)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Get request information Error: %v", err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	iLog.ClientID = clientid
+	iLog.User = user
+	data, ok := requestbody["data"].(map[string]interface{})
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "data is required"})
+		return
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+
+	err = workflow.RemoveSubstitute(int64(id), user)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("failed to remove the substitute %d of the user %s with error: %v", int64(id), user, err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"data": "OK"})
+}
+
+// parseSubstituteDate parses a date of a substitute, a RFC 3339 time or a date
+func parseSubstituteDate(value interface{}) (time.Time, error) {
+	date, _ := value.(string)
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", date)
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -37,7 +38,7 @@ type DBOperation struct {
 // GetDialect returns the dialect for the current database type
 // This provides automatic database type support by using the factory pattern
 func (db *DBOperation) GetDialect() Dialect {
-	dialect, err := GetFactory().GetDialect(DBType(DatabaseType))
+	dialect, err := GetFactory().GetDialect(dialectType(DatabaseType))
 	if err != nil {
 		db.iLog.Error(fmt.Sprintf("Failed to get dialect for database type %s: %v", DatabaseType, err))
 		// Return MySQL dialect as default for backward compatibility
@@ -56,6 +57,66 @@ func (db *DBOperation) QuoteIdentifier(name string) string {
 	return db.GetDialect().QuoteIdentifier(name)
 }
 
+// dialectType maps the legacy DatabaseType driver names to the registered dialect types
+func dialectType(databaseType string) DBType {
+	switch strings.ToLower(databaseType) {
+	case "sqlserver", "mssql":
+		return DBTypeMSSQL
+	case "goracle", "godror", "oracle":
+		return DBTypeOracle
+	case "postgres", "postgresql", "pgx":
+		return DBTypePostgreSQL
+	default:
+		return DBType(strings.ToLower(databaseType))
+	}
+}
+
+// Savepoint creates a savepoint with the given name in the current transaction.
+// Savepoint names are restricted to letters, digits and underscores so they can be used unquoted on every database.
+func (db *DBOperation) Savepoint(name string) error {
+	return db.execSavepoint("Savepoint", name, db.GetDialect().SavepointSQL)
+}
+
+// RollbackToSavepoint undoes all work done in the current transaction since the savepoint was created.
+// The transaction itself stays open.
+func (db *DBOperation) RollbackToSavepoint(name string) error {
+	return db.execSavepoint("RollbackToSavepoint", name, db.GetDialect().RollbackToSavepointSQL)
+}
+
+// ReleaseSavepoint releases the savepoint if the database supports it.
+func (db *DBOperation) ReleaseSavepoint(name string) error {
+	return db.execSavepoint("ReleaseSavepoint", name, db.GetDialect().ReleaseSavepointSQL)
+}
+
+func (db *DBOperation) execSavepoint(operation string, name string, statement func(string) string) error {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		db.iLog.PerformanceWithDuration("dbconn."+operation, elapsed)
+	}()
+
+	if db.DBTx == nil {
+		return fmt.Errorf("%s requires an open transaction", operation)
+	}
+	if !savepointNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name: %s", name)
+	}
+
+	querystr := statement(name)
+	if querystr == "" {
+		return nil
+	}
+
+	db.iLog.Debug(fmt.Sprintf("%s: %s", operation, querystr))
+	if _, err := db.DBTx.Exec(querystr); err != nil {
+		db.iLog.Error(fmt.Sprintf("There is error to %s %s with error: %s", operation, name, err.Error()))
+		return err
+	}
+	return nil
+}
+
+var savepointNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,29}$`)
+
 // NewDBOperation creates a new instance of DBOperation.
 // It takes the following parameters:
 // - User: the name of the user performing the database operation.
@@ -188,6 +249,13 @@ func (db *DBOperation) QuerybyList(querystr string, namelist []string, inputs ma
 			paramValuePlaceholder = fmt.Sprintf("%f", inputs[namelist[i]])
 		case types.Bool:
 			paramValuePlaceholder = fmt.Sprintf("%t", inputs[namelist[i]])
+		case types.Decimal, types.Date, types.Time, types.Duration, types.Binary:
+			literal, err := db.extendedLiteral(inputs[namelist[i]], finputs[i].Datatype)
+			if err != nil {
+				db.iLog.Error(fmt.Sprintf("There is error to convert the query parameter %s with error: %s", namelist[i], err.Error()))
+				return nil, 0, 0, err
+			}
+			paramValuePlaceholder = literal
 		default:
 			paramValuePlaceholder = fmt.Sprintf("'%v'", inputs[namelist[i]])
 		}
@@ -536,11 +604,41 @@ func (db *DBOperation) chechoutputparameter(str string) (bool, string) {
 
 }
 
+// extendedLiteral formats a value of the decimal, date, time, duration and binary data types
+// for statements that inline their parameter values
+func (db *DBOperation) extendedLiteral(value interface{}, datatype types.DataType) (string, error) {
+	converted, err := types.ConvertValue(value, datatype)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := converted.(type) {
+	case nil:
+		return "NULL", nil
+	case types.DecimalValue:
+		return v.String(), nil
+	case types.DurationValue:
+		return fmt.Sprintf("%d", v.Milliseconds()), nil
+	case []byte:
+		return db.GetDialect().BinaryLiteral(v), nil
+	default:
+		return fmt.Sprintf("'%v'", v), nil
+	}
+}
+
 // TableInsert inserts data into a specified table in the database.
 // It takes the table name, column names, and corresponding values as input.
 // It returns the last insert ID and any error encountered during the operation.
-// The function measures the performance duration of the operation using the PerformanceWithDuration method of the db.iLog object.
 func (db *DBOperation) TableInsert(TableName string, Columns []string, Values []string) (int64, error) {
+	return db.TableInsertWithTypes(TableName, Columns, Values, nil)
+}
+
+// TableInsertWithTypes inserts data into a specified table in the database.
+// The values of the decimal, date, time, duration and binary data types are bound as typed parameters,
+// all other values are bound as strings. An empty value of these data types inserts NULL.
+// It returns the last insert ID and any error encountered during the operation.
+// The function measures the performance duration of the operation using the PerformanceWithDuration method of the db.iLog object.
+func (db *DBOperation) TableInsertWithTypes(TableName string, Columns []string, Values []string, datatypes []int) (int64, error) {
 	startTime := time.Now()
 	defer func() {
 		elapsed := time.Since(startTime)
@@ -584,6 +682,19 @@ func (db *DBOperation) TableInsert(TableName string, Columns []string, Values []
 
 	for i, s := range Values {
 		args[i] = s
+		if i >= len(datatypes) || !types.DataType(datatypes[i]).IsExtended() {
+			continue
+		}
+		if s == "" {
+			args[i] = nil
+			continue
+		}
+		value, err := types.SQLValue(s, types.DataType(datatypes[i]))
+		if err != nil {
+			db.iLog.Error(fmt.Sprintf("There is error to convert the value of column %s with error: %s", Columns[i], err.Error()))
+			return 0, err
+		}
+		args[i] = value
 	}
 
 	//	fmt.Println(querystr)
@@ -697,6 +808,14 @@ func (db *DBOperation) TableUpdate(TableName string, Columns []string, Values []
 			case int(types.Bool):
 				setPlaceholders[i] = fmt.Sprintf("%s = %t", column, Values[i])
 
+			case int(types.Decimal), int(types.Date), int(types.Time), int(types.Duration), int(types.Binary):
+				literal, err := db.extendedLiteral(Values[i], types.DataType(datatypes[i]))
+				if err != nil {
+					db.iLog.Error(fmt.Sprintf("There is error to convert the value of column %s with error: %s", column, err.Error()))
+					return 0, err
+				}
+				setPlaceholders[i] = fmt.Sprintf("%s = %s", column, literal)
+
 			default:
 				setPlaceholders[i] = fmt.Sprintf("%s = '%v'", column, Values[i])
 
@@ -794,6 +913,14 @@ func (db *DBOperation) TableUpdate(TableName string, Columns []string, Values []
 					// Parse boolean from string
 					trimmedVal := strings.TrimSpace(strings.ToLower(Values[i]))
 					argValue = trimmedVal == "true" || trimmedVal == "1" || trimmedVal == "t"
+				case int(types.Decimal), int(types.Date), int(types.Time), int(types.Duration), int(types.Binary):
+					// Bind the exact value, decimals must not be rounded through float64
+					if typedVal, err := types.SQLValue(Values[i], types.DataType(datatype)); err == nil {
+						argValue = typedVal
+					} else {
+						db.iLog.Warn(fmt.Sprintf("Failed to parse value for column %s, value: %s, using as string", column, Values[i]))
+						argValue = Values[i] // Fallback to string
+					}
 				default:
 					// String or other types - but check if it looks like a number that should be converted
 					trimmedVal := strings.TrimSpace(Values[i])
@@ -15,6 +15,7 @@
 package dbconn
 
 import (
+	"encoding/hex"
 	"fmt"
 	"strings"
 )
@@ -121,6 +122,22 @@ func (d *MySQLDialect) TranslateUpsert(table string, columns []string, conflictC
 	)
 }
 
+func (d *MySQLDialect) SavepointSQL(name string) string {
+	return fmt.Sprintf("SAVEPOINT %s", name)
+}
+
+func (d *MySQLDialect) RollbackToSavepointSQL(name string) string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", name)
+}
+
+func (d *MySQLDialect) ReleaseSavepointSQL(name string) string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", name)
+}
+
+func (d *MySQLDialect) BinaryLiteral(data []byte) string {
+	return fmt.Sprintf("X'%s'", hex.EncodeToString(data))
+}
+
 // DDL Generation Methods for MySQL
 
 func (d *MySQLDialect) CreateTableDDL(schema *TableSchema) string {
@@ -383,6 +400,22 @@ func (d *PostgreSQLDialect) TranslateUpsert(table string, columns []string, conf
 	)
 }
 
+func (d *PostgreSQLDialect) SavepointSQL(name string) string {
+	return fmt.Sprintf("SAVEPOINT %s", name)
+}
+
+func (d *PostgreSQLDialect) RollbackToSavepointSQL(name string) string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", name)
+}
+
+func (d *PostgreSQLDialect) ReleaseSavepointSQL(name string) string {
+	return fmt.Sprintf("RELEASE SAVEPOINT %s", name)
+}
+
+func (d *PostgreSQLDialect) BinaryLiteral(data []byte) string {
+	return fmt.Sprintf("'\\x%s'::bytea", hex.EncodeToString(data))
+}
+
 // DDL Generation Methods for PostgreSQL
 
 func (d *PostgreSQLDialect) CreateTableDDL(schema *TableSchema) string {
@@ -594,6 +627,23 @@ func (d *MSSQLDialect) TranslateUpsert(table string, columns []string, conflictC
 		d.QuoteIdentifier(table))
 }
 
+func (d *MSSQLDialect) SavepointSQL(name string) string {
+	return fmt.Sprintf("SAVE TRANSACTION %s", name)
+}
+
+func (d *MSSQLDialect) RollbackToSavepointSQL(name string) string {
+	return fmt.Sprintf("ROLLBACK TRANSACTION %s", name)
+}
+
+func (d *MSSQLDialect) ReleaseSavepointSQL(name string) string {
+	// SQL Server savepoints are released when the transaction ends
+	return ""
+}
+
+func (d *MSSQLDialect) BinaryLiteral(data []byte) string {
+	return fmt.Sprintf("0x%s", hex.EncodeToString(data))
+}
+
 // OracleDialect implements Oracle-specific SQL operations
 type OracleDialect struct{}
 
@@ -678,6 +728,23 @@ func (d *OracleDialect) TranslateUpsert(table string, columns []string, conflict
 	return fmt.Sprintf("MERGE INTO %s ...", d.QuoteIdentifier(table))
 }
 
+func (d *OracleDialect) SavepointSQL(name string) string {
+	return fmt.Sprintf("SAVEPOINT %s", name)
+}
+
+func (d *OracleDialect) RollbackToSavepointSQL(name string) string {
+	return fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", name)
+}
+
+func (d *OracleDialect) ReleaseSavepointSQL(name string) string {
+	// Oracle has no statement to release a savepoint
+	return ""
+}
+
+func (d *OracleDialect) BinaryLiteral(data []byte) string {
+	return fmt.Sprintf("HEXTORAW('%s')", hex.EncodeToString(data))
+}
+
 // DDL Generation Methods for MSSQL
 
 func (d *MSSQLDialect) CreateTableDDL(schema *TableSchema) string {
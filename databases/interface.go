@@ -83,6 +83,15 @@ type Dialect interface {
 	AlterColumnDDL(tableName string, column *ColumnInfo) string
 	CreateIndexDDL(tableName string, index *IndexInfo) string
 	DropIndexDDL(tableName, indexName string) string
+
+	// Savepoints - partial rollback inside a transaction
+	// ReleaseSavepointSQL returns an empty string if the database has no release statement
+	SavepointSQL(name string) string
+	RollbackToSavepointSQL(name string) string
+	ReleaseSavepointSQL(name string) string
+
+	// BinaryLiteral returns binary data as a literal for statements that inline their values
+	BinaryLiteral(data []byte) string
 }
 
 // DBConfig represents database connection configuration
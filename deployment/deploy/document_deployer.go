@@ -22,6 +22,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/mdaxf/iac/deployment/models"
 	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/trancode"
 	"github.com/mdaxf/iac/logger"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -88,6 +89,9 @@ func (dd *DocumentDeployer) Deploy(pkg *models.Package, options models.Deploymen
 		return record, nil
 	}
 
+	// the deployed transaction codes replace the cached definitions, also after a partial deployment
+	defer dd.invalidateTranCodes(pkg)
+
 	// Deploy each collection
 	for _, collData := range pkg.DocumentData.Collections {
 		dd.logger.Debug(fmt.Sprintf("Deploying collection: %s", collData.CollectionName))
@@ -136,6 +140,15 @@ func (dd *DocumentDeployer) Deploy(pkg *models.Package, options models.Deploymen
 	return record, nil
 }
 
+// invalidateTranCodes drops the cached definitions of the transaction codes of the package on all nodes
+func (dd *DocumentDeployer) invalidateTranCodes(pkg *models.Package) {
+	for _, collData := range pkg.DocumentData.Collections {
+		for _, doc := range collData.Documents {
+			trancode.InvalidateTranCodeDocument(collData.CollectionName, doc)
+		}
+	}
+}
+
 // deployCollection deploys a single collection
 func (dd *DocumentDeployer) deployCollection(collData models.CollectionData, idMapping models.IDMapping, options models.DeploymentOptions) error {
 	collection := dd.docDB.MongoDBDatabase.Collection(collData.CollectionName)
@@ -28,6 +28,9 @@ const (
 	EventTransactionBegin    EventType = "transaction.begin"
 	EventTransactionCommit   EventType = "transaction.commit"
 	EventTransactionRollback EventType = "transaction.rollback"
+	EventExecutionPaused     EventType = "debug.paused"
+	EventExecutionResumed    EventType = "debug.resumed"
+	EventVariableChanged     EventType = "debug.variable"
 )
 
 // DebugEvent represents a single debug event during execution
@@ -136,6 +136,7 @@ func (ds *DebugSession) GetSummary() map[string]interface{} {
 // DebugSessionManager manages multiple debug sessions
 type DebugSessionManager struct {
 	sessions   map[string]*DebugSession
+	debuggers  map[string]*Debugger
 	messageBus *MessageBus
 	mu         sync.RWMutex
 }
@@ -144,6 +145,7 @@ type DebugSessionManager struct {
 func NewDebugSessionManager(messageBus *MessageBus) *DebugSessionManager {
 	return &DebugSessionManager{
 		sessions:   make(map[string]*DebugSession),
+		debuggers:  make(map[string]*Debugger),
 		messageBus: messageBus,
 	}
 }
@@ -174,6 +176,48 @@ func (dsm *DebugSessionManager) RemoveSession(sessionID string) {
 	defer dsm.mu.Unlock()
 
 	delete(dsm.sessions, sessionID)
+	if _, exists := dsm.debuggers[sessionID]; exists {
+		delete(dsm.debuggers, sessionID)
+		dsm.messageBus.UnregisterCommandHandler(sessionID)
+	}
+}
+
+// EnableDebugger makes a debug session interactive. Executions of the session pause at the breakpoints
+// and are controlled with commands sent over the message bus. The session is created and started if it
+// does not exist yet.
+func (dsm *DebugSessionManager) EnableDebugger(sessionID, tranCodeName, userID string, breakpoints []Breakpoint, pauseTimeout time.Duration) *Debugger {
+	session, exists := dsm.GetSession(sessionID)
+	if !exists {
+		session = dsm.CreateSession(sessionID, tranCodeName, userID)
+	}
+	if !session.IsEnabled() {
+		session.Start()
+	}
+
+	dsm.mu.Lock()
+	debugger, exists := dsm.debuggers[sessionID]
+	if !exists {
+		debugger = NewDebugger(sessionID, pauseTimeout, dsm)
+		dsm.debuggers[sessionID] = debugger
+	}
+	dsm.mu.Unlock()
+
+	debugger.SetBreakpoints(breakpoints)
+	dsm.messageBus.RegisterCommandHandler(sessionID, debugger.HandleCommand)
+	return debugger
+}
+
+// GetDebugger returns the debugger of an interactive debug session, or nil
+func (dsm *DebugSessionManager) GetDebugger(sessionID string) *Debugger {
+	dsm.mu.RLock()
+	defer dsm.mu.RUnlock()
+
+	return dsm.debuggers[sessionID]
+}
+
+// SendCommand sends a control command to a debug session over the message bus
+func (dsm *DebugSessionManager) SendCommand(command *DebugCommand) error {
+	return dsm.messageBus.SendCommand(command)
 }
 
 // EmitEvent emits a debug event to the message bus and stores it in the session
@@ -0,0 +1,440 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// DEBUGGER DESIGN: a debugger is attached to a debug session and travels with the execution context,
+// so sub transaction codes executed by a SubTranCode function pause in the same session. The engine
+// calls Pausing before every function group and function, and Pause blocks the executing goroutine
+// until a control command arrives over the message bus. The database transaction stays open while
+// paused; a pause that gets no command within the pause timeout aborts the execution, which rolls
+// the transaction back. The whole execution, pauses included, is limited by the maximum execution time,
+// so stepping through it can not keep the transaction open without end.
+
+// Debugger control commands
+const (
+	CommandContinue       = "continue"
+	CommandStepOver       = "step_over"
+	CommandStepInto       = "step_into"
+	CommandSetVariable    = "set_variable"
+	CommandSetBreakpoints = "set_breakpoints"
+	CommandStop           = "stop"
+)
+
+// Variable scopes that can be edited while paused
+const (
+	ScopeSystemSession = "system"
+	ScopeUserSession   = "user"
+	ScopeFuncCache     = "cache"
+	ScopeInputs        = "inputs"
+)
+
+// DefaultPauseTimeout is the time a paused execution waits for a command before it is aborted
+const DefaultPauseTimeout = 5 * time.Minute
+
+// DefaultMaxExecution is the time a debugged execution may take with all its pauses before it is aborted
+const DefaultMaxExecution = time.Hour
+
+// StepMode tells the debugger where to pause next
+type StepMode int
+
+const (
+	StepRun      StepMode = iota // pause at breakpoints only
+	StepOverMode                 // pause at the next function group or function of the same or a calling transaction code
+	StepIntoMode                 // pause at the next function group or function, including sub transaction codes
+)
+
+// Breakpoint pauses the execution before a function group, or before a function of the group.
+// An empty TranCodeName matches all transaction codes.
+type Breakpoint struct {
+	TranCodeName string `json:"trancodename"`
+	FuncGroup    string `json:"funcgroup"`
+	Function     string `json:"function"`
+}
+
+// DebugCommand is a control command for the debugger of a session
+type DebugCommand struct {
+	SessionID   string       `json:"sessionID"`
+	Action      string       `json:"action"`
+	Scope       string       `json:"scope,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Value       interface{}  `json:"value,omitempty"`
+	Breakpoints []Breakpoint `json:"breakpoints,omitempty"`
+}
+
+// PausePoint is the position and state of an execution that is about to run a function group or function.
+// The session maps are the live maps of the execution, variables edited while paused are seen by the
+// remaining functions.
+type PausePoint struct {
+	TranCodeName        string
+	TranCodeVersion     string
+	FuncGroup           string
+	Function            string
+	FunctionType        string
+	SystemSession       map[string]interface{}
+	UserSession         map[string]interface{}
+	FuncCachedVariables map[string]interface{}
+	Inputs              map[string]interface{}
+	MappedInputs        map[string]interface{} // preview of the function inputs, not editable
+	Ctx                 context.Context        // context of the execution, the pause ends when it is done
+}
+
+// PausedState is the snapshot of a paused execution returned to debugger clients
+type PausedState struct {
+	SessionID           string                 `json:"sessionID"`
+	TranCodeName        string                 `json:"trancodename"`
+	TranCodeVersion     string                 `json:"trancodeversion"`
+	FuncGroup           string                 `json:"funcgroup"`
+	Function            string                 `json:"function,omitempty"`
+	FunctionType        string                 `json:"functiontype,omitempty"`
+	Depth               int                    `json:"depth"`
+	PausedAt            time.Time              `json:"pausedat"`
+	Deadline            time.Time              `json:"deadline"`
+	SystemSession       map[string]interface{} `json:"systemsession"`
+	UserSession         map[string]interface{} `json:"usersession"`
+	FuncCachedVariables map[string]interface{} `json:"funccachedvariables"`
+	Inputs              map[string]interface{} `json:"inputs"`
+	MappedInputs        map[string]interface{} `json:"mappedinputs,omitempty"`
+}
+
+// Debugger pauses the executions of a debug session at breakpoints and steps
+type Debugger struct {
+	SessionID    string
+	PauseTimeout time.Duration
+	MaxExecution time.Duration
+
+	manager     *DebugSessionManager
+	breakpoints []Breakpoint
+	mode        StepMode
+	stepDepth   int
+	depth       int
+	point       *PausePoint
+	state       *PausedState
+	commands    chan *DebugCommand
+	mu          sync.Mutex
+}
+
+// NewDebugger creates a debugger for the session. A pause timeout of 0 uses DefaultPauseTimeout.
+func NewDebugger(sessionID string, pauseTimeout time.Duration, manager *DebugSessionManager) *Debugger {
+	if pauseTimeout <= 0 {
+		pauseTimeout = DefaultPauseTimeout
+	}
+	return &Debugger{
+		SessionID:    sessionID,
+		PauseTimeout: pauseTimeout,
+		MaxExecution: DefaultMaxExecution,
+		manager:      manager,
+		breakpoints:  []Breakpoint{},
+		mode:         StepRun,
+		commands:     make(chan *DebugCommand),
+	}
+}
+
+type debuggerContextKey struct{}
+
+// WithDebugger returns a context carrying the debugger
+func WithDebugger(ctx context.Context, debugger *Debugger) context.Context {
+	return context.WithValue(ctx, debuggerContextKey{}, debugger)
+}
+
+// DebuggerFromContext returns the debugger of the context, or nil
+func DebuggerFromContext(ctx context.Context) *Debugger {
+	if ctx == nil {
+		return nil
+	}
+	debugger, _ := ctx.Value(debuggerContextKey{}).(*Debugger)
+	return debugger
+}
+
+// SetBreakpoints replaces the breakpoints of the debugger
+func (d *Debugger) SetBreakpoints(breakpoints []Breakpoint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = append([]Breakpoint{}, breakpoints...)
+}
+
+// GetBreakpoints returns the breakpoints of the debugger
+func (d *Debugger) GetBreakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]Breakpoint{}, d.breakpoints...)
+}
+
+// EnterTranCode is called when a transaction code starts executing under the debugger
+func (d *Debugger) EnterTranCode() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.depth++
+}
+
+// ExitTranCode is called when a transaction code executing under the debugger ends
+func (d *Debugger) ExitTranCode() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.depth--
+	// stepping over the last function of a sub transaction code continues in the caller
+	if d.mode == StepOverMode && d.stepDepth > d.depth {
+		d.stepDepth = d.depth
+	}
+}
+
+// Pausing reports whether the execution pauses before the function group or function.
+// An empty function name is the start of the function group.
+func (d *Debugger) Pausing(tranCodeName, funcGroup, function string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.mode {
+	case StepIntoMode:
+		return true
+	case StepOverMode:
+		if d.depth <= d.stepDepth {
+			return true
+		}
+	}
+
+	for _, bp := range d.breakpoints {
+		if (bp.TranCodeName == "" || bp.TranCodeName == tranCodeName) && bp.FuncGroup == funcGroup && bp.Function == function {
+			return true
+		}
+	}
+	return false
+}
+
+// Pause blocks until a continue, step or stop command arrives. Set variable commands are applied
+// to the maps of the pause point while paused. It returns an error if the execution has to be aborted,
+// because of a stop command or because the pause timed out.
+func (d *Debugger) Pause(point *PausePoint) error {
+	d.mu.Lock()
+	pausedAt := time.Now()
+	d.point = point
+	d.state = &PausedState{
+		SessionID:           d.SessionID,
+		TranCodeName:        point.TranCodeName,
+		TranCodeVersion:     point.TranCodeVersion,
+		FuncGroup:           point.FuncGroup,
+		Function:            point.Function,
+		FunctionType:        point.FunctionType,
+		Depth:               d.depth,
+		PausedAt:            pausedAt,
+		Deadline:            pausedAt.Add(d.PauseTimeout),
+		SystemSession:       point.SystemSession,
+		UserSession:         point.UserSession,
+		FuncCachedVariables: point.FuncCachedVariables,
+		Inputs:              point.Inputs,
+		MappedInputs:        point.MappedInputs,
+	}
+	depth := d.depth
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		d.point = nil
+		d.state = nil
+		d.mu.Unlock()
+	}()
+
+	d.emit(d.pointEvent(EventExecutionPaused, point).
+		WithInputs(point.Inputs).
+		WithMessage(fmt.Sprintf("Execution paused before %s", describePoint(point))).
+		WithMetadata("depth", depth).
+		WithMetadata("system_session", sanitizeData(point.SystemSession)).
+		WithMetadata("user_session", sanitizeData(point.UserSession)).
+		WithMetadata("func_cached_variables", sanitizeData(point.FuncCachedVariables)).
+		WithMetadata("mapped_inputs", sanitizeData(point.MappedInputs)))
+
+	timer := time.NewTimer(d.PauseTimeout)
+	defer timer.Stop()
+
+	var done <-chan struct{}
+	if point.Ctx != nil {
+		done = point.Ctx.Done()
+	}
+
+	for {
+		select {
+		case command := <-d.commands:
+			switch command.Action {
+			case CommandContinue, CommandStepOver, CommandStepInto:
+				d.mu.Lock()
+				switch command.Action {
+				case CommandContinue:
+					d.mode = StepRun
+				case CommandStepOver:
+					d.mode = StepOverMode
+					d.stepDepth = depth
+				case CommandStepInto:
+					d.mode = StepIntoMode
+				}
+				d.mu.Unlock()
+
+				d.emit(d.pointEvent(EventExecutionResumed, point).
+					WithMessage(fmt.Sprintf("Execution resumed with %s", command.Action)))
+				return nil
+
+			case CommandStop:
+				d.mu.Lock()
+				d.mode = StepRun
+				d.mu.Unlock()
+				return types.NewExecutionError(fmt.Sprintf("Execution stopped by debug session %s", d.SessionID), nil)
+			}
+
+		case <-timer.C:
+			d.mu.Lock()
+			d.mode = StepRun
+			d.mu.Unlock()
+
+			d.emit(d.pointEvent(EventExecutionResumed, point).
+				WithLevel("WARNING").
+				WithMessage(fmt.Sprintf("No debug command within %v, execution aborted", d.PauseTimeout)))
+			return types.NewTimeoutError(fmt.Sprintf("debug pause of session %s", d.SessionID), d.PauseTimeout)
+
+		case <-done:
+			d.mu.Lock()
+			d.mode = StepRun
+			d.mu.Unlock()
+
+			d.emit(d.pointEvent(EventExecutionResumed, point).
+				WithLevel("WARNING").
+				WithMessage("The execution ended while paused, execution aborted"))
+			return types.NewTimeoutError(fmt.Sprintf("debugged execution of session %s", d.SessionID), d.MaxExecution)
+		}
+	}
+}
+
+// HandleCommand processes a control command. Breakpoints can be set at any time, all other commands
+// need a paused execution.
+func (d *Debugger) HandleCommand(command *DebugCommand) error {
+	switch command.Action {
+	case CommandSetBreakpoints:
+		d.SetBreakpoints(command.Breakpoints)
+		return nil
+
+	case CommandSetVariable:
+		return d.setVariable(command.Scope, command.Name, command.Value)
+
+	case CommandContinue, CommandStepOver, CommandStepInto, CommandStop:
+		if !d.IsPaused() {
+			return fmt.Errorf("debug session %s has no paused execution", d.SessionID)
+		}
+		select {
+		case d.commands <- command:
+			return nil
+		case <-time.After(time.Second):
+			return fmt.Errorf("debug session %s has no paused execution", d.SessionID)
+		}
+	}
+	return fmt.Errorf("unknown debug command %s", command.Action)
+}
+
+// IsPaused reports whether an execution of the session is paused
+func (d *Debugger) IsPaused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.point != nil
+}
+
+// GetPausedState returns the state of the paused execution, or nil if the session is running
+func (d *Debugger) GetPausedState() *PausedState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state == nil {
+		return nil
+	}
+	state := *d.state
+	state.SystemSession = sanitizeData(state.SystemSession)
+	state.UserSession = sanitizeData(state.UserSession)
+	state.FuncCachedVariables = sanitizeData(state.FuncCachedVariables)
+	state.Inputs = sanitizeData(state.Inputs)
+	state.MappedInputs = sanitizeData(state.MappedInputs)
+	return &state
+}
+
+// setVariable changes a variable of the paused execution. Names are dot separated paths,
+// "FuncA.qty" in the cache scope is the output qty of function FuncA.
+func (d *Debugger) setVariable(scope, name string, value interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.point == nil {
+		return fmt.Errorf("debug session %s has no paused execution", d.SessionID)
+	}
+
+	var target map[string]interface{}
+	switch scope {
+	case ScopeSystemSession:
+		target = d.point.SystemSession
+	case ScopeUserSession:
+		target = d.point.UserSession
+	case ScopeFuncCache:
+		target = d.point.FuncCachedVariables
+	case ScopeInputs:
+		target = d.point.Inputs
+	default:
+		return fmt.Errorf("unknown variable scope %s", scope)
+	}
+	if target == nil {
+		return fmt.Errorf("variable scope %s is not available at %s", scope, describePoint(d.point))
+	}
+
+	if err := setPath(target, name, value); err != nil {
+		return err
+	}
+
+	go d.emit(d.pointEvent(EventVariableChanged, d.point).
+		WithMessage(fmt.Sprintf("Variable %s.%s changed", scope, name)).
+		WithMetadata("scope", scope).
+		WithMetadata("name", name))
+	return nil
+}
+
+// setPath sets the value at the dot separated path, creating missing objects on the way
+func setPath(target map[string]interface{}, path string, value interface{}) error {
+	if path == "" {
+		return fmt.Errorf("variable name is required")
+	}
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := target[part].(map[string]interface{})
+		if !ok {
+			if target[part] != nil {
+				return fmt.Errorf("variable %s is not an object", part)
+			}
+			next = map[string]interface{}{}
+			target[part] = next
+		}
+		target = next
+	}
+	target[parts[len(parts)-1]] = value
+	return nil
+}
+
+func (d *Debugger) pointEvent(eventType EventType, point *PausePoint) *DebugEvent {
+	return NewDebugEvent(d.SessionID, eventType).
+		WithTranCode(point.TranCodeName, point.TranCodeVersion).
+		WithFuncGroup(point.FuncGroup).
+		WithFunction(point.Function, point.FunctionType)
+}
+
+func (d *Debugger) emit(event *DebugEvent) {
+	if d.manager != nil {
+		d.manager.EmitEvent(event)
+	}
+}
+
+func describePoint(point *PausePoint) string {
+	if point.Function == "" {
+		return fmt.Sprintf("function group %s", point.FuncGroup)
+	}
+	return fmt.Sprintf("function %s of function group %s", point.Function, point.FuncGroup)
+}
@@ -0,0 +1,115 @@
+package debug
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitPaused(t *testing.T, d *Debugger) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !d.IsPaused() {
+		if time.Now().After(deadline) {
+			t.Fatal("execution did not pause")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDebugger_Pausing(t *testing.T) {
+	d := NewDebugger("s1", 0, nil)
+	d.SetBreakpoints([]Breakpoint{{FuncGroup: "FG1", Function: "F2"}, {TranCodeName: "Order", FuncGroup: "FG2"}})
+	d.EnterTranCode()
+
+	tests := []struct {
+		name                          string
+		tranCode, funcGroup, function string
+		want                          bool
+	}{
+		{"function breakpoint", "Any", "FG1", "F2", true},
+		{"other function", "Any", "FG1", "F1", false},
+		{"group breakpoint", "Order", "FG2", "", true},
+		{"group breakpoint of other trancode", "Invoice", "FG2", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Pausing(tt.tranCode, tt.funcGroup, tt.function); got != tt.want {
+				t.Errorf("Pausing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebugger_StepAndSetVariable(t *testing.T) {
+	d := NewDebugger("s1", time.Second, nil)
+	d.EnterTranCode()
+
+	userSession := map[string]interface{}{"qty": 1}
+	cache := map[string]interface{}{"F1": map[string]interface{}{"total": 10}}
+	done := make(chan error)
+	go func() {
+		done <- d.Pause(&PausePoint{FuncGroup: "FG1", Function: "F2", UserSession: userSession, FuncCachedVariables: cache})
+	}()
+	waitPaused(t, d)
+
+	if err := d.HandleCommand(&DebugCommand{Action: CommandSetVariable, Scope: ScopeUserSession, Name: "qty", Value: 5}); err != nil {
+		t.Fatalf("set_variable error = %v", err)
+	}
+	if err := d.HandleCommand(&DebugCommand{Action: CommandSetVariable, Scope: ScopeFuncCache, Name: "F1.total", Value: 20}); err != nil {
+		t.Fatalf("set_variable error = %v", err)
+	}
+	if state := d.GetPausedState(); state == nil || state.Function != "F2" || state.Depth != 1 {
+		t.Errorf("GetPausedState() = %+v", state)
+	}
+	if err := d.HandleCommand(&DebugCommand{Action: CommandStepOver}); err != nil {
+		t.Fatalf("step_over error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+
+	if userSession["qty"] != 5 || cache["F1"].(map[string]interface{})["total"] != 20 {
+		t.Errorf("variables not changed: %v %v", userSession, cache)
+	}
+
+	// stepping over does not stop in a sub transaction code, but at the next function of the caller
+	d.EnterTranCode()
+	if d.Pausing("Sub", "SFG1", "SF1") {
+		t.Errorf("step over paused inside the sub transaction code")
+	}
+	d.ExitTranCode()
+	if !d.Pausing("Order", "FG1", "F3") {
+		t.Errorf("step over did not pause at the next function")
+	}
+}
+
+func TestDebugger_PauseEndsWithExecution(t *testing.T) {
+	d := NewDebugger("s1", time.Minute, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := d.Pause(&PausePoint{FuncGroup: "FG1", Ctx: ctx}); err == nil {
+		t.Fatal("Pause() expected a timeout error at the deadline of the execution")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Pause() waited %v after the deadline of the execution", elapsed)
+	}
+	if d.IsPaused() {
+		t.Error("debugger still paused after the deadline of the execution")
+	}
+}
+
+func TestDebugger_PauseTimeout(t *testing.T) {
+	d := NewDebugger("s1", 10*time.Millisecond, nil)
+	if err := d.Pause(&PausePoint{FuncGroup: "FG1"}); err == nil {
+		t.Fatal("Pause() expected a timeout error")
+	}
+	if d.IsPaused() {
+		t.Error("debugger still paused after the timeout")
+	}
+	if err := d.HandleCommand(&DebugCommand{Action: CommandContinue}); err == nil {
+		t.Error("continue without a paused execution expected an error")
+	}
+}
@@ -2,27 +2,30 @@ package debug
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 )
 
 // MessageBus manages event distribution to multiple subscribers
 type MessageBus struct {
-	subscribers map[string][]*Subscriber
-	mu          sync.RWMutex
-	buffer      int
-	ctx         context.Context
-	cancel      context.CancelFunc
+	subscribers     map[string][]*Subscriber
+	commandHandlers map[string]CommandHandler
+	mu              sync.RWMutex
+	buffer          int
+	ctx             context.Context
+	cancel          context.CancelFunc
 }
 
 // NewMessageBus creates a new message bus
 func NewMessageBus(bufferSize int) *MessageBus {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &MessageBus{
-		subscribers: make(map[string][]*Subscriber),
-		buffer:      bufferSize,
-		ctx:         ctx,
-		cancel:      cancel,
+		subscribers:     make(map[string][]*Subscriber),
+		commandHandlers: make(map[string]CommandHandler),
+		buffer:          bufferSize,
+		ctx:             ctx,
+		cancel:          cancel,
 	}
 }
 
@@ -224,6 +227,37 @@ func meetsMinLevel(eventLevel, minLevel string) bool {
 	return eventLevelValue >= minLevelValue
 }
 
+// CommandHandler processes the control commands sent to a debug session
+type CommandHandler func(command *DebugCommand) error
+
+// RegisterCommandHandler registers the handler of the control commands of a session
+func (mb *MessageBus) RegisterCommandHandler(sessionID string, handler CommandHandler) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.commandHandlers[sessionID] = handler
+}
+
+// UnregisterCommandHandler removes the command handler of a session
+func (mb *MessageBus) UnregisterCommandHandler(sessionID string) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	delete(mb.commandHandlers, sessionID)
+}
+
+// SendCommand delivers a control command to the handler of its session
+func (mb *MessageBus) SendCommand(command *DebugCommand) error {
+	mb.mu.RLock()
+	handler := mb.commandHandlers[command.SessionID]
+	mb.mu.RUnlock()
+
+	if handler == nil {
+		return fmt.Errorf("debug session %s does not accept commands", command.SessionID)
+	}
+	return handler(command)
+}
+
 // GetSubscribers returns all subscribers for a session
 func (mb *MessageBus) GetSubscribers(sessionID string) []*Subscriber {
 	mb.mu.RLock()
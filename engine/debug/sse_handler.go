@@ -156,6 +156,11 @@ func (h *SSEHandler) StartDebugSession(w http.ResponseWriter, r *http.Request) {
 		TranCodeName string `json:"tranCodeName"`
 		UserID       string `json:"userID"`
 		Description  string `json:"description"`
+
+		// Interactive sessions pause at breakpoints and are controlled with debug commands
+		Interactive  bool         `json:"interactive"`
+		Breakpoints  []Breakpoint `json:"breakpoints"`
+		PauseTimeout int          `json:"pauseTimeout"` // seconds
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -177,8 +182,13 @@ func (h *SSEHandler) StartDebugSession(w http.ResponseWriter, r *http.Request) {
 
 	session.Start()
 
-	h.log.Info(fmt.Sprintf("Debug session started: %s (trancode: %s, user: %s)",
-		req.SessionID, req.TranCodeName, req.UserID))
+	if req.Interactive {
+		GetGlobalDebugSessionManager().EnableDebugger(req.SessionID, req.TranCodeName, req.UserID,
+			req.Breakpoints, time.Duration(req.PauseTimeout)*time.Second)
+	}
+
+	h.log.Info(fmt.Sprintf("Debug session started: %s (trancode: %s, user: %s, interactive: %t)",
+		req.SessionID, req.TranCodeName, req.UserID, req.Interactive))
 
 	// Return session info
 	w.Header().Set("Content-Type", "application/json")
@@ -215,6 +225,15 @@ func (h *SSEHandler) StopDebugSession(w http.ResponseWriter, r *http.Request) {
 
 	session.Stop()
 
+	// abort a paused execution, its transaction is rolled back
+	manager := GetGlobalDebugSessionManager()
+	if debugger := manager.GetDebugger(sessionID); debugger != nil {
+		if debugger.IsPaused() {
+			debugger.HandleCommand(&DebugCommand{SessionID: sessionID, Action: CommandStop})
+		}
+		manager.RemoveSession(sessionID)
+	}
+
 	h.log.Info(fmt.Sprintf("Debug session stopped: %s", sessionID))
 
 	// Return session summary
@@ -319,6 +338,72 @@ func (h *SSEHandler) RegisterRoutes(mux *http.ServeMux) {
 	})
 	mux.HandleFunc("/api/debug/sessions/stop", h.StopDebugSession)
 	mux.HandleFunc("/api/debug/sessions/trace", h.GetExecutionTrace)
+	mux.HandleFunc("/api/debug/sessions/command", h.SendDebugCommand)
+	mux.HandleFunc("/api/debug/sessions/paused", h.GetPausedState)
+}
+
+// SendDebugCommand sends a control command to an interactive debug session
+// URL: POST /api/debug/sessions/command
+// Body: {"sessionID": "...", "action": "continue|step_over|step_into|set_variable|set_breakpoints|stop", ...}
+func (h *SSEHandler) SendDebugCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var command DebugCommand
+	if err := json.NewDecoder(r.Body).Decode(&command); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if command.SessionID == "" {
+		http.Error(w, "sessionID is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := GetGlobalDebugSessionManager().SendCommand(&command); err != nil {
+		h.log.Warn(fmt.Sprintf("Debug command %s for session %s failed: %s", command.Action, command.SessionID, err.Error()))
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	h.log.Info(fmt.Sprintf("Debug command %s sent to session %s", command.Action, command.SessionID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessionID": command.SessionID,
+		"action":    command.Action,
+		"status":    "accepted",
+	})
+}
+
+// GetPausedState returns the position, sessions and inputs of the paused execution of a debug session
+// URL: GET /api/debug/sessions/paused?sessionID=...
+func (h *SSEHandler) GetPausedState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.URL.Query().Get("sessionID")
+	if sessionID == "" {
+		http.Error(w, "sessionID is required", http.StatusBadRequest)
+		return
+	}
+
+	debugger := GetGlobalDebugSessionManager().GetDebugger(sessionID)
+	if debugger == nil {
+		http.Error(w, "Session is not interactive", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessionID":   sessionID,
+		"paused":      debugger.IsPaused(),
+		"state":       debugger.GetPausedState(),
+		"breakpoints": debugger.GetBreakpoints(),
+	})
 }
 
 // CleanupSessions removes completed sessions older than the specified duration
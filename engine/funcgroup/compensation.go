@@ -0,0 +1,109 @@
+package funcgroup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mdaxf/iac-signalr/signalr"
+	"github.com/mdaxf/iac/com"
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// Compensate runs the compensating functions of the entries in the given order and returns the number of failures.
+// Each compensation runs in its own database transaction because the transaction of the original execution
+// is already rolled back. The compensation reads the mapped inputs of the original function as external inputs
+// and its outputs as the outputs of a function with the original function name.
+// The compensations get the values of the execution context ctx without its cancellation, as the failure they
+// compensate has usually cancelled it. A test run rolls back its transaction and mocks the external effects,
+// so nothing is compensated in a context with function mocks.
+func Compensate(ctx context.Context, entries []*types.CompensationEntry, DocDBCon *documents.DocDB, SignalRClient signalr.Client, systemSession map[string]interface{}) int {
+	log := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "Compensation"}
+	if userNo, ok := systemSession["UserNo"].(string); ok {
+		log.User = userNo
+	}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		log.PerformanceWithDuration("engine.funcgroup.Compensate", elapsed)
+	}()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if funcs.FunctionMocksFromContext(ctx) != nil {
+		log.Info(fmt.Sprintf("Skip %d compensations in the test run", len(entries)))
+		return 0
+	}
+
+	failed := 0
+	for _, entry := range entries {
+		log.Info(fmt.Sprintf("Start compensation %s of function %s in function group %s", entry.Compensation.Name, entry.FunctionName, entry.FunctionGroup))
+		if err := compensateEntry(ctx, entry, DocDBCon, SignalRClient, systemSession); err != nil {
+			log.Error(fmt.Sprintf("Compensation %s of function %s failed: %s", entry.Compensation.Name, entry.FunctionName, err.Error()))
+			entry.Fail(err)
+			failed++
+			continue
+		}
+		entry.Complete()
+		log.Info(fmt.Sprintf("End compensation %s of function %s", entry.Compensation.Name, entry.FunctionName))
+	}
+	return failed
+}
+
+// compensateEntry executes the compensating function of one entry and commits its own transaction on success
+func compensateEntry(ctx context.Context, entry *types.CompensationEntry, DocDBCon *documents.DocDB, SignalRClient signalr.Client, systemSession map[string]interface{}) (err error) {
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		return err
+	}
+	ctx, ctxcancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*time.Duration(com.TransactionTimeout))
+	defer ctxcancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			DBTx.Rollback()
+			if bpmErr, ok := r.(*types.BPMError); ok {
+				err = bpmErr
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	inputs := entry.Inputs
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	outputs := entry.Outputs
+	if outputs == nil {
+		outputs = map[string]interface{}{}
+	}
+
+	f := &funcs.Funcs{
+		Fobj:                entry.Compensation,
+		Ctx:                 ctx,
+		CtxCancel:           ctxcancel,
+		DBTx:                DBTx,
+		DocDBCon:            DocDBCon,
+		SignalRClient:       SignalRClient,
+		SystemSession:       systemSession,
+		UserSession:         map[string]interface{}{},
+		Externalinputs:      inputs,
+		Externaloutputs:     map[string]interface{}{},
+		FuncCachedVariables: map[string]interface{}{entry.FunctionName: outputs},
+		ErrorMessage:        "",
+		TestResults:         make([]map[string]interface{}, 0),
+	}
+	f.Execute()
+
+	if f.ErrorMessage != "" {
+		DBTx.Rollback()
+		return fmt.Errorf("%s", f.ErrorMessage)
+	}
+	return DBTx.Commit()
+}
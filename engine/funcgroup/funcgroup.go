@@ -5,18 +5,24 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"database/sql"
 
+	dbconn "github.com/mdaxf/iac/databases"
 	"github.com/mdaxf/iac/documents"
 	tcom "github.com/mdaxf/iac/engine/com"
+	"github.com/mdaxf/iac/engine/debug"
 	funcs "github.com/mdaxf/iac/engine/function"
 	"github.com/mdaxf/iac/engine/types"
 	"github.com/mdaxf/iac/logger"
 	"github.com/mdaxf/iac-signalr/signalr"
 )
 
+// savepointSeq makes savepoint names unique within a transaction, including nested sub transaction codes
+var savepointSeq uint64
+
 type FGroup struct {
 	FGobj               types.FuncGroup
 	DBTx                *sql.Tx
@@ -34,6 +40,8 @@ type FGroup struct {
 	ErrorMessage        string
 	TestwithSc          bool
 	TestResults         map[string]interface{}
+	Compensations       *types.CompensationLog // completed side-effecting functions of the transaction code execution
+	TranCodeName        string                 // transaction code of the group, matched against debugger breakpoints
 }
 
 // NewFGroup creates a new instance of FGroup.
@@ -158,6 +166,65 @@ func (c *FGroup) Execute() {
 		}
 	}()
 
+	// TRY DESIGN: a try function group runs inside a savepoint. This defer runs before the
+	// rollback defer above, so a failure only undoes the group's own work and routing continues
+	// with the error handler instead of rolling back the whole transaction.
+	tryScope := c.FGobj.Try && c.DBTx != nil
+	if tryScope {
+		savepoint := fmt.Sprintf("iac_fg_%d", atomic.AddUint64(&savepointSeq, 1))
+		dbop := dbconn.NewDBOperation(c.iLog.User, c.DBTx, logger.TranCode)
+		if err := dbop.Savepoint(savepoint); err != nil {
+			panic(types.NewDatabaseError("Savepoint", fmt.Sprintf("Failed to create savepoint for function group %s", c.FGobj.Name), err))
+		}
+		userSession := copySession(c.UserSession)
+		externaloutputs := copySession(c.Externaloutputs)
+		compensationMark := 0
+		if c.Compensations != nil {
+			compensationMark = c.Compensations.Len()
+		}
+
+		defer func() {
+			r := recover()
+			if r == nil {
+				if err := dbop.ReleaseSavepoint(savepoint); err != nil {
+					c.iLog.Warn(fmt.Sprintf("Failed to release savepoint %s for function group %s: %s", savepoint, c.FGobj.Name, err.Error()))
+				}
+				return
+			}
+
+			bpmErr, ok := r.(*types.BPMError)
+			if !ok {
+				bpmErr = types.NewExecutionError(fmt.Sprintf("Panic in function group %s: %v", c.FGobj.Name, r), nil)
+			}
+			if bpmErr.Context == nil {
+				bpmErr.Context = &types.ExecutionContext{}
+			}
+			bpmErr.Context.FunctionGroup = c.FGobj.Name
+
+			if err := dbop.RollbackToSavepoint(savepoint); err != nil {
+				c.iLog.Error(fmt.Sprintf("Failed to roll back to savepoint %s for function group %s: %s", savepoint, c.FGobj.Name, err.Error()))
+				panic(r)
+			}
+
+			c.iLog.Error(bpmErr.GetFormattedError())
+			c.iLog.Info(fmt.Sprintf("Function group %s rolled back to savepoint %s, continue with error handler %s", c.FGobj.Name, savepoint, c.FGobj.Errorhandler))
+
+			// the savepoint only undoes database work, side effects of the group are compensated right away
+			if c.Compensations != nil {
+				if failed := Compensate(c.Ctx, c.Compensations.Pending(compensationMark), c.DocDBCon, c.SignalRClient, c.SystemSession); failed > 0 {
+					c.iLog.Error(fmt.Sprintf("%d compensations failed for function group %s", failed, c.FGobj.Name))
+				}
+			}
+
+			userSession[types.ErrorSessionKey] = bpmErr.ToMap()
+			c.UserSession = userSession
+			c.Externaloutputs = externaloutputs
+			c.funcCachedVariables = map[string]interface{}{}
+			c.ErrorMessage = ""
+			c.Nextfuncgroup = c.FGobj.Errorhandler
+		}()
+	}
+
 	c.iLog.Info(fmt.Sprintf("Start process function group %s's %s ", c.FGobj.Name, reflect.ValueOf(c.Execute).Kind().String()))
 	c.iLog.Debug(fmt.Sprintf("systemSession: %s", logger.ConvertJson(c.SystemSession)))
 	c.iLog.Debug(fmt.Sprintf("userSession: %s", logger.ConvertJson(c.UserSession)))
@@ -185,7 +252,18 @@ func (c *FGroup) Execute() {
 			externalinputs, externaloutputs, systemSession, userSession, nil, c.SystemSession["ClientID"].(string), c.SystemSession["UserNo"].(string))
 	}
 
-	for _, fobj := range c.FGobj.Functions {
+	functions := c.FGobj.Functions
+	if c.FGobj.IsParallel() {
+		var completed bool
+		userSession, externaloutputs, funcCachedVariables, completed = c.executeParallel(systemSession, userSession, externalinputs, externaloutputs, funcCachedVariables, tryScope)
+		if !completed {
+			return
+		}
+		// the functions already ran, skip the sequential loop
+		functions = nil
+	}
+
+	for _, fobj := range functions {
 		//	f := *(funcs.NewFuncs(fobj, systemSession, userSession, externalinputs, externaloutputs, funcCachedVariables))
 		c.iLog.Info(fmt.Sprintf("Start process function %s", fobj.Name))
 		c.iLog.Debug(fmt.Sprintf("systemSession: %s", logger.ConvertJson(systemSession)))
@@ -194,6 +272,8 @@ func (c *FGroup) Execute() {
 		c.iLog.Debug(fmt.Sprintf("externalinputs: %s", logger.ConvertJson(externalinputs)))
 		c.iLog.Debug(fmt.Sprintf("externaloutputs: %s", logger.ConvertJson(externaloutputs)))
 
+		c.debugPause(fobj, systemSession, userSession, externalinputs, funcCachedVariables)
+
 		f := &funcs.Funcs{
 			Fobj:                fobj,
 			Ctx:                 c.Ctx,
@@ -209,6 +289,7 @@ func (c *FGroup) Execute() {
 			ErrorMessage:        "",
 			TestwithSc:          c.TestwithSc,
 			TestResults:         make([]map[string]interface{}, 0),
+			TryScope:            tryScope,
 		}
 
 		f.Execute()
@@ -229,10 +310,19 @@ func (c *FGroup) Execute() {
 		if f.ErrorMessage != "" {
 			c.ErrorMessage = f.ErrorMessage
 			c.iLog.Error(fmt.Sprintf("Error: %s", c.ErrorMessage))
+			if tryScope {
+				panic(types.NewExecutionError(f.ErrorMessage, nil).
+					WithContext(&types.ExecutionContext{FunctionGroup: c.FGobj.Name, FunctionName: fobj.Name, FunctionType: fobj.TypeName()}))
+			}
 			c.CtxCancel()
 			return
 		}
 
+		if c.Compensations != nil {
+			outputs, _ := f.FuncCachedVariables[fobj.Name].(map[string]interface{})
+			c.Compensations.Record(c.FGobj.Name, fobj, f.FunctionMappedInputs, outputs)
+		}
+
 		c.iLog.Info(fmt.Sprintf("End process function %s", fobj.Name))
 		//c.iLog.Debug(fmt.Sprintf("systemSession: %s", logger.ConvertJson(systemSession)))
 		c.iLog.Debug(fmt.Sprintf("funcCachedVariables: %s", logger.ConvertJson(funcCachedVariables)))
@@ -269,6 +359,37 @@ func (c *FGroup) Execute() {
 
 }
 
+// debugPause pauses before the function if the execution runs under a debugger that stops there.
+// Functions of parallel groups run concurrently and are not paused, only their group is.
+func (c *FGroup) debugPause(fobj types.Function, systemSession, userSession, externalinputs, funcCachedVariables map[string]interface{}) {
+	debugger := debug.DebuggerFromContext(c.Ctx)
+	if debugger == nil || !debugger.Pausing(c.TranCodeName, c.FGobj.Name, fobj.Name) {
+		return
+	}
+
+	// preview of the inputs the function will receive, a mapping error is shown instead of failing here
+	mapper := funcs.NewInputMapper(systemSession, userSession, externalinputs, funcCachedVariables, c.iLog)
+	mappedInputs, _, _, err := mapper.MapAllInputs(fobj.Inputs)
+	if err != nil {
+		mappedInputs = map[string]interface{}{"error": err.Error()}
+	}
+
+	if pauseErr := debugger.Pause(&debug.PausePoint{
+		TranCodeName:        c.TranCodeName,
+		FuncGroup:           c.FGobj.Name,
+		Function:            fobj.Name,
+		FunctionType:        fobj.TypeName(),
+		SystemSession:       systemSession,
+		UserSession:         userSession,
+		FuncCachedVariables: funcCachedVariables,
+		Inputs:              externalinputs,
+		MappedInputs:        mappedInputs,
+		Ctx:                 c.Ctx,
+	}); pauseErr != nil {
+		panic(pauseErr)
+	}
+}
+
 // CheckRouter checks the router definition and determines the next function group to execute based on the provided RouterDef.
 // It returns the name of the next function group.
 func (c *FGroup) CheckRouter(RouterDef types.RouterDef) string {
@@ -281,6 +402,16 @@ func (c *FGroup) CheckRouter(RouterDef types.RouterDef) string {
 	c.iLog.Info(fmt.Sprintf("Start process function group %s's %s ", c.FGobj.Name, reflect.ValueOf(c.CheckRouter).Kind().String()))
 	c.iLog.Debug(fmt.Sprintf("RouterDef: %s", logger.ConvertJson(RouterDef)))
 
+	if RouterDef.IsExpression() {
+		nextfuncgroup, err := RouterDef.Evaluate(c.routerEnv())
+		if err != nil {
+			panic(types.NewValidationError(fmt.Sprintf("Router of function group %s failed", c.FGobj.Name), err).
+				WithDetail("expressions", strings.Join(RouterDef.Expressions, "; ")))
+		}
+		c.iLog.Info(fmt.Sprintf("End process function group %s's %s 's Next func group: %s", c.FGobj.Name, reflect.ValueOf(c.CheckRouter).Kind().String(), nextfuncgroup))
+		return nextfuncgroup
+	}
+
 	variable := RouterDef.Variable
 	vartype := RouterDef.Vartype
 	values := RouterDef.Values
@@ -344,3 +475,29 @@ func (c *FGroup) CheckRouter(RouterDef types.RouterDef) string {
 
 	return defaultfuncgroup
 }
+
+// routerEnv builds the variables visible to router expressions.
+// Function outputs are addressed by function name (GetOrder.qty), user session variables by their bare name,
+// and the scopes are also available explicitly as system, session and funcs.
+func (c *FGroup) routerEnv() map[string]interface{} {
+	env := make(map[string]interface{}, len(c.UserSession)+len(c.funcCachedVariables)+3)
+	for key, value := range c.UserSession {
+		env[key] = value
+	}
+	for key, value := range c.funcCachedVariables {
+		env[key] = value
+	}
+	env["system"] = c.SystemSession
+	env["session"] = c.UserSession
+	env["funcs"] = c.funcCachedVariables
+	return env
+}
+
+// copySession returns a shallow copy of a session map
+func copySession(session map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(session))
+	for key, value := range session {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,219 @@
+package funcgroup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/com"
+	tcom "github.com/mdaxf/iac/engine/com"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// parallelExecutor runs the functions of a parallel function group for the execution graph.
+// Every function works on its own copy of the sessions, built from the state at the start of the group
+// and the results of the functions of earlier levels merged in declaration order, so the result does not
+// depend on which function finishes first.
+type parallelExecutor struct {
+	c                   *FGroup
+	tryScope            bool
+	systemSession       map[string]interface{}
+	userSession         map[string]interface{}
+	externalinputs      map[string]interface{}
+	externaloutputs     map[string]interface{}
+	funcCachedVariables map[string]interface{}
+	level               map[string]int
+	results             map[string]*funcs.Funcs
+	failures            map[string]interface{}
+	mu                  sync.Mutex
+	txMu                sync.Mutex // functions sharing the database transaction run one at a time
+}
+
+// Execute implements funcs.FunctionExecutor
+func (p *parallelExecutor) Execute(ctx context.Context, fn *types.Function, inputs map[string]interface{}) (outputs map[string]interface{}, err error) {
+	userSession, externaloutputs, funcCachedVariables := p.merge(func(name string) bool {
+		return p.level[name] < p.level[fn.Name]
+	})
+
+	f := &funcs.Funcs{
+		Fobj:                *fn,
+		Ctx:                 p.c.Ctx,
+		CtxCancel:           p.c.CtxCancel,
+		DBTx:                p.c.DBTx,
+		DocDBCon:            p.c.DocDBCon,
+		SignalRClient:       p.c.SignalRClient,
+		SystemSession:       copySession(p.systemSession),
+		UserSession:         userSession,
+		Externalinputs:      copySession(p.externalinputs),
+		Externaloutputs:     externaloutputs,
+		FuncCachedVariables: funcCachedVariables,
+		ErrorMessage:        "",
+		TestwithSc:          p.c.TestwithSc,
+		TestResults:         make([]map[string]interface{}, 0),
+		TryScope:            p.tryScope,
+	}
+
+	if funcs.SharesTransaction(fn) {
+		p.txMu.Lock()
+		defer p.txMu.Unlock()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.mu.Lock()
+			p.results[fn.Name] = f
+			p.failures[fn.Name] = r
+			p.mu.Unlock()
+			err = fmt.Errorf("function %s failed: %v", fn.Name, r)
+		}
+	}()
+
+	p.c.iLog.Info(fmt.Sprintf("Start process function %s in parallel", fn.Name))
+	f.Execute()
+
+	p.mu.Lock()
+	p.results[fn.Name] = f
+	p.mu.Unlock()
+
+	if f.ErrorMessage != "" {
+		return nil, fmt.Errorf("%s", f.ErrorMessage)
+	}
+	p.c.iLog.Info(fmt.Sprintf("End process function %s in parallel", fn.Name))
+	outputs, _ = f.FuncCachedVariables[fn.Name].(map[string]interface{})
+	return outputs, nil
+}
+
+// merge applies the outputs of the successful functions accepted by include to copies of the
+// group's starting state, in declaration order
+func (p *parallelExecutor) merge(include func(name string) bool) (map[string]interface{}, map[string]interface{}, map[string]interface{}) {
+	userSession := copySession(p.userSession)
+	externaloutputs := copySession(p.externaloutputs)
+	funcCachedVariables := copySession(p.funcCachedVariables)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, fobj := range p.c.FGobj.Functions {
+		f := p.results[fobj.Name]
+		if f == nil || p.failures[fobj.Name] != nil || f.ErrorMessage != "" || !include(fobj.Name) {
+			continue
+		}
+		outputs, ok := f.FuncCachedVariables[fobj.Name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, output := range fobj.Outputs {
+			if outputs[output.Name] == nil {
+				continue
+			}
+			for j, dest := range output.Outputdest {
+				if j >= len(output.Aliasname) {
+					continue
+				}
+				switch dest {
+				case types.Tosession:
+					userSession[output.Aliasname[j]] = outputs[output.Name]
+				case types.Toexternal:
+					externaloutputs[output.Aliasname[j]] = outputs[output.Name]
+				}
+			}
+		}
+		funcCachedVariables[fobj.Name] = outputs
+	}
+	return userSession, externaloutputs, funcCachedVariables
+}
+
+// executeParallel runs the functions of the group concurrently in the order of the dependencies inferred
+// from their inputs. It returns the merged user session, external outputs and function outputs, and false
+// if a function reported an error without panicking, like the sequential execution does.
+func (c *FGroup) executeParallel(systemSession, userSession, externalinputs, externaloutputs, funcCachedVariables map[string]interface{}, tryScope bool) (map[string]interface{}, map[string]interface{}, map[string]interface{}, bool) {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		c.iLog.PerformanceWithDuration("engine.funcgroup.executeParallel", elapsed)
+	}()
+
+	graph := funcs.NewExecutionGraph(len(c.FGobj.Functions), time.Second*time.Duration(com.TransactionTimeout), c.iLog)
+	dependencies := c.FGobj.FunctionDependencies(funcs.SharesTransaction)
+	for i := range c.FGobj.Functions {
+		name := c.FGobj.Functions[i].Name
+		if graph.Nodes[name] != nil {
+			panic(types.NewValidationError(fmt.Sprintf("Function group %s has more than one function named %s and cannot run in parallel", c.FGobj.Name, name), nil))
+		}
+		graph.AddFunction(&c.FGobj.Functions[i], dependencies[name])
+	}
+	if err := graph.BuildExecutionOrder(); err != nil {
+		if bpmErr, ok := err.(*types.BPMError); ok {
+			panic(bpmErr.WithDetail("functiongroup", c.FGobj.Name))
+		}
+		panic(err)
+	}
+
+	p := &parallelExecutor{
+		c:                   c,
+		tryScope:            tryScope,
+		systemSession:       systemSession,
+		userSession:         userSession,
+		externalinputs:      externalinputs,
+		externaloutputs:     externaloutputs,
+		funcCachedVariables: funcCachedVariables,
+		level:               map[string]int{},
+		results:             map[string]*funcs.Funcs{},
+		failures:            map[string]interface{}{},
+	}
+	for i, level := range graph.ExecutionOrder {
+		for _, name := range level {
+			p.level[name] = i
+		}
+	}
+	graph.SetFunctionExecutor(p)
+
+	ctx := c.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c.iLog.Info(fmt.Sprintf("Start process function group %s in parallel with %d levels", c.FGobj.Name, len(graph.ExecutionOrder)))
+	err := graph.ExecuteParallel(ctx)
+
+	// test results and compensations follow the declaration order, not the completion order
+	for _, fobj := range c.FGobj.Functions {
+		f := p.results[fobj.Name]
+		if f == nil {
+			continue
+		}
+		if c.TestwithSc {
+			c.TestResults["Functions"] = append(c.TestResults["Functions"].([]map[string]interface{}), f.TestResults...)
+			tcom.SendTestResultMessageBus("", c.FGobj.ID, fobj.ID, "End", "",
+				f.Externalinputs, f.Externaloutputs, f.SystemSession, f.UserSession, fmt.Errorf("%s", f.ErrorMessage), c.SystemSession["ClientID"].(string), c.SystemSession["UserNo"].(string))
+		}
+		if c.Compensations != nil && p.failures[fobj.Name] == nil && f.ErrorMessage == "" {
+			outputs, _ := f.FuncCachedVariables[fobj.Name].(map[string]interface{})
+			c.Compensations.Record(c.FGobj.Name, fobj, f.FunctionMappedInputs, outputs)
+		}
+	}
+
+	if err != nil {
+		// the first failed function in declaration order decides how the group fails
+		for _, fobj := range c.FGobj.Functions {
+			if r, failed := p.failures[fobj.Name]; failed {
+				panic(r)
+			}
+			if f := p.results[fobj.Name]; f != nil && f.ErrorMessage != "" {
+				c.ErrorMessage = f.ErrorMessage
+				c.iLog.Error(fmt.Sprintf("Error: %s", c.ErrorMessage))
+				if tryScope {
+					panic(types.NewExecutionError(f.ErrorMessage, nil).
+						WithContext(&types.ExecutionContext{FunctionGroup: c.FGobj.Name, FunctionName: fobj.Name, FunctionType: fobj.TypeName()}))
+				}
+				c.CtxCancel()
+				return userSession, externaloutputs, funcCachedVariables, false
+			}
+		}
+		panic(types.NewExecutionError(fmt.Sprintf("Parallel execution of function group %s failed", c.FGobj.Name), err))
+	}
+
+	userSession, externaloutputs, funcCachedVariables = p.merge(func(string) bool { return true })
+	c.iLog.Info(fmt.Sprintf("End process function group %s in parallel", c.FGobj.Name))
+	return userSession, externaloutputs, funcCachedVariables, true
+}
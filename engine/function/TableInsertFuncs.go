@@ -90,7 +90,7 @@ func (cf *TableInsertFuncs) Execute(f *Funcs) {
 	// Create SELECT clause with aliases
 	dboperation := dbconn.NewDBOperation(user, f.DBTx, "TableInsert Function")
 
-	output, err := dboperation.TableInsert(TableName, columnList, columnvalueList)
+	output, err := dboperation.TableInsertWithTypes(TableName, columnList, columnvalueList, columndatatypeList)
 	if err != nil {
 		f.iLog.Error(fmt.Sprintf("Error in TableInsert Execute: %s", err.Error()))
 		return
@@ -2,9 +2,11 @@ package funcs
 
 import (
 	//	"context"
-
+	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/mdaxf/iac/engine/types"
 )
 
 type SendMessagebyActiveMQ struct {
@@ -53,10 +55,23 @@ func (w *SendMessagebyActiveMQ) Execute(f *Funcs) {
 		return
 	}
 
-	// get activeMQ connection
+	if f.sendToOutbox(types.MessageChannelAMQP, Topic, activeMQCfg, data) {
+		return
+	}
 
-	// product the data
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		f.iLog.Error(fmt.Sprintf("Error:%v", err))
+		return
+	}
 
-	// send the data to activeMQ
+	message := &types.OutboundMessage{Channel: types.MessageChannelAMQP, Topic: Topic, Server: activeMQCfg, Payload: string(jsonData)}
+	if err := PublishMessage(f.Ctx, message); err != nil {
+		f.iLog.Error(fmt.Sprintf("Failed to publish the message to topic %s: %v", Topic, err))
+		f.ErrorMessage = fmt.Sprintf("Failed to publish the message to topic %s: %v", Topic, err)
+		return
+	}
 
+	outputs := make(map[string][]interface{})
+	f.SetOutputs(f.convertMap(outputs))
 }
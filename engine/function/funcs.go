@@ -38,6 +38,11 @@ type Funcs struct {
 	ErrorMessage         string
 	TestwithSc           bool
 	TestResults          []map[string]interface{}
+	TryScope             bool           // the function group handles failures with a savepoint, so the transaction must stay open
+	IterationIndexes     map[string]int // element index of each repeat list input in the running iteration
+	iterations           []map[string]int
+	iterationErrors      []interface{}
+	repeating            bool
 }
 
 // NewFuncs creates a new instance of the Funcs struct.
@@ -75,8 +80,10 @@ func NewFuncs(DocDBCon *documents.DocDB, SignalRClient signalr.Client, dbTx *sql
 
 	var newdata []map[string]interface{}
 
-	systemSession["UTCTime"] = time.Now().UTC()
-	systemSession["LocalTime"] = time.Now()
+	// recorded executions keep the current time of each function, so a replay sees the same time
+	now := ExecutionRecorderFromContext(ctx).Now(fobj.Name)
+	systemSession["UTCTime"] = now.UTC()
+	systemSession["LocalTime"] = now
 	if systemSession["UserNo"] == nil {
 		systemSession["UserNo"] = "System"
 	}
@@ -137,7 +144,7 @@ func (f *Funcs) HandleInputs() ([]string, []string, map[string]interface{}, erro
 			// Create structured error
 			execContext := &types.ExecutionContext{
 				FunctionName:  f.Fobj.Name,
-				FunctionType:  f.Fobj.Functype.String(),
+				FunctionType:  f.Fobj.TypeName(),
 				ExecutionTime: startTime,
 			}
 
@@ -499,7 +506,7 @@ func (f *Funcs) SetInputs() ([]string, []string, map[string]interface{}) {
 	}
 	inputs := f.Fobj.Inputs
 
-	if f.ExecutionNumber > 1 {
+	if f.IterationIndexes != nil {
 		f.iLog.Debug(fmt.Sprintf("function inputs: %s execution count: %d/%d", logger.ConvertJson(inputs), f.ExecutionCount, f.ExecutionNumber))
 
 		f.iLog.Debug(fmt.Sprintf("function mapped inputs: %s", logger.ConvertJson(f.FunctionMappedInputs)))
@@ -508,28 +515,25 @@ func (f *Funcs) SetInputs() ([]string, []string, map[string]interface{}) {
 			//	newinputs[inputs[i].Name] = inputs[i].Value
 			namelist[i] = inputs[i].Name
 			f.iLog.Debug(fmt.Sprintf("function input: %s, Source: %d", logger.ConvertJson(inputs[i]), inputs[i].Source))
-			if f.ExecutionNumber > 1 && inputs[i].Repeat && inputs[i].List {
+			if index, ok := f.IterationIndexes[inputs[i].Name]; ok {
 
-				//valuelist[i] = (f.FunctionMappedInputs[inputs[i].Name]).([]interface{})[f.ExecutionCount].(string)
-				if inputs[i].Datatype == types.DateTime {
-					temp := (f.FunctionMappedInputs[inputs[i].Name]).([]time.Time)[f.ExecutionCount]
+				element := reflect.ValueOf(f.FunctionMappedInputs[inputs[i].Name]).Index(index).Interface()
+				switch temp := element.(type) {
+				case time.Time:
 					newinputs[inputs[i].Name] = temp.Format("2006-01-02 15:04:05")
 					valuelist[i] = temp.Format("2006-01-02 15:04:05")
-				} else if inputs[i].Datatype == types.Integer {
-					temp := (f.FunctionMappedInputs[inputs[i].Name]).([]int)[f.ExecutionCount]
+				case int:
 					newinputs[inputs[i].Name] = strconv.Itoa(temp)
 					valuelist[i] = strconv.Itoa(temp)
-				} else if inputs[i].Datatype == types.Float {
-					temp := (f.FunctionMappedInputs[inputs[i].Name]).([]float64)[f.ExecutionCount]
+				case float64:
 					newinputs[inputs[i].Name] = strconv.FormatFloat(temp, 'f', -1, 64)
 					valuelist[i] = strconv.FormatFloat(temp, 'f', -1, 64)
-				} else if inputs[i].Datatype == types.Bool {
-					temp := (f.FunctionMappedInputs[inputs[i].Name]).([]bool)[f.ExecutionCount]
+				case bool:
 					newinputs[inputs[i].Name] = strconv.FormatBool(temp)
 					valuelist[i] = strconv.FormatBool(temp)
-				} else {
-					valuelist[i] = (f.FunctionMappedInputs[inputs[i].Name]).([]string)[f.ExecutionCount]
-					newinputs[inputs[i].Name] = (f.FunctionMappedInputs[inputs[i].Name]).([]string)[f.ExecutionCount]
+				default:
+					newinputs[inputs[i].Name] = element
+					valuelist[i] = fmt.Sprintf("%v", element)
 				}
 
 			} else {
@@ -558,10 +562,11 @@ func isArray(value interface{}) bool {
 }
 
 // checkifRepeatExecution checks if the function should be repeated execution based on the inputs.
+// The repeat list inputs are combined into iterations by the repeat mode of the function.
 // It returns the count of repetitions and an error if any.
 // Returns:
 // - The count of repetitions.
-// - An error if there was an error in the process.
+// - An error if the repeat lists do not fit the repeat mode.
 
 func (f *Funcs) checkifRepeatExecution() (int, error) {
 	/*	startTime := time.Now()
@@ -577,7 +582,6 @@ func (f *Funcs) checkifRepeatExecution() (int, error) {
 		}()
 	*/
 	inputs := f.Fobj.Inputs
-	lastcount := -2
 
 	_, _, newinputs, err := f.HandleInputs()
 
@@ -587,44 +591,34 @@ func (f *Funcs) checkifRepeatExecution() (int, error) {
 
 	f.Fobj.Inputs = inputs
 	f.iLog.Debug(fmt.Sprintf("parse inputs result: %s", logger.ConvertJson(newinputs)))
-	for _, input := range inputs {
 
-		f.iLog.Debug(fmt.Sprintf("checkifRepeatExecution input: %s", logger.ConvertJson(input)))
+	repeatInputs := []types.RepeatInput{}
+	f.repeating = false
+	f.iterations = nil
+	for _, input := range inputs {
+		if !input.Repeat {
+			continue
+		}
 
 		count := -1
-		if input.Repeat && input.List {
-			inputvalue := newinputs[input.Name]
-			if isArray(inputvalue) {
-				if input.Datatype == types.DateTime {
-					count = len(inputvalue.([]time.Time))
-				} else if input.Datatype == types.Integer {
-					count = len(inputvalue.([]int))
-				} else if input.Datatype == types.Float {
-					count = len(inputvalue.([]float64))
-				} else if input.Datatype == types.Bool {
-					count = len(inputvalue.([]bool))
-				} else {
-					count = len(inputvalue.([]string))
-				}
-
-			} else {
-				count = 1
-			}
-			f.iLog.Debug(fmt.Sprintf("checkifRepeatExecution input.Value: %s, count: %d, lastcount %d", input.Value, count, lastcount))
-			if lastcount == -2 {
-				lastcount = count
-			} else if lastcount != count {
-				return -1, nil
-			}
-
+		if input.List && isArray(newinputs[input.Name]) {
+			count = reflect.ValueOf(newinputs[input.Name]).Len()
+			f.repeating = true
 		}
+		f.iLog.Debug(fmt.Sprintf("checkifRepeatExecution input: %s, count: %d", input.Name, count))
+		repeatInputs = append(repeatInputs, types.RepeatInput{Name: input.Name, Length: count})
 	}
 
-	if lastcount > 0 {
-		return lastcount, nil
-	} else {
+	if !f.repeating {
 		return 1, nil
 	}
+
+	iterations, err := f.Fobj.RepeatIterations(repeatInputs)
+	if err != nil {
+		return -1, err
+	}
+	f.iterations = iterations
+	return len(iterations), nil
 }
 
 // checkinputvalue is a function that checks the input value for a given alias name and variables.
@@ -895,6 +889,11 @@ func (f *Funcs) SetfuncOutputs() {
 			}
 		}()
 	*/
+	if f.repeating {
+		f.SetfuncSingleOutputs(f.iterationOutputs())
+		return
+	}
+
 	newoutputs := make(map[string]interface{})
 	if f.ExecutionNumber > 1 {
 		for index, outputs := range f.FunctionOutputs {
@@ -907,7 +906,8 @@ func (f *Funcs) SetfuncOutputs() {
 		}
 
 		f.SetfuncSingleOutputs(newoutputs)
-	} else {
+	} else if len(f.FunctionOutputs) > 0 {
+		// a function that failed before its outputs has none, the error message fails the function group
 		f.SetfuncSingleOutputs(f.FunctionOutputs[0])
 	}
 
@@ -940,6 +940,16 @@ func (f *Funcs) SetfuncSingleOutputs(outputs map[string]interface{}) {
 			continue
 		}
 
+		// scripts return decimals, dates and durations as strings and numbers, store them as typed values
+		if f.Fobj.Outputs[i].Datatype.IsExtended() {
+			value, err := types.ConvertValues(outputs[f.Fobj.Outputs[i].Name], f.Fobj.Outputs[i].Datatype)
+			if err != nil {
+				f.iLog.Error(fmt.Sprintf("failed to convert output %s: %s", f.Fobj.Outputs[i].Name, err.Error()))
+			} else {
+				outputs[f.Fobj.Outputs[i].Name] = value
+			}
+		}
+
 		for j := 0; j < len(f.Fobj.Outputs[i].Outputdest); j++ {
 
 			switch f.Fobj.Outputs[i].Outputdest[j] {
@@ -1014,7 +1024,7 @@ func (f *Funcs) Execute() {
 					bpmErr.Context = &types.ExecutionContext{}
 				}
 				bpmErr.Context.FunctionName = f.Fobj.Name
-				bpmErr.Context.FunctionType = f.Fobj.Functype.String()
+				bpmErr.Context.FunctionType = f.Fobj.TypeName()
 
 				// Log the formatted error
 				f.iLog.Error(bpmErr.GetFormattedError())
@@ -1033,7 +1043,7 @@ func (f *Funcs) Execute() {
 				// Create a structured error for better tracking
 				execContext := &types.ExecutionContext{
 					FunctionName:  f.Fobj.Name,
-					FunctionType:  f.Fobj.Functype.String(),
+					FunctionType:  f.Fobj.TypeName(),
 					ExecutionTime: startTime,
 				}
 				if userNo, ok := f.SystemSession["UserNo"].(string); ok {
@@ -1064,12 +1074,7 @@ func (f *Funcs) Execute() {
 
 	if err != nil {
 		f.iLog.Error(fmt.Sprintf("Error in Execute: %s", err.Error()))
-	}
-	if number < 1 {
-		f.iLog.Debug(fmt.Sprintf("No repeat execution"))
-		outputs := make(map[string]interface{})
-		f.SetOutputs(outputs)
-		return
+		panic(types.NewValidationError(err.Error(), err))
 	}
 	f.iLog.Debug(fmt.Sprintf("Execute the function: %s, inputs: %s mapped inputs: %s", f.Fobj.Name, logger.ConvertJson(f.Fobj.Inputs), logger.ConvertJson(f.FunctionMappedInputs)))
 	f.iLog.Debug(fmt.Sprintf("Repeat execution: %d", number))
@@ -1077,133 +1082,52 @@ func (f *Funcs) Execute() {
 	f.ExecutionNumber = number
 	f.ExecutionCount = 0
 
+	if f.repeating {
+		f.executeIterations(startTime)
+		f.SetfuncOutputs()
+		return
+	}
+
 	for i := 0; i < f.ExecutionNumber; i++ {
 		f.ErrorMessage = ""
 		f.iLog.Debug(fmt.Sprintf("Execute the function: %s, execution count: %d / %d", f.Fobj.Name, i+1, f.ExecutionNumber))
-		switch f.Fobj.Functype {
-		case types.InputMap:
-			inputmapfuncs := InputMapFuncs{}
-			inputmapfuncs.Execute(f)
-
-		case types.GoExpr:
-			// Use enhanced Go expression executor with safety features
-			goexprfuncs := EnhancedGoExprFuncs{}
-			goexprfuncs.Execute(f)
-
-		case types.Javascript:
-			jsfuncs := JSFuncs{}
-			jsfuncs.Execute(f)
-
-		case types.PythonExpr:
-			// NEW: Python expression execution
-			pythonExprFuncs := PythonExprFuncs{}
-			pythonExprFuncs.Execute(f)
-
-		case types.PythonScript:
-			// NEW: Python script execution
-			pythonScriptFuncs := PythonScriptFuncs{}
-			pythonScriptFuncs.Execute(f)
-
-		case types.Query:
-			qfuncs := QueryFuncs{}
-			qfuncs.Execute(f)
-
-		case types.SubTranCode:
-			stcfuncs := SubTranCodeFuncs{}
-			//stcfuncs := NewSubTran()
-			stcfuncs.Execute(f)
-
-		case types.StoreProcedure:
-			spfuncs := StoreProcFuncs{}
-			spfuncs.Execute(f)
-
-		case types.TableInsert:
-			ti := TableInsertFuncs{}
-			ti.Execute(f)
-
-		case types.TableUpdate:
-			tu := TableUpdateFuncs{}
-			tu.Execute(f)
-
-		case types.TableDelete:
-			td := TableDeleteFuncs{}
-			td.Execute(f)
-
-		case types.CollectionInsert:
-			ci := CollectionInsertFuncs{}
-			ci.Execute(f)
-
-		case types.CollectionUpdate:
-			cu := CollectionUpdateFuncs{}
-			cu.Execute(f)
-
-		case types.CollectionDelete:
-			cd := CollectionDeleteFuncs{}
-			cd.Execute(f)
-
-		case types.ThrowError:
-			te := ThrowErrorFuncs{}
-			te.Execute(f)
-
-		case types.SendMessage:
-			sm := SendMessageFuncs{}
-			sm.Execute(f)
-
-		case types.SendEmail:
-			se := EmailFuncs{}
-			se.Execute(f)
-
-		case types.ExplodeWorkFlow:
-			wf := WorkFlowFunc{}
-			wf.Execute_Explode(f)
-
-		case types.StartWorkFlowTask:
-			wf := WorkFlowFunc{}
-			wf.Execute_StartTask(f)
-
-		case types.CompleteWorkFlowTask:
-			wf := WorkFlowFunc{}
-			wf.Execute_CompleteTask(f)
-
-		case types.SendMessagebyKafka:
-			sm := SendMessagebyKafka{}
-			sm.Execute(f)
-
-		case types.WebServiceCall:
-			ws := WebServiceCallFunc{}
-			ws.Execute(f)
-		}
+		f.executeFunctionType()
 
 		f.iLog.Debug(fmt.Sprintf("executed function %s with outputs: %s", f.Fobj.Name, logger.ConvertJson(f.FunctionOutputs)))
 
 		f.ExecutionCount = i + 1
 
 		if f.TestwithSc == true {
-			functioninputs := map[string]interface{}{}
-
-			for _, input := range f.Fobj.Inputs {
-				functioninputs[input.Name] = input.Value
-			}
-
-			TestResult := make(map[string]interface{})
-			TestResult["Name"] = f.Fobj.Name
-			TestResult["Type"] = "Function"
-			TestResult["FunctionType"] = f.Fobj.Functype
-			TestResult["Inputs"] = functioninputs
-			TestResult["Outputs"] = f.FunctionOutputs
-			TestResult["UserSession"] = f.UserSession
-			TestResult["SystemSession"] = f.SystemSession
-			TestResult["ExecutionCount"] = f.ExecutionCount
-			TestResult["ExecutionNumber"] = f.ExecutionNumber
-			TestResult["ExecutionTime"] = time.Since(startTime)
-			TestResult["Error"] = f.ErrorMessage
-			f.TestResults = append(f.TestResults, TestResult)
+			f.addTestResult(startTime)
 		}
 
 	}
 	f.SetfuncOutputs()
 }
 
+// addTestResult records the inputs and outputs of the executed function for the test of the transaction code
+func (f *Funcs) addTestResult(startTime time.Time) {
+	functioninputs := map[string]interface{}{}
+
+	for _, input := range f.Fobj.Inputs {
+		functioninputs[input.Name] = input.Value
+	}
+
+	TestResult := make(map[string]interface{})
+	TestResult["Name"] = f.Fobj.Name
+	TestResult["Type"] = "Function"
+	TestResult["FunctionType"] = f.Fobj.Functype
+	TestResult["Inputs"] = functioninputs
+	TestResult["Outputs"] = f.FunctionOutputs
+	TestResult["UserSession"] = f.UserSession
+	TestResult["SystemSession"] = f.SystemSession
+	TestResult["ExecutionCount"] = f.ExecutionCount
+	TestResult["ExecutionNumber"] = f.ExecutionNumber
+	TestResult["ExecutionTime"] = time.Since(startTime)
+	TestResult["Error"] = f.ErrorMessage
+	f.TestResults = append(f.TestResults, TestResult)
+}
+
 func (f *Funcs) Validate() (bool, error) {
 	startTime := time.Now()
 	defer func() {
@@ -1237,12 +1161,25 @@ func (f *Funcs) CancelExecution(errormessage string) {
 		}()
 	*/
 	f.iLog.Error(fmt.Sprintf("There is error during functoin %s execution: %s", f.Fobj.Name, errormessage))
-	f.DBTx.Rollback()
-	f.CtxCancel()
-	f.Ctx.Done()
+	f.abortTransaction()
 	return
 }
 
+// abortTransaction rolls back the database transaction and cancels the context.
+// Inside a try function group the transaction is kept open so the group can roll back to its savepoint.
+func (f *Funcs) abortTransaction() {
+	if f.TryScope {
+		f.iLog.Info(fmt.Sprintf("Function %s failed inside a try function group, the transaction is kept for the error handler", f.Fobj.Name))
+		return
+	}
+	if f.DBTx != nil {
+		f.DBTx.Rollback()
+	}
+	if f.CtxCancel != nil {
+		f.CtxCancel()
+	}
+}
+
 // convertMap converts a map[string][]interface{} to a map[string]interface{}.
 // It iterates over the input map and assigns the first value of each key's slice
 // to the corresponding key in the output map. If a key's slice has more than one
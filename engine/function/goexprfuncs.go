@@ -6,6 +6,7 @@ import (
 
 	"github.com/antonmedv/expr"
 	//	"reflect"
+	"github.com/mdaxf/iac/engine/types"
 	"github.com/mdaxf/iac/logger"
 )
 
@@ -30,7 +31,7 @@ func (cf *GoExprFuncs) Execute(f *Funcs) {
 
 	env := make(map[string]interface{}, 0)
 	for i := range namelist {
-		env[namelist[i]] = inputs[namelist[i]]
+		env[namelist[i]] = types.ExprValue(inputs[namelist[i]])
 	}
 
 	program, err := expr.Compile(f.Fobj.Content, expr.Env(env))
@@ -3,6 +3,7 @@ package funcs
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/antonmedv/expr"
@@ -15,6 +16,9 @@ import (
 type EnhancedGoExprExecutor struct {
 	Config *ScriptExecutionConfig
 	Log    logger.Log
+
+	// libraries loads the script libraries of require("name"), nil if the expression cannot require libraries
+	libraries *scriptRuntime
 }
 
 // NewEnhancedGoExprExecutor creates a new enhanced Go expression executor
@@ -49,10 +53,13 @@ func (e *EnhancedGoExprExecutor) Execute(
 		return nil, err
 	}
 
-	// Prepare environment
-	env := make(map[string]interface{})
-	for k, v := range inputs {
-		env[k] = v
+	// Prepare environment, the operators work on numbers, times and durations instead of the engine's value types
+	env := types.ExprValues(inputs)
+	if _, ok := env["decimal"]; !ok {
+		env["decimal"] = exprDecimal
+	}
+	if e.libraries != nil {
+		env["require"] = e.libraries.exprRequire
 	}
 
 	e.Log.Debug(fmt.Sprintf("Expression environment: %v", env))
@@ -88,6 +95,17 @@ func (e *EnhancedGoExprExecutor) Execute(
 	return outputMap, nil
 }
 
+// exprDecimal is the decimal(value) function of the expressions. Decimal inputs are float64 in expressions, for
+// exact results decimal converts a number or string to a decimal value to calculate with its methods,
+// e.g. decimal(price).Mul(decimal(quantity)).Round(2)
+func exprDecimal(value interface{}) (types.DecimalValue, error) {
+	decimal, err := types.ConvertValue(value, types.Decimal)
+	if err != nil {
+		return types.DecimalValue{}, err
+	}
+	return decimal.(types.DecimalValue), nil
+}
+
 // executeWithContext executes the compiled program with context support
 func (e *EnhancedGoExprExecutor) executeWithContext(
 	ctx context.Context,
@@ -128,6 +146,9 @@ func (e *EnhancedGoExprExecutor) executeWithContext(
 		).WithDetail("error_type", "execution_error")
 
 	case <-ctx.Done():
+		if e.libraries != nil {
+			e.libraries.vm.Interrupt(ctx.Err())
+		}
 		return nil, types.NewTimeoutError(
 			"Go expression execution",
 			0, // timeout value not available from context
@@ -141,8 +162,9 @@ func (e *EnhancedGoExprExecutor) Validate(script string) error {
 		return types.NewValidationError("Go expression is empty", nil)
 	}
 
-	// Try to compile with empty environment
-	_, err := expr.Compile(script, expr.Env(map[string]interface{}{}))
+	// Try to compile with an environment that only knows require
+	env := map[string]interface{}{"require": (&scriptRuntime{}).exprRequire}
+	_, err := expr.Compile(script, expr.Env(env))
 	if err != nil {
 		return types.NewValidationError(
 			"Invalid Go expression syntax",
@@ -206,6 +228,9 @@ func (cf *EnhancedGoExprFuncs) Execute(f *Funcs) {
 	}
 
 	executor := NewEnhancedGoExprExecutor(config, f.iLog)
+	if strings.Contains(f.Fobj.Content, "require(") {
+		executor.libraries = newScriptRuntime(scriptHostOf(f))
+	}
 
 	// Extract output names
 	outputNames := make([]string, len(f.Fobj.Outputs))
@@ -301,6 +326,9 @@ func (cf *EnhancedGoExprFuncs) Testfunction(
 	// Create executor
 	config := DefaultScriptConfig()
 	executor := NewEnhancedGoExprExecutor(config, iLog)
+	if strings.Contains(content, "require(") {
+		executor.libraries = newScriptRuntime(scriptHost{Name: "Test", Log: iLog})
+	}
 
 	// Execute with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
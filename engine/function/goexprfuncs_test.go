@@ -0,0 +1,51 @@
+package funcs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/logs"
+	"github.com/mdaxf/iac/logger"
+)
+
+func TestEnhancedGoExprExecutor_ExtendedValues(t *testing.T) {
+	price, _ := types.ParseDecimal("19.99")
+	quantity, _ := types.ParseDecimal("3")
+	inputs := map[string]interface{}{
+		"price":    price,
+		"quantity": quantity,
+		"due":      types.DateValue{Year: 2024, Month: time.March, Day: 1},
+		"shipped":  types.DateValue{Year: 2024, Month: time.March, Day: 4},
+		"sla":      types.DurationValue(48 * time.Hour),
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		want   interface{}
+	}{
+		{"decimal operators", "price > 10 && price * quantity > 50", true},
+		{"exact decimals", "decimal(price).Mul(decimal(quantity)).String()", "59.97"},
+		{"decimal of a string", `decimal("0.1").Add(decimal("0.2")).String()`, "0.3"},
+		{"date comparison", "shipped > due", true},
+		{"date difference against a duration", "shipped - due > sla", true},
+	}
+
+	if logger.TranCodeLogger == nil {
+		logger.TranCodeLogger = logs.NewLogger()
+	}
+	executor := NewEnhancedGoExprExecutor(nil, logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "GoExpression"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs, err := executor.Execute(context.Background(), tt.script, inputs, []string{"result"})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if outputs["result"] != tt.want {
+				t.Errorf("Execute() = %v (%T), want %v", outputs["result"], outputs["result"], tt.want)
+			}
+		})
+	}
+}
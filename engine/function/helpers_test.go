@@ -1,12 +1,52 @@
 package funcs
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/logs"
+	"github.com/mdaxf/iac/logger"
 )
 
+// newTestFuncs returns the function object without a database, sessions or cached variables
+func newTestFuncs(fobj types.Function) *Funcs {
+	if logger.TranCodeLogger == nil {
+		logger.TranCodeLogger = logs.NewLogger()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return NewFuncs(nil, nil, nil, fobj, map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{}, ctx, cancel)
+}
+
+// registerTestType registers a function type for the test, the type is removed when the test ends
+func registerTestType(t *testing.T, descriptor FunctionTypeDescriptor, execute func(f *Funcs)) {
+	t.Helper()
+	if err := RegisterFunctionType(descriptor, ExecutorFunc(execute), nil); err != nil {
+		t.Fatalf("failed to register the function type %s: %v", descriptor.Name, err)
+	}
+	t.Cleanup(func() {
+		functionTypesMu.Lock()
+		defer functionTypesMu.Unlock()
+		delete(functionTypes, descriptor.Name)
+	})
+}
+
+// executeError executes the function and returns the error it fails the function group with, nil when it succeeds
+func executeError(f *Funcs) (bpmErr *types.BPMError) {
+	defer func() {
+		if r := recover(); r != nil {
+			var ok bool
+			if bpmErr, ok = r.(*types.BPMError); !ok {
+				bpmErr = types.NewExecutionError(fmt.Sprint(r), nil)
+			}
+		}
+	}()
+	f.Execute()
+	return nil
+}
+
 // TestTypeConverter_ConvertToInt demonstrates table-driven testing
 func TestTypeConverter_ConvertToInt(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,309 @@
+package funcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// httpTemplateFuncs are the functions the templates of HTTP requests can use to encode input values
+var httpTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"xml": func(value interface{}) (string, error) {
+		var buf bytes.Buffer
+		err := xml.EscapeText(&buf, []byte(fmt.Sprintf("%v", value)))
+		return buf.String(), err
+	},
+	"query": func(value interface{}) string {
+		return url.QueryEscape(fmt.Sprintf("%v", value))
+	},
+}
+
+// renderHTTPTemplate fills the template with the function inputs, a missing input is an error
+func renderHTTPTemplate(name, text string, inputs map[string]interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Funcs(httpTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, inputs); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// extractResponse returns the values of a JSONPath or XPath expression in the response body
+func extractResponse(body []byte, path string) ([]interface{}, error) {
+	if strings.HasPrefix(path, "$") {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("the response is not JSON: %v", err)
+		}
+		return jsonPath(doc, path)
+	}
+	return xPath(body, path)
+}
+
+// jsonPath evaluates the JSONPath subset used for response mappings: $, .name, ['name'], [n], [*], .* and ..name
+func jsonPath(doc interface{}, path string) ([]interface{}, error) {
+	nodes := []interface{}{doc}
+	rest := strings.TrimPrefix(path, "$")
+	for rest != "" {
+		descendant := false
+		var name string
+		index, wildcard, isIndex := 0, false, false
+
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			descendant = true
+			rest = rest[2:]
+			name, rest = jsonPathName(rest)
+		case strings.HasPrefix(rest, "."):
+			name, rest = jsonPathName(rest[1:])
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in JSONPath %s", path)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				wildcard = true
+			case strings.HasPrefix(selector, "'") || strings.HasPrefix(selector, `"`):
+				name = strings.Trim(selector, `'"`)
+			default:
+				n, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %s in JSONPath %s", selector, path)
+				}
+				index, isIndex = n, true
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %s at %s", path, rest)
+		}
+		if name == "*" {
+			wildcard, name = true, ""
+		}
+		if name == "" && !wildcard && !isIndex {
+			return nil, fmt.Errorf("invalid JSONPath %s", path)
+		}
+
+		candidates := nodes
+		if descendant {
+			candidates = []interface{}{}
+			for _, node := range nodes {
+				candidates = jsonDescendants(node, candidates)
+			}
+		}
+		next := []interface{}{}
+		for _, node := range candidates {
+			switch {
+			case wildcard:
+				next = append(next, jsonChildren(node)...)
+			case isIndex:
+				if list, ok := node.([]interface{}); ok {
+					i := index
+					if i < 0 {
+						i += len(list)
+					}
+					if i >= 0 && i < len(list) {
+						next = append(next, list[i])
+					}
+				}
+			default:
+				if object, ok := node.(map[string]interface{}); ok {
+					if value, exists := object[name]; exists {
+						next = append(next, value)
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes, nil
+}
+
+func jsonPathName(rest string) (string, string) {
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		return rest, ""
+	}
+	return rest[:end], rest[end:]
+}
+
+// jsonChildren returns the values of an object in the order of their keys or the elements of a list
+func jsonChildren(node interface{}) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		children := make([]interface{}, 0, len(v))
+		for _, key := range keys {
+			children = append(children, v[key])
+		}
+		return children
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+func jsonDescendants(node interface{}, result []interface{}) []interface{} {
+	result = append(result, node)
+	for _, child := range jsonChildren(node) {
+		result = jsonDescendants(child, result)
+	}
+	return result
+}
+
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	children []*xmlNode
+	text     strings.Builder
+	parent   *xmlNode
+}
+
+// value is the text of the element and of all its descendants, like the XPath string value
+func (n *xmlNode) value() string {
+	var sb strings.Builder
+	var collect func(node *xmlNode)
+	collect = func(node *xmlNode) {
+		sb.WriteString(node.text.String())
+		for _, child := range node.children {
+			collect(child)
+		}
+	}
+	collect(n)
+	return strings.TrimSpace(sb.String())
+}
+
+func parseXML(body []byte) (*xmlNode, error) {
+	root := &xmlNode{attrs: map[string]string{}}
+	current := root
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("the response is not XML: %v", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: map[string]string{}, parent: current}
+			for _, attr := range t.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			current.children = append(current.children, node)
+			current = node
+		case xml.EndElement:
+			if current.parent != nil {
+				current = current.parent
+			}
+		case xml.CharData:
+			current.text.Write(t)
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("the response is not XML")
+	}
+	return root, nil
+}
+
+// xPath evaluates the XPath subset used for response mappings: /name, //name, *, [n], @attribute and text()
+func xPath(body []byte, path string) ([]interface{}, error) {
+	root, err := parseXML(body)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*xmlNode{root}
+	rest := path
+	for rest != "" {
+		descendant := strings.HasPrefix(rest, "//")
+		rest = strings.TrimLeft(rest, "/")
+		step := rest
+		if end := strings.Index(rest, "/"); end >= 0 {
+			step, rest = rest[:end], rest[end:]
+		} else {
+			rest = ""
+		}
+		if step == "" {
+			return nil, fmt.Errorf("invalid XPath %s", path)
+		}
+
+		if strings.HasPrefix(step, "@") || step == "text()" {
+			if rest != "" {
+				return nil, fmt.Errorf("%s must be the last step of XPath %s", step, path)
+			}
+			values := []interface{}{}
+			for _, node := range nodes {
+				if step == "text()" {
+					values = append(values, strings.TrimSpace(node.text.String()))
+				} else if value, exists := node.attrs[step[1:]]; exists {
+					values = append(values, value)
+				}
+			}
+			return values, nil
+		}
+
+		name, position := step, 0
+		if open := strings.Index(step, "["); open >= 0 && strings.HasSuffix(step, "]") {
+			n, err := strconv.Atoi(step[open+1 : len(step)-1])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid position in XPath step %s", step)
+			}
+			name, position = step[:open], n
+		}
+
+		next := []*xmlNode{}
+		for _, node := range nodes {
+			matches := []*xmlNode{}
+			var match func(parent *xmlNode)
+			match = func(parent *xmlNode) {
+				for _, child := range parent.children {
+					if name == "*" || child.name == name {
+						matches = append(matches, child)
+					}
+					if descendant {
+						match(child)
+					}
+				}
+			}
+			match(node)
+			if position > 0 {
+				if position <= len(matches) {
+					next = append(next, matches[position-1])
+				}
+				continue
+			}
+			next = append(next, matches...)
+		}
+		nodes = next
+	}
+
+	values := make([]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		values = append(values, node.value())
+	}
+	return values, nil
+}
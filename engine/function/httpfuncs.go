@@ -0,0 +1,583 @@
+package funcs
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// HTTP DESIGN: the HTTPRequest function type keeps its request in the function content and gets the address,
+// the credentials and the certificates from a named connection profile, registered at startup with
+// RegisterHTTPProfile. The profile also owns the state shared by all transaction codes calling the service:
+// the HTTP client with its mTLS certificates, the cached OAuth2 token and the circuit breaker.
+// A request is retried on network errors and on the configured status codes with the same idempotency key,
+// so the service can drop the duplicates of a request that was received but whose response was lost.
+
+type httpConnection struct {
+	profile types.HTTPProfile
+	client  *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	failures    int
+	openUntil   time.Time
+	probing     bool
+}
+
+var (
+	httpProfiles   = map[string]*httpConnection{}
+	httpProfilesMu sync.RWMutex
+)
+
+// RegisterHTTPProfile makes a connection profile available to the HTTP functions, it replaces
+// a profile with the same name and resets its token and circuit breaker
+func RegisterHTTPProfile(profile types.HTTPProfile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	client, err := newHTTPClient(profile)
+	if err != nil {
+		return fmt.Errorf("HTTP profile %s: %v", profile.Name, err)
+	}
+
+	httpProfilesMu.Lock()
+	defer httpProfilesMu.Unlock()
+	httpProfiles[profile.Name] = &httpConnection{profile: profile, client: client}
+	return nil
+}
+
+// UnregisterHTTPProfile removes a connection profile
+func UnregisterHTTPProfile(name string) {
+	httpProfilesMu.Lock()
+	defer httpProfilesMu.Unlock()
+	delete(httpProfiles, name)
+}
+
+// GetHTTPProfile returns the connection profile registered under the name
+func GetHTTPProfile(name string) (types.HTTPProfile, bool) {
+	conn, exists := httpConnectionOf(name)
+	if !exists {
+		return types.HTTPProfile{}, false
+	}
+	return conn.profile, true
+}
+
+func httpConnectionOf(name string) (*httpConnection, bool) {
+	httpProfilesMu.RLock()
+	defer httpProfilesMu.RUnlock()
+	conn, exists := httpProfiles[name]
+	return conn, exists
+}
+
+func newHTTPClient(profile types.HTTPProfile) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: profile.TLS.InsecureSkipVerify}
+	if profile.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(profile.TLS.CertFile, profile.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if profile.TLS.CAFile != "" {
+		ca, err := os.ReadFile(profile.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("the CA file %s has no certificates", profile.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// allow reports whether the circuit breaker lets a request through. After OpenSeconds an open
+// circuit lets one request through, its result closes the circuit or opens it again.
+func (c *httpConnection) allow(now time.Time) bool {
+	if c.profile.CircuitBreaker.Failures <= 0 {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures < c.profile.CircuitBreaker.Failures {
+		return true
+	}
+	if now.Before(c.openUntil) || c.probing {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+func (c *httpConnection) record(success bool, now time.Time) {
+	breaker := c.profile.CircuitBreaker
+	if breaker.Failures <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.probing = false
+	if success {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= breaker.Failures {
+		open := breaker.OpenSeconds
+		if open <= 0 {
+			open = 30
+		}
+		c.openUntil = now.Add(time.Duration(open) * time.Second)
+	}
+}
+
+// accessToken returns the OAuth2 token of the profile, it gets a new token from the token URL
+// when there is none, when it expires or when refresh is set
+func (c *httpConnection) accessToken(ctx context.Context, refresh bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !refresh && c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+
+	auth := c.profile.Auth
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", auth.ClientID)
+	form.Set("client_secret", auth.ClientSecret)
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("the token response has no access token")
+	}
+	if token.ExpiresIn <= 0 {
+		token.ExpiresIn = 300
+	}
+	c.token = token.AccessToken
+	// renew the token before it expires during a request
+	c.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - 30*time.Second)
+	return c.token, nil
+}
+
+// authorize adds the credentials of the profile to the request
+func (c *httpConnection) authorize(ctx context.Context, req *http.Request, refresh bool) error {
+	auth := c.profile.Auth
+	switch auth.Type {
+	case types.HTTPAuthAPIKey:
+		if auth.APIKeyQuery != "" {
+			query := req.URL.Query()
+			query.Set(auth.APIKeyQuery, auth.APIKey)
+			req.URL.RawQuery = query.Encode()
+			return nil
+		}
+		header := auth.APIKeyHeader
+		if header == "" {
+			header = "X-API-Key"
+		}
+		req.Header.Set(header, auth.APIKey)
+	case types.HTTPAuthBasic:
+		req.SetBasicAuth(auth.Username, auth.Password)
+	case types.HTTPAuthOAuth2:
+		token, err := c.accessToken(ctx, refresh)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// httpRequest is a request of an HTTP function ready to be sent, possibly several times
+type httpRequest struct {
+	method         string
+	url            *url.URL
+	headers        http.Header
+	body           string
+	idempotencyKey string
+}
+
+// HTTPFuncs implements the HTTPRequest function type
+type HTTPFuncs struct {
+}
+
+// Execute sends the request of the function and maps the response to the outputs.
+// Outputs: StatusCode, Headers, Body (parsed if the response is JSON), Attempts and the outputs of the response mapping.
+func (hf *HTTPFuncs) Execute(f *Funcs) {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		f.iLog.PerformanceWithDuration("engine.funcs.HTTPFuncs.Execute", elapsed)
+	}()
+
+	definition, err := types.ParseHTTPRequestDefinition(f.Fobj.Content)
+	if err != nil {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s: %v", f.Fobj.Name, err), err))
+	}
+	_, _, inputs := f.SetInputs()
+
+	conn := &httpConnection{client: http.DefaultClient}
+	if definition.Profile != "" {
+		var exists bool
+		if conn, exists = httpConnectionOf(definition.Profile); !exists {
+			panic(types.NewNetworkError("connect", fmt.Sprintf("HTTP profile %s is not registered", definition.Profile), nil).
+				WithDetail("profile", definition.Profile))
+		}
+	}
+
+	request, err := hf.buildRequest(conn, definition, inputs)
+	if err != nil {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s has an invalid HTTP request: %v", f.Fobj.Name, err), err))
+	}
+
+	retry := conn.profile.Retry
+	if definition.Retry != nil {
+		retry = *definition.Retry
+	}
+	timeout := definition.Timeout
+	if timeout <= 0 {
+		timeout = conn.profile.Timeout
+	}
+	if timeout <= 0 {
+		timeout = 30
+	}
+
+	resp, body, attempts := hf.send(f, conn, request, retry, time.Duration(timeout)*time.Second)
+
+	endpoint := request.url.Scheme + "://" + request.url.Host + request.url.Path
+	if resp.StatusCode >= 300 {
+		snippet := string(body)
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		panic(types.NewNetworkError("http", fmt.Sprintf("HTTP %s %s failed with status %d: %s", request.method, endpoint, resp.StatusCode, snippet), nil).
+			WithDetail("profile", definition.Profile).
+			WithDetail("status_code", strconv.Itoa(resp.StatusCode)).
+			WithDetail("attempts", strconv.Itoa(attempts)))
+	}
+
+	headers := make(map[string]interface{}, len(resp.Header))
+	for name := range resp.Header {
+		headers[name] = resp.Header.Get(name)
+	}
+	outputs := make(map[string]interface{})
+	outputs["StatusCode"] = resp.StatusCode
+	outputs["Headers"] = headers
+	outputs["Attempts"] = attempts
+	outputs["Body"] = string(body)
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		var parsed interface{}
+		if err := json.Unmarshal(body, &parsed); err == nil {
+			outputs["Body"] = parsed
+		}
+	}
+
+	for name, path := range definition.Response {
+		values, err := extractResponse(body, path)
+		if err != nil {
+			panic(types.NewExecutionError(fmt.Sprintf("Function %s cannot map the response to output %s: %v", f.Fobj.Name, name, err), err).
+				WithDetail("path", path))
+		}
+		outputs[name] = httpOutputValue(f, name, values)
+	}
+
+	f.iLog.Debug(fmt.Sprintf("HTTP %s %s returned status %d after %d attempts", request.method, endpoint, resp.StatusCode, attempts))
+	f.SetOutputs(outputs)
+}
+
+// buildRequest fills the templates of the request definition with the function inputs
+func (hf *HTTPFuncs) buildRequest(conn *httpConnection, definition *types.HTTPRequestDefinition, inputs map[string]interface{}) (*httpRequest, error) {
+	path, err := renderHTTPTemplate("path", definition.Path, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		path = strings.TrimRight(conn.profile.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	for _, name := range sortedHTTPKeys(definition.Query) {
+		value, err := renderHTTPTemplate("query "+name, definition.Query[name], inputs)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	headers := http.Header{}
+	for name, value := range conn.profile.Headers {
+		headers.Set(name, value)
+	}
+	for _, name := range sortedHTTPKeys(definition.Headers) {
+		value, err := renderHTTPTemplate("header "+name, definition.Headers[name], inputs)
+		if err != nil {
+			return nil, err
+		}
+		headers.Set(name, value)
+	}
+
+	body, err := renderHTTPTemplate("body", definition.Body, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if body != "" && headers.Get("Content-Type") == "" {
+		switch definition.BodyFormat {
+		case types.HTTPBodyJSON:
+			headers.Set("Content-Type", "application/json")
+		case types.HTTPBodyXML:
+			headers.Set("Content-Type", "application/xml")
+		case types.HTTPBodyForm:
+			headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		case types.HTTPBodyText:
+			headers.Set("Content-Type", "text/plain")
+		}
+	}
+
+	request := &httpRequest{method: definition.Method, url: u, headers: headers, body: body}
+	if definition.IdempotencyKey != "" {
+		key := definition.IdempotencyKey
+		if key == types.HTTPIdempotencyAuto {
+			sum := sha256.Sum256([]byte(definition.Method + " " + u.String() + "\n" + body))
+			key = hex.EncodeToString(sum[:])
+		} else if key, err = renderHTTPTemplate("idempotency key", key, inputs); err != nil {
+			return nil, err
+		}
+		request.idempotencyKey = key
+		headers.Set(definition.IdempotencyHeader, key)
+	}
+	return request, nil
+}
+
+// send sends the request until it succeeds or the attempts are used up and returns the last response,
+// its body and the number of attempts. It panics if no attempt got a response.
+func (hf *HTTPFuncs) send(f *Funcs, conn *httpConnection, request *httpRequest, retry types.HTTPRetry, timeout time.Duration) (*http.Response, []byte, int) {
+	ctx := f.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attempts := retry.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	refreshed := false
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if !conn.allow(time.Now()) {
+			panic(types.NewNetworkError("circuit", fmt.Sprintf("The circuit breaker of HTTP profile %s is open after %d failed requests", conn.profile.Name, conn.profile.CircuitBreaker.Failures), nil).
+				WithDetail("profile", conn.profile.Name))
+		}
+
+		resp, body, err := hf.do(ctx, conn, request, timeout, refreshed)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && conn.profile.Auth.Type == types.HTTPAuthOAuth2 && !refreshed {
+			// the token was revoked or expired early, get a new one once
+			refreshed = true
+			f.iLog.Debug(fmt.Sprintf("HTTP profile %s got status 401, refresh the access token", conn.profile.Name))
+			resp, body, err = hf.do(ctx, conn, request, timeout, true)
+		}
+
+		failed := err != nil || resp.StatusCode >= 500 || retry.Retries(resp.StatusCode)
+		conn.record(!failed, time.Now())
+		if err == nil && (!retry.Retries(resp.StatusCode) || attempt >= attempts) {
+			return resp, body, attempt
+		}
+		if err != nil {
+			lastErr = err
+			f.iLog.Warn(fmt.Sprintf("HTTP %s %s attempt %d failed: %v", request.method, request.url.Path, attempt, err))
+		} else {
+			f.iLog.Warn(fmt.Sprintf("HTTP %s %s attempt %d returned status %d", request.method, request.url.Path, attempt, resp.StatusCode))
+		}
+		if attempt >= attempts {
+			break
+		}
+
+		wait := retry.Wait(attempt)
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+		}
+		select {
+		case <-ctx.Done():
+			panic(types.NewNetworkError("http", fmt.Sprintf("HTTP %s %s was cancelled while waiting for the retry", request.method, request.url.Path), ctx.Err()))
+		case <-time.After(wait):
+		}
+	}
+
+	panic(types.NewNetworkError("http", fmt.Sprintf("HTTP %s %s failed after %d attempts: %v", request.method, request.url.Scheme+"://"+request.url.Host+request.url.Path, attempts, lastErr), lastErr).
+		WithDetail("profile", conn.profile.Name).
+		WithDetail("attempts", strconv.Itoa(attempts)))
+}
+
+// do sends the request once
+func (hf *HTTPFuncs) do(ctx context.Context, conn *httpConnection, request *httpRequest, timeout time.Duration, refreshToken bool) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if request.body != "" {
+		body = strings.NewReader(request.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, request.method, request.url.String(), body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range request.headers {
+		req.Header[name] = append([]string{}, values...)
+	}
+	if err := conn.authorize(ctx, req, refreshToken); err != nil {
+		return nil, nil, fmt.Errorf("authentication failed: %v", err)
+	}
+
+	resp, err := conn.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+// httpOutputValue returns the extracted values as the declared output: all values for a list output,
+// otherwise the first value, converted to the data type of the output
+func httpOutputValue(f *Funcs, name string, values []interface{}) interface{} {
+	var output *types.Output
+	for i := range f.Fobj.Outputs {
+		if f.Fobj.Outputs[i].Name == name {
+			output = &f.Fobj.Outputs[i]
+			break
+		}
+	}
+	if output != nil && output.List {
+		list := make([]interface{}, len(values))
+		for i, value := range values {
+			list[i] = httpTypedValue(value, output.Datatype)
+		}
+		return list
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if output == nil {
+		return values[0]
+	}
+	return httpTypedValue(values[0], output.Datatype)
+}
+
+func httpTypedValue(value interface{}, datatype types.DataType) interface{} {
+	if value == nil {
+		return nil
+	}
+	converter := &TypeConverter{}
+	var converted interface{}
+	var err error
+	switch datatype {
+	case types.String:
+		if _, ok := value.(string); !ok {
+			if data, jsonErr := json.Marshal(value); jsonErr == nil {
+				return string(data)
+			}
+		}
+		return converter.ConvertToString(value)
+	case types.Integer:
+		converted, err = converter.ConvertToInt(value)
+	case types.Float:
+		converted, err = converter.ConvertToFloat(value)
+	case types.Bool:
+		converted, err = converter.ConvertToBool(value)
+	case types.DateTime:
+		converted, err = converter.ConvertToDateTime(value)
+	default:
+		return value
+	}
+	if err != nil {
+		return value
+	}
+	return converted
+}
+
+func sortedHTTPKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateHTTPRequest checks the request definition in the content of the function
+func validateHTTPRequest(fobj *types.Function) error {
+	_, err := types.ParseHTTPRequestDefinition(fobj.Content)
+	return err
+}
+
+func init() {
+	MustRegisterFunctionType(FunctionTypeDescriptor{
+		Name:             "HTTPRequest",
+		Label:            "HTTP Request",
+		Category:         "Integration",
+		Description:      "Calls an HTTP service through a connection profile with retries and response mapping",
+		Builtin:          true,
+		Functype:         -1,
+		ExternalEffect:   true,
+		Nondeterministic: true,
+		Inputs:           []types.SchemaField{},
+		Outputs: []types.SchemaField{
+			{Name: "StatusCode", Datatype: types.Integer, Description: "Status code of the response"},
+			{Name: "Headers", Datatype: types.Object, Description: "Headers of the response"},
+			{Name: "Body", Datatype: types.Object, Description: "Body of the response, parsed if it is JSON"},
+			{Name: "Attempts", Datatype: types.Integer, Description: "Number of attempts the request needed"},
+		},
+	}, ExecutorFunc(func(f *Funcs) { (&HTTPFuncs{}).Execute(f) }), ValidatorFunc(validateHTTPRequest))
+}
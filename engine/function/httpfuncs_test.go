@@ -0,0 +1,185 @@
+package funcs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+func newHTTPTestFuncs(content string, inputs []types.Input, outputs []types.Output) *Funcs {
+	return newTestFuncs(types.Function{Name: "CallERP", Typename: "HTTPRequest", Content: content, Inputs: inputs, Outputs: outputs})
+}
+
+func TestHTTPFuncs_Retry(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	keys := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		keys[r.Header.Get("Idempotency-Key")] = true
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	if err := RegisterHTTPProfile(types.HTTPProfile{Name: "erp", BaseURL: server.URL, Retry: types.HTTPRetry{Attempts: 3, Backoff: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterHTTPProfile("erp")
+
+	f := newHTTPTestFuncs(`{"profile":"erp","method":"post","path":"orders","idempotencykey":"auto"}`, nil, []types.Output{{Name: "Attempts"}})
+	f.Execute()
+	if attempts := f.FuncCachedVariables["CallERP"].(map[string]interface{})["Attempts"]; attempts != 3 {
+		t.Errorf("Attempts = %v, want 3", attempts)
+	}
+	if len(keys) != 1 || keys[""] {
+		t.Errorf("idempotency keys = %v, want one key for all attempts", keys)
+	}
+}
+
+func TestHTTPFuncs_RequestAndResponseMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" || r.URL.Query().Get("plant") != "P1" || r.URL.Path != "/orders/4711" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"order":{"id":"4711","lines":[{"qty":"2"},{"qty":"5"}]}}`)
+	}))
+	defer server.Close()
+
+	if err := RegisterHTTPProfile(types.HTTPProfile{
+		Name:    "erp",
+		BaseURL: server.URL,
+		Auth:    types.HTTPAuth{Type: types.HTTPAuthAPIKey, APIKey: "secret"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterHTTPProfile("erp")
+
+	content := `{"profile":"erp","method":"post","path":"/orders/{{.OrderNo}}","query":{"plant":"{{.Plant}}"},
+		"body":"{\"order\":{{json .OrderNo}}}",
+		"response":{"OrderID":"$.order.id","Quantities":"$.order.lines[*].qty"}}`
+	f := newHTTPTestFuncs(content,
+		[]types.Input{{Name: "OrderNo", Value: "4711"}, {Name: "Plant", Value: "P1"}},
+		[]types.Output{{Name: "OrderID"}, {Name: "Quantities", Datatype: types.Integer, List: true}})
+	f.Execute()
+
+	outputs := f.FuncCachedVariables["CallERP"].(map[string]interface{})
+	if outputs["OrderID"] != "4711" {
+		t.Errorf("OrderID = %v, want 4711", outputs["OrderID"])
+	}
+	if quantities, ok := outputs["Quantities"].([]interface{}); !ok || len(quantities) != 2 || quantities[1] != 5 {
+		t.Errorf("Quantities = %v, want [2 5]", outputs["Quantities"])
+	}
+}
+
+func TestHTTPFuncs_UnregisteredProfile(t *testing.T) {
+	bpmErr := executeError(newHTTPTestFuncs(`{"profile":"unknown","path":"status"}`, nil, nil))
+	if bpmErr == nil || bpmErr.Details["operation"] != "connect" {
+		t.Errorf("Execute() = %v, want the unregistered profile refused", bpmErr)
+	}
+}
+
+func TestHTTPFuncs_CircuitBreaker(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := RegisterHTTPProfile(types.HTTPProfile{Name: "mes", BaseURL: server.URL, CircuitBreaker: types.HTTPCircuitBreaker{Failures: 2, OpenSeconds: 60}}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterHTTPProfile("mes")
+
+	operations := []string{}
+	for i := 0; i < 3; i++ {
+		if bpmErr := executeError(newHTTPTestFuncs(`{"profile":"mes","path":"status"}`, nil, nil)); bpmErr != nil {
+			operations = append(operations, bpmErr.Details["operation"])
+		}
+	}
+	if calls != 2 || len(operations) != 3 || operations[0] != "http" || operations[2] != "circuit" {
+		t.Errorf("calls = %d, operations = %v, want the third call stopped by the open circuit", calls, operations)
+	}
+}
+
+func TestHTTPFuncs_OAuth2(t *testing.T) {
+	tokens := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens++
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "iac" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token":"token%d","expires_in":3600}`, tokens)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<result><item code="A">1</item><item code="B">2</item></result>`)
+	}))
+	defer server.Close()
+
+	if err := RegisterHTTPProfile(types.HTTPProfile{
+		Name:    "wms",
+		BaseURL: server.URL,
+		Auth:    types.HTTPAuth{Type: types.HTTPAuthOAuth2, TokenURL: server.URL + "/token", ClientID: "iac", ClientSecret: "s"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterHTTPProfile("wms")
+
+	for i := 0; i < 2; i++ {
+		f := newHTTPTestFuncs(`{"profile":"wms","path":"stock","response":{"Code":"//item[2]/@code","Qty":"/result/item[2]"}}`,
+			nil, []types.Output{{Name: "Code"}, {Name: "Qty", Datatype: types.Integer}})
+		f.Execute()
+		outputs := f.FuncCachedVariables["CallERP"].(map[string]interface{})
+		if outputs["Code"] != "B" || outputs["Qty"] != 2 {
+			t.Errorf("outputs = %v, want item B with quantity 2", outputs)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("token requests = %d, want the token cached", tokens)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	doc := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{
+		map[string]interface{}{"c": 1.0}, map[string]interface{}{"c": 2.0},
+	}}}
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"$.a.b[0].c", 1},
+		{"$.a.b[-1].c", 1},
+		{"$['a'].b[*].c", 2},
+		{"$..c", 2},
+		{"$.a.x", 0},
+	}
+	for _, tt := range tests {
+		values, err := jsonPath(doc, tt.path)
+		if err != nil || len(values) != tt.want {
+			t.Errorf("jsonPath(%s) = %v, %v, want %d values", tt.path, values, err, tt.want)
+		}
+	}
+	if _, err := jsonPath(doc, "$.a[0"); err == nil {
+		t.Errorf("jsonPath() accepted an unclosed index")
+	}
+}
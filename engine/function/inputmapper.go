@@ -1,8 +1,10 @@
 package funcs
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -242,13 +244,16 @@ func (im *InputMapper) convertValueToString(value interface{}) (string, error) {
 	case int64:
 		return fmt.Sprintf("%d", v), nil
 	case float64:
-		return fmt.Sprintf("%f", v), nil
+		// shortest representation, %f would cut decimals after the 6th place
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
 	case float32:
-		return fmt.Sprintf("%f", v), nil
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
 	case bool:
 		return fmt.Sprintf("%t", v), nil
 	case time.Time:
 		return v.Format(types.DateTimeFormat), nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
 	case []interface{}, map[string]interface{}:
 		// For complex types, marshal to JSON
 		jsonBytes, err := json.Marshal(v)
@@ -298,6 +303,9 @@ func (im *InputMapper) convertToType(name string, rawValue string, dataType type
 	case types.Object:
 		return im.parseObject(rawValue)
 
+	case types.Decimal, types.Date, types.Time, types.Duration, types.Binary:
+		return im.parseExtended(rawValue, dataType)
+
 	default:
 		return rawValue, nil
 	}
@@ -326,6 +334,9 @@ func (im *InputMapper) convertToListType(name string, rawValue string, dataType
 	case types.Object:
 		return im.parseObjectList(rawValue)
 
+	case types.Decimal, types.Date, types.Time, types.Duration, types.Binary:
+		return im.parseExtendedList(rawValue, dataType)
+
 	default:
 		return im.parseStringList(rawValue)
 	}
@@ -618,6 +629,49 @@ func (im *InputMapper) parseObjectList(value string) ([]map[string]interface{},
 	return result, nil
 }
 
+// Decimal, date, time, duration and binary parsing
+func (im *InputMapper) parseExtended(value string, dataType types.DataType) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	result, err := types.ConvertValue(value, dataType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse value: %w", err)
+	}
+
+	return result, nil
+}
+
+func (im *InputMapper) parseExtendedList(value string, dataType types.DataType) ([]interface{}, error) {
+	if value == "" {
+		return []interface{}{}, nil
+	}
+
+	// Decimals may be sent as JSON numbers, keep their digits
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var items []interface{}
+	if err := decoder.Decode(&items); err != nil {
+		// If not JSON, treat as single value
+		items = []interface{}{value}
+	}
+
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		if n, ok := item.(json.Number); ok {
+			item = n.String()
+		}
+		converted, err := types.ConvertValue(item, dataType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse list item %d: %w", i, err)
+		}
+		result[i] = converted
+	}
+
+	return result, nil
+}
+
 // valueToString converts any value to string for logging/display
 func (im *InputMapper) valueToString(value interface{}) string {
 	if value == nil {
@@ -629,6 +683,8 @@ func (im *InputMapper) valueToString(value interface{}) string {
 		return v
 	case time.Time:
 		return v.Format(types.DateTimeFormat)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
 	case []interface{}, map[string]interface{}, []string, []int, []float64, []bool, []time.Time:
 		jsonBytes, err := json.Marshal(v)
 		if err != nil {
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/mdaxf/iac/engine/types"
 	"github.com/mdaxf/iac/logger"
 	"github.com/robertkrimen/otto"
 )
@@ -35,10 +36,14 @@ func (cf *JSFuncs) Execute(f *Funcs) {
 
 	namelist, _, inputs := f.SetInputs()
 
-	vm := goja.New()
+	// the script can require the shared script libraries and use the host API iac
+	runtime := newScriptRuntime(scriptHostOf(f))
+	defer runtime.watch(f.Ctx)()
+	vm := runtime.vm
 
+	// decimals arrive as strings to keep their digits, price + 1 concatenates, Number(price) + 1 adds
 	for i := 0; i < len(namelist); i++ {
-		vm.Set(namelist[i], inputs[namelist[i]])
+		vm.Set(namelist[i], types.ScriptValue(inputs[namelist[i]]))
 	}
 	f.iLog.Debug(fmt.Sprintf("Fucntion %s script: %s", f.Fobj.Name, f.Fobj.Content))
 
@@ -62,8 +67,9 @@ func (cf *JSFuncs) Execute(f *Funcs) {
 }
 
 // Validate validates the given Funcs object using JavaScript functions.
-// It executes the JavaScript code stored in the Content field of the Funcs object using the goja VM.
-// If the JavaScript code throws an error, it returns false and the error.
+// It compiles the JavaScript code stored in the Content field of the Funcs object and
+// loads the script libraries it requires.
+// If the code does not compile or a library is missing, it returns false and the error.
 // Otherwise, it returns true and nil.
 
 func (cf *JSFuncs) Validate(f *Funcs) (bool, error) {
@@ -80,11 +86,13 @@ func (cf *JSFuncs) Validate(f *Funcs) (bool, error) {
 			}
 		}()
 	*/
-	vm := goja.New()
-	_, err := vm.RunString(f.Fobj.Content)
+	_, err := goja.Compile(f.Fobj.Name, f.Fobj.Content, false)
 	if err != nil {
 		return false, err
 	}
+	if err := checkScriptRequires(f.Fobj.Content, f.DocDBCon); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
@@ -117,10 +125,10 @@ func (cf *JSFuncs) Testfunction(content string, inputs interface{}, outputs []st
 		valuelist = append(valuelist, value)
 	}
 
-	vm := goja.New()
+	vm := newScriptRuntime(scriptHost{Name: "Test", Log: iLog}).vm
 
 	for i := 0; i < len(namelist); i++ {
-		vm.Set(namelist[i], valuelist[i])
+		vm.Set(namelist[i], types.ScriptValue(valuelist[i]))
 	}
 
 	value, err := vm.RunString(content)
@@ -162,7 +170,7 @@ func (cf *JSFuncs) Execute_otto(f *Funcs) {
 
 	vm := otto.New()
 	for i := 0; i < len(namelist); i++ {
-		vm.Set(namelist[i], inputs[namelist[i]])
+		vm.Set(namelist[i], types.ScriptValue(inputs[namelist[i]]))
 	}
 	f.iLog.Debug(fmt.Sprintf("Fucntion %s script: %s", f.Fobj.Name, f.Fobj.Content))
 
@@ -210,7 +218,7 @@ func (cf *JSFuncs) Testfunction_otto(content string, inputs interface{}, outputs
 
 	vm := otto.New()
 	for i := 0; i < len(namelist); i++ {
-		vm.Set(namelist[i], valuelist[i])
+		vm.Set(namelist[i], types.ScriptValue(valuelist[i]))
 	}
 
 	vm.Run(content)
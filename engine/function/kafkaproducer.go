@@ -10,6 +10,7 @@ import (
 	"os/signal"
 
 	"github.com/IBM/sarama"
+	"github.com/mdaxf/iac/engine/types"
 )
 
 type SendMessagebyKafka struct {
@@ -58,6 +59,10 @@ func (cf *SendMessagebyKafka) Execute(f *Funcs) {
 		return
 	}
 
+	if f.sendToOutbox(types.MessageChannelKafka, Topic, kafkaServer, data) {
+		return
+	}
+
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
 
@@ -0,0 +1,119 @@
+package funcs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// MOCK DESIGN: test runs carry the recorded responses of their test data in the context, so the mocks
+// reach every function of the transaction code and of its sub transaction codes without new parameters.
+// A mocked function does not run its executor, its inputs are not read and its outputs are the recorded ones.
+
+// FunctionMocks are the mocks of a test run. It remembers the functions with external effects that ran
+// without a mock, so the test runner can report the effects it could not isolate.
+type FunctionMocks struct {
+	mocks    []types.FunctionMock
+	mu       sync.Mutex
+	calls    map[string]int
+	unmocked []string
+}
+
+// NewFunctionMocks creates the mocks of a test run
+func NewFunctionMocks(mocks []types.FunctionMock) *FunctionMocks {
+	return &FunctionMocks{mocks: mocks, calls: map[string]int{}, unmocked: []string{}}
+}
+
+// Unmocked returns the functions with external effects that ran without a mock
+func (m *FunctionMocks) Unmocked() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string{}, m.unmocked...)
+}
+
+// find counts the call of the function and returns its mock. A mock of the call goes before a mock
+// of the function name, which goes before a mock of the function type.
+func (m *FunctionMocks) find(fobj *types.Function) *types.FunctionMock {
+	m.mu.Lock()
+	m.calls[fobj.Name]++
+	call := m.calls[fobj.Name]
+	m.mu.Unlock()
+
+	var byName, byType *types.FunctionMock
+	for i := range m.mocks {
+		mock := &m.mocks[i]
+		if !mock.Matches(fobj) || (mock.Sequence > 0 && mock.Sequence != call) {
+			continue
+		}
+		switch {
+		case mock.Function != "" && mock.Sequence > 0:
+			return mock
+		case mock.Function != "" && byName == nil:
+			byName = mock
+		case mock.Function == "" && byType == nil:
+			byType = mock
+		}
+	}
+	if byName != nil {
+		return byName
+	}
+	return byType
+}
+
+func (m *FunctionMocks) addUnmocked(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.unmocked {
+		if existing == name {
+			return
+		}
+	}
+	m.unmocked = append(m.unmocked, name)
+}
+
+type functionMocksContextKey struct{}
+
+// WithFunctionMocks returns a context carrying the mocks of a test run
+func WithFunctionMocks(ctx context.Context, mocks *FunctionMocks) context.Context {
+	return context.WithValue(ctx, functionMocksContextKey{}, mocks)
+}
+
+// FunctionMocksFromContext returns the mocks of the context, or nil outside of test runs
+func FunctionMocksFromContext(ctx context.Context) *FunctionMocks {
+	if ctx == nil {
+		return nil
+	}
+	mocks, _ := ctx.Value(functionMocksContextKey{}).(*FunctionMocks)
+	return mocks
+}
+
+// executeMock returns the recorded response of the function if the test run mocks it and reports whether it did
+func (f *Funcs) executeMock(registered *registeredFunctionType) bool {
+	mocks := FunctionMocksFromContext(f.Ctx)
+	if mocks == nil {
+		return false
+	}
+
+	mock := mocks.find(&f.Fobj)
+	if mock == nil {
+		if registered != nil && registered.descriptor.ExternalEffect {
+			f.iLog.Warn(fmt.Sprintf("Function %s of type %s has external effects and runs without a mock", f.Fobj.Name, f.Fobj.TypeName()))
+			mocks.addUnmocked(f.Fobj.Name)
+		}
+		return false
+	}
+
+	f.iLog.Debug(fmt.Sprintf("Function %s is mocked with outputs: %s, error: %s", f.Fobj.Name, logger.ConvertJson(mock.Outputs), mock.Error))
+	if mock.Error != "" {
+		panic(types.NewExecutionError(mock.Error, nil).WithDetail("mock", f.Fobj.Name))
+	}
+	outputs := make(map[string]interface{}, len(mock.Outputs))
+	for key, value := range mock.Outputs {
+		outputs[key] = value
+	}
+	f.SetOutputs(outputs)
+	return true
+}
@@ -0,0 +1,79 @@
+package funcs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// registerNotifyType registers Test.Notify for the test, a type with external effects that counts its runs
+func registerNotifyType(t *testing.T) *int {
+	runs := 0
+	registerTestType(t, FunctionTypeDescriptor{Name: "Test.Notify", ExternalEffect: true}, func(f *Funcs) {
+		runs++
+		f.SetOutputs(map[string]interface{}{"Sent": "live"})
+	})
+	return &runs
+}
+
+func newMockTestFuncs(name string, mocks *FunctionMocks) *Funcs {
+	f := newTestFuncs(types.Function{Name: name, Typename: "Test.Notify", Outputs: []types.Output{{Name: "Sent"}}})
+	f.Ctx = WithFunctionMocks(f.Ctx, mocks)
+	return f
+}
+
+func TestFunctionMocks_OutputsReplaceTheRun(t *testing.T) {
+	runs := registerNotifyType(t)
+	mocks := NewFunctionMocks([]types.FunctionMock{{Function: "Notify", Outputs: map[string]interface{}{"Sent": "recorded"}}})
+
+	f := newMockTestFuncs("Notify", mocks)
+	f.Execute()
+	if got := f.FuncCachedVariables["Notify"].(map[string]interface{})["Sent"]; got != "recorded" || *runs != 0 {
+		t.Errorf("Sent = %v after %d runs, want the recorded output without a run", got, *runs)
+	}
+	if unmocked := mocks.Unmocked(); len(unmocked) != 0 {
+		t.Errorf("Unmocked() = %v, want none", unmocked)
+	}
+}
+
+func TestFunctionMocks_Precedence(t *testing.T) {
+	registerNotifyType(t)
+	mocks := NewFunctionMocks([]types.FunctionMock{
+		{Type: "Test.Notify", Outputs: map[string]interface{}{"Sent": "type"}},
+		{Function: "Notify", Outputs: map[string]interface{}{"Sent": "name"}},
+		{Function: "Notify", Sequence: 2, Outputs: map[string]interface{}{"Sent": "second call"}},
+	})
+
+	sent := []interface{}{}
+	for _, name := range []string{"Notify", "Notify", "Notify", "Alert"} {
+		f := newMockTestFuncs(name, mocks)
+		f.Execute()
+		sent = append(sent, f.FuncCachedVariables[name].(map[string]interface{})["Sent"])
+	}
+	if want := []interface{}{"name", "second call", "name", "type"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("Sent = %v, want %v", sent, want)
+	}
+}
+
+func TestFunctionMocks_Unmocked(t *testing.T) {
+	runs := registerNotifyType(t)
+	mocks := NewFunctionMocks([]types.FunctionMock{{Function: "Other", Outputs: map[string]interface{}{}}})
+
+	for i := 0; i < 2; i++ {
+		newMockTestFuncs("Notify", mocks).Execute()
+	}
+	if unmocked := mocks.Unmocked(); *runs != 2 || len(unmocked) != 1 || unmocked[0] != "Notify" {
+		t.Errorf("Unmocked() = %v after %d runs, want Notify once", unmocked, *runs)
+	}
+}
+
+func TestFunctionMocks_Error(t *testing.T) {
+	mocks := NewFunctionMocks([]types.FunctionMock{{Type: types.WebServiceCall.String(), Error: "service unavailable"}})
+
+	f := newTestFuncs(types.Function{Name: "CallERP", Functype: types.WebServiceCall})
+	f.Ctx = WithFunctionMocks(f.Ctx, mocks)
+	if bpmErr := executeError(f); bpmErr == nil || bpmErr.Message != "service unavailable" || bpmErr.Details["mock"] != "CallERP" {
+		t.Errorf("Execute() = %v, want the recorded error of the mock", bpmErr)
+	}
+}
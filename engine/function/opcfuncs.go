@@ -0,0 +1,549 @@
+package funcs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// OPCConnection is a connected OPC UA client the OPC function types run on.
+// The clients of the integration/OPCClient package implement it and register themselves
+// under their configured name with RegisterOPCConnection once they are connected.
+type OPCConnection interface {
+	ReadValues(nodeIDs []string) ([]*ua.DataValue, error)
+	BatchWrite(nodeValues map[string]interface{}) (map[string]ua.StatusCode, error)
+	CallMethod(objectID, methodID string, inputArgs ...interface{}) ([]interface{}, error)
+	ReadHistory(nodeID string, startTime, endTime time.Time, maxValues uint32) ([]*ua.DataValue, error)
+}
+
+var (
+	opcConnections   = map[string]OPCConnection{}
+	opcConnectionsMu sync.RWMutex
+)
+
+// RegisterOPCConnection makes a connected OPC UA client available to the OPC function types
+func RegisterOPCConnection(name string, conn OPCConnection) {
+	opcConnectionsMu.Lock()
+	defer opcConnectionsMu.Unlock()
+	opcConnections[name] = conn
+}
+
+// UnregisterOPCConnection removes an OPC UA client when it is disconnected
+func UnregisterOPCConnection(name string) {
+	opcConnectionsMu.Lock()
+	defer opcConnectionsMu.Unlock()
+	delete(opcConnections, name)
+}
+
+// GetOPCConnection returns the OPC UA client registered under the name
+func GetOPCConnection(name string) (OPCConnection, bool) {
+	opcConnectionsMu.RLock()
+	defer opcConnectionsMu.RUnlock()
+	conn, exists := opcConnections[name]
+	return conn, exists
+}
+
+// OPCFuncs implements the OPCRead, OPCWrite, OPCCallMethod and OPCHistoryRead function types.
+// The OPC UA client is selected with the Client input. A bad OPC UA status code fails the function
+// with a device error that has the node, the status code and the status name in its details.
+type OPCFuncs struct {
+}
+
+// Read reads the values of the nodes of the NodeID input.
+// Outputs: Value (value of the first node), Values (values by node id) and SourceTimestamp.
+func (cf *OPCFuncs) Read(f *Funcs) {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		f.iLog.PerformanceWithDuration("engine.funcs.OPCFuncs.Read", elapsed)
+	}()
+
+	_, _, inputs := f.SetInputs()
+	conn := cf.connection(inputs)
+
+	nodeIDs := opcStringList(opcInput(inputs, "NodeID"))
+	if len(nodeIDs) == 0 {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s has no NodeID input", f.Fobj.Name), nil))
+	}
+
+	f.iLog.Debug(fmt.Sprintf("OPC read of nodes %v", nodeIDs))
+	dataValues, err := conn.ReadValues(nodeIDs)
+	if err != nil {
+		panic(opcError("read", strings.Join(nodeIDs, ","), err))
+	}
+	if len(dataValues) != len(nodeIDs) {
+		panic(types.NewDeviceError("read", fmt.Sprintf("OPC read returned %d values for %d nodes", len(dataValues), len(nodeIDs)), nil))
+	}
+
+	values := make(map[string]interface{}, len(nodeIDs))
+	for i, dataValue := range dataValues {
+		values[nodeIDs[i]] = cf.dataValue(f, "read", nodeIDs[i], dataValue)
+	}
+
+	outputs := make(map[string]interface{})
+	outputs["Value"] = values[nodeIDs[0]]
+	outputs["Values"] = values
+	if dataValues[0] != nil {
+		outputs["SourceTimestamp"] = dataValues[0].SourceTimestamp
+	}
+	f.SetOutputs(opcTypedOutputs(f, outputs))
+}
+
+// Write writes the Value input to the NodeID input. With lists of node ids and values
+// the values are written to the nodes at the same position in one request.
+// Outputs: StatusCode (status code of the first node) and StatusCodes (status codes by node id).
+func (cf *OPCFuncs) Write(f *Funcs) {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		f.iLog.PerformanceWithDuration("engine.funcs.OPCFuncs.Write", elapsed)
+	}()
+
+	_, _, inputs := f.SetInputs()
+	conn := cf.connection(inputs)
+
+	nodeIDs := opcStringList(opcInput(inputs, "NodeID"))
+	if len(nodeIDs) == 0 {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s has no NodeID input", f.Fobj.Name), nil))
+	}
+
+	nodeValues := make(map[string]interface{}, len(nodeIDs))
+	value := opcInput(inputs, "Value")
+	if len(nodeIDs) == 1 {
+		nodeValues[nodeIDs[0]] = opcWriteValue(value)
+	} else {
+		values := opcList(value)
+		if len(values) != len(nodeIDs) {
+			panic(types.NewValidationError(fmt.Sprintf("Function %s has %d node ids but %d values", f.Fobj.Name, len(nodeIDs), len(values)), nil))
+		}
+		for i, nodeID := range nodeIDs {
+			nodeValues[nodeID] = opcWriteValue(values[i])
+		}
+	}
+
+	f.iLog.Debug(fmt.Sprintf("OPC write of nodes %v", nodeIDs))
+	results, err := conn.BatchWrite(nodeValues)
+	if err != nil {
+		panic(opcError("write", strings.Join(nodeIDs, ","), err))
+	}
+
+	statusCodes := make(map[string]interface{}, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		status, exists := results[nodeID]
+		if !exists {
+			panic(types.NewDeviceError("write", fmt.Sprintf("OPC write returned no status for node %s", nodeID), nil).
+				WithDetail("node_id", nodeID))
+		}
+		if status&ua.StatusBad != 0 {
+			panic(opcStatusError("write", nodeID, status))
+		}
+		statusCodes[nodeID] = uint32(status)
+	}
+
+	outputs := make(map[string]interface{})
+	outputs["StatusCode"] = statusCodes[nodeIDs[0]]
+	outputs["StatusCodes"] = statusCodes
+	f.SetOutputs(opcTypedOutputs(f, outputs))
+}
+
+// CallMethod calls the MethodID method of the ObjectID object with the Arguments input.
+// Outputs: Outputs (all output arguments) and Output1, Output2, ... for each output argument.
+func (cf *OPCFuncs) CallMethod(f *Funcs) {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		f.iLog.PerformanceWithDuration("engine.funcs.OPCFuncs.CallMethod", elapsed)
+	}()
+
+	_, _, inputs := f.SetInputs()
+	conn := cf.connection(inputs)
+
+	objectID := opcString(opcInput(inputs, "ObjectID"))
+	methodID := opcString(opcInput(inputs, "MethodID"))
+	if objectID == "" || methodID == "" {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s needs the ObjectID and MethodID inputs", f.Fobj.Name), nil))
+	}
+
+	args := []interface{}{}
+	for _, arg := range opcList(opcInput(inputs, "Arguments")) {
+		args = append(args, opcWriteValue(arg))
+	}
+
+	f.iLog.Debug(fmt.Sprintf("OPC call of method %s on object %s with %d arguments", methodID, objectID, len(args)))
+	results, err := conn.CallMethod(objectID, methodID, args...)
+	if err != nil {
+		panic(opcError("call", methodID, err).WithDetail("object_id", objectID))
+	}
+
+	outputs := make(map[string]interface{})
+	outputs["Outputs"] = results
+	for i, result := range results {
+		outputs[fmt.Sprintf("Output%d", i+1)] = result
+	}
+	f.SetOutputs(opcTypedOutputs(f, outputs))
+}
+
+// HistoryRead reads the raw history of the NodeID input between the StartTime and EndTime inputs,
+// at most MaxValues values (0 for all).
+// Outputs: Values, Timestamps (source timestamps of the values) and Count.
+func (cf *OPCFuncs) HistoryRead(f *Funcs) {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		f.iLog.PerformanceWithDuration("engine.funcs.OPCFuncs.HistoryRead", elapsed)
+	}()
+
+	_, _, inputs := f.SetInputs()
+	conn := cf.connection(inputs)
+
+	nodeID := opcString(opcInput(inputs, "NodeID"))
+	if nodeID == "" {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s has no NodeID input", f.Fobj.Name), nil))
+	}
+	from, err := opcTime(opcInput(inputs, "StartTime"))
+	if err != nil {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s has an invalid StartTime input", f.Fobj.Name), err))
+	}
+	to, err := opcTime(opcInput(inputs, "EndTime"))
+	if err != nil {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s has an invalid EndTime input", f.Fobj.Name), err))
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	maxValues, _ := strconv.ParseUint(opcString(opcInput(inputs, "MaxValues")), 10, 32)
+
+	f.iLog.Debug(fmt.Sprintf("OPC history read of node %s from %v to %v", nodeID, from, to))
+	dataValues, err := conn.ReadHistory(nodeID, from, to, uint32(maxValues))
+	if err != nil {
+		panic(opcError("history read", nodeID, err))
+	}
+
+	values := make([]interface{}, 0, len(dataValues))
+	timestamps := make([]interface{}, 0, len(dataValues))
+	for _, dataValue := range dataValues {
+		values = append(values, cf.dataValue(f, "history read", nodeID, dataValue))
+		timestamps = append(timestamps, dataValue.SourceTimestamp)
+	}
+
+	outputs := make(map[string]interface{})
+	outputs["Values"] = values
+	outputs["Timestamps"] = timestamps
+	outputs["Count"] = len(values)
+	f.SetOutputs(opcTypedOutputs(f, outputs))
+}
+
+// connection returns the OPC UA client of the Client input
+func (cf *OPCFuncs) connection(inputs map[string]interface{}) OPCConnection {
+	name := opcString(opcInput(inputs, "Client"))
+	conn, exists := GetOPCConnection(name)
+	if !exists {
+		panic(types.NewDeviceError("connect", fmt.Sprintf("OPC UA client %s is not connected", name), nil).
+			WithDetail("client", name))
+	}
+	return conn
+}
+
+// dataValue returns the value of a data value and fails on a bad status code
+func (cf *OPCFuncs) dataValue(f *Funcs, operation, nodeID string, dataValue *ua.DataValue) interface{} {
+	if dataValue == nil {
+		return nil
+	}
+	if dataValue.Status&ua.StatusBad != 0 {
+		panic(opcStatusError(operation, nodeID, dataValue.Status))
+	}
+	if dataValue.Status&ua.StatusUncertain != 0 {
+		f.iLog.Warn(fmt.Sprintf("OPC %s of node %s returned the uncertain status %s", operation, nodeID, opcStatusName(dataValue.Status)))
+	}
+	if dataValue.Value == nil {
+		return nil
+	}
+	return dataValue.Value.Value()
+}
+
+// opcStatusError returns the device error of a bad OPC UA status code
+func opcStatusError(operation, nodeID string, status ua.StatusCode) *types.BPMError {
+	return types.NewDeviceError(operation, fmt.Sprintf("OPC %s of node %s failed with status %s", operation, nodeID, opcStatusName(status)), status).
+		WithDetail("node_id", nodeID).
+		WithDetail("status_code", fmt.Sprintf("0x%08X", uint32(status))).
+		WithDetail("status", opcStatusName(status))
+}
+
+// opcError returns the device error of a failed OPC UA request, with the status code if the server returned one
+func opcError(operation, nodeID string, err error) *types.BPMError {
+	var status ua.StatusCode
+	if errors.As(err, &status) {
+		return opcStatusError(operation, nodeID, status)
+	}
+	return types.NewDeviceError(operation, fmt.Sprintf("OPC %s of %s failed: %v", operation, nodeID, err), err).
+		WithDetail("node_id", nodeID)
+}
+
+func opcStatusName(status ua.StatusCode) string {
+	if desc, ok := ua.StatusCodes[status]; ok {
+		return desc.Name
+	}
+	return fmt.Sprintf("0x%08X", uint32(status))
+}
+
+// opcInput returns an input by its name ignoring the case
+func opcInput(inputs map[string]interface{}, name string) interface{} {
+	if value, exists := inputs[name]; exists {
+		return value
+	}
+	for key, value := range inputs {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+func opcString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", value))
+}
+
+// opcList returns the elements of a list input, a single value is a list with one element
+func opcList(value interface{}) []interface{} {
+	if value == nil {
+		return []interface{}{}
+	}
+	if _, ok := value.([]byte); ok {
+		return []interface{}{value}
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []interface{}{value}
+	}
+	list := make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		list[i] = v.Index(i).Interface()
+	}
+	return list
+}
+
+func opcStringList(value interface{}) []string {
+	list := []string{}
+	for _, item := range opcList(value) {
+		if s := opcString(item); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// opcWriteValue converts the engine value types to values OPC UA variants can hold
+func opcWriteValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case types.DecimalValue:
+		return v.Float64()
+	case types.DurationValue:
+		return float64(time.Duration(v).Milliseconds())
+	case types.DateValue, types.TimeValue:
+		return fmt.Sprintf("%v", v)
+	}
+	return value
+}
+
+func opcTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	}
+	s := opcString(value)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", types.DateFormat} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s", s)
+}
+
+// opcTypedOutputs converts the outputs to the data types of the declared function outputs,
+// OPC UA servers return values like int16 or float32 the rest of the engine does not work with
+func opcTypedOutputs(f *Funcs, outputs map[string]interface{}) map[string]interface{} {
+	for _, output := range f.Fobj.Outputs {
+		value, exists := outputs[output.Name]
+		if !exists || value == nil {
+			continue
+		}
+		if output.List {
+			list := opcList(value)
+			for i := range list {
+				list[i] = opcTypedValue(list[i], output.Datatype)
+			}
+			outputs[output.Name] = list
+			continue
+		}
+		outputs[output.Name] = opcTypedValue(value, output.Datatype)
+	}
+	return outputs
+}
+
+func opcTypedValue(value interface{}, datatype types.DataType) interface{} {
+	v := reflect.ValueOf(value)
+	switch datatype {
+	case types.String:
+		return fmt.Sprintf("%v", value)
+	case types.Integer:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return int(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			return int(v.Float())
+		}
+	case types.Float:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(v.Uint())
+		case reflect.Float32:
+			// format the float32 so 0.1 does not become 0.10000000149011612
+			f, _ := strconv.ParseFloat(strconv.FormatFloat(v.Float(), 'g', -1, 32), 64)
+			return f
+		}
+	case types.Bool:
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b
+			}
+		}
+	}
+	return value
+}
+
+func opcField(name, description string, datatype types.DataType, list, required bool) types.SchemaField {
+	return types.SchemaField{Name: name, Datatype: datatype, List: list, Required: required, Description: description}
+}
+
+// opcRequiredInputs checks that the function maps the required inputs of its type
+func opcRequiredInputs(names ...string) ValidatorFunc {
+	return func(fobj *types.Function) error {
+		missing := []string{}
+		for _, name := range names {
+			found := false
+			for _, input := range fobj.Inputs {
+				if strings.EqualFold(input.Name, name) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("misses the inputs %s", strings.Join(missing, ", "))
+		}
+		return nil
+	}
+}
+
+func init() {
+	client := opcField("Client", "Name of the connected OPC UA client", types.String, false, true)
+
+	MustRegisterFunctionType(FunctionTypeDescriptor{
+		Name:             "OPCRead",
+		Label:            "OPC Read",
+		Category:         "Equipment",
+		Description:      "Reads node values from an OPC UA server",
+		Builtin:          true,
+		Functype:         -1,
+		ExternalEffect:   true,
+		Nondeterministic: true,
+		Inputs: []types.SchemaField{
+			client,
+			opcField("NodeID", "Node id or list of node ids, like ns=2;s=Line1.Speed", types.String, true, true),
+		},
+		Outputs: []types.SchemaField{
+			opcField("Value", "Value of the first node", types.Object, false, false),
+			opcField("Values", "Values by node id", types.Object, false, false),
+			opcField("SourceTimestamp", "Source timestamp of the first node", types.DateTime, false, false),
+		},
+	}, ExecutorFunc(func(f *Funcs) { (&OPCFuncs{}).Read(f) }), opcRequiredInputs("Client", "NodeID"))
+
+	MustRegisterFunctionType(FunctionTypeDescriptor{
+		Name:             "OPCWrite",
+		Label:            "OPC Write",
+		Category:         "Equipment",
+		Description:      "Writes node values to an OPC UA server",
+		Builtin:          true,
+		Functype:         -1,
+		ExternalEffect:   true,
+		Nondeterministic: true,
+		Inputs: []types.SchemaField{
+			client,
+			opcField("NodeID", "Node id or list of node ids", types.String, true, true),
+			opcField("Value", "Value or list of values in the order of the node ids", types.Object, true, true),
+		},
+		Outputs: []types.SchemaField{
+			opcField("StatusCode", "Status code of the first node", types.Integer, false, false),
+			opcField("StatusCodes", "Status codes by node id", types.Object, false, false),
+		},
+	}, ExecutorFunc(func(f *Funcs) { (&OPCFuncs{}).Write(f) }), opcRequiredInputs("Client", "NodeID", "Value"))
+
+	MustRegisterFunctionType(FunctionTypeDescriptor{
+		Name:             "OPCCallMethod",
+		Label:            "OPC Call Method",
+		Category:         "Equipment",
+		Description:      "Calls a method on an OPC UA server",
+		Builtin:          true,
+		Functype:         -1,
+		ExternalEffect:   true,
+		Nondeterministic: true,
+		Inputs: []types.SchemaField{
+			client,
+			opcField("ObjectID", "Node id of the object", types.String, false, true),
+			opcField("MethodID", "Node id of the method", types.String, false, true),
+			opcField("Arguments", "Input arguments of the method", types.Object, true, false),
+		},
+		Outputs: []types.SchemaField{
+			opcField("Outputs", "Output arguments of the method", types.Object, true, false),
+			opcField("Output1", "First output argument, Output2 the second and so on", types.Object, false, false),
+		},
+	}, ExecutorFunc(func(f *Funcs) { (&OPCFuncs{}).CallMethod(f) }), opcRequiredInputs("Client", "ObjectID", "MethodID"))
+
+	MustRegisterFunctionType(FunctionTypeDescriptor{
+		Name:             "OPCHistoryRead",
+		Label:            "OPC History Read",
+		Category:         "Equipment",
+		Description:      "Reads the raw history of a node from an OPC UA server",
+		Builtin:          true,
+		Functype:         -1,
+		ExternalEffect:   true,
+		Nondeterministic: true,
+		Inputs: []types.SchemaField{
+			client,
+			opcField("NodeID", "Node id", types.String, false, true),
+			opcField("StartTime", "Start of the time range", types.DateTime, false, true),
+			opcField("EndTime", "End of the time range, now if empty", types.DateTime, false, false),
+			opcField("MaxValues", "Maximum number of values, 0 for all", types.Integer, false, false),
+		},
+		Outputs: []types.SchemaField{
+			opcField("Values", "Historical values", types.Object, true, false),
+			opcField("Timestamps", "Source timestamps of the values", types.DateTime, true, false),
+			opcField("Count", "Number of values", types.Integer, false, false),
+		},
+	}, ExecutorFunc(func(f *Funcs) { (&OPCFuncs{}).HistoryRead(f) }), opcRequiredInputs("Client", "NodeID", "StartTime"))
+}
@@ -0,0 +1,149 @@
+package funcs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+type fakeOPCConnection struct {
+	values  map[string]*ua.DataValue
+	written map[string]interface{}
+	status  ua.StatusCode
+}
+
+func (c *fakeOPCConnection) ReadValues(nodeIDs []string) ([]*ua.DataValue, error) {
+	results := make([]*ua.DataValue, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		results[i] = c.values[nodeID]
+	}
+	return results, nil
+}
+
+func (c *fakeOPCConnection) BatchWrite(nodeValues map[string]interface{}) (map[string]ua.StatusCode, error) {
+	results := map[string]ua.StatusCode{}
+	for nodeID, value := range nodeValues {
+		c.written[nodeID] = value
+		results[nodeID] = c.status
+	}
+	return results, nil
+}
+
+func (c *fakeOPCConnection) CallMethod(objectID, methodID string, inputArgs ...interface{}) ([]interface{}, error) {
+	return nil, ua.StatusBadMethodInvalid
+}
+
+func (c *fakeOPCConnection) ReadHistory(nodeID string, startTime, endTime time.Time, maxValues uint32) ([]*ua.DataValue, error) {
+	return nil, nil
+}
+
+func opcDeviceError(t *testing.T, run func()) *types.BPMError {
+	t.Helper()
+	var bpmErr *types.BPMError
+	func() {
+		defer func() {
+			bpmErr, _ = recover().(*types.BPMError)
+		}()
+		run()
+	}()
+	if bpmErr == nil || bpmErr.Category != types.ErrorCategoryDevice {
+		t.Fatalf("expected a device error, got %v", bpmErr)
+	}
+	return bpmErr
+}
+
+func TestOPCFuncs_Read(t *testing.T) {
+	conn := &fakeOPCConnection{values: map[string]*ua.DataValue{
+		"ns=2;s=Speed": {Value: ua.MustVariant(float32(0.1)), Status: ua.StatusOK},
+		"ns=2;s=Fault": {Status: ua.StatusBadNodeIDUnknown},
+	}}
+	RegisterOPCConnection("line1", conn)
+	defer UnregisterOPCConnection("line1")
+
+	f := newTestFuncs(types.Function{
+		Name: "ReadSpeed",
+		Inputs: []types.Input{
+			{Name: "Client", Value: "line1"},
+			{Name: "NodeID", Value: "ns=2;s=Speed"},
+		},
+		Outputs: []types.Output{{Name: "Value", Datatype: types.Float}},
+	})
+	(&OPCFuncs{}).Read(f)
+	if got := f.FunctionOutputs[0]["Value"]; got != 0.1 {
+		t.Errorf("Value = %v (%T), want 0.1", got, got)
+	}
+
+	f = newTestFuncs(types.Function{
+		Name: "ReadFault",
+		Inputs: []types.Input{
+			{Name: "Client", Value: "line1"},
+			{Name: "NodeID", Value: "ns=2;s=Fault"},
+		},
+	})
+	bpmErr := opcDeviceError(t, func() { (&OPCFuncs{}).Read(f) })
+	if bpmErr.Details["node_id"] != "ns=2;s=Fault" || bpmErr.Details["status"] != "StatusBadNodeIDUnknown" {
+		t.Errorf("Details = %v", bpmErr.Details)
+	}
+}
+
+func TestOPCFuncs_WriteAndCall(t *testing.T) {
+	conn := &fakeOPCConnection{written: map[string]interface{}{}, status: ua.StatusBadNotWritable}
+	RegisterOPCConnection("line1", conn)
+	defer UnregisterOPCConnection("line1")
+
+	f := newTestFuncs(types.Function{
+		Name: "WriteSetpoint",
+		Inputs: []types.Input{
+			{Name: "Client", Value: "line1"},
+			{Name: "NodeID", Value: "ns=2;s=Setpoint"},
+			{Name: "Value", Value: "42", Datatype: types.Integer},
+		},
+	})
+	bpmErr := opcDeviceError(t, func() { (&OPCFuncs{}).Write(f) })
+	if bpmErr.Details["status_code"] != "0x803B0000" {
+		t.Errorf("status_code = %s, want 0x803B0000", bpmErr.Details["status_code"])
+	}
+	if conn.written["ns=2;s=Setpoint"] != int64(42) {
+		t.Errorf("written value = %v (%T), want 42", conn.written["ns=2;s=Setpoint"], conn.written["ns=2;s=Setpoint"])
+	}
+
+	f = newTestFuncs(types.Function{
+		Name: "Start",
+		Inputs: []types.Input{
+			{Name: "Client", Value: "line1"},
+			{Name: "ObjectID", Value: "ns=2;s=Line1"},
+			{Name: "MethodID", Value: "ns=2;s=Line1.Start"},
+		},
+	})
+	bpmErr = opcDeviceError(t, func() { (&OPCFuncs{}).CallMethod(f) })
+	if bpmErr.Details["status"] != "StatusBadMethodInvalid" || bpmErr.Details["object_id"] != "ns=2;s=Line1" {
+		t.Errorf("Details = %v", bpmErr.Details)
+	}
+
+	f = newTestFuncs(types.Function{
+		Name:   "ReadOther",
+		Inputs: []types.Input{{Name: "Client", Value: "line2"}, {Name: "NodeID", Value: "ns=2;s=Speed"}},
+	})
+	opcDeviceError(t, func() { (&OPCFuncs{}).Read(f) })
+}
+
+func TestValidateFunctions_OPCRequiredInputs(t *testing.T) {
+	tcode := types.TranCode{Name: "Equipment", Functiongroups: []types.FuncGroup{{
+		Name: "FG1",
+		Functions: []types.Function{
+			{Name: "Read", Typename: "OPCRead", Inputs: []types.Input{{Name: "Client"}, {Name: "NodeID"}}},
+			{Name: "Write", Typename: "OPCWrite", Inputs: []types.Input{{Name: "Client"}}},
+		},
+	}}}
+	err := ValidateFunctions(&tcode)
+	bpmErr, ok := err.(*types.BPMError)
+	if !ok {
+		t.Fatalf("ValidateFunctions() = %v, want a validation error", err)
+	}
+	if len(bpmErr.Details) != 1 || bpmErr.Details["FG1.Write"] != "misses the inputs NodeID, Value" {
+		t.Errorf("Details = %v", bpmErr.Details)
+	}
+}
@@ -0,0 +1,252 @@
+package funcs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-stomp/stomp"
+	"github.com/google/uuid"
+	"github.com/mdaxf/iac/com"
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// OUTBOX DESIGN: a message function in the outbox mode does not publish while the transaction code runs. It writes
+// the message to the outbox table in the transaction of the transaction code, so a rollback drops the message with
+// the rest of the data and a commit keeps it even when the channel is down. The relay of the job system publishes the
+// committed messages with the publishers registered here, the direct mode of the message functions uses the same
+// publishers. Messages with the same key on the same channel and topic are published in the order they were written.
+
+// OutboxWriter writes a message to the outbox in the transaction of the transaction code, it is registered by the job system
+type OutboxWriter func(tx *sql.Tx, message *types.OutboundMessage) error
+
+// MessagePublisher publishes a message on its channel
+type MessagePublisher func(ctx context.Context, message *types.OutboundMessage) error
+
+var (
+	outboxMu          sync.RWMutex
+	outboxWriter      OutboxWriter
+	messagePublishers = map[string]MessagePublisher{
+		types.MessageChannelBus:   publishToMessageBus,
+		types.MessageChannelKafka: publishToKafka,
+		types.MessageChannelMQTT:  publishToMQTT,
+		types.MessageChannelAMQP:  publishToAMQP,
+	}
+)
+
+// RegisterOutboxWriter sets the writer of the messages of the functions in the outbox mode
+func RegisterOutboxWriter(writer OutboxWriter) {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	outboxWriter = writer
+}
+
+// RegisterMessagePublisher sets the publisher of a channel, replacing the built in one
+func RegisterMessagePublisher(channel string, publisher MessagePublisher) {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	messagePublishers[channel] = publisher
+}
+
+// PublishMessage publishes the message with the publisher of its channel
+func PublishMessage(ctx context.Context, message *types.OutboundMessage) error {
+	outboxMu.RLock()
+	publisher, ok := messagePublishers[message.Channel]
+	outboxMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("there is no publisher for the message channel %s", message.Channel)
+	}
+	return publisher(ctx, message)
+}
+
+// sendToOutbox writes the message of the function to the outbox when the function is in the outbox mode.
+// It reports whether the function is done with the message, a message that cannot go to the outbox fails the
+// function and with it the transaction code, otherwise the function publishes the message directly.
+func (f *Funcs) sendToOutbox(channel string, topic string, server string, data map[string]interface{}) bool {
+	written, err := f.writeToOutbox(channel, topic, server, data)
+	if err != nil {
+		f.ErrorMessage = err.Error()
+		f.CancelExecution(f.ErrorMessage)
+		return true
+	}
+	return written
+}
+
+// writeToOutbox writes the message to the outbox and reports whether the function is in the outbox mode
+func (f *Funcs) writeToOutbox(channel string, topic string, server string, data map[string]interface{}) (bool, error) {
+	delivery, err := types.ParseMessageDelivery(f.Fobj.Content)
+	if err != nil {
+		return false, types.NewValidationError(fmt.Sprintf("Function %s has an invalid message delivery", f.Fobj.Name), err)
+	}
+	if delivery.Mode != types.MessageDeliveryOutbox {
+		return false, nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return true, types.NewExecutionError(fmt.Sprintf("Failed to serialize the message of function %s", f.Fobj.Name), err)
+	}
+
+	message := &types.OutboundMessage{
+		MessageID:   uuid.New().String(),
+		Channel:     channel,
+		Topic:       topic,
+		Server:      server,
+		Payload:     string(payload),
+		MaxAttempts: delivery.MaxAttempts,
+	}
+	if delivery.Key != "" {
+		value, ok := data[delivery.Key]
+		if !ok {
+			return true, types.NewValidationError(fmt.Sprintf("Function %s has no input %s for the message key", f.Fobj.Name, delivery.Key), nil)
+		}
+		message.Key = fmt.Sprint(value)
+	}
+	if identity := ExecutionIdentityFromContext(f.Ctx); identity != nil {
+		message.ExecutionID = identity.ExecutionID
+		message.CorrelationID = identity.CorrelationID
+		message.TranCodeName = identity.TranCodeName
+	}
+	message.CreatedBy, _ = f.SystemSession["UserNo"].(string)
+
+	outboxMu.RLock()
+	writer := outboxWriter
+	outboxMu.RUnlock()
+
+	if writer == nil {
+		return true, types.NewExecutionError(fmt.Sprintf("No outbox for the message of function %s", f.Fobj.Name), nil)
+	}
+	if f.DBTx == nil {
+		return true, types.NewExecutionError(fmt.Sprintf("The message of function %s needs the transaction of the transaction code for the outbox", f.Fobj.Name), nil)
+	}
+	if err := writer(f.DBTx, message); err != nil {
+		return true, types.NewExecutionError(fmt.Sprintf("Failed to write the message of function %s to the outbox", f.Fobj.Name), err)
+	}
+
+	f.iLog.Info(fmt.Sprintf("Wrote message %s of function %s for topic %s to the %s outbox", message.MessageID, f.Fobj.Name, topic, channel))
+	f.SetOutputs(map[string]interface{}{"MessageID": message.MessageID})
+	return true, nil
+}
+
+func publishToMessageBus(ctx context.Context, message *types.OutboundMessage) error {
+	if com.IACMessageBusClient == nil {
+		return fmt.Errorf("the message bus is not connected")
+	}
+
+	select {
+	case result := <-com.IACMessageBusClient.Invoke("send", message.Topic, message.Payload, ""):
+		return result.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	publisherMu    sync.Mutex
+	kafkaProducers = map[string]sarama.SyncProducer{}
+	mqttClients    = map[string]mqtt.Client{}
+	stompConns     = map[string]*stomp.Conn{}
+)
+
+func publishToKafka(ctx context.Context, message *types.OutboundMessage) error {
+	if message.Server == "" {
+		return fmt.Errorf("the kafka message for topic %s has no server", message.Topic)
+	}
+
+	publisherMu.Lock()
+	producer, ok := kafkaProducers[message.Server]
+	if !ok {
+		config := sarama.NewConfig()
+		config.Producer.Return.Successes = true
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		var err error
+		if producer, err = sarama.NewSyncProducer([]string{message.Server}, config); err != nil {
+			publisherMu.Unlock()
+			return err
+		}
+		kafkaProducers[message.Server] = producer
+	}
+	publisherMu.Unlock()
+
+	producerMessage := &sarama.ProducerMessage{Topic: message.Topic, Value: sarama.StringEncoder(message.Payload)}
+	if message.Key != "" {
+		producerMessage.Key = sarama.StringEncoder(message.Key)
+	}
+	if _, _, err := producer.SendMessage(producerMessage); err != nil {
+		publisherMu.Lock()
+		delete(kafkaProducers, message.Server)
+		publisherMu.Unlock()
+		producer.Close()
+		return err
+	}
+	return nil
+}
+
+func publishToMQTT(ctx context.Context, message *types.OutboundMessage) error {
+	if message.Server == "" {
+		return fmt.Errorf("the MQTT message for topic %s has no broker", message.Topic)
+	}
+
+	publisherMu.Lock()
+	client, ok := mqttClients[message.Server]
+	if !ok {
+		opts := mqtt.NewClientOptions()
+		opts.AddBroker(message.Server)
+		opts.SetClientID(uuid.New().String())
+		opts.SetAutoReconnect(true)
+		client = mqtt.NewClient(opts)
+		// the client is kept only once it is connected, the next message dials the broker again
+		token := client.Connect()
+		if !token.WaitTimeout(30 * time.Second) {
+			publisherMu.Unlock()
+			client.Disconnect(0)
+			return fmt.Errorf("the MQTT broker %s did not accept the connection", message.Server)
+		}
+		if err := token.Error(); err != nil {
+			publisherMu.Unlock()
+			return err
+		}
+		mqttClients[message.Server] = client
+	}
+	publisherMu.Unlock()
+
+	// QoS 1, the broker acknowledges the message
+	token := client.Publish(message.Topic, 1, false, message.Payload)
+	if !token.WaitTimeout(30 * time.Second) {
+		return fmt.Errorf("the MQTT broker %s did not acknowledge the message for topic %s", message.Server, message.Topic)
+	}
+	return token.Error()
+}
+
+func publishToAMQP(ctx context.Context, message *types.OutboundMessage) error {
+	if message.Server == "" {
+		return fmt.Errorf("the ActiveMQ message for topic %s has no server", message.Topic)
+	}
+
+	publisherMu.Lock()
+	conn, ok := stompConns[message.Server]
+	if !ok {
+		var err error
+		if conn, err = stomp.Dial("tcp", message.Server, stomp.ConnOpt.AcceptVersion(stomp.V12)); err != nil {
+			publisherMu.Unlock()
+			return err
+		}
+		stompConns[message.Server] = conn
+	}
+	publisherMu.Unlock()
+
+	// the send waits for the receipt of the broker
+	if err := conn.Send(message.Topic, "application/json", []byte(message.Payload), stomp.SendOpt.Receipt); err != nil {
+		publisherMu.Lock()
+		delete(stompConns, message.Server)
+		publisherMu.Unlock()
+		conn.Disconnect()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package funcs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+func newMQTTTestFuncs(content string) *Funcs {
+	f := newTestFuncs(types.Function{Name: "Notify", Functype: types.SendMessagebyMQTT, Content: content,
+		Inputs: []types.Input{{Name: "Topic", Value: "orders"}, {Name: "Server", Value: "tcp://broker:1883"},
+			{Name: "OrderNo", Value: "SO-1"}, {Name: "Qty", Datatype: types.Integer, Value: "2"}},
+		Outputs: []types.Output{{Name: "MessageID"}}})
+	f.Ctx = WithExecutionIdentity(f.Ctx, &types.ExecutionIdentity{ExecutionID: "exec", CorrelationID: "request", TranCodeName: "Ship"})
+	return f
+}
+
+// stubMessaging replaces the outbox writer and the MQTT publisher for the test and returns the messages they got
+func stubMessaging(t *testing.T) (written, published *[]*types.OutboundMessage) {
+	writer, publisher := outboxWriter, messagePublishers[types.MessageChannelMQTT]
+	t.Cleanup(func() {
+		RegisterOutboxWriter(writer)
+		RegisterMessagePublisher(types.MessageChannelMQTT, publisher)
+	})
+
+	written, published = &[]*types.OutboundMessage{}, &[]*types.OutboundMessage{}
+	RegisterOutboxWriter(func(tx *sql.Tx, message *types.OutboundMessage) error {
+		*written = append(*written, message)
+		return nil
+	})
+	RegisterMessagePublisher(types.MessageChannelMQTT, func(ctx context.Context, message *types.OutboundMessage) error {
+		*published = append(*published, message)
+		return nil
+	})
+	return written, published
+}
+
+func TestSendMessage_Outbox(t *testing.T) {
+	written, published := stubMessaging(t)
+
+	f := newMQTTTestFuncs(`{"delivery":"outbox","key":"OrderNo","maxattempts":4}`)
+	f.DBTx = &sql.Tx{}
+	f.Execute()
+	if len(*written) != 1 || len(*published) != 0 {
+		t.Fatalf("written = %v, published = %v, want the message in the outbox only", *written, *published)
+	}
+	message := (*written)[0]
+	if message.Channel != types.MessageChannelMQTT || message.Topic != "orders" || message.Server != "tcp://broker:1883" ||
+		message.Key != "SO-1" || message.MaxAttempts != 4 || message.CorrelationID != "request" || message.TranCodeName != "Ship" {
+		t.Errorf("message = %+v", message)
+	}
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(message.Payload), &payload); err != nil || payload["OrderNo"] != "SO-1" || payload["Topic"] != nil {
+		t.Errorf("payload = %s, want the inputs without the topic", message.Payload)
+	}
+	if outputs := f.FuncCachedVariables["Notify"].(map[string]interface{}); outputs["MessageID"] != message.MessageID {
+		t.Errorf("outputs = %v, want the id of the outbox message", outputs)
+	}
+}
+
+func TestSendMessage_OutboxSharesTransaction(t *testing.T) {
+	if outbox := newMQTTTestFuncs(`{"delivery":"outbox"}`); !SharesTransaction(&outbox.Fobj) {
+		t.Error("SharesTransaction() = false for a message function in the outbox mode")
+	}
+	if direct := newMQTTTestFuncs(""); SharesTransaction(&direct.Fobj) {
+		t.Error("SharesTransaction() = true for a message function in the direct mode")
+	}
+}
+
+func TestSendMessage_Direct(t *testing.T) {
+	written, published := stubMessaging(t)
+
+	newMQTTTestFuncs("").Execute()
+	if len(*written) != 0 || len(*published) != 1 || (*published)[0].Topic != "orders" {
+		t.Errorf("written = %v, published = %v, want the message published directly", *written, *published)
+	}
+}
+
+func TestSendMessage_OutboxWithoutTransaction(t *testing.T) {
+	written, published := stubMessaging(t)
+
+	f := newMQTTTestFuncs(`{"delivery":"outbox"}`)
+	f.Execute()
+	if f.ErrorMessage == "" || len(*written) != 0 || len(*published) != 0 {
+		t.Errorf("Execute() without a transaction: error = %q, written = %d, published = %d, want the function failed without a message",
+			f.ErrorMessage, len(*written), len(*published))
+	}
+}
+
+func TestPublishToMQTT_FailedConnect(t *testing.T) {
+	// nothing listens on the port, the connection is refused
+	message := &types.OutboundMessage{Channel: types.MessageChannelMQTT, Topic: "orders", Server: "tcp://127.0.0.1:1", Payload: "{}"}
+	if err := publishToMQTT(context.Background(), message); err == nil {
+		t.Fatalf("publishToMQTT() to a closed port = nil, want the connect error")
+	}
+	publisherMu.Lock()
+	_, cached := mqttClients[message.Server]
+	publisherMu.Unlock()
+	if cached {
+		t.Errorf("the client of the failed connect is kept, the next message would not dial the broker again")
+	}
+}
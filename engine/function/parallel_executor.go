@@ -3,6 +3,7 @@ package funcs
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -55,6 +56,11 @@ func (eg *ExecutionGraph) AddFunction(fn *types.Function, dependencies []string)
 	}
 }
 
+// SetFunctionExecutor sets the executor that runs the functions of the graph
+func (eg *ExecutionGraph) SetFunctionExecutor(executor FunctionExecutor) {
+	eg.funcExecutor = executor
+}
+
 // BuildExecutionOrder performs topological sort to determine parallel execution groups
 func (eg *ExecutionGraph) BuildExecutionOrder() error {
 	// Track in-degree (number of dependencies) for each node
@@ -79,6 +85,9 @@ func (eg *ExecutionGraph) BuildExecutionOrder() error {
 			}
 		}
 
+		// Keep the order within a level stable between executions
+		sort.Strings(currentLevel)
+
 		if len(currentLevel) == 0 {
 			// Circular dependency detected
 			return types.NewValidationError("Circular dependency detected in function execution graph", nil).
@@ -0,0 +1,447 @@
+package funcs
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// PYTHON WORKER DESIGN: Python functions run in pools of pre-started interpreter processes instead of a new
+// process per call. A worker runs pythonworker.py, which reads one JSON request per line from stdin and writes
+// one JSON response per line to stdout, so the user code is never pasted into generated source. With the
+// sandbox a worker runs its calls without the builtins that run code or look attributes up by a computed name,
+// rejects scripts using private, frame or code attributes, and only imports the allowed modules, as read-only
+// views. The classes of those modules are restored after every call, so a call cannot change the worker or
+// later calls of the pool. The allowed modules are imported when the worker starts, the memory and CPU rlimits
+// apply to every worker. The sandbox is a restricted interpreter, not an operating system boundary: workers of
+// pools with filesystem or network access can open files or sockets, and the process limit does not apply to
+// workers running as root, run the engine as an unprivileged user. The wall-clock limit is enforced here: a
+// worker that does not answer in time is killed and replaced. Workers that hit a limit, or reached their number
+// of calls, are replaced as well. The workers share a pool when they have the same sandbox settings.
+
+//go:embed pythonworker.py
+var pythonWorkerSource string
+
+const pythonWorkerProtocol = 1
+
+// DefaultPythonModules are the modules the Python functions can import in the sandbox
+var DefaultPythonModules = []string{
+	"base64", "collections", "copy", "datetime", "decimal", "fractions", "functools", "hashlib", "itertools",
+	"json", "math", "operator", "random", "re", "statistics", "string", "time", "uuid",
+}
+
+// PythonWorkerSettings are the installation settings of the Python workers
+type PythonWorkerSettings struct {
+	PythonPath     string   `json:"pythonpath"`
+	PoolSize       int      `json:"poolsize"`       // workers per pool
+	MaxCalls       int      `json:"maxcalls"`       // calls after which a worker is replaced, 0 for no limit
+	AllowedModules []string `json:"allowedmodules"` // modules allowed in addition to DefaultPythonModules
+}
+
+var (
+	pythonWorkerSettings = PythonWorkerSettings{PythonPath: "python3", PoolSize: 2, MaxCalls: 1000}
+	pythonWorkerPools    = map[string]*PythonWorkerPool{}
+	pythonWorkerPoolsMu  sync.Mutex
+)
+
+// ConfigurePythonWorkers sets the installation settings of the Python workers and stops the running workers,
+// the next calls start workers with the new settings
+func ConfigurePythonWorkers(settings PythonWorkerSettings) {
+	if settings.PythonPath == "" {
+		settings.PythonPath = "python3"
+	}
+	if settings.PoolSize <= 0 {
+		settings.PoolSize = 2
+	}
+
+	pythonWorkerPoolsMu.Lock()
+	defer pythonWorkerPoolsMu.Unlock()
+	pythonWorkerSettings = settings
+	for key, pool := range pythonWorkerPools {
+		pool.Close()
+		delete(pythonWorkerPools, key)
+	}
+}
+
+// pythonSandbox are the settings a worker is started with, the argument of pythonworker.py
+type pythonSandbox struct {
+	Sandbox    bool     `json:"sandbox"`
+	MemoryMB   int      `json:"memory"`
+	Modules    []string `json:"modules"`
+	FileSystem bool     `json:"filesystem"`
+	Network    bool     `json:"network"`
+}
+
+// pythonWorkerPoolFor returns the pool of the workers with the sandbox of the script configuration
+func pythonWorkerPoolFor(config *ScriptExecutionConfig) *PythonWorkerPool {
+	pythonWorkerPoolsMu.Lock()
+	defer pythonWorkerPoolsMu.Unlock()
+
+	modules := config.AllowedModules
+	if len(modules) == 0 {
+		modules = append(append([]string{}, DefaultPythonModules...), pythonWorkerSettings.AllowedModules...)
+	}
+	modules = append([]string{}, modules...)
+	sort.Strings(modules)
+	sandbox := pythonSandbox{
+		Sandbox:    config.SandboxEnabled,
+		MemoryMB:   config.MaxMemoryMB,
+		Modules:    modules,
+		FileSystem: config.AllowFileSystem,
+		Network:    config.AllowNetwork,
+	}
+	data, _ := json.Marshal(sandbox)
+	key := string(data)
+
+	pool, exists := pythonWorkerPools[key]
+	if !exists {
+		pool = NewPythonWorkerPool(pythonWorkerSettings.PythonPath, pythonWorkerSettings.PoolSize, pythonWorkerSettings.MaxCalls, key)
+		pythonWorkerPools[key] = pool
+	}
+	return pool
+}
+
+type pythonWorkerRequest struct {
+	ID      int64                  `json:"id"`
+	Mode    string                 `json:"mode"` // expr, script or compile
+	Code    string                 `json:"code"`
+	Inputs  map[string]interface{} `json:"inputs"`
+	Types   map[string]string      `json:"types"` // inputs JSON cannot keep the type of: datetime and float
+	Outputs []string               `json:"outputs"`
+	CPU     int                    `json:"cpu"` // CPU seconds
+}
+
+type pythonWorkerResponse struct {
+	ID        int64                  `json:"id"`
+	Ready     bool                   `json:"ready"`
+	Protocol  int                    `json:"protocol"`
+	Python    string                 `json:"python"`
+	Missing   []string               `json:"missing"`
+	Outputs   map[string]interface{} `json:"outputs"`
+	Stdout    string                 `json:"stdout"`
+	Error     string                 `json:"error"`
+	Kind      string                 `json:"kind"`
+	Traceback string                 `json:"traceback"`
+	Recycle   bool                   `json:"recycle"`
+}
+
+type pythonWorker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	done   chan struct{}
+	once   sync.Once
+	calls  int
+	dead   bool
+	stderr *tailBuffer
+}
+
+// tailBuffer keeps the end of the stderr of a worker for the error of a worker that died
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > 4096 {
+		b.data = b.data[len(b.data)-4096:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.data))
+}
+
+// PythonWorkerPool is a pool of Python workers with the same sandbox
+type PythonWorkerPool struct {
+	pythonPath string
+	size       int
+	maxCalls   int
+	sandbox    string
+
+	mu      sync.Mutex
+	idle    chan *pythonWorker
+	started int
+	closed  bool
+	nextID  int64
+}
+
+// NewPythonWorkerPool creates a pool of at most size workers started with the sandbox JSON of pythonworker.py
+func NewPythonWorkerPool(pythonPath string, size, maxCalls int, sandbox string) *PythonWorkerPool {
+	if size <= 0 {
+		size = 1
+	}
+	return &PythonWorkerPool{pythonPath: pythonPath, size: size, maxCalls: maxCalls, sandbox: sandbox, idle: make(chan *pythonWorker, size)}
+}
+
+// Warm starts the workers of the pool that are not running yet
+func (p *PythonWorkerPool) Warm() error {
+	for {
+		p.mu.Lock()
+		if p.closed || p.started >= p.size {
+			p.mu.Unlock()
+			return nil
+		}
+		p.started++
+		p.mu.Unlock()
+
+		worker, err := p.start()
+		if err != nil {
+			p.mu.Lock()
+			p.started--
+			p.mu.Unlock()
+			return err
+		}
+		p.release(worker)
+	}
+}
+
+// Close stops the idle workers, the busy workers stop when their call ends
+func (p *PythonWorkerPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for {
+		select {
+		case worker := <-p.idle:
+			worker.stop()
+		default:
+			return
+		}
+	}
+}
+
+func (p *PythonWorkerPool) start() (*pythonWorker, error) {
+	cmd := exec.Command(p.pythonPath, "-I", "-u", "-c", pythonWorkerSource, p.sandbox)
+	cmd.Env = pythonWorkerEnv()
+	cmd.Dir = os.TempDir()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	worker := &pythonWorker{cmd: cmd, stdin: stdin, lines: make(chan []byte, 1), done: make(chan struct{}), stderr: &tailBuffer{}}
+	cmd.Stderr = worker.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the Python worker %s: %v", p.pythonPath, err)
+	}
+
+	go func() {
+		reader := bufio.NewReaderSize(stdout, 64*1024)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				select {
+				case worker.lines <- line:
+				case <-worker.done:
+				}
+			}
+			if err != nil {
+				close(worker.lines)
+				cmd.Wait()
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	ready, err := worker.read(ctx)
+	if err != nil {
+		worker.stop()
+		return nil, err
+	}
+	if !ready.Ready || ready.Protocol != pythonWorkerProtocol {
+		worker.stop()
+		return nil, fmt.Errorf("the Python worker speaks protocol %d, expected %d", ready.Protocol, pythonWorkerProtocol)
+	}
+	return worker, nil
+}
+
+// pythonWorkerEnv returns the environment of the workers, only what is needed to find and start the interpreter,
+// so the scripts do not see the secrets in the environment of the server
+func pythonWorkerEnv() []string {
+	env := []string{"PYTHONIOENCODING=utf-8", "PYTHONDONTWRITEBYTECODE=1"}
+	for _, variable := range os.Environ() {
+		name := strings.SplitN(variable, "=", 2)[0]
+		switch {
+		case name == "PATH", name == "HOME", name == "LANG", strings.EqualFold(name, "SYSTEMROOT"), strings.HasPrefix(name, "PYENV_"):
+			env = append(env, variable)
+		}
+	}
+	return env
+}
+
+// read returns the next response of the worker, it kills the worker if the context ends first
+func (w *pythonWorker) read(ctx context.Context) (*pythonWorkerResponse, error) {
+	select {
+	case line, ok := <-w.lines:
+		if !ok {
+			w.dead = true
+			return nil, fmt.Errorf("the Python worker stopped: %s", w.stderr.String())
+		}
+		response := &pythonWorkerResponse{}
+		if err := json.Unmarshal(line, response); err != nil {
+			w.stop()
+			return nil, fmt.Errorf("invalid response of the Python worker: %v", err)
+		}
+		return response, nil
+	case <-ctx.Done():
+		w.stop()
+		return nil, ctx.Err()
+	}
+}
+
+func (w *pythonWorker) stop() {
+	w.dead = true
+	w.once.Do(func() {
+		close(w.done)
+		w.stdin.Close()
+		if w.cmd.Process != nil {
+			w.cmd.Process.Kill()
+		}
+	})
+}
+
+// acquire returns an idle worker, starts a new one if the pool is not full or waits for one
+func (p *PythonWorkerPool) acquire(ctx context.Context) (*pythonWorker, error) {
+	for {
+		select {
+		case worker := <-p.idle:
+			return worker, nil
+		default:
+		}
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("the Python worker pool is closed")
+		}
+		if p.started < p.size {
+			p.started++
+			p.mu.Unlock()
+			worker, err := p.start()
+			if err != nil {
+				p.mu.Lock()
+				p.started--
+				p.mu.Unlock()
+				return nil, err
+			}
+			return worker, nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case worker := <-p.idle:
+			return worker, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			// a replaced worker frees its place without going through the idle channel
+		}
+	}
+}
+
+// release returns the worker to the pool or stops it if it cannot take more calls
+func (p *PythonWorkerPool) release(worker *pythonWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if worker.dead || p.closed || (p.maxCalls > 0 && worker.calls >= p.maxCalls) {
+		worker.stop()
+		p.started--
+		return
+	}
+	p.idle <- worker
+}
+
+// execute runs the request on a worker of the pool
+func (p *PythonWorkerPool) execute(ctx context.Context, request *pythonWorkerRequest) (*pythonWorkerResponse, error) {
+	worker, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(worker)
+
+	request.ID = atomic.AddInt64(&p.nextID, 1)
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	worker.calls++
+	if _, err := worker.stdin.Write(append(data, '\n')); err != nil {
+		worker.stop()
+		return nil, fmt.Errorf("failed to send the call to the Python worker: %v", err)
+	}
+
+	response, err := worker.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if response.ID != request.ID {
+		worker.stop()
+		return nil, fmt.Errorf("the Python worker answered call %d instead of %d", response.ID, request.ID)
+	}
+	if response.Recycle {
+		worker.stop()
+	}
+	return response, nil
+}
+
+// pythonWorkerInputs returns the inputs as JSON values and the types JSON loses
+func pythonWorkerInputs(inputs map[string]interface{}) (map[string]interface{}, map[string]string) {
+	values := make(map[string]interface{}, len(inputs))
+	valueTypes := map[string]string{}
+	for name, value := range inputs {
+		switch v := value.(type) {
+		case time.Time:
+			values[name] = v.Format("2006-01-02T15:04:05.999999-07:00")
+			valueTypes[name] = "datetime"
+		case float32, float64:
+			values[name] = v
+			valueTypes[name] = "float"
+		default:
+			values[name] = v
+		}
+	}
+	return values, valueTypes
+}
+
+// pythonWorkerError converts the error of a call to the error of the function
+func pythonWorkerError(scriptType string, response *pythonWorkerResponse) *types.BPMError {
+	var err *types.BPMError
+	switch response.Kind {
+	case "sandbox", "import", "syntax":
+		err = types.NewValidationError(fmt.Sprintf("%s is not allowed to run: %s", scriptType, response.Error), nil)
+	default:
+		err = types.NewScriptError(scriptType, fmt.Sprintf("%s failed: %s", scriptType, response.Error), nil)
+	}
+	err.WithDetail("kind", response.Kind)
+	if response.Traceback != "" {
+		err.WithDetail("traceback", response.Traceback)
+	}
+	return err
+}
@@ -0,0 +1,309 @@
+# IAC Python worker.
+#
+# Runs the Python functions of the engine one call at a time and speaks JSON lines over stdin and stdout.
+# The first line the worker writes is {"ready": true, "protocol": 1, ...}. Every request line
+#   {"id": 1, "mode": "expr" | "script" | "compile", "code": "...", "inputs": {...}, "types": {...},
+#    "outputs": [...], "cpu": 10}
+# gets one response line
+#   {"id": 1, "outputs": {...}, "stdout": "..."} or {"id": 1, "error": "...", "kind": "...", "traceback": "...", "recycle": false}
+# The configuration is the JSON argument of the worker:
+#   {"sandbox": true, "memory": 128, "modules": [...], "filesystem": false, "network": false}
+
+import ast
+import builtins
+import datetime
+import decimal
+import io
+import json
+import signal
+import sys
+import traceback
+import types
+
+try:
+    import resource
+except ImportError:  # not available on Windows, the limits are not applied there
+    resource = None
+
+PROTOCOL = 1
+MB = 1024 * 1024
+
+# the builtins that run code, open files or look attributes up by a name built at runtime, which the check of the
+# source cannot see
+BLOCKED_BUILTINS = ("open", "input", "breakpoint", "exit", "quit", "exec", "eval", "compile", "help", "globals",
+                    "locals", "vars", "getattr", "setattr", "delattr", "type", "object")
+ALLOWED_DUNDERS = ("__name__", "__doc__")
+# the attributes that lead from generators, coroutines, tracebacks and classes to frames, code and the globals of
+# the worker
+BLOCKED_ATTRIBUTES = ("gi_frame", "gi_code", "gi_yieldfrom", "cr_frame", "cr_code", "cr_await", "cr_origin",
+                      "ag_frame", "ag_code", "ag_await", "f_back", "f_globals", "f_locals", "f_builtins", "f_code",
+                      "tb_frame", "tb_next", "mro")
+# the members of the allowed modules that look attributes up by a name given at runtime
+HIDDEN_MODULE_ATTRIBUTES = {"operator": ("attrgetter", "methodcaller"), "string": ("Formatter",)}
+
+# the worker's own references, taken before any user code runs
+write_json = json.dumps
+read_json = json.loads
+stdin = sys.stdin
+stdout = sys.stdout
+
+
+class SandboxViolation(Exception):
+    pass
+
+
+class CPULimitExceeded(Exception):
+    pass
+
+
+class ReadOnlyModule:
+    """The view of an allowed module the user code imports. It has the public members of the module, the
+    members cannot be replaced or deleted, so a script cannot change the modules of the worker or of later calls."""
+
+    __slots__ = ("_name", "_members")
+
+    def __init__(self, name, members):
+        object.__setattr__(self, "_name", name)
+        object.__setattr__(self, "_members", members)
+
+    def __getattr__(self, name):
+        if name == "__all__":
+            return tuple(sorted(self._members))
+        try:
+            return self._members[name]
+        except KeyError:
+            raise AttributeError("module %s has no attribute %s" % (self._name, name)) from None
+
+    def __setattr__(self, name, value):
+        raise SandboxViolation("module %s is read-only" % self._name)
+
+    def __delattr__(self, name):
+        raise SandboxViolation("module %s is read-only" % self._name)
+
+    def __dir__(self):
+        return sorted(self._members)
+
+    def __repr__(self):
+        return "<module %s>" % self._name
+
+
+def set_limit(limit, soft, hard=None):
+    if resource is None:
+        return
+    try:
+        resource.setrlimit(limit, (soft, soft if hard is None else hard))
+    except (ValueError, OSError):
+        pass
+
+
+def on_cpu_limit(signum, frame):
+    raise CPULimitExceeded("the script exceeded its CPU time")
+
+
+class Worker:
+    def __init__(self, config):
+        self.config = config
+        self.sandbox = config.get("sandbox", True)
+        self.allowed = set(config.get("modules") or [])
+        self.missing = []
+        self.proxies = {}
+
+        # pre-import the allowed modules, the sandbox cannot open their files later
+        for name in sorted(self.allowed):
+            try:
+                __import__(name)
+            except Exception:
+                self.missing.append(name)
+
+        # the classes of the allowed modules and of json are restored after every call, a script that patches
+        # them does not change the responses of the worker or later calls
+        self.classes = {}
+        for name, module in list(sys.modules.items()):
+            if name.split(".")[0] in self.allowed | {"json"}:
+                for value in list(vars(module).values()):
+                    if isinstance(value, type):
+                        self.classes[value] = dict(value.__dict__)
+        self.decimal_context = decimal.getcontext().copy()
+
+        self.user_builtins = dict(vars(builtins))
+        if self.sandbox:
+            self.user_builtins["__import__"] = self.guarded_import
+            for name in BLOCKED_BUILTINS:
+                if name == "open" and config.get("filesystem"):
+                    continue
+                self.user_builtins.pop(name, None)
+            self.apply_limits()
+
+    def apply_limits(self):
+        if resource is None:
+            return
+        memory = self.config.get("memory") or 0
+        if memory > 0:
+            set_limit(resource.RLIMIT_AS, memory * MB)
+        if hasattr(signal, "SIGXCPU"):
+            signal.signal(signal.SIGXCPU, on_cpu_limit)
+        if not self.config.get("filesystem"):
+            if hasattr(signal, "SIGXFSZ"):
+                signal.signal(signal.SIGXFSZ, signal.SIG_IGN)
+            set_limit(resource.RLIMIT_FSIZE, 0)
+        if hasattr(resource, "RLIMIT_NPROC"):
+            set_limit(resource.RLIMIT_NPROC, 0)
+        if not self.config.get("filesystem") and not self.config.get("network"):
+            # no new file descriptors: no files and no sockets, stdin, stdout and stderr stay open
+            set_limit(resource.RLIMIT_NOFILE, 3)
+
+    def guarded_import(self, name, globals=None, locals=None, fromlist=(), level=0):
+        if level != 0 or name.split(".")[0] not in self.allowed:
+            raise ImportError("module %s is not allowed" % name)
+        builtins.__import__(name, globals, locals, fromlist, level)
+        return self.read_only(sys.modules[name if fromlist else name.split(".")[0]])
+
+    def read_only(self, module):
+        """returns the read-only view of the module. Lists, sets and dicts of the module are copied, submodules
+        are only visible if they are allowed."""
+        proxy = self.proxies.get(module.__name__)
+        if proxy is not None:
+            return proxy
+        members = {}
+        proxy = self.proxies[module.__name__] = ReadOnlyModule(module.__name__, members)
+        hidden = HIDDEN_MODULE_ATTRIBUTES.get(module.__name__, ())
+        for name, value in list(vars(module).items()):
+            if name.startswith("_") or name in hidden:
+                continue
+            if isinstance(value, types.ModuleType):
+                if value.__name__.split(".")[0] not in self.allowed:
+                    continue
+                value = self.read_only(value)
+            elif isinstance(value, list):
+                value = tuple(value)
+            elif isinstance(value, set):
+                value = frozenset(value)
+            elif isinstance(value, dict):
+                value = types.MappingProxyType(dict(value))
+            members[name] = value
+        return proxy
+
+    def restore(self):
+        """undoes the changes of a call to the classes of the modules and to the decimal context"""
+        for cls, members in self.classes.items():
+            current = cls.__dict__
+            for name in [name for name in current if name not in members]:
+                type.__delattr__(cls, name)
+            for name, value in members.items():
+                if current.get(name) is not value:
+                    type.__setattr__(cls, name, value)
+        decimal.setcontext(self.decimal_context.copy())
+
+    def check(self, tree):
+        if not self.sandbox:
+            return
+        for node in ast.walk(tree):
+            if isinstance(node, ast.Attribute) and (node.attr.startswith("_") or node.attr.startswith("co_") or
+                                                    node.attr in BLOCKED_ATTRIBUTES):
+                raise SandboxViolation("access to the attribute %s is not allowed" % node.attr)
+            if isinstance(node, ast.Name) and node.id.startswith("__") and node.id not in ALLOWED_DUNDERS:
+                raise SandboxViolation("access to the name %s is not allowed" % node.id)
+
+    def inputs(self, request):
+        values = dict(request.get("inputs") or {})
+        for name, kind in (request.get("types") or {}).items():
+            value = values.get(name)
+            if value is None:
+                continue
+            if kind == "datetime":
+                values[name] = datetime.datetime.fromisoformat(value)
+            elif kind == "float":
+                values[name] = float(value)
+        return values
+
+    def run(self, request):
+        mode = request.get("mode")
+        code = request.get("code") or ""
+        outputs = request.get("outputs") or []
+
+        if mode == "compile":
+            self.check(ast.parse(code, mode="exec"))
+            return {}
+
+        namespace = {"__builtins__": self.user_builtins, "__name__": "__iac__"}
+        namespace.update(self.inputs(request))
+        if mode == "expr":
+            tree = ast.parse(code.strip(), mode="eval")
+            self.check(tree)
+            result = eval(compile(tree, "<expression>", "eval"), namespace)
+            if len(outputs) == 1:
+                return {outputs[0]: result}
+            if isinstance(result, dict):
+                return result
+            return {name: namespace.get(name) for name in outputs}
+        if mode == "script":
+            tree = ast.parse(code, mode="exec")
+            self.check(tree)
+            exec(compile(tree, "<script>", "exec"), namespace)
+            return {name: namespace[name] for name in outputs if name in namespace}
+        raise ValueError("unknown mode %s" % mode)
+
+    def call(self, request):
+        response = {"id": request.get("id")}
+        output = io.StringIO()
+        sys.stdout = output
+        cpu = request.get("cpu") or 0
+        try:
+            if cpu > 0 and resource is not None:
+                usage = resource.getrusage(resource.RUSAGE_SELF)
+                set_limit(resource.RLIMIT_CPU, int(usage.ru_utime + usage.ru_stime) + cpu + 1, resource.RLIM_INFINITY)
+            response["outputs"] = self.run(request)
+        except MemoryError:
+            response.update(error="the script exceeded its memory limit", kind="memory", recycle=True)
+        except CPULimitExceeded as e:
+            response.update(error=str(e), kind="cpu", recycle=True)
+        except SandboxViolation as e:
+            response.update(error=str(e), kind="sandbox")
+        except SyntaxError as e:
+            response.update(error="syntax error: %s" % e, kind="syntax")
+        except ImportError as e:
+            response.update(error=str(e), kind="import")
+        except Exception as e:
+            response.update(error="%s: %s" % (type(e).__name__, e), kind="script", traceback=traceback.format_exc())
+        finally:
+            sys.stdout = stdout
+            if self.sandbox:
+                self.restore()
+            if cpu > 0 and resource is not None:
+                set_limit(resource.RLIMIT_CPU, resource.RLIM_INFINITY, resource.RLIM_INFINITY)
+        response["stdout"] = output.getvalue()
+        return response
+
+    def write(self, response):
+        try:
+            line = write_json(response, default=serialize)
+        except (TypeError, ValueError) as e:
+            line = write_json({"id": response.get("id"), "error": "the outputs cannot be returned: %s" % e, "kind": "output"})
+        stdout.write(line + "\n")
+        stdout.flush()
+
+    def serve(self):
+        self.write({"ready": True, "protocol": PROTOCOL, "python": sys.version.split()[0], "missing": self.missing})
+        for line in stdin:
+            if not line.strip():
+                continue
+            try:
+                request = read_json(line)
+            except ValueError as e:
+                self.write({"error": "invalid request: %s" % e, "kind": "protocol", "recycle": True})
+                continue
+            self.write(self.call(request))
+
+
+def serialize(value):
+    if isinstance(value, (datetime.datetime, datetime.date, datetime.time)):
+        return value.isoformat()
+    if isinstance(value, decimal.Decimal):
+        return str(value)
+    if isinstance(value, (set, frozenset, tuple)):
+        return list(value)
+    raise TypeError("object of type %s is not JSON serializable" % type(value).__name__)
+
+
+if __name__ == "__main__":
+    Worker(read_json(sys.argv[1] if len(sys.argv) > 1 else "{}")).serve()
@@ -0,0 +1,115 @@
+package funcs
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/logs"
+	"github.com/mdaxf/iac/logger"
+)
+
+func newPythonTestExecutor(t *testing.T, isExpr bool, config *ScriptExecutionConfig) *EnhancedPythonExecutor {
+	t.Helper()
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	if logger.TranCodeLogger == nil {
+		logger.TranCodeLogger = logs.NewLogger()
+	}
+	executor := NewEnhancedPythonExecutor(config, isExpr, logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "Python"})
+	executor.Pool = NewPythonWorkerPool("python3", 1, 0, `{"sandbox":true,"memory":256,"modules":["datetime","json","math","operator","string"]}`)
+	t.Cleanup(executor.Pool.Close)
+	return executor
+}
+
+func TestPythonWorker_Execute(t *testing.T) {
+	executor := newPythonTestExecutor(t, false, nil)
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	script := "import math\nprint('debug')\nArea = math.pi * Radius ** 2\nHour = Start.hour\n"
+	outputs, err := executor.Execute(ctx, script, map[string]interface{}{"Radius": 2.0, "Start": start}, []string{"Area", "Hour", "Missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area, _ := outputs["Area"].(float64); area < 12.56 || area > 12.57 || outputs["Hour"] != 8.0 || outputs["Missing"] != nil {
+		t.Errorf("Execute() = %v", outputs)
+	}
+
+	// the second call runs on the same worker without state of the first call
+	outputs, err = executor.Execute(ctx, "Result = 'Area' in dir()", map[string]interface{}{}, []string{"Result"})
+	if err != nil || outputs["Result"] != false {
+		t.Errorf("Execute() = %v, %v, want no variables of the previous call", outputs, err)
+	}
+}
+
+func TestPythonWorker_Sandbox(t *testing.T) {
+	executor := newPythonTestExecutor(t, false, nil)
+	ctx := context.Background()
+
+	scripts := map[string]string{
+		"import":    "import os\nResult = os.getcwd()",
+		"builtin":   "Result = open('/etc/passwd').read()",
+		"attribute": "Result = ().__class__.__bases__[0].__subclasses__()",
+		"getattr":   "Result = str(getattr(getattr((), '__cla' + 'ss__'), '__ba' + 'se__'))",
+		"frame":     "def walk():\n    yield steps.gi_frame.f_back.f_back.f_globals\nsteps = walk()\nResult = str(next(steps))",
+		"module":    "import operator\nResult = str(operator.attrgetter('__class__')(()))",
+		"formatter": "import string\nResult = str(string.Formatter().get_field('0.__class__', [()], {}))",
+		"submodule": "import json\nResult = str(json.decoder.re)",
+	}
+	for name, script := range scripts {
+		_, err := executor.Execute(ctx, script, map[string]interface{}{}, []string{"Result"})
+		if err == nil {
+			t.Errorf("%s: Execute() expected the sandbox to stop the script", name)
+		}
+	}
+
+	_, err := executor.Execute(ctx, "Result = 1 / 0", map[string]interface{}{}, []string{"Result"})
+	bpmErr, ok := err.(*types.BPMError)
+	if !ok || bpmErr.Category != types.ErrorCategoryScript || !strings.Contains(bpmErr.Message, "ZeroDivisionError") {
+		t.Errorf("Execute() error = %v, want the script error", err)
+	}
+}
+
+func TestPythonWorker_ModulesOfLaterCalls(t *testing.T) {
+	executor := newPythonTestExecutor(t, false, nil)
+	ctx := context.Background()
+
+	// the pool has one worker, every script runs on the worker of the previous one
+	if _, err := executor.Execute(ctx, "import json\njson.dumps = lambda *a, **k: 'HIJACK'", map[string]interface{}{}, nil); err == nil {
+		t.Errorf("Execute() replaced a member of a module")
+	}
+	if _, err := executor.Execute(ctx, "import json\njson.JSONEncoder.encode = lambda self, o: 'HIJACK'\nResult = 1", map[string]interface{}{}, []string{"Result"}); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs, err := executor.Execute(ctx, "import json\nResult = json.dumps({'a': 1})", map[string]interface{}{}, []string{"Result"})
+	if err != nil || outputs["Result"] != `{"a": 1}` {
+		t.Errorf("Execute() = %v, %v, want the modules unchanged by the previous calls", outputs, err)
+	}
+}
+
+func TestPythonWorker_Limits(t *testing.T) {
+	executor := newPythonTestExecutor(t, true, &ScriptExecutionConfig{Timeout: 5 * time.Second, MaxCPUSeconds: 1})
+
+	_, err := executor.Execute(context.Background(), "sum(i for i in range(10**12))", map[string]interface{}{}, []string{"Result"})
+	if bpmErr, ok := err.(*types.BPMError); !ok || bpmErr.Details["kind"] != "cpu" {
+		t.Errorf("Execute() error = %v, want the CPU limit", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := executor.Execute(ctx, "sum(i for i in range(10**12))", map[string]interface{}{}, []string{"Result"}); err == nil {
+		t.Errorf("Execute() expected the timeout")
+	}
+
+	// the killed worker is replaced
+	outputs, err := executor.Execute(context.Background(), "A * 2", map[string]interface{}{"A": 21}, []string{"Result"})
+	if err != nil || outputs["Result"] != 42.0 {
+		t.Errorf("Execute() = %v, %v after the timeout", outputs, err)
+	}
+}
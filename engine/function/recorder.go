@@ -0,0 +1,192 @@
+package funcs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// RECORDING DESIGN: an execution is reproducible from its inputs and system session once the results of the
+// calls that cannot be run again with the same outcome are known. The recorder travels with the context of the
+// execution and captures the results of the function types marked Nondeterministic or ExternalEffect and the
+// current time each function gets. A replay recorder returns the captured results instead of running these
+// functions, so the replay neither depends on the production data nor repeats the external effects.
+// Calls made inside a recorded call, like the functions of a sub transaction code, are not recorded, because
+// the recorded result of the outer call already covers them. Calls are matched by function name and call count,
+// so functions running in parallel with the same name can be replayed in a different order.
+
+// ExecutionRecorder records the nondeterministic results of an execution or replays them
+type ExecutionRecorder struct {
+	replay    bool
+	suspended bool
+	mu        sync.Mutex
+	calls     map[string]int
+	clocks    map[string]int
+	recorded  []types.RecordedCall
+	clock     []types.RecordedTime
+	missing   []string
+}
+
+// suspendedRecorder stops the recording inside recorded calls
+var suspendedRecorder = &ExecutionRecorder{suspended: true}
+
+// NewExecutionRecorder creates a recorder that captures the results of an execution
+func NewExecutionRecorder() *ExecutionRecorder {
+	return &ExecutionRecorder{calls: map[string]int{}, clocks: map[string]int{}, recorded: []types.RecordedCall{}, clock: []types.RecordedTime{}, missing: []string{}}
+}
+
+// NewReplayRecorder creates a recorder that returns the results captured in the recording
+func NewReplayRecorder(recording *types.ExecutionRecording) *ExecutionRecorder {
+	r := NewExecutionRecorder()
+	r.replay = true
+	r.recorded = append(r.recorded, recording.Calls...)
+	r.clock = append(r.clock, recording.Clock...)
+	return r
+}
+
+// Calls returns the recorded calls
+func (r *ExecutionRecorder) Calls() []types.RecordedCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]types.RecordedCall{}, r.recorded...)
+}
+
+// Clock returns the recorded current times
+func (r *ExecutionRecorder) Clock() []types.RecordedTime {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]types.RecordedTime{}, r.clock...)
+}
+
+// Missing returns the calls of a replay that have no recorded result, the replay diverged from the recording at them
+func (r *ExecutionRecorder) Missing() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.missing...)
+}
+
+// Now returns the current time for the next call of the function, the recorded time in a replay
+func (r *ExecutionRecorder) Now(function string) time.Time {
+	now := time.Now()
+	if r == nil || r.suspended {
+		return now
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clocks[function]++
+	sequence := r.clocks[function]
+	if !r.replay {
+		r.clock = append(r.clock, types.RecordedTime{Function: function, Sequence: sequence, Time: now})
+		return now
+	}
+	for _, recorded := range r.clock {
+		if recorded.Function == function && recorded.Sequence == sequence {
+			return recorded.Time
+		}
+	}
+	return now
+}
+
+func (r *ExecutionRecorder) nextCall(function string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[function]++
+	return r.calls[function]
+}
+
+func (r *ExecutionRecorder) addCall(call types.RecordedCall) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, call)
+}
+
+func (r *ExecutionRecorder) recordedCall(function string, sequence int) (types.RecordedCall, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, call := range r.recorded {
+		if call.Function == function && call.Sequence == sequence {
+			return call, true
+		}
+	}
+	r.missing = append(r.missing, fmt.Sprintf("%s#%d", function, sequence))
+	return types.RecordedCall{}, false
+}
+
+type executionRecorderContextKey struct{}
+
+// WithExecutionRecorder returns a context carrying the recorder of an execution
+func WithExecutionRecorder(ctx context.Context, recorder *ExecutionRecorder) context.Context {
+	return context.WithValue(ctx, executionRecorderContextKey{}, recorder)
+}
+
+// ExecutionRecorderFromContext returns the recorder of the context, or nil if the execution is not recorded or replayed
+func ExecutionRecorderFromContext(ctx context.Context) *ExecutionRecorder {
+	if ctx == nil {
+		return nil
+	}
+	recorder, _ := ctx.Value(executionRecorderContextKey{}).(*ExecutionRecorder)
+	return recorder
+}
+
+// executeRecorded records the result of a nondeterministic function or returns its recorded result in a replay,
+// it reports whether it ran the function
+func (f *Funcs) executeRecorded(registered *registeredFunctionType) bool {
+	recorder := ExecutionRecorderFromContext(f.Ctx)
+	if recorder == nil || recorder.suspended || registered == nil {
+		return false
+	}
+	if !registered.descriptor.Nondeterministic && !registered.descriptor.ExternalEffect {
+		return false
+	}
+
+	sequence := recorder.nextCall(f.Fobj.Name)
+	if recorder.replay {
+		call, exists := recorder.recordedCall(f.Fobj.Name, sequence)
+		if !exists {
+			panic(types.NewExecutionError(fmt.Sprintf("Call %d of function %s has no recorded result, the replay diverged from the recording", sequence, f.Fobj.Name), nil))
+		}
+		f.iLog.Debug(fmt.Sprintf("Replay call %d of function %s with outputs: %s, error: %s", sequence, f.Fobj.Name, logger.ConvertJson(call.Outputs), call.Error))
+		if call.Error != "" {
+			category := call.Category
+			if category == "" {
+				category = types.ErrorCategoryExecution
+			}
+			panic(types.NewBPMError(category, types.ErrorSeverityError, call.Error, nil).WithDetail("recording", fmt.Sprintf("%s#%d", f.Fobj.Name, sequence)))
+		}
+		outputs := make(map[string]interface{}, len(call.Outputs))
+		for key, value := range call.Outputs {
+			outputs[key] = value
+		}
+		f.SetOutputs(outputs)
+		return true
+	}
+
+	ctx := f.Ctx
+	f.Ctx = WithExecutionRecorder(ctx, suspendedRecorder)
+	executed := len(f.FunctionOutputs)
+	defer func() {
+		f.Ctx = ctx
+		call := types.RecordedCall{Function: f.Fobj.Name, Type: f.Fobj.TypeName(), Sequence: sequence}
+		if r := recover(); r != nil {
+			if bpmErr, ok := r.(*types.BPMError); ok {
+				call.Error = bpmErr.Message
+				call.Category = bpmErr.Category
+			} else {
+				call.Error = fmt.Sprintf("%v", r)
+			}
+			recorder.addCall(call)
+			panic(r)
+		}
+		if len(f.FunctionOutputs) > executed {
+			call.Outputs = f.FunctionOutputs[len(f.FunctionOutputs)-1]
+		}
+		recorder.addCall(call)
+	}()
+	registered.executor.Execute(f)
+	return true
+}
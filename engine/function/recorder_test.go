@@ -0,0 +1,84 @@
+package funcs
+
+import (
+	"testing"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// registerLookupType registers Test.Lookup for the test, a nondeterministic type that returns the number of its runs
+func registerLookupType(t *testing.T) *int {
+	runs := 0
+	registerTestType(t, FunctionTypeDescriptor{Name: "Test.Lookup", Nondeterministic: true}, func(f *Funcs) {
+		runs++
+		f.SetOutputs(map[string]interface{}{"Count": runs})
+	})
+	return &runs
+}
+
+func newLookupTestFuncs(recorder *ExecutionRecorder) *Funcs {
+	f := newTestFuncs(types.Function{Name: "Lookup", Typename: "Test.Lookup", Outputs: []types.Output{{Name: "Count"}}})
+	f.Ctx = WithExecutionRecorder(f.Ctx, recorder)
+	return f
+}
+
+func TestExecutionRecorder_RecordsSequencedCalls(t *testing.T) {
+	registerLookupType(t)
+	recorder := NewExecutionRecorder()
+	for i := 0; i < 2; i++ {
+		newLookupTestFuncs(recorder).Execute()
+	}
+
+	calls := recorder.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("Calls() = %v, want two calls", calls)
+	}
+	for i, call := range calls {
+		if call.Function != "Lookup" || call.Type != "Test.Lookup" || call.Sequence != i+1 || call.Outputs["Count"] != i+1 {
+			t.Errorf("call %d = %+v, want the sequence and outputs of run %d", i, call, i+1)
+		}
+	}
+}
+
+func TestExecutionRecorder_ReplayReturnsRecordedOutputs(t *testing.T) {
+	runs := registerLookupType(t)
+	replay := NewReplayRecorder(&types.ExecutionRecording{Calls: []types.RecordedCall{
+		{Function: "Lookup", Type: "Test.Lookup", Sequence: 1, Outputs: map[string]interface{}{"Count": 7}},
+		{Function: "Lookup", Type: "Test.Lookup", Sequence: 2, Outputs: map[string]interface{}{"Count": 8}},
+	}})
+
+	for _, want := range []int{7, 8} {
+		f := newLookupTestFuncs(replay)
+		f.Execute()
+		if got := f.FuncCachedVariables["Lookup"].(map[string]interface{})["Count"]; got != want {
+			t.Errorf("replayed Count = %v, want %v", got, want)
+		}
+	}
+	if *runs != 0 {
+		t.Errorf("the replay ran the function %d times, want none", *runs)
+	}
+}
+
+func TestExecutionRecorder_ReplayRecordedError(t *testing.T) {
+	registerLookupType(t)
+	replay := NewReplayRecorder(&types.ExecutionRecording{Calls: []types.RecordedCall{
+		{Function: "Lookup", Type: "Test.Lookup", Sequence: 1, Error: "lookup timed out", Category: types.ErrorCategoryTimeout},
+	}})
+
+	bpmErr := executeError(newLookupTestFuncs(replay))
+	if bpmErr == nil || bpmErr.Message != "lookup timed out" || bpmErr.Category != types.ErrorCategoryTimeout {
+		t.Errorf("Execute() = %v, want the recorded error", bpmErr)
+	}
+}
+
+func TestExecutionRecorder_ReplayMissingCall(t *testing.T) {
+	registerLookupType(t)
+	replay := NewReplayRecorder(&types.ExecutionRecording{Calls: []types.RecordedCall{
+		{Function: "Lookup", Type: "Test.Lookup", Sequence: 1, Outputs: map[string]interface{}{"Count": 1}},
+	}})
+
+	newLookupTestFuncs(replay).Execute()
+	if bpmErr := executeError(newLookupTestFuncs(replay)); bpmErr == nil || len(replay.Missing()) != 1 {
+		t.Errorf("Execute() = %v with the missing calls %v, want the second call missing", bpmErr, replay.Missing())
+	}
+}
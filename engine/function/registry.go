@@ -0,0 +1,328 @@
+package funcs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// REGISTRY DESIGN: function types are looked up by name. The built-in types are registered under the
+// names of their numeric types (types.FunctionType.String()), so saved transaction codes that only have
+// a functype keep working. New step kinds are registered from other packages or plugins with
+// RegisterFunctionType and are referenced by the typename of the function, no engine code changes
+// and no new numeric constants are needed.
+
+// TypeExecutor runs a function of a registered function type.
+// Like the built-in types it reads its inputs with f.SetInputs, writes its outputs with f.SetOutputs
+// and panics with a *types.BPMError to fail the transaction.
+type TypeExecutor interface {
+	Execute(f *Funcs)
+}
+
+// ExecutorFunc adapts a function to a TypeExecutor
+type ExecutorFunc func(f *Funcs)
+
+// Execute calls ef(f)
+func (ef ExecutorFunc) Execute(f *Funcs) {
+	ef(f)
+}
+
+// TypeValidator checks the definition of a function when a transaction code is loaded
+type TypeValidator interface {
+	Validate(fobj *types.Function) error
+}
+
+// ValidatorFunc adapts a function to a TypeValidator
+type ValidatorFunc func(fobj *types.Function) error
+
+// Validate calls vf(fobj)
+func (vf ValidatorFunc) Validate(fobj *types.Function) error {
+	return vf(fobj)
+}
+
+// FunctionTypeDescriptor describes a function type for the designer
+type FunctionTypeDescriptor struct {
+	Name              string              `json:"name"`
+	Label             string              `json:"label"`
+	Category          string              `json:"category"`
+	Description       string              `json:"description"`
+	Builtin           bool                `json:"builtin"`
+	Functype          int                 `json:"functype"` // numeric type of the built-in types, -1 for registered types
+	SharesTransaction bool                `json:"sharestransaction"`
+	ExternalEffect    bool                `json:"externaleffect"`   // the function has effects a test cannot roll back, tests should mock it
+	Nondeterministic  bool                `json:"nondeterministic"` // running the function again can give another result, recordings keep its results
+	Inputs            []types.SchemaField `json:"inputs"`
+	Outputs           []types.SchemaField `json:"outputs"`
+}
+
+type registeredFunctionType struct {
+	descriptor FunctionTypeDescriptor
+	executor   TypeExecutor
+	validator  TypeValidator
+}
+
+var (
+	functionTypes   = map[string]*registeredFunctionType{}
+	functionTypesMu sync.RWMutex
+)
+
+// RegisterFunctionType registers a function type. The validator is optional.
+// Names are unique, the built-in type names cannot be replaced.
+func RegisterFunctionType(descriptor FunctionTypeDescriptor, executor TypeExecutor, validator TypeValidator) error {
+	name := strings.TrimSpace(descriptor.Name)
+	if name == "" {
+		return fmt.Errorf("function type name is required")
+	}
+	if executor == nil {
+		return fmt.Errorf("function type %s has no executor", name)
+	}
+
+	functionTypesMu.Lock()
+	defer functionTypesMu.Unlock()
+
+	if _, exists := functionTypes[name]; exists {
+		return fmt.Errorf("function type %s is already registered", name)
+	}
+
+	descriptor.Name = name
+	if descriptor.Label == "" {
+		descriptor.Label = name
+	}
+	if !descriptor.Builtin {
+		descriptor.Functype = -1
+	}
+	functionTypes[name] = &registeredFunctionType{descriptor: descriptor, executor: executor, validator: validator}
+	return nil
+}
+
+// MustRegisterFunctionType registers a function type and panics if that fails, for use in init functions
+func MustRegisterFunctionType(descriptor FunctionTypeDescriptor, executor TypeExecutor, validator TypeValidator) {
+	if err := RegisterFunctionType(descriptor, executor, validator); err != nil {
+		panic(err)
+	}
+}
+
+// GetFunctionType returns the descriptor of a registered function type
+func GetFunctionType(name string) (FunctionTypeDescriptor, bool) {
+	functionTypesMu.RLock()
+	defer functionTypesMu.RUnlock()
+
+	registered, exists := functionTypes[name]
+	if !exists {
+		return FunctionTypeDescriptor{}, false
+	}
+	return registered.descriptor, true
+}
+
+// ListFunctionTypes returns the descriptors of all function types ordered by category and name
+func ListFunctionTypes() []FunctionTypeDescriptor {
+	functionTypesMu.RLock()
+	descriptors := make([]FunctionTypeDescriptor, 0, len(functionTypes))
+	for _, registered := range functionTypes {
+		descriptors = append(descriptors, registered.descriptor)
+	}
+	functionTypesMu.RUnlock()
+
+	sort.Slice(descriptors, func(i, j int) bool {
+		if descriptors[i].Category != descriptors[j].Category {
+			return descriptors[i].Category < descriptors[j].Category
+		}
+		return descriptors[i].Name < descriptors[j].Name
+	})
+	return descriptors
+}
+
+func lookupFunctionType(fobj *types.Function) (*registeredFunctionType, bool) {
+	functionTypesMu.RLock()
+	defer functionTypesMu.RUnlock()
+
+	registered, exists := functionTypes[fobj.TypeName()]
+	return registered, exists
+}
+
+// SharesTransaction reports whether the function works on the database transaction of the transaction code.
+// Message functions in the outbox mode write their message to the transaction.
+func SharesTransaction(fobj *types.Function) bool {
+	if fobj.WritesToOutbox() {
+		return true
+	}
+	if registered, exists := lookupFunctionType(fobj); exists {
+		return registered.descriptor.SharesTransaction
+	}
+	return fobj.Functype.SharesTransaction()
+}
+
+// ValidateFunctions checks that every function of the transaction code has a registered type and valid
+// repeat settings and runs the validators of the types. All problems are reported in one validation error.
+func ValidateFunctions(tcode *types.TranCode) error {
+	violations := []types.ContractViolation{}
+	var validate func(fgName string, fobj *types.Function)
+	validate = func(fgName string, fobj *types.Function) {
+		path := fmt.Sprintf("%s.%s", fgName, fobj.Name)
+		registered, exists := lookupFunctionType(fobj)
+		switch {
+		case !exists && fobj.Typename != "":
+			violations = append(violations, types.ContractViolation{Path: path, Message: fmt.Sprintf("has unknown function type %s", fobj.Typename)})
+		case exists && registered.validator != nil:
+			if err := registered.validator.Validate(fobj); err != nil {
+				violations = append(violations, types.ContractViolation{Path: path, Message: err.Error()})
+			}
+		}
+		if err := fobj.ValidateRepeat(); err != nil {
+			violations = append(violations, types.ContractViolation{Path: path, Message: err.Error()})
+		}
+		if fobj.Compensation != nil {
+			validate(fgName, fobj.Compensation)
+		}
+	}
+
+	for i := range tcode.Functiongroups {
+		for j := range tcode.Functiongroups[i].Functions {
+			validate(tcode.Functiongroups[i].Name, &tcode.Functiongroups[i].Functions[j])
+		}
+	}
+
+	if len(violations) > 0 {
+		return types.NewContractError(fmt.Sprintf("Transaction code %s has invalid functions", tcode.Name), violations)
+	}
+	return nil
+}
+
+// executeFunctionType runs the function with the executor of its type
+func (f *Funcs) executeFunctionType() {
+	registered, exists := lookupFunctionType(&f.Fobj)
+	if f.executeMock(registered) || f.executeRecorded(registered) {
+		return
+	}
+	if !exists {
+		if f.Fobj.Typename != "" {
+			panic(types.NewExecutionError(fmt.Sprintf("Function %s has unknown function type %s", f.Fobj.Name, f.Fobj.Typename), nil))
+		}
+		f.iLog.Warn(fmt.Sprintf("Function %s has function type %d without implementation, skipped", f.Fobj.Name, f.Fobj.Functype))
+		return
+	}
+	registered.executor.Execute(f)
+}
+
+func builtinFunctionType(functype types.FunctionType, category, description string, inputs, outputs []types.SchemaField, executor ExecutorFunc) {
+	if inputs == nil {
+		inputs = []types.SchemaField{}
+	}
+	if outputs == nil {
+		outputs = []types.SchemaField{}
+	}
+	MustRegisterFunctionType(FunctionTypeDescriptor{
+		Name:              functype.String(),
+		Category:          category,
+		Description:       description,
+		Builtin:           true,
+		Functype:          int(functype),
+		SharesTransaction: functype.SharesTransaction(),
+		ExternalEffect:    functype.HasExternalEffect(),
+		Nondeterministic:  functype.IsNondeterministic(),
+		Inputs:            inputs,
+		Outputs:           outputs,
+	}, executor, nil)
+}
+
+// The inputs and outputs the built-in types read and write by name. The script, query and mapping types have no fixed
+// inputs, the functions declare their own. The other inputs of the table functions are the columns, a name ending
+// with KEY is a column of the where clause, the other inputs of the message functions are the message.
+var (
+	tableNameInput = types.SchemaField{Name: "TableName", Datatype: types.String, Required: true, Description: "Table of the rows"}
+	rowCountOutput = types.SchemaField{Name: "RowCount", Datatype: types.Integer, Description: "Number of rows"}
+	queryOutputs   = []types.SchemaField{
+		{Name: "ColumnCount", Datatype: types.Integer, Description: "Number of columns of the result"},
+		rowCountOutput,
+	}
+	topicInput     = types.SchemaField{Name: "Topic", Datatype: types.String, Required: true, Description: "Topic of the message"}
+	messageOutputs = []types.SchemaField{
+		{Name: "MessageID", Datatype: types.String, Description: "Outbox message id in the outbox delivery"},
+	}
+	taskIDInput = types.SchemaField{Name: "TaskID", Datatype: types.Integer, Required: true, Description: "Workflow task"}
+)
+
+func init() {
+	builtinFunctionType(types.InputMap, "Data", "Maps inputs to outputs", nil, nil, func(f *Funcs) { (&InputMapFuncs{}).Execute(f) })
+	// Use enhanced Go expression executor with safety features
+	builtinFunctionType(types.GoExpr, "Script", "Evaluates a Go expression", nil, nil, func(f *Funcs) { (&EnhancedGoExprFuncs{}).Execute(f) })
+	builtinFunctionType(types.Javascript, "Script", "Runs a JavaScript script", nil, nil, func(f *Funcs) { (&JSFuncs{}).Execute(f) })
+	builtinFunctionType(types.PythonExpr, "Script", "Evaluates a Python expression", nil, nil, func(f *Funcs) { (&PythonExprFuncs{}).Execute(f) })
+	builtinFunctionType(types.PythonScript, "Script", "Runs a Python script", nil, nil, func(f *Funcs) { (&PythonScriptFuncs{}).Execute(f) })
+	builtinFunctionType(types.Query, "Database", "Runs a SQL query", nil, queryOutputs, func(f *Funcs) { (&QueryFuncs{}).Execute(f) })
+	builtinFunctionType(types.SubTranCode, "Flow", "Executes another transaction code",
+		[]types.SchemaField{{Name: "TranCode", Datatype: types.String, Required: true, Description: "Transaction code to execute"}},
+		[]types.SchemaField{
+			{Name: "ExecutionID", Datatype: types.String, Description: "Execution of the queued or started transaction code"},
+			{Name: "CorrelationID", Datatype: types.String, Description: "Correlation of the queued or started transaction code"},
+		}, func(f *Funcs) { (&SubTranCodeFuncs{}).Execute(f) })
+	builtinFunctionType(types.StoreProcedure, "Database", "Calls a stored procedure", nil, queryOutputs, func(f *Funcs) { (&StoreProcFuncs{}).Execute(f) })
+	builtinFunctionType(types.TableInsert, "Database", "Inserts a table row",
+		[]types.SchemaField{tableNameInput, {Name: "Execution", Datatype: types.Bool, Description: "false skips the insert"}},
+		[]types.SchemaField{{Name: "Identify", Datatype: types.Integer, Description: "Id of the inserted row"}},
+		func(f *Funcs) { (&TableInsertFuncs{}).Execute(f) })
+	builtinFunctionType(types.TableUpdate, "Database", "Updates table rows", []types.SchemaField{tableNameInput}, []types.SchemaField{rowCountOutput},
+		func(f *Funcs) { (&TableUpdateFuncs{}).Execute(f) })
+	builtinFunctionType(types.TableDelete, "Database", "Deletes table rows", []types.SchemaField{tableNameInput}, []types.SchemaField{rowCountOutput},
+		func(f *Funcs) { (&TableDeleteFuncs{}).Execute(f) })
+	builtinFunctionType(types.CollectionInsert, "Document", "Inserts a document", nil, nil, func(f *Funcs) { (&CollectionInsertFuncs{}).Execute(f) })
+	builtinFunctionType(types.CollectionUpdate, "Document", "Updates documents", nil, nil, func(f *Funcs) { (&CollectionUpdateFuncs{}).Execute(f) })
+	builtinFunctionType(types.CollectionDelete, "Document", "Deletes documents", nil, nil, func(f *Funcs) { (&CollectionDeleteFuncs{}).Execute(f) })
+	builtinFunctionType(types.ThrowError, "Flow", "Fails the transaction code with an error",
+		[]types.SchemaField{
+			{Name: "message", Datatype: types.String, Description: "Message of the error"},
+			{Name: "iserror", Datatype: types.Bool, Description: "false continues without an error"},
+			{Name: "category", Datatype: types.String, Description: "Category of the error"},
+			{Name: "error_code", Datatype: types.String, Description: "Code of the error"},
+		},
+		[]types.SchemaField{{Name: "result", Datatype: types.String, Description: "no_error when iserror is false"}},
+		func(f *Funcs) { (&ThrowErrorFuncs{}).Execute(f) })
+	builtinFunctionType(types.SendMessage, "Messaging", "Sends a message to the message bus", []types.SchemaField{topicInput}, messageOutputs,
+		func(f *Funcs) { (&SendMessageFuncs{}).Execute(f) })
+	builtinFunctionType(types.SendEmail, "Messaging", "Sends an email",
+		[]types.SchemaField{
+			{Name: "ToEmails", Datatype: types.String, Required: true, Description: "Recipients separated by semicolons"},
+			{Name: "FromEmail", Datatype: types.String, Description: "Sender"},
+			{Name: "Subject", Datatype: types.String, Required: true, Description: "Subject"},
+			{Name: "Body", Datatype: types.String, Required: true, Description: "Body"},
+			{Name: "Attachment", Datatype: types.String, Description: "File to attach"},
+			{Name: "SmtpServer", Datatype: types.String, Description: "SMTP server"},
+			{Name: "SmtpPort", Datatype: types.Integer, Description: "SMTP port"},
+			{Name: "SmtpUser", Datatype: types.String, Description: "SMTP user"},
+			{Name: "SmtpPassword", Datatype: types.String, Description: "SMTP password"},
+		}, nil, func(f *Funcs) { (&EmailFuncs{}).Execute(f) })
+	builtinFunctionType(types.ExplodeWorkFlow, "Workflow", "Starts a workflow",
+		[]types.SchemaField{
+			{Name: "WorkFlowName", Datatype: types.String, Required: true, Description: "Workflow to start"},
+			{Name: "EntityName", Datatype: types.String, Description: "Entity of the workflow"},
+			{Name: "EntityType", Datatype: types.String, Description: "Type of the entity"},
+			{Name: "Description", Datatype: types.String, Description: "Description of the workflow entity"},
+		},
+		[]types.SchemaField{{Name: "WorkFloeEntityID", Datatype: types.Integer, Description: "Started workflow entity"}},
+		func(f *Funcs) { (&WorkFlowFunc{}).Execute_Explode(f) })
+	builtinFunctionType(types.StartWorkFlowTask, "Workflow", "Starts a workflow task", []types.SchemaField{taskIDInput}, nil,
+		func(f *Funcs) { (&WorkFlowFunc{}).Execute_StartTask(f) })
+	builtinFunctionType(types.CompleteWorkFlowTask, "Workflow", "Completes a workflow task", []types.SchemaField{taskIDInput}, nil,
+		func(f *Funcs) { (&WorkFlowFunc{}).Execute_CompleteTask(f) })
+	builtinFunctionType(types.SendMessagebyKafka, "Messaging", "Sends a message to Kafka",
+		[]types.SchemaField{topicInput, {Name: "Server", Datatype: types.String, Required: true, Description: "Kafka broker"}}, messageOutputs,
+		func(f *Funcs) { (&SendMessagebyKafka{}).Execute(f) })
+	builtinFunctionType(types.SendMessagebyMQTT, "Messaging", "Sends a message to an MQTT broker",
+		[]types.SchemaField{topicInput, {Name: "Server", Datatype: types.String, Required: true, Description: "MQTT broker"}}, messageOutputs,
+		func(f *Funcs) { (&SendMessagebyMQTT{}).Execute(f) })
+	builtinFunctionType(types.SendMessagebyAQMP, "Messaging", "Sends a message to ActiveMQ",
+		[]types.SchemaField{topicInput, {Name: "ActiveMQ", Datatype: types.String, Required: true, Description: "ActiveMQ server"}}, messageOutputs,
+		func(f *Funcs) { (&SendMessagebyActiveMQ{}).Execute(f) })
+	builtinFunctionType(types.WebServiceCall, "Integration", "Calls a web service",
+		[]types.SchemaField{
+			{Name: "URL", Datatype: types.String, Required: true, Description: "URL of the web service"},
+			{Name: "METHOD", Datatype: types.String, Description: "HTTP method, GET by default"},
+		},
+		[]types.SchemaField{
+			{Name: "Response", Datatype: types.Object, Description: "Data of the response"},
+			{Name: "StatusCode", Datatype: types.Integer, Description: "Status code of the response"},
+		}, func(f *Funcs) { (&WebServiceCallFunc{}).Execute(f) })
+}
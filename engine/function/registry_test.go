@@ -0,0 +1,29 @@
+package funcs
+
+import (
+	"testing"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+func TestBuiltinFunctionType_Fields(t *testing.T) {
+	descriptor, ok := GetFunctionType(types.WebServiceCall.String())
+	if !ok {
+		t.Fatalf("GetFunctionType(%s) found no descriptor", types.WebServiceCall)
+	}
+	fields := map[string]types.SchemaField{}
+	for _, input := range descriptor.Inputs {
+		fields[input.Name] = input
+	}
+	if !fields["URL"].Required || fields["METHOD"].Name == "" || fields["METHOD"].Required {
+		t.Errorf("Inputs = %+v, want the required URL and the optional METHOD", descriptor.Inputs)
+	}
+	if len(descriptor.Outputs) != 2 {
+		t.Errorf("Outputs = %+v, want Response and StatusCode", descriptor.Outputs)
+	}
+
+	// the script types have no fixed inputs, the list is empty rather than null for the designer
+	if descriptor, _ := GetFunctionType(types.Javascript.String()); descriptor.Inputs == nil || len(descriptor.Inputs) != 0 {
+		t.Errorf("Inputs of %s = %#v, want an empty list", types.Javascript, descriptor.Inputs)
+	}
+}
@@ -0,0 +1,184 @@
+package funcs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+)
+
+// executeIterations runs a function once for each iteration of its repeat inputs.
+// A failed iteration fails the function with the failfast policy. With the continue policy the error is
+// kept by iteration and returned in the IterationErrors output, with the skip policy the iteration has no outputs.
+// Functions that do not share the database transaction run up to Repeatconcurrency iterations in parallel.
+func (f *Funcs) executeIterations(startTime time.Time) {
+	policy := f.Fobj.RepeatErrorPolicy()
+	results := make([]map[string]interface{}, f.ExecutionNumber)
+	f.iterationErrors = nil
+	if policy == types.RepeatContinue {
+		f.iterationErrors = make([]interface{}, f.ExecutionNumber)
+	}
+
+	if policy != types.RepeatFailFast {
+		// a failed iteration must not roll back the transaction the remaining iterations work on
+		tryScope := f.TryScope
+		f.TryScope = true
+		defer func() {
+			f.TryScope = tryScope
+		}()
+	}
+
+	concurrency := f.Fobj.Repeatconcurrency
+	if concurrency > 1 && SharesTransaction(&f.Fobj) {
+		f.iLog.Warn(fmt.Sprintf("Function %s works on the database transaction, its iterations run one at a time", f.Fobj.Name))
+		concurrency = 1
+	}
+
+	handle := func(i int, outputs map[string]interface{}, err error) error {
+		if err == nil {
+			results[i] = outputs
+			return nil
+		}
+		switch policy {
+		case types.RepeatContinue:
+			f.iLog.Error(fmt.Sprintf("Iteration %d of function %s failed: %s", i+1, f.Fobj.Name, err.Error()))
+			f.iterationErrors[i] = err.Error()
+		case types.RepeatSkip:
+			f.iLog.Warn(fmt.Sprintf("Iteration %d of function %s failed and is skipped: %s", i+1, f.Fobj.Name, err.Error()))
+		default:
+			return err
+		}
+		return nil
+	}
+
+	if concurrency <= 1 {
+		for i := 0; i < f.ExecutionNumber; i++ {
+			outputs, err := f.runIteration(f, i, startTime)
+			if err := handle(i, outputs, err); err != nil {
+				panic(err)
+			}
+		}
+		f.FunctionOutputs = results
+		return
+	}
+
+	f.iLog.Debug(fmt.Sprintf("Run %d iterations of function %s with %d in parallel", f.ExecutionNumber, f.Fobj.Name, concurrency))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, concurrency)
+	iterationFuncs := make([]*Funcs, f.ExecutionNumber)
+	for i := 0; i < f.ExecutionNumber; i++ {
+		mu.Lock()
+		stop := firstErr != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+
+		sem <- struct{}{}
+		it := f.iterationCopy()
+		iterationFuncs[i] = it
+
+		wg.Add(1)
+		go func(i int, it *Funcs) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			outputs, err := f.runIteration(it, i, startTime)
+			mu.Lock()
+			defer mu.Unlock()
+			if err := handle(i, outputs, err); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(i, it)
+	}
+	wg.Wait()
+
+	for _, it := range iterationFuncs {
+		if it != nil {
+			f.TestResults = append(f.TestResults, it.TestResults...)
+		}
+	}
+	if firstErr != nil {
+		panic(firstErr)
+	}
+	f.FunctionOutputs = results
+}
+
+// iterationCopy returns the copy of the function a parallel iteration runs on.
+// The input handlers write the inputs and the mapped inputs, so each iteration gets its own, the sessions
+// and the cached variables stay shared and are only written with the outputs once the iterations are done.
+func (f *Funcs) iterationCopy() *Funcs {
+	it := *f
+	it.Fobj.Inputs = make([]types.Input, len(f.Fobj.Inputs))
+	copy(it.Fobj.Inputs, f.Fobj.Inputs)
+	it.FunctionMappedInputs = make(map[string]interface{}, len(f.FunctionMappedInputs))
+	for name, value := range f.FunctionMappedInputs {
+		it.FunctionMappedInputs[name] = value
+	}
+	it.IterationIndexes = nil
+	it.FunctionOutputs = []map[string]interface{}{}
+	it.TestResults = []map[string]interface{}{}
+	return &it
+}
+
+// runIteration runs iteration i of the function on it, which is f itself or a copy of f for parallel iterations.
+// It returns the outputs of the iteration and the error if the iteration failed.
+func (f *Funcs) runIteration(it *Funcs, i int, startTime time.Time) (outputs map[string]interface{}, err error) {
+	it.ExecutionCount = i
+	it.IterationIndexes = f.iterations[i]
+	it.ErrorMessage = ""
+	executed := len(it.FunctionOutputs)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if bpmErr, ok := r.(*types.BPMError); ok {
+				err = bpmErr
+			} else {
+				err = types.NewExecutionError(fmt.Sprintf("Panic in function %s: %v", f.Fobj.Name, r), nil)
+			}
+		} else if it.ErrorMessage != "" {
+			err = types.NewExecutionError(it.ErrorMessage, nil)
+		}
+		if bpmErr, ok := err.(*types.BPMError); ok {
+			bpmErr.WithDetail("iteration", fmt.Sprintf("%d", i+1))
+		}
+
+		if err == nil && len(it.FunctionOutputs) > executed {
+			outputs = it.FunctionOutputs[len(it.FunctionOutputs)-1]
+		}
+		it.ExecutionCount = i + 1
+		if it.TestwithSc {
+			it.addTestResult(startTime)
+		}
+	}()
+
+	it.iLog.Debug(fmt.Sprintf("Execute the function: %s, iteration: %d / %d, indexes: %s", f.Fobj.Name, i+1, f.ExecutionNumber, logger.ConvertJson(it.IterationIndexes)))
+	it.executeFunctionType()
+	return outputs, nil
+}
+
+// iterationOutputs gathers the outputs of the iterations into lists indexed by iteration
+func (f *Funcs) iterationOutputs() map[string]interface{} {
+	newoutputs := make(map[string]interface{})
+	for _, output := range f.Fobj.Outputs {
+		newoutputs[output.Name] = make([]interface{}, f.ExecutionNumber)
+	}
+	for index, outputs := range f.FunctionOutputs {
+		for key, value := range outputs {
+			if newoutputs[key] == nil {
+				newoutputs[key] = make([]interface{}, f.ExecutionNumber)
+			}
+			newoutputs[key].([]interface{})[index] = value
+		}
+	}
+	if f.iterationErrors != nil {
+		newoutputs["IterationErrors"] = f.iterationErrors
+	}
+	return newoutputs
+}
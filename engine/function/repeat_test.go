@@ -0,0 +1,146 @@
+package funcs
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// registerMultiplyType registers Test.Multiply for the test, it joins the Qty and Factor inputs and fails for a
+// quantity of 0. It returns the most iterations that ran at the same time.
+func registerMultiplyType(t *testing.T) *int32 {
+	var running, maxRunning int32
+	registerTestType(t, FunctionTypeDescriptor{Name: "Test.Multiply"}, func(f *Funcs) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if now <= max || atomic.CompareAndSwapInt32(&maxRunning, max, now) {
+				break
+			}
+		}
+
+		_, _, inputs := f.SetInputs()
+		qty, factor := fmt.Sprint(inputs["Qty"]), fmt.Sprint(inputs["Factor"])
+		if qty == "0" {
+			panic(types.NewBusinessError("quantity is 0"))
+		}
+		f.SetOutputs(map[string]interface{}{"Result": qty + "x" + factor})
+	})
+	return &maxRunning
+}
+
+func newRepeatTestFuncs(fobj types.Function) *Funcs {
+	fobj.Typename = "Test.Multiply"
+	fobj.Outputs = []types.Output{{Name: "Result"}}
+	return newTestFuncs(fobj)
+}
+
+func TestFuncs_RepeatModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		qty    types.Input
+		factor types.Input
+		want   []interface{}
+	}{
+		{"zip", types.RepeatZip,
+			types.Input{Name: "Qty", Value: `["1","2"]`, List: true, Repeat: true},
+			types.Input{Name: "Factor", Value: `["a","b"]`, List: true, Repeat: true},
+			[]interface{}{"1xa", "2xb"}},
+		{"product", types.RepeatProduct,
+			types.Input{Name: "Qty", Value: `["1","2"]`, List: true, Repeat: true},
+			types.Input{Name: "Factor", Value: `["a","b"]`, List: true, Repeat: true},
+			[]interface{}{"1xa", "1xb", "2xa", "2xb"}},
+		{"broadcast", types.RepeatBroadcast,
+			types.Input{Name: "Qty", Value: `["1","2","3"]`, List: true, Repeat: true},
+			types.Input{Name: "Factor", Value: "a", Repeat: true},
+			[]interface{}{"1xa", "2xa", "3xa"}},
+		{"single iteration", "",
+			types.Input{Name: "Qty", Value: `["5"]`, List: true, Repeat: true},
+			types.Input{Name: "Factor", Value: "a"},
+			[]interface{}{"5xa"}},
+	}
+	registerMultiplyType(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRepeatTestFuncs(types.Function{Name: "Multiply", Repeatmode: tt.mode, Inputs: []types.Input{tt.qty, tt.factor}})
+			f.Execute()
+			if got := f.FuncCachedVariables["Multiply"].(map[string]interface{})["Result"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFuncs_RepeatLengthMismatch(t *testing.T) {
+	registerMultiplyType(t)
+	f := newRepeatTestFuncs(types.Function{Name: "Multiply", Inputs: []types.Input{
+		{Name: "Qty", Value: `["1","2"]`, List: true, Repeat: true},
+		{Name: "Factor", Value: `["a","b","c"]`, List: true, Repeat: true},
+	}})
+	if bpmErr := executeError(f); bpmErr == nil || bpmErr.Category != types.ErrorCategoryValidation {
+		t.Errorf("Execute() expected a validation error, got %v", bpmErr)
+	}
+}
+
+func TestFuncs_RepeatErrorPolicy(t *testing.T) {
+	registerMultiplyType(t)
+	qty := types.Input{Name: "Qty", Value: `["1","0","3"]`, List: true, Repeat: true}
+	factor := types.Input{Name: "Factor", Value: "a"}
+
+	f := newRepeatTestFuncs(types.Function{Name: "Multiply", Repeaterrorpolicy: types.RepeatContinue, Inputs: []types.Input{qty, factor}})
+	f.Execute()
+	outputs := f.FuncCachedVariables["Multiply"].(map[string]interface{})
+	if !reflect.DeepEqual(outputs["Result"], []interface{}{"1xa", nil, "3xa"}) {
+		t.Errorf("Result = %v", outputs["Result"])
+	}
+	errs := outputs["IterationErrors"].([]interface{})
+	if errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Errorf("IterationErrors = %v", errs)
+	}
+
+	f = newRepeatTestFuncs(types.Function{Name: "Multiply", Repeaterrorpolicy: types.RepeatSkip, Inputs: []types.Input{qty, factor}})
+	f.Execute()
+	if outputs := f.FuncCachedVariables["Multiply"].(map[string]interface{}); outputs["IterationErrors"] != nil {
+		t.Errorf("IterationErrors = %v, want none with the skip policy", outputs["IterationErrors"])
+	}
+
+	f = newRepeatTestFuncs(types.Function{Name: "Multiply", Inputs: []types.Input{qty, factor}})
+	if bpmErr := executeError(f); bpmErr == nil || bpmErr.Details["iteration"] != "2" {
+		t.Errorf("Execute() expected the error of iteration 2, got %v", bpmErr)
+	}
+}
+
+func TestFuncs_RepeatConcurrency(t *testing.T) {
+	maxRunning := registerMultiplyType(t)
+	values := `["1","2","3","4","5","6","7","8"]`
+	f := newRepeatTestFuncs(types.Function{Name: "Multiply", Repeatconcurrency: 3, Inputs: []types.Input{
+		{Name: "Qty", Value: values, List: true, Repeat: true},
+		{Name: "Factor", Value: "b"},
+	}})
+	f.Execute()
+
+	want := []interface{}{"1xb", "2xb", "3xb", "4xb", "5xb", "6xb", "7xb", "8xb"}
+	if got := f.FuncCachedVariables["Multiply"].(map[string]interface{})["Result"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Result = %v, want %v", got, want)
+	}
+	if max := atomic.LoadInt32(maxRunning); max > 3 {
+		t.Errorf("%d iterations ran at the same time, want at most 3", max)
+	}
+}
+
+func TestFuncs_RepeatIterationCopy(t *testing.T) {
+	f := newRepeatTestFuncs(types.Function{Name: "Multiply", Inputs: []types.Input{{Name: "Qty", Value: "1"}}})
+	f.FunctionMappedInputs = map[string]interface{}{"Qty": "1"}
+
+	it := f.iterationCopy()
+	it.Fobj.Inputs[0].Value = "2"
+	it.FunctionMappedInputs["Qty"] = "2"
+	if f.Fobj.Inputs[0].Value != "1" || f.FunctionMappedInputs["Qty"] != "1" {
+		t.Errorf("inputs = %v, mapped inputs = %v, an iteration must not write the inputs of the function", f.Fobj.Inputs, f.FunctionMappedInputs)
+	}
+}
@@ -18,10 +18,13 @@ type ScriptExecutor interface {
 
 // ScriptExecutionConfig holds configuration for script execution
 type ScriptExecutionConfig struct {
-	Timeout        time.Duration
-	MaxMemoryMB    int
-	AllowedModules []string
-	SandboxEnabled bool
+	Timeout         time.Duration
+	MaxMemoryMB     int
+	MaxCPUSeconds   int // CPU time of a call, 0 for no limit besides the timeout
+	AllowedModules  []string
+	SandboxEnabled  bool
+	AllowFileSystem bool // the sandbox allows opening files
+	AllowNetwork    bool // the sandbox allows opening sockets, the modules still have to be allowed
 }
 
 // DefaultScriptConfig returns default script execution configuration
@@ -0,0 +1,578 @@
+package funcs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SCRIPT LIBRARY DESIGN: shared scripts are versioned libraries in the document DB. The JavaScript functions load
+// them with require("name") for the default version or require("name@version") for a pinned version, the Go
+// expressions call the functions the libraries export the same way. A library is a CommonJS module: it sets
+// module.exports or the properties of exports and can require other libraries. A saved version does not change,
+// so its compiled program is cached for the lifetime of the process, only the default version of a name is
+// looked up again after scriptLibraryDefaultTimeout or a save on this node.
+// The scripts and libraries get the host API iac: iac.log, the read-only iac.session and iac.query, which runs
+// a SELECT statement in the transaction of the transaction code. The statement runs inside a savepoint that is
+// rolled back after the rows are read, so what the functions it calls write does not stay in the transaction.
+
+// ScriptLibraryCollection is the document collection that keeps the script libraries
+const ScriptLibraryCollection = "Script_Library"
+
+// ScriptQueryMaxRows is the number of rows iac.query returns at most
+const ScriptQueryMaxRows = 1000
+
+const scriptLibraryDefaultTimeout = time.Minute
+
+var (
+	scriptQuerySeq           uint64 // makes the savepoint names of the queries unique within a transaction
+	scriptLibraryMu          sync.RWMutex
+	registeredScriptLibrary  = map[string]*types.ScriptLibrary{} // by name@version and by name for the default version
+	loadedScriptLibrary      = map[string]*types.ScriptLibrary{} // by name@version
+	defaultScriptLibrary     = map[string]cachedDefaultScriptLibrary{}
+	compiledScriptLibrary    = map[string]*goja.Program{}
+	scriptRequirePattern     = regexp.MustCompile(`require\(\s*["']([^"']+)["']\s*\)`)
+	scriptQueryDeniedPattern = regexp.MustCompile(`(?i)\b(insert|update|delete|merge|drop|alter|create|truncate|grant|revoke|exec|execute|call|into|lock|setval|nextval|get_lock|pg_advisory_\w+)\b`)
+	scriptFreezeSource       = `(function freeze(o) {
+	Object.getOwnPropertyNames(o).forEach(function (k) {
+		var v = o[k];
+		if (v !== null && typeof v === "object") { freeze(v); }
+	});
+	return Object.freeze(o);
+})`
+	scriptFreezeProgram = goja.MustCompile("iac:freeze", scriptFreezeSource, true)
+)
+
+type cachedDefaultScriptLibrary struct {
+	library *types.ScriptLibrary
+	expires time.Time
+}
+
+// RegisterScriptLibrary adds a library that is not kept in the document DB, like the libraries shipped with an
+// installation. A registered library wins over a library of the same name and version in the document DB.
+func RegisterScriptLibrary(library types.ScriptLibrary) error {
+	if err := library.Validate(); err != nil {
+		return err
+	}
+
+	scriptLibraryMu.Lock()
+	defer scriptLibraryMu.Unlock()
+	key := library.Key()
+	registeredScriptLibrary[key] = &library
+	delete(compiledScriptLibrary, key)
+	if _, ok := registeredScriptLibrary[library.Name]; library.IsDefault || !ok {
+		registeredScriptLibrary[library.Name] = &library
+	}
+	return nil
+}
+
+// UnregisterScriptLibrary removes all registered versions of the library
+func UnregisterScriptLibrary(name string) {
+	scriptLibraryMu.Lock()
+	defer scriptLibraryMu.Unlock()
+	for key, library := range registeredScriptLibrary {
+		if library.Name == name {
+			delete(registeredScriptLibrary, key)
+			delete(compiledScriptLibrary, library.Key())
+		}
+	}
+}
+
+// GetScriptLibrary returns the version of the library, the default version if version is empty
+func GetScriptLibrary(name string, version string, DBCon *documents.DocDB) (*types.ScriptLibrary, error) {
+	key := name
+	if version != "" {
+		key = name + "@" + version
+	}
+
+	scriptLibraryMu.RLock()
+	library, ok := registeredScriptLibrary[key]
+	if !ok && version != "" {
+		library, ok = loadedScriptLibrary[key]
+	}
+	if !ok && version == "" {
+		if cached, found := defaultScriptLibrary[name]; found && time.Now().Before(cached.expires) {
+			library, ok = cached.library, true
+		}
+	}
+	scriptLibraryMu.RUnlock()
+	if ok {
+		return library, nil
+	}
+
+	if DBCon == nil {
+		DBCon = documents.DocDBCon
+	}
+	if DBCon == nil {
+		return nil, fmt.Errorf("script library %s not found", key)
+	}
+
+	filter := bson.M{"name": name, "isdefault": true}
+	if version != "" {
+		filter = bson.M{"name": name, "version": version}
+	}
+	items, err := DBCon.QueryCollection(ScriptLibraryCollection, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("script library %s not found", key)
+	}
+	library, err = scriptLibraryFromItem(items[0])
+	if err != nil {
+		return nil, err
+	}
+
+	scriptLibraryMu.Lock()
+	loadedScriptLibrary[library.Key()] = library
+	if version == "" {
+		defaultScriptLibrary[name] = cachedDefaultScriptLibrary{library: library, expires: time.Now().Add(scriptLibraryDefaultTimeout)}
+	}
+	scriptLibraryMu.Unlock()
+	return library, nil
+}
+
+// GetScriptLibraries returns all versions of the library, or all libraries if name is empty
+func GetScriptLibraries(name string, DBCon *documents.DocDB) ([]*types.ScriptLibrary, error) {
+	filter := bson.M{}
+	if name != "" {
+		filter = bson.M{"name": name}
+	}
+	items, err := DBCon.QueryCollection(ScriptLibraryCollection, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	libraries := make([]*types.ScriptLibrary, 0, len(items))
+	for _, item := range items {
+		library, err := scriptLibraryFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		libraries = append(libraries, library)
+	}
+	return libraries, nil
+}
+
+// SaveScriptLibrary saves a new version of a library. The first version of a library becomes its default version.
+// The library must compile, an existing version cannot be replaced.
+func SaveScriptLibrary(library *types.ScriptLibrary, DBCon *documents.DocDB) error {
+	if err := library.Validate(); err != nil {
+		return err
+	}
+	if _, err := goja.Compile(library.Key(), scriptLibrarySource(library), false); err != nil {
+		return types.NewValidationError(fmt.Sprintf("script library %s does not compile", library.Key()), err)
+	}
+
+	items, err := DBCon.QueryCollection(ScriptLibraryCollection, bson.M{"name": library.Name}, nil)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if fmt.Sprint(item["version"]) == library.Version {
+			return fmt.Errorf("script library %s already exists", library.Key())
+		}
+	}
+	if len(items) == 0 {
+		library.IsDefault = true
+	}
+	if library.IsDefault {
+		if err := clearDefaultScriptLibrary(library.Name, DBCon); err != nil {
+			return err
+		}
+	}
+	if _, err := DBCon.InsertCollection(ScriptLibraryCollection, library); err != nil {
+		return err
+	}
+
+	invalidateScriptLibrary(library.Name)
+	return nil
+}
+
+// SetDefaultScriptLibrary makes the version the default version of the library
+func SetDefaultScriptLibrary(name string, version string, DBCon *documents.DocDB) error {
+	items, err := DBCon.QueryCollection(ScriptLibraryCollection, bson.M{"name": name, "version": version}, nil)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("script library %s@%s not found", name, version)
+	}
+	if err := clearDefaultScriptLibrary(name, DBCon); err != nil {
+		return err
+	}
+	if err := DBCon.UpdateCollection(ScriptLibraryCollection, bson.M{"name": name, "version": version}, bson.M{"$set": bson.M{"isdefault": true}}, nil); err != nil {
+		return err
+	}
+
+	invalidateScriptLibrary(name)
+	return nil
+}
+
+func clearDefaultScriptLibrary(name string, DBCon *documents.DocDB) error {
+	items, err := DBCon.QueryCollection(ScriptLibraryCollection, bson.M{"name": name, "isdefault": true}, nil)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		filter := bson.M{"name": name, "version": item["version"]}
+		if err := DBCon.UpdateCollection(ScriptLibraryCollection, filter, bson.M{"$set": bson.M{"isdefault": false}}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidateScriptLibrary drops the cached default version of the library, the other nodes load it again after scriptLibraryDefaultTimeout
+func invalidateScriptLibrary(name string) {
+	scriptLibraryMu.Lock()
+	defer scriptLibraryMu.Unlock()
+	delete(defaultScriptLibrary, name)
+}
+
+func scriptLibraryFromItem(item bson.M) (*types.ScriptLibrary, error) {
+	jsonString, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	library := &types.ScriptLibrary{}
+	if err := json.Unmarshal(jsonString, library); err != nil {
+		return nil, err
+	}
+	return library, nil
+}
+
+// scriptLibrarySource wraps the library into the function the module is initialized with
+func scriptLibrarySource(library *types.ScriptLibrary) string {
+	return "(function (exports, module, require, iac) {\n" + library.Content + "\n})"
+}
+
+// compileScriptLibrary returns the cached program of the library version
+func compileScriptLibrary(library *types.ScriptLibrary) (*goja.Program, error) {
+	key := library.Key()
+	scriptLibraryMu.RLock()
+	program, ok := compiledScriptLibrary[key]
+	scriptLibraryMu.RUnlock()
+	if ok {
+		return program, nil
+	}
+
+	program, err := goja.Compile(key, scriptLibrarySource(library), false)
+	if err != nil {
+		return nil, types.NewScriptError("Javascript", fmt.Sprintf("script library %s does not compile", key), err)
+	}
+	scriptLibraryMu.Lock()
+	compiledScriptLibrary[key] = program
+	scriptLibraryMu.Unlock()
+	return program, nil
+}
+
+// checkScriptRequires loads and compiles the libraries the script requires with a literal name
+func checkScriptRequires(script string, DBCon *documents.DocDB) error {
+	for _, match := range scriptRequirePattern.FindAllStringSubmatch(script, -1) {
+		name, version, err := types.ParseScriptLibraryReference(match[1])
+		if err != nil {
+			return err
+		}
+		library, err := GetScriptLibrary(name, version, DBCon)
+		if err != nil {
+			return err
+		}
+		if _, err := compileScriptLibrary(library); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scriptHost is what the host API of a script is bound to
+type scriptHost struct {
+	Name    string
+	User    string
+	Log     logger.Log
+	Session map[string]interface{}
+	DBTx    *sql.Tx
+	Ctx     context.Context
+	DocDB   *documents.DocDB
+}
+
+func scriptHostOf(f *Funcs) scriptHost {
+	user := "System"
+	if name, ok := f.SystemSession["User"].(string); ok {
+		user = name
+	}
+	return scriptHost{Name: f.Fobj.Name, User: user, Log: f.iLog, Session: f.SystemSession, DBTx: f.DBTx, Ctx: f.Ctx, DocDB: f.DocDBCon}
+}
+
+// scriptRuntime is a JavaScript VM with require and the host API. A module is initialized once per runtime.
+type scriptRuntime struct {
+	vm      *goja.Runtime
+	host    scriptHost
+	modules map[string]*goja.Object
+	loading map[string]bool
+}
+
+func newScriptRuntime(host scriptHost) *scriptRuntime {
+	r := &scriptRuntime{vm: goja.New(), host: host, modules: map[string]*goja.Object{}, loading: map[string]bool{}}
+	r.vm.Set("require", r.require)
+	r.vm.Set("iac", r.hostAPI())
+	return r
+}
+
+// watch interrupts the running script when the context is done, the returned function stops watching
+func (r *scriptRuntime) watch(ctx context.Context) func() {
+	if ctx == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.vm.Interrupt(ctx.Err())
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+func (r *scriptRuntime) require(reference string) goja.Value {
+	module, err := r.load(reference)
+	if err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+	return module.Get("exports")
+}
+
+// load returns the module of the library reference, it is initialized on the first load
+func (r *scriptRuntime) load(reference string) (*goja.Object, error) {
+	name, version, err := types.ParseScriptLibraryReference(reference)
+	if err != nil {
+		return nil, err
+	}
+	library, err := GetScriptLibrary(name, version, r.host.DocDB)
+	if err != nil {
+		return nil, err
+	}
+	key := library.Key()
+	if module, ok := r.modules[key]; ok {
+		return module, nil
+	}
+	if r.loading[key] {
+		return nil, fmt.Errorf("script library %s requires itself", key)
+	}
+
+	program, err := compileScriptLibrary(library)
+	if err != nil {
+		return nil, err
+	}
+	r.loading[key] = true
+	defer delete(r.loading, key)
+
+	r.host.Log.Debug(fmt.Sprintf("Load script library %s for function %s", key, r.host.Name))
+	wrapper, err := r.vm.RunProgram(program)
+	if err != nil {
+		return nil, err
+	}
+	initialize, ok := goja.AssertFunction(wrapper)
+	if !ok {
+		return nil, fmt.Errorf("script library %s is not a module", key)
+	}
+	module := r.vm.NewObject()
+	exports := r.vm.NewObject()
+	module.Set("exports", exports)
+	if _, err := initialize(goja.Undefined(), exports, module, r.vm.Get("require"), r.vm.Get("iac")); err != nil {
+		return nil, err
+	}
+	r.modules[key] = module
+	return module, nil
+}
+
+// exprRequire loads a library for a Go expression, the exported functions are called as func(args...) (value, error)
+func (r *scriptRuntime) exprRequire(reference string) (map[string]interface{}, error) {
+	module, err := r.load(reference)
+	if err != nil {
+		return nil, err
+	}
+	exports, ok := module.Get("exports").(*goja.Object)
+	if !ok {
+		return nil, fmt.Errorf("script library %s does not export an object", reference)
+	}
+
+	result := make(map[string]interface{})
+	for _, key := range exports.Keys() {
+		value := exports.Get(key)
+		if function, ok := goja.AssertFunction(value); ok {
+			result[key] = r.exprFunction(function)
+		} else {
+			result[key] = value.Export()
+		}
+	}
+	return result, nil
+}
+
+func (r *scriptRuntime) exprFunction(function goja.Callable) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		values := make([]goja.Value, len(args))
+		for i, arg := range args {
+			values[i] = r.vm.ToValue(types.ScriptValue(arg))
+		}
+		value, err := function(goja.Undefined(), values...)
+		if err != nil {
+			return nil, err
+		}
+		return value.Export(), nil
+	}
+}
+
+// hostAPI creates the iac object of the scripts
+func (r *scriptRuntime) hostAPI() *goja.Object {
+	api := r.vm.NewObject()
+
+	log := r.vm.NewObject()
+	log.Set("debug", r.logFunction(r.host.Log.Debug))
+	log.Set("info", r.logFunction(r.host.Log.Info))
+	log.Set("warn", r.logFunction(r.host.Log.Warn))
+	log.Set("error", r.logFunction(r.host.Log.Error))
+	api.Set("log", log)
+	api.Set("session", r.session())
+	api.Set("query", r.query)
+
+	freeze, err := r.vm.RunProgram(scriptFreezeProgram)
+	if err == nil {
+		if function, ok := goja.AssertFunction(freeze); ok {
+			function(goja.Undefined(), api)
+		}
+	}
+	return api
+}
+
+func (r *scriptRuntime) logFunction(write func(string)) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		parts := make([]string, len(call.Arguments))
+		for i, argument := range call.Arguments {
+			parts[i] = argument.String()
+		}
+		write(fmt.Sprintf("Script %s: %s", r.host.Name, strings.Join(parts, " ")))
+		return goja.Undefined()
+	}
+}
+
+// session copies the system session into plain script objects, so the script cannot change the session
+func (r *scriptRuntime) session() goja.Value {
+	jsonString, err := json.Marshal(r.host.Session)
+	if err != nil || r.host.Session == nil {
+		r.host.Log.Debug(fmt.Sprintf("Script %s gets an empty session", r.host.Name))
+		return r.vm.NewObject()
+	}
+	parse, _ := goja.AssertFunction(r.vm.Get("JSON").ToObject(r.vm).Get("parse"))
+	session, err := parse(goja.Undefined(), r.vm.ToValue(string(jsonString)))
+	if err != nil {
+		return r.vm.NewObject()
+	}
+	return session
+}
+
+// query runs a SELECT statement with positional parameters in the transaction of the script and returns the rows
+// as objects. The statement runs inside a savepoint that is rolled back, the transaction keeps no change of it.
+func (r *scriptRuntime) query(call goja.FunctionCall) goja.Value {
+	statement := call.Argument(0).String()
+	if err := checkScriptQuery(statement); err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+	if r.host.DBTx == nil {
+		panic(r.vm.NewGoError(fmt.Errorf("iac.query needs the database transaction of the transaction code")))
+	}
+
+	args := make([]interface{}, 0, len(call.Arguments))
+	for _, argument := range call.Arguments[1:] {
+		args = append(args, argument.Export())
+	}
+	ctx := r.host.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	r.host.Log.Debug(fmt.Sprintf("Script %s query: %s %v", r.host.Name, statement, args))
+	dbop := dbconn.NewDBOperation(r.host.User, r.host.DBTx, "Script Query")
+	savepoint := fmt.Sprintf("iac_script_%d", atomic.AddUint64(&scriptQuerySeq, 1))
+	if err := dbop.Savepoint(savepoint); err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+	result, err := readScriptQuery(ctx, r.host.DBTx, statement, args)
+	if rollbackErr := dbop.RollbackToSavepoint(savepoint); rollbackErr != nil && err == nil {
+		err = rollbackErr
+	}
+	if releaseErr := dbop.ReleaseSavepoint(savepoint); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+	if err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+	return r.vm.ToValue(result)
+}
+
+// readScriptQuery runs the statement in the transaction and reads all of its rows, the rows are closed on return
+func readScriptQuery(ctx context.Context, tx *sql.Tx, statement string, args []interface{}) ([]interface{}, error) {
+	rows, err := tx.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0)
+	for rows.Next() {
+		if len(result) == ScriptQueryMaxRows {
+			return nil, fmt.Errorf("the query returns more than %d rows", ScriptQueryMaxRows)
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if bytes, ok := values[i].([]byte); ok {
+				values[i] = string(bytes)
+			}
+			row[column] = types.ScriptValue(values[i])
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// checkScriptQuery accepts a single SELECT statement that does not change data
+func checkScriptQuery(statement string) error {
+	text := strings.TrimSuffix(strings.TrimSpace(statement), ";")
+	upper := strings.ToUpper(text)
+	if !strings.HasPrefix(upper, "SELECT") && !strings.HasPrefix(upper, "WITH") {
+		return types.NewValidationError("iac.query only runs SELECT statements", nil).WithDetail("query", statement)
+	}
+	if strings.Contains(text, ";") {
+		return types.NewValidationError("iac.query runs a single statement", nil).WithDetail("query", statement)
+	}
+	if word := scriptQueryDeniedPattern.FindString(text); word != "" {
+		return types.NewValidationError(fmt.Sprintf("iac.query does not allow %s in a query", strings.ToUpper(word)), nil).WithDetail("query", statement)
+	}
+	return nil
+}
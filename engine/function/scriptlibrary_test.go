@@ -0,0 +1,193 @@
+package funcs
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/logs"
+	"github.com/mdaxf/iac/logger"
+)
+
+var registerScriptQueryDriverOnce sync.Once
+
+// newScriptQueryTestTx opens a transaction of an in-memory database with a steps table and the SQL function
+// record_step(name), which inserts a step the way a user function of the database would
+func newScriptQueryTestTx(t *testing.T) *sql.Tx {
+	t.Helper()
+	for _, log := range []**logs.IACLogger{&logger.Logger, &logger.DatabaseLogger} {
+		if *log == nil {
+			*log = logs.NewLogger()
+		}
+	}
+	registerScriptQueryDriverOnce.Do(func() {
+		sql.Register("sqlite3_script_query", &sqlite3.SQLiteDriver{ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("record_step", func(name string) (int64, error) {
+				_, err := conn.Exec(`INSERT INTO steps (name) VALUES (?)`, []driver.Value{name})
+				return 1, err
+			}, false)
+		}})
+	})
+
+	db, err := sql.Open("sqlite3_script_query", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open the test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE steps (name TEXT)`); err != nil {
+		t.Fatalf("failed to create the test schema: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin the test transaction: %v", err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	if _, err := tx.Exec(`INSERT INTO steps (name) VALUES ('before')`); err != nil {
+		t.Fatalf("failed to insert the step: %v", err)
+	}
+	return tx
+}
+
+func registerTestScriptLibraries(t *testing.T) {
+	t.Helper()
+	libraries := []types.ScriptLibrary{
+		{Name: "test/units", Version: "1", IsDefault: true, Content: `exports.toKg = function (lb) { return lb * 0.5; }; exports.version = 1;`},
+		{Name: "test/units", Version: "2", Content: `exports.toKg = function (lb) { return lb * 0.45359237; }; exports.version = 2;`},
+		{Name: "test/weights", Version: "1", Content: `var units = require("test/units");
+			module.exports = { net: function (gross, tare) { return units.toKg(gross - tare); }, user: iac.session.User };`},
+		{Name: "test/loop", Version: "1", Content: `require("test/loop");`},
+	}
+	for _, library := range libraries {
+		if err := RegisterScriptLibrary(library); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		UnregisterScriptLibrary("test/units")
+		UnregisterScriptLibrary("test/weights")
+		UnregisterScriptLibrary("test/loop")
+	})
+}
+
+func TestJSFuncs_RequireLibrary(t *testing.T) {
+	registerTestScriptLibraries(t)
+
+	content := `var weights = require("test/weights");
+		Net = weights.net(Gross, 4);
+		Pinned = require("test/units@2").version;
+		User = weights.user;
+		try { require("test/loop"); } catch (e) { Loop = String(e); }`
+	f := newTestFuncs(types.Function{Name: "Weigh", Functype: types.Javascript, Content: content,
+		Inputs:  []types.Input{{Name: "Gross", Datatype: types.Float, Value: "10"}},
+		Outputs: []types.Output{{Name: "Net"}, {Name: "Pinned"}, {Name: "User"}, {Name: "Loop"}}})
+	f.SystemSession["User"] = "operator"
+	f.Execute()
+
+	outputs := f.FuncCachedVariables["Weigh"].(map[string]interface{})
+	if outputs["Net"] != "3" || outputs["Pinned"] != "2" || outputs["User"] != "operator" {
+		t.Errorf("outputs = %v, want the default and the pinned library version", outputs)
+	}
+	if loop, _ := outputs["Loop"].(string); !strings.Contains(loop, "requires itself") {
+		t.Errorf("Loop = %v, want the require cycle reported", outputs["Loop"])
+	}
+}
+
+func TestJSFuncs_HostAPI(t *testing.T) {
+	content := `iac.session.User = "admin";
+		User = iac.session.User;
+		try { iac.query("DELETE FROM orders"); } catch (e) { Denied = String(e); }
+		try { iac.query("SELECT * FROM orders"); } catch (e) { NoTx = String(e); }
+		iac.log.info("checked", User);`
+	f := newTestFuncs(types.Function{Name: "Host", Functype: types.Javascript, Content: content,
+		Outputs: []types.Output{{Name: "User"}, {Name: "Denied"}, {Name: "NoTx"}}})
+	f.SystemSession["User"] = "operator"
+	f.Execute()
+
+	outputs := f.FuncCachedVariables["Host"].(map[string]interface{})
+	if outputs["User"] != "operator" {
+		t.Errorf("User = %v, want the session unchanged", outputs["User"])
+	}
+	if denied, _ := outputs["Denied"].(string); !strings.Contains(denied, "SELECT") {
+		t.Errorf("Denied = %v, want the delete refused", outputs["Denied"])
+	}
+	if noTx, _ := outputs["NoTx"].(string); !strings.Contains(noTx, "transaction") {
+		t.Errorf("NoTx = %v, want the missing transaction reported", outputs["NoTx"])
+	}
+}
+
+func TestJSFuncs_QueryRollsBackItsChanges(t *testing.T) {
+	tx := newScriptQueryTestTx(t)
+	content := `Recorded = iac.query("SELECT record_step('script') AS n")[0].n;
+		Seen = iac.query("SELECT name FROM steps").map(function (row) { return row.name; }).join(",");`
+	f := newTestFuncs(types.Function{Name: "Probe", Functype: types.Javascript, Content: content,
+		Outputs: []types.Output{{Name: "Recorded"}, {Name: "Seen"}}})
+	f.DBTx = tx
+	f.Execute()
+
+	outputs := f.FuncCachedVariables["Probe"].(map[string]interface{})
+	if fmt.Sprint(outputs["Recorded"]) != "1" || outputs["Seen"] != "before" {
+		t.Errorf("outputs = %v, want the step of the query rolled back and the step of the transaction seen", outputs)
+	}
+	var steps int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM steps`).Scan(&steps); err != nil || steps != 1 {
+		t.Errorf("the transaction has %d steps (%v), want the one inserted before the script", steps, err)
+	}
+}
+
+func TestGoExpr_RequireLibrary(t *testing.T) {
+	registerTestScriptLibraries(t)
+
+	f := newTestFuncs(types.Function{Name: "Convert", Functype: types.GoExpr, Content: `require("test/units@2").toKg(Pounds) < 4.6`,
+		Inputs:  []types.Input{{Name: "Pounds", Datatype: types.Float, Value: "10"}},
+		Outputs: []types.Output{{Name: "Heavy"}}})
+	f.Execute()
+
+	if outputs := f.FuncCachedVariables["Convert"].(map[string]interface{}); outputs["Heavy"] != true {
+		t.Errorf("outputs = %v, want Heavy", outputs)
+	}
+}
+
+func TestJSFuncs_ValidateRequires(t *testing.T) {
+	registerTestScriptLibraries(t)
+
+	valid := newTestFuncs(types.Function{Name: "Valid", Content: `var u = require("test/units@1"); Result = u.toKg(2);`})
+	if ok, err := (&JSFuncs{}).Validate(valid); !ok || err != nil {
+		t.Errorf("Validate() = %v, %v", ok, err)
+	}
+	missing := newTestFuncs(types.Function{Name: "Missing", Content: `var u = require("test/units@9");`})
+	if ok, _ := (&JSFuncs{}).Validate(missing); ok {
+		t.Errorf("Validate() accepted a missing library version")
+	}
+}
+
+func TestCheckScriptQuery(t *testing.T) {
+	allowed := []string{
+		"SELECT * FROM orders WHERE id = ?",
+		"with open as (select id from orders) select * from open;",
+	}
+	for _, statement := range allowed {
+		if err := checkScriptQuery(statement); err != nil {
+			t.Errorf("checkScriptQuery(%s) = %v", statement, err)
+		}
+	}
+	denied := []string{
+		"UPDATE orders SET status = 1",
+		"SELECT 1; DROP TABLE orders",
+		"SELECT * INTO backup FROM orders",
+		"WITH x AS (DELETE FROM orders RETURNING *) SELECT * FROM x",
+		"SELECT setval('orders_id_seq', 1)",
+		"SELECT pg_advisory_lock(42)",
+	}
+	for _, statement := range denied {
+		if err := checkScriptQuery(statement); err == nil {
+			t.Errorf("checkScriptQuery(%s) accepted a statement that changes data", statement)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	//	dapr "github.com/dapr/go-sdk/client"
 	"github.com/mdaxf/iac/com"
+	"github.com/mdaxf/iac/engine/types"
 )
 
 type SendMessageFuncs struct {
@@ -55,6 +56,10 @@ func (cf *SendMessageFuncs) Execute(f *Funcs) {
 		return
 	}
 
+	if f.sendToOutbox(types.MessageChannelBus, Topic, "", data) {
+		return
+	}
+
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		f.iLog.Error(fmt.Sprintf("Error:%v", err))
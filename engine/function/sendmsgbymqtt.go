@@ -2,9 +2,11 @@ package funcs
 
 import (
 	//	"context"
-
+	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/mdaxf/iac/engine/types"
 )
 
 type SendMessagebyMQTT struct {
@@ -29,7 +31,7 @@ func (w *SendMessagebyMQTT) Execute(f *Funcs) {
 
 	namelist, valuelist, _ := f.SetInputs()
 	f.iLog.Debug(fmt.Sprintf("SendMessagebyMQTT Execute: %v, %v", namelist, valuelist))
-	//activeMQCfg := ""
+	broker := ""
 	Topic := ""
 	data := make(map[string]interface{})
 	for i, name := range namelist {
@@ -37,21 +39,39 @@ func (w *SendMessagebyMQTT) Execute(f *Funcs) {
 			Topic = valuelist[i]
 
 			continue
-		} else if name == "ActiveMQ" {
-			//	activeMQCfg = valuelist[i]
+		} else if name == "Server" {
+			broker = valuelist[i]
 		}
 		data[name] = valuelist[i]
 	}
 
 	if Topic == "" {
-		f.iLog.Error(fmt.Sprintf("SendMessagebyActiveMQ validate wrong: %v", "Topic is empty"))
+		f.iLog.Error(fmt.Sprintf("SendMessagebyMQTT validate wrong: %v", "Topic is empty"))
 		return
 	}
 
-	// get activeMQ connection
+	if broker == "" {
+		f.iLog.Error(fmt.Sprintf("SendMessagebyMQTT validate wrong: %v", "Server is empty"))
+		return
+	}
 
-	// product the data
+	if f.sendToOutbox(types.MessageChannelMQTT, Topic, broker, data) {
+		return
+	}
 
-	// send the data to activeMQ
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		f.iLog.Error(fmt.Sprintf("Error:%v", err))
+		return
+	}
+
+	message := &types.OutboundMessage{Channel: types.MessageChannelMQTT, Topic: Topic, Server: broker, Payload: string(jsonData)}
+	if err := PublishMessage(f.Ctx, message); err != nil {
+		f.iLog.Error(fmt.Sprintf("Failed to publish the message to topic %s: %v", Topic, err))
+		f.ErrorMessage = fmt.Sprintf("Failed to publish the message to topic %s: %v", Topic, err)
+		return
+	}
 
+	outputs := make(map[string][]interface{})
+	f.SetOutputs(f.convertMap(outputs))
 }
@@ -4,12 +4,67 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mdaxf/iac-signalr/signalr"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/types"
+
 	//	"github.com/mdaxf/iac/engine/callback"
 	"github.com/mdaxf/iac/framework/callback_mgr"
 )
 
+// SUB TRANSACTION CODE DESIGN: the content of a sub transaction code function selects how the transaction code runs.
+// Without content it runs synchronously in the transaction of the parent. In the aftercommit mode a job is written
+// in the transaction of the parent, so the job system only runs the transaction code once the parent has committed.
+// In the await mode the transaction code runs in its own transaction and the parent waits for its outputs up to the
+// timeout. Both modes get an execution id, pass the correlation id of the parent on and keep a child execution
+// record that lists them under the execution of the parent.
+
+// ChildTranCodeExecutor runs a sub transaction code in its own transaction, it is registered by the trancode package
+type ChildTranCodeExecutor func(ctx context.Context, request *types.SubTranCodeRequest, sc signalr.Client, docDB *documents.DocDB) (map[string]interface{}, error)
+
+// SubTranCodeEnqueuer writes the job of a sub transaction code in the transaction of the parent, it is registered by the job system
+type SubTranCodeEnqueuer func(tx *sql.Tx, request *types.SubTranCodeRequest) error
+
+var (
+	subTranCodeMu         sync.RWMutex
+	childTranCodeExecutor ChildTranCodeExecutor
+	subTranCodeEnqueuer   SubTranCodeEnqueuer
+)
+
+// RegisterChildTranCodeExecutor sets the executor of the awaited sub transaction codes
+func RegisterChildTranCodeExecutor(executor ChildTranCodeExecutor) {
+	subTranCodeMu.Lock()
+	defer subTranCodeMu.Unlock()
+	childTranCodeExecutor = executor
+}
+
+// RegisterSubTranCodeEnqueuer sets the enqueuer of the sub transaction codes that run after the commit of the parent
+func RegisterSubTranCodeEnqueuer(enqueuer SubTranCodeEnqueuer) {
+	subTranCodeMu.Lock()
+	defer subTranCodeMu.Unlock()
+	subTranCodeEnqueuer = enqueuer
+}
+
+type executionIdentityContextKey struct{}
+
+// WithExecutionIdentity returns a context carrying the identity of the running transaction code execution
+func WithExecutionIdentity(ctx context.Context, identity *types.ExecutionIdentity) context.Context {
+	return context.WithValue(ctx, executionIdentityContextKey{}, identity)
+}
+
+// ExecutionIdentityFromContext returns the identity of the running execution, or nil outside of an execution
+func ExecutionIdentityFromContext(ctx context.Context) *types.ExecutionIdentity {
+	if ctx == nil {
+		return nil
+	}
+	identity, _ := ctx.Value(executionIdentityContextKey{}).(*types.ExecutionIdentity)
+	return identity
+}
+
 type TranFlow interface {
 	Execute(string, map[string]interface{}, context.Context, context.CancelFunc, *sql.Tx) (map[string]interface{}, error)
 }
@@ -46,6 +101,11 @@ func (cf *SubTranCodeFuncs) Execute(f *Funcs) {
 			f.iLog.Error(fmt.Sprintf("There is error to engine.funcs.SubTransCode.Execute with error: %s", err))
 			f.CancelExecution(fmt.Sprintf("There is error to engine.funcs.SubTransCode.Execute with error: %s", err))
 			f.ErrorMessage = fmt.Sprintf("There is error to engine.funcs.SubTransCode.Execute with error: %s", err)
+
+			// the failures of the asynchronous modes fail the parent
+			if bpmErr, ok := err.(*types.BPMError); ok {
+				panic(bpmErr)
+			}
 			return
 		}
 	}()
@@ -64,6 +124,15 @@ func (cf *SubTranCodeFuncs) Execute(f *Funcs) {
 		return
 	}
 
+	invocation, err := types.ParseSubTranCodeInvocation(f.Fobj.Content)
+	if err != nil {
+		panic(types.NewValidationError(fmt.Sprintf("Function %s has an invalid sub transaction code invocation", f.Fobj.Name), err))
+	}
+	if invocation.Mode != types.SubTranCodeSync {
+		cf.executeChild(f, tcode, invocation, mappedinputs)
+		return
+	}
+
 	f.iLog.Debug(fmt.Sprintf("Executing subtran function to call transaction code: %v with inputs %s", tcode, mappedinputs))
 
 	outputs, err := callback_mgr.CallBackFunc("TranCode_Execute", tcode, mappedinputs, f.SignalRClient, f.DocDBCon, f.Ctx, f.CtxCancel, f.DBTx)
@@ -76,6 +145,96 @@ func (cf *SubTranCodeFuncs) Execute(f *Funcs) {
 	f.SetOutputs(convertSliceToMap(outputs))
 }
 
+// executeChild runs the sub transaction code outside of the transaction of the parent
+func (cf *SubTranCodeFuncs) executeChild(f *Funcs, tcode string, invocation *types.SubTranCodeInvocation, mappedinputs map[string]interface{}) {
+	inputs := make(map[string]interface{}, len(mappedinputs))
+	for name, value := range mappedinputs {
+		if name != "TranCode" {
+			inputs[name] = value
+		}
+	}
+
+	request := &types.SubTranCodeRequest{
+		ExecutionID:  uuid.New().String(),
+		TranCodeName: tcode,
+		Mode:         invocation.Mode,
+		Inputs:       inputs,
+		Priority:     invocation.Priority,
+		MaxRetries:   invocation.MaxRetries,
+	}
+	if parent := ExecutionIdentityFromContext(f.Ctx); parent != nil {
+		request.ParentExecutionID = parent.ExecutionID
+		request.CorrelationID = parent.CorrelationID
+		request.ParentTranCode = parent.TranCodeName
+	}
+	if request.CorrelationID == "" {
+		request.CorrelationID = request.ExecutionID
+	}
+	request.UserNo, _ = f.SystemSession["UserNo"].(string)
+	request.ClientID, _ = f.SystemSession["ClientID"].(string)
+
+	subTranCodeMu.RLock()
+	executor, enqueuer := childTranCodeExecutor, subTranCodeEnqueuer
+	subTranCodeMu.RUnlock()
+
+	if invocation.Mode == types.SubTranCodeAfterCommit {
+		if enqueuer == nil {
+			panic(types.NewExecutionError(fmt.Sprintf("No job system to queue the sub transaction code %s of function %s", tcode, f.Fobj.Name), nil))
+		}
+		if err := enqueuer(f.DBTx, request); err != nil {
+			panic(types.NewExecutionError(fmt.Sprintf("Failed to queue the sub transaction code %s of function %s", tcode, f.Fobj.Name), err))
+		}
+		f.iLog.Info(fmt.Sprintf("Queued the sub transaction code %s as execution %s after the commit of the parent", tcode, request.ExecutionID))
+		f.SetOutputs(map[string]interface{}{"ExecutionID": request.ExecutionID, "CorrelationID": request.CorrelationID})
+		return
+	}
+
+	if executor == nil {
+		panic(types.NewExecutionError(fmt.Sprintf("No executor for the sub transaction code %s of function %s", tcode, f.Fobj.Name), nil))
+	}
+	parentCtx := f.Ctx
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+	timeout := time.Duration(invocation.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(parentCtx, timeout)
+	defer cancel()
+
+	type childResult struct {
+		outputs map[string]interface{}
+		err     error
+	}
+	done := make(chan childResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- childResult{err: fmt.Errorf("%v", r)}
+			}
+		}()
+		outputs, err := executor(ctx, request, f.SignalRClient, f.DocDBCon)
+		done <- childResult{outputs: outputs, err: err}
+	}()
+
+	f.iLog.Debug(fmt.Sprintf("Waiting up to %v for the sub transaction code %s as execution %s", timeout, tcode, request.ExecutionID))
+	select {
+	case result := <-done:
+		if result.err != nil {
+			panic(types.NewExecutionError(fmt.Sprintf("Sub transaction code %s of function %s failed", tcode, f.Fobj.Name), result.err).
+				WithDetail("executionid", request.ExecutionID))
+		}
+		outputs := make(map[string]interface{}, len(result.outputs)+2)
+		for name, value := range result.outputs {
+			outputs[name] = value
+		}
+		outputs["ExecutionID"] = request.ExecutionID
+		outputs["CorrelationID"] = request.CorrelationID
+		f.SetOutputs(outputs)
+	case <-ctx.Done():
+		panic(types.NewTimeoutError(fmt.Sprintf("sub transaction code %s of function %s", tcode, f.Fobj.Name), timeout).
+			WithDetail("executionid", request.ExecutionID))
+	}
+}
+
 // Validate is a method of the SubTranCodeFuncs struct that validates the function.
 // It measures the performance of the function and logs the duration.
 // It returns a boolean value indicating the success of the validation and an error if any.
@@ -94,6 +253,9 @@ func (cf *SubTranCodeFuncs) Validate(f *Funcs) (bool, error) {
 		}
 	}() */
 
+	if _, err := types.ParseSubTranCodeInvocation(f.Fobj.Content); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
@@ -1,8 +1,15 @@
 package funcs
 
 import (
+	"context"
+	"database/sql"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/mdaxf/iac-signalr/signalr"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/types"
 )
 
 func TestSubTranCodeFuncs_Execute(t *testing.T) {
@@ -42,3 +49,81 @@ func Test_convertSliceToMap(t *testing.T) {
 		})
 	}
 }
+
+func newSubTranCodeTestFuncs(content string, outputs []types.Output) *Funcs {
+	f := newTestFuncs(types.Function{Name: "CallChild", Functype: types.SubTranCode, Content: content,
+		Inputs:  []types.Input{{Name: "TranCode", Value: "Child"}, {Name: "Qty", Datatype: types.Integer, Value: "2"}},
+		Outputs: outputs})
+	f.Ctx = WithExecutionIdentity(f.Ctx, &types.ExecutionIdentity{ExecutionID: "parent", CorrelationID: "request", TranCodeName: "Parent"})
+	return f
+}
+
+func TestSubTranCodeFuncs_Await(t *testing.T) {
+	executor := childTranCodeExecutor
+	defer RegisterChildTranCodeExecutor(executor)
+
+	var got *types.SubTranCodeRequest
+	RegisterChildTranCodeExecutor(func(ctx context.Context, request *types.SubTranCodeRequest, sc signalr.Client, docDB *documents.DocDB) (map[string]interface{}, error) {
+		got = request
+		if request.Inputs["Qty"] == 3 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return map[string]interface{}{"Total": 4}, nil
+	})
+
+	f := newSubTranCodeTestFuncs(`{"mode":"await","timeout":1}`, []types.Output{{Name: "Total"}, {Name: "ExecutionID"}, {Name: "CorrelationID"}})
+	f.Execute()
+	outputs := f.FuncCachedVariables["CallChild"].(map[string]interface{})
+	if outputs["Total"] != 4 || outputs["CorrelationID"] != "request" || outputs["ExecutionID"] != got.ExecutionID {
+		t.Errorf("outputs = %v, want the outputs of the child", outputs)
+	}
+	if got.ParentExecutionID != "parent" || got.ParentTranCode != "Parent" || got.TranCodeName != "Child" || got.Inputs["TranCode"] != nil {
+		t.Errorf("request = %+v, want the child of the parent execution", got)
+	}
+
+	f = newSubTranCodeTestFuncs(`{"mode":"await","timeout":1}`, nil)
+	f.Fobj.Inputs[1].Value = "3"
+	start := time.Now()
+	func() {
+		defer func() {
+			if bpmErr, ok := recover().(*types.BPMError); !ok || bpmErr.Category != types.ErrorCategoryTimeout {
+				t.Errorf("Execute() = %v, want the timeout error", bpmErr)
+			}
+		}()
+		f.Execute()
+	}()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Execute() waited %v for a timeout of 1s", elapsed)
+	}
+}
+
+func TestSubTranCodeFuncs_AfterCommit(t *testing.T) {
+	enqueuer := subTranCodeEnqueuer
+	defer RegisterSubTranCodeEnqueuer(enqueuer)
+
+	queued := []*types.SubTranCodeRequest{}
+	RegisterSubTranCodeEnqueuer(func(tx *sql.Tx, request *types.SubTranCodeRequest) error {
+		queued = append(queued, request)
+		return nil
+	})
+
+	f := newSubTranCodeTestFuncs(`{"mode":"aftercommit","priority":7}`, []types.Output{{Name: "ExecutionID"}})
+	f.Execute()
+	if len(queued) != 1 || queued[0].Priority != 7 || queued[0].CorrelationID != "request" || queued[0].Mode != types.SubTranCodeAfterCommit {
+		t.Fatalf("queued = %v, want one job of the parent's correlation", queued)
+	}
+	if outputs := f.FuncCachedVariables["CallChild"].(map[string]interface{}); outputs["ExecutionID"] != queued[0].ExecutionID {
+		t.Errorf("outputs = %v, want the execution id of the queued child", outputs)
+	}
+
+	RegisterSubTranCodeEnqueuer(nil)
+	func() {
+		defer func() {
+			if _, ok := recover().(*types.BPMError); !ok {
+				t.Errorf("Execute() without a job system must fail the parent")
+			}
+		}()
+		newSubTranCodeTestFuncs(`{"mode":"aftercommit"}`, nil).Execute()
+	}()
+}
@@ -68,7 +68,7 @@ func (cf *ThrowErrorFuncs) Execute(f *Funcs) {
 	// Create execution context for the error
 	execContext := &types.ExecutionContext{
 		FunctionName: f.Fobj.Name,
-		FunctionType: f.Fobj.Functype.String(),
+		FunctionType: f.Fobj.TypeName(),
 		ExecutionTime: startTime,
 	}
 
@@ -100,13 +100,9 @@ func (cf *ThrowErrorFuncs) Execute(f *Funcs) {
 	// Set error message for propagation
 	f.ErrorMessage = bpmErr.Error()
 
-	// Rollback the database transaction
+	// Rollback the database transaction and cancel the execution context
 	f.iLog.Info("Rolling back transaction due to ThrowError function")
-	f.DBTx.Rollback()
-
-	// Cancel execution context
-	f.CtxCancel()
-	f.Ctx.Done()
+	f.abortTransaction()
 
 	// Panic with the structured error to trigger rollback up the chain
 	// This is BY DESIGN - the panic/recover pattern ensures transaction atomicity
@@ -0,0 +1,121 @@
+package trancode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/cache"
+	"github.com/mdaxf/iac/logger"
+)
+
+// TRANCODE CACHE DESIGN: parsed transaction codes are kept in a local memory cache so an execution does not
+// query the document database and parse the definition every time. Every entry remembers the revision stamp
+// of its transaction code. Saving a definition replaces the stamp in the shared cache, so every node using the
+// same cache (redis, memcache, documentdb) drops its stale entries on the next lookup.
+
+const tranCodeRevisionKeyPrefix = "IAC_TranCode_Revision_"
+
+var (
+	tranCodeLocalCache   cache.Cache = cache.NewMemoryCache()
+	tranCodeSharedCache  cache.Cache
+	tranCodeCacheTimeout time.Duration
+	localRevisions       cache.Cache = cache.NewMemoryCache()
+)
+
+type cachedTranCode struct {
+	tcode    types.TranCode
+	revision string
+	modTime  time.Time
+}
+
+// InitTranCodeCache sets the shared cache holding the revision stamps and the lifetime of the parsed entries.
+// Without a shared cache the stamps are only kept on the local node.
+func InitTranCodeCache(shared cache.Cache, timeout time.Duration) {
+	tranCodeSharedCache = shared
+	tranCodeCacheTimeout = timeout
+}
+
+// InvalidateTranCode drops the cached definitions of all versions of the transaction code on all nodes
+func InvalidateTranCode(name string) {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeCache"}
+
+	revision := uuid.New().String()
+	localRevisions.Put(context.Background(), name, revision, 0)
+	if tranCodeSharedCache != nil {
+		if err := tranCodeSharedCache.Put(context.Background(), tranCodeRevisionKeyPrefix+name, revision, 0); err != nil {
+			iLog.Error(fmt.Sprintf("Failed to invalidate the cached transaction code %s on other nodes: %s", name, err.Error()))
+		}
+	}
+	iLog.Debug(fmt.Sprintf("Invalidated the cached transaction code %s with revision %s", name, revision))
+}
+
+// InvalidateTranCodeDocument drops the cached definitions of the transaction code of a document that a generic
+// writer, like the collection API or a package deployment, wrote to the collection. Other collections are ignored.
+func InvalidateTranCodeDocument(collectionName string, document map[string]interface{}) {
+	if collectionName != "Transaction_Code" {
+		return
+	}
+	if name, ok := document["trancodename"].(string); ok && name != "" {
+		InvalidateTranCode(name)
+	}
+}
+
+// tranCodeRevision returns the current revision stamp of the transaction code
+func tranCodeRevision(name string) string {
+	cacheStore := localRevisions
+	key := name
+	if tranCodeSharedCache != nil {
+		cacheStore = tranCodeSharedCache
+		key = tranCodeRevisionKeyPrefix + name
+	}
+	value, err := cacheStore.Get(context.Background(), key)
+	if err != nil {
+		return ""
+	}
+	return cache.GetString(value)
+}
+
+func tranCodeCacheKey(name, version string) string {
+	if version == "" {
+		version = "default"
+	}
+	return name + "@" + version
+}
+
+// getCachedTranCode returns a copy of the cached definition if it was cached at the given revision.
+// modTime is the modification time of the definition file, zero for definitions from the document database.
+func getCachedTranCode(name, version, revision string, modTime time.Time) (types.TranCode, bool) {
+	value, err := tranCodeLocalCache.Get(context.Background(), tranCodeCacheKey(name, version))
+	if err != nil {
+		return types.TranCode{}, false
+	}
+	entry, ok := value.(*cachedTranCode)
+	if !ok || entry.revision != revision || !entry.modTime.Equal(modTime) {
+		return types.TranCode{}, false
+	}
+	return entry.tcode.Clone(), true
+}
+
+// putCachedTranCode caches the parsed definition. The revision must be read before the definition was loaded,
+// so a definition saved in between is not cached under the new revision.
+func putCachedTranCode(name, version, revision string, modTime time.Time, tcode types.TranCode) {
+	entry := &cachedTranCode{
+		tcode:    tcode.Clone(),
+		revision: revision,
+		modTime:  modTime,
+	}
+	tranCodeLocalCache.Put(context.Background(), tranCodeCacheKey(name, version), entry, tranCodeCacheTimeout)
+}
+
+// fileModTime returns the modification time of a transaction code definition file
+func fileModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,109 @@
+package trancode
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/cache"
+)
+
+// useTranCodeTestCache replaces the caches of the transaction codes for the test, shared is the cache of the
+// revision stamps of all nodes or nil
+func useTranCodeTestCache(t *testing.T, shared cache.Cache) {
+	t.Helper()
+	newTranCodeTestDB(t)
+	local, revisions, previousShared, timeout := tranCodeLocalCache, localRevisions, tranCodeSharedCache, tranCodeCacheTimeout
+	t.Cleanup(func() {
+		tranCodeLocalCache, localRevisions = local, revisions
+		InitTranCodeCache(previousShared, timeout)
+	})
+	tranCodeLocalCache, localRevisions = cache.NewMemoryCache(), cache.NewMemoryCache()
+	InitTranCodeCache(shared, time.Minute)
+}
+
+// writeTranCodeFile writes the transaction code file of the working directory with a fixed modification time
+func writeTranCodeFile(t *testing.T, name, description string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join("trancodes", name+".json")
+	if err := os.WriteFile(path, []byte(`{"trancodename":"`+name+`","description":"`+description+`"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetTransCode_InvalidatedRevision(t *testing.T) {
+	useTranCodeTestCache(t, nil)
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("trancodes", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// a definition replaced with the same modification time is only seen after the invalidation
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, step := range []struct {
+		write, want string
+		invalidate  bool
+	}{
+		{write: "first", want: "first"},
+		{write: "second", want: "first"},
+		{want: "second", invalidate: true},
+	} {
+		if step.write != "" {
+			writeTranCodeFile(t, "Ship", step.write, modTime)
+		}
+		if step.invalidate {
+			InvalidateTranCode("Ship")
+		}
+		tcode, err := GetTransCode("Ship")
+		if err != nil || tcode.Description != step.want {
+			t.Errorf("GetTransCode() = %q, %v, want %q", tcode.Description, err, step.want)
+		}
+	}
+}
+
+func TestInvalidateTranCode_SharedRevision(t *testing.T) {
+	shared := cache.NewMemoryCache()
+	useTranCodeTestCache(t, shared)
+
+	revision := tranCodeRevision("Ship")
+	putCachedTranCode("Ship", "", revision, time.Time{}, types.TranCode{Name: "Ship"})
+	if _, ok := getCachedTranCode("Ship", "", tranCodeRevision("Ship"), time.Time{}); !ok {
+		t.Fatal("getCachedTranCode() missed the definition cached at the current revision")
+	}
+
+	// another node saves the definition, it replaces the revision stamp in the shared cache
+	if err := shared.Put(context.Background(), tranCodeRevisionKeyPrefix+"Ship", "saved on another node", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := getCachedTranCode("Ship", "", tranCodeRevision("Ship"), time.Time{}); ok {
+		t.Error("getCachedTranCode() returned the definition cached before the save on another node")
+	}
+}
+
+func TestInvalidateTranCodeDocument(t *testing.T) {
+	useTranCodeTestCache(t, nil)
+
+	revision := tranCodeRevision("Ship")
+	InvalidateTranCodeDocument("Workflow", map[string]interface{}{"trancodename": "Ship"})
+	if got := tranCodeRevision("Ship"); got != revision {
+		t.Errorf("the write of another collection changed the revision to %q", got)
+	}
+	InvalidateTranCodeDocument("Transaction_Code", map[string]interface{}{"trancodename": "Ship"})
+	if got := tranCodeRevision("Ship"); got == revision {
+		t.Error("the write of the transaction code kept the revision")
+	}
+	InvalidateTranCodeDocument("Transaction_Code", nil)
+}
@@ -0,0 +1,112 @@
+package trancode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mdaxf/iac-signalr/signalr"
+	"github.com/mdaxf/iac/documents"
+	funcgroup "github.com/mdaxf/iac/engine/funcgroup"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/callback_mgr"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CompensationLogCollection is the document collection that keeps the compensation logs
+const CompensationLogCollection = "Compensation_Log"
+
+// CompensationRetryCallback is registered by the job system to queue the retry of failed compensations.
+// The callback receives the compensation log id and the transaction code name.
+const CompensationRetryCallback = "Compensation_Retry"
+
+// compensate runs the compensations of the completed side-effecting functions in reverse order.
+// It is called on every rollback path of Execute and runs at most once per execution.
+func (t *TranFlow) compensate() {
+	if t.compensations == nil || t.compensated {
+		return
+	}
+	t.compensated = true
+
+	entries := t.compensations.Pending(0)
+	if len(entries) == 0 {
+		return
+	}
+
+	t.ilog.Info(fmt.Sprintf("Compensating %d functions of transaction code %s", len(entries), t.Tcode.Name))
+	if failed := funcgroup.Compensate(t.Ctx, entries, t.DocDBCon, t.SignalRClient, t.SystemSession); failed > 0 {
+		t.ilog.Error(fmt.Sprintf("%d compensations failed for transaction code %s", failed, t.Tcode.Name))
+	}
+}
+
+// keepCompensationLog stores the compensation log once compensations have run
+// and queues a retry job when any of them failed.
+func (t *TranFlow) keepCompensationLog() {
+	if t.compensations == nil || !t.compensations.Attempted() {
+		return
+	}
+
+	status := t.compensations.UpdateStatus()
+	if t.DocDBCon == nil {
+		t.ilog.Error(fmt.Sprintf("No document database to keep the compensation log %s of transaction code %s", t.compensations.ID, t.Tcode.Name))
+		return
+	}
+	if _, err := t.DocDBCon.InsertCollection(CompensationLogCollection, t.compensations); err != nil {
+		t.ilog.Error(fmt.Sprintf("Failed to keep the compensation log %s of transaction code %s: %s", t.compensations.ID, t.Tcode.Name, err.Error()))
+		return
+	}
+
+	if status == types.CompensationFailed {
+		// the callback returns the error of the job system as its only result
+		result, err := callback_mgr.CallBackFunc(CompensationRetryCallback, t.compensations.ID, t.Tcode.Name)
+		if err == nil && len(result) > 0 {
+			err, _ = result[0].(error)
+		}
+		if err != nil {
+			t.ilog.Error(fmt.Sprintf("Failed to queue the retry of compensation log %s: %s", t.compensations.ID, err.Error()))
+		}
+	}
+}
+
+// RetryCompensation loads a compensation log and runs its failed compensations again in reverse order.
+// It returns an error while compensations are still failing so the job system retries it.
+func RetryCompensation(logID string, DocDBCon *documents.DocDB, sc signalr.Client) error {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "Compensation"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("engine.TranCode.RetryCompensation", elapsed)
+	}()
+
+	filter := bson.M{"id": logID}
+	items, err := DocDBCon.QueryCollection(CompensationLogCollection, filter, nil)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("compensation log %s not found", logID)
+	}
+
+	jsonString, err := json.Marshal(items[0])
+	if err != nil {
+		return err
+	}
+	clog := &types.CompensationLog{}
+	if err := json.Unmarshal(jsonString, clog); err != nil {
+		return err
+	}
+
+	iLog.Info(fmt.Sprintf("Retry compensation log %s of transaction code %s", clog.ID, clog.TranCodeName))
+	failed := funcgroup.Compensate(context.Background(), clog.Pending(0), DocDBCon, sc, map[string]interface{}{})
+	clog.UpdateStatus()
+
+	if err := DocDBCon.UpdateCollection(CompensationLogCollection, filter, nil, clog); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d compensations of transaction code %s are still failing", failed, clog.TranCodeName)
+	}
+	return nil
+}
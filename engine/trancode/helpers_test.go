@@ -0,0 +1,131 @@
+package trancode
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/framework/logs"
+	"github.com/mdaxf/iac/logger"
+)
+
+var (
+	registerTranCodeTestTypesOnce sync.Once
+	tranCodeTestNotifications     int64 // the runs of TranCodeTest.Notify
+	tranCodeTestActiveInserts     int64 // the runs of TranCodeTest.Insert in progress
+	tranCodeTestOverlaps          int64 // the runs of TranCodeTest.Insert that started while another one was running
+)
+
+// registerTranCodeTestTypes registers the function types of the transaction code tests. The registry has no way to
+// remove a type, so they are registered once for all tests of the package.
+//   - TranCodeTest.Insert inserts its Name input into the steps table of the transaction, after Delay milliseconds,
+//     and counts the runs that overlap in tranCodeTestOverlaps
+//   - TranCodeTest.Fail fails the function group with its Message input without panicking
+//   - TranCodeTest.Notify has an external effect and counts its runs in tranCodeTestNotifications
+func registerTranCodeTestTypes() {
+	registerTranCodeTestTypesOnce.Do(func() {
+		funcs.MustRegisterFunctionType(funcs.FunctionTypeDescriptor{Name: "TranCodeTest.Insert", SharesTransaction: true}, funcs.ExecutorFunc(func(f *funcs.Funcs) {
+			_, _, inputs := f.SetInputs()
+			if atomic.AddInt64(&tranCodeTestActiveInserts, 1) > 1 {
+				atomic.AddInt64(&tranCodeTestOverlaps, 1)
+			}
+			defer atomic.AddInt64(&tranCodeTestActiveInserts, -1)
+			if delay, err := strconv.Atoi(fmt.Sprint(inputs["Delay"])); err == nil {
+				time.Sleep(time.Duration(delay) * time.Millisecond)
+			}
+			if _, err := f.DBTx.Exec(`INSERT INTO steps (name) VALUES (?)`, fmt.Sprint(inputs["Name"])); err != nil {
+				panic(types.NewDatabaseError("Insert", "failed to insert the step", err))
+			}
+			f.SetOutputs(map[string]interface{}{})
+		}), nil)
+		funcs.MustRegisterFunctionType(funcs.FunctionTypeDescriptor{Name: "TranCodeTest.Fail"}, funcs.ExecutorFunc(func(f *funcs.Funcs) {
+			_, _, inputs := f.SetInputs()
+			f.ErrorMessage = fmt.Sprint(inputs["Message"])
+			f.CancelExecution(f.ErrorMessage)
+		}), nil)
+		funcs.MustRegisterFunctionType(funcs.FunctionTypeDescriptor{Name: "TranCodeTest.Notify", ExternalEffect: true}, funcs.ExecutorFunc(func(f *funcs.Funcs) {
+			atomic.AddInt64(&tranCodeTestNotifications, 1)
+			f.SetOutputs(map[string]interface{}{})
+		}), nil)
+	})
+}
+
+// newTranCodeTestDB opens an in-memory database with a steps table, installed as the database of the engine
+func newTranCodeTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	for _, log := range []**logs.IACLogger{&logger.Logger, &logger.TranCodeLogger, &logger.FrameworkLogger, &logger.DatabaseLogger} {
+		if *log == nil {
+			*log = logs.NewLogger()
+		}
+	}
+	registerTranCodeTestTypes()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open the test database: %v", err)
+	}
+	// one connection, every connection of an in-memory database is a database of its own
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE steps (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`); err != nil {
+		t.Fatalf("failed to create the test schema: %v", err)
+	}
+
+	previous := dbconn.DB
+	dbconn.DB = db
+	t.Cleanup(func() {
+		dbconn.DB = previous
+		db.Close()
+	})
+	return db
+}
+
+// testSteps returns the names in the steps table in insertion order
+func testSteps(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM steps ORDER BY id`)
+	if err != nil {
+		t.Fatalf("failed to read the steps: %v", err)
+	}
+	defer rows.Close()
+
+	steps := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to read the steps: %v", err)
+		}
+		steps = append(steps, name)
+	}
+	return steps
+}
+
+// testFunction returns a function of a test type with constant inputs
+func testFunction(name, typename string, inputs map[string]string) types.Function {
+	fobj := types.Function{Name: name, Typename: typename}
+	for inputName, value := range inputs {
+		fobj.Inputs = append(fobj.Inputs, types.Input{Name: inputName, Value: value})
+	}
+	return fobj
+}
+
+// testGroup returns a function group that continues with next
+func testGroup(name, next string, functions ...types.Function) types.FuncGroup {
+	return types.FuncGroup{Name: name, Functions: functions, RouterDef: types.RouterDef{Defaultfuncgroup: next}}
+}
+
+// executeTestTranCode executes the transaction code in its own transaction of the test database
+func executeTestTranCode(tcode types.TranCode, inputs map[string]interface{}) (map[string]interface{}, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return NewTranFlow(tcode, inputs, map[string]interface{}{"UserNo": "tester", "ClientID": "test"}, ctx, cancel).Execute()
+}
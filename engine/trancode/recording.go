@@ -0,0 +1,330 @@
+package trancode
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RECORD AND REPLAY DESIGN: executions of a transaction code with an enabled recording policy carry a recorder
+// in their context. The recording keeps the external inputs, the system session and the results of the
+// nondeterministic calls, sampled executions are always saved and failed executions with OnFailure. A replay
+// runs the recorded version again with the recorded results in a transaction that is rolled back, with the
+// debug events of a debug session on, and compares its outcome with the recorded one.
+
+// TranCodeRecordingPolicyCollection is the document collection that keeps the recording policies
+const TranCodeRecordingPolicyCollection = "TranCode_RecordingPolicy"
+
+// TranCodeRecordingCollection is the document collection that keeps the recorded executions
+const TranCodeRecordingCollection = "TranCode_Recording"
+
+const tranCodeRecordingKeyPrefix = "recording:"
+
+type cachedRecordingPolicy struct {
+	policy   *types.TranCodeRecordingPolicy
+	revision string
+}
+
+// getRecordingPolicy returns the cached recording policy of the transaction code, nil if it has none
+func getRecordingPolicy(name string, DBCon *documents.DocDB) *types.TranCodeRecordingPolicy {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeRecording"}
+
+	revision := tranCodeRevision(name)
+	key := tranCodeRecordingKeyPrefix + name
+	if value, err := tranCodeLocalCache.Get(context.Background(), key); err == nil {
+		if entry, ok := value.(*cachedRecordingPolicy); ok && entry.revision == revision {
+			return entry.policy
+		}
+	}
+	if DBCon == nil {
+		return nil
+	}
+
+	policy, err := GetTranCodeRecordingPolicy(name, DBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Failed to load the recording policy of transaction code %s: %s", name, err.Error()))
+		return nil
+	}
+	tranCodeLocalCache.Put(context.Background(), key, &cachedRecordingPolicy{policy: policy, revision: revision}, tranCodeCacheTimeout)
+	return policy
+}
+
+// GetTranCodeRecordingPolicy loads the recording policy of the transaction code, nil if it has none
+func GetTranCodeRecordingPolicy(name string, DBCon *documents.DocDB) (*types.TranCodeRecordingPolicy, error) {
+	items, err := DBCon.QueryCollection(TranCodeRecordingPolicyCollection, bson.M{"trancodename": name}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	jsonString, err := json.Marshal(items[0])
+	if err != nil {
+		return nil, err
+	}
+	policy := &types.TranCodeRecordingPolicy{}
+	if err := json.Unmarshal(jsonString, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SaveTranCodeRecordingPolicy inserts or replaces the recording policy of the transaction code and
+// drops the cached policy on all nodes
+func SaveTranCodeRecordingPolicy(policy *types.TranCodeRecordingPolicy, DBCon *documents.DocDB) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	filter := bson.M{"trancodename": policy.TranCodeName}
+	items, err := DBCon.QueryCollection(TranCodeRecordingPolicyCollection, filter, nil)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		_, err = DBCon.InsertCollection(TranCodeRecordingPolicyCollection, policy)
+	} else {
+		err = DBCon.UpdateCollection(TranCodeRecordingPolicyCollection, filter, nil, policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	InvalidateTranCode(policy.TranCodeName)
+	return nil
+}
+
+// startRecording puts a recorder into the context of the execution if the recording policy asks for it.
+// Test runs, replays and executions inside a recorded execution are not recorded.
+func (t *TranFlow) startRecording() {
+	if t.TestwithSc || funcs.ExecutionRecorderFromContext(t.Ctx) != nil || funcs.FunctionMocksFromContext(t.Ctx) != nil {
+		return
+	}
+	policy := getRecordingPolicy(t.Tcode.Name, t.DocDBCon)
+	sample := rand.Float64() * 100
+	if !policy.Records(sample) {
+		return
+	}
+
+	t.recording = &types.ExecutionRecording{
+		ID:            uuid.New().String(),
+		TranCodeName:  t.Tcode.Name,
+		Version:       t.Tcode.Version,
+		ExecutionID:   t.identity.ExecutionID,
+		CorrelationID: t.identity.CorrelationID,
+		Inputs:        copySession(t.Externalinputs),
+		SystemSession: copySession(t.SystemSession),
+	}
+	if policy.Sampled(sample) {
+		t.recording.Reason = types.RecordingSampled
+	}
+	t.recorder = funcs.NewExecutionRecorder()
+	t.Ctx = funcs.WithExecutionRecorder(t.Ctx, t.recorder)
+	t.ilog.Debug(fmt.Sprintf("Record the execution of transaction code %s as %s", t.Tcode.Name, t.recording.ID))
+}
+
+// finishRecording saves the recording of the execution if it was sampled or failed, without delaying the caller
+func (t *TranFlow) finishRecording(startTime time.Time, outputs map[string]interface{}, err error) {
+	if t.recorder == nil {
+		return
+	}
+	recording := t.recording
+	recorder := t.recorder
+	t.recording, t.recorder = nil, nil
+
+	if err == nil {
+		err = t.failure
+	}
+	if err == nil && t.ErrorMessage != "" {
+		err = errors.New(t.ErrorMessage)
+	}
+	if err != nil {
+		recording.Error = err.Error()
+		if bpmErr, ok := err.(*types.BPMError); ok {
+			recording.Error = bpmErr.Message
+		}
+		if recording.Reason == "" {
+			recording.Reason = types.RecordingFailure
+		}
+	}
+	if recording.Reason == "" {
+		return
+	}
+
+	recording.RecordedOn = startTime.UTC()
+	recording.Duration = time.Since(startTime).Milliseconds()
+	recording.Outputs = outputs
+	recording.Calls = recorder.Calls()
+	recording.Clock = recorder.Clock()
+	go saveRecording(recording, t.DocDBCon)
+}
+
+func saveRecording(recording *types.ExecutionRecording, DBCon *documents.DocDB) {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeRecording"}
+	defer func() {
+		if r := recover(); r != nil {
+			iLog.Error(fmt.Sprintf("Failed to save the recording %s of transaction code %s: %v", recording.ID, recording.TranCodeName, r))
+		}
+	}()
+	if DBCon == nil {
+		return
+	}
+
+	if _, err := DBCon.InsertCollection(TranCodeRecordingCollection, recording); err != nil {
+		iLog.Error(fmt.Sprintf("Failed to save the recording %s of transaction code %s: %s", recording.ID, recording.TranCodeName, err.Error()))
+		return
+	}
+	iLog.Info(fmt.Sprintf("Recorded the %s execution %s of transaction code %s", recording.Reason, recording.ID, recording.TranCodeName))
+}
+
+// GetTranCodeRecording loads a recorded execution by its id
+func GetTranCodeRecording(id string, DBCon *documents.DocDB) (*types.ExecutionRecording, error) {
+	items, err := DBCon.QueryCollection(TranCodeRecordingCollection, bson.M{"id": id}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("recording %s not found", id)
+	}
+
+	jsonString, err := json.Marshal(items[0])
+	if err != nil {
+		return nil, err
+	}
+	recording := &types.ExecutionRecording{}
+	if err := json.Unmarshal(jsonString, recording); err != nil {
+		return nil, err
+	}
+	return recording, nil
+}
+
+// GetTranCodeRecordings returns the recorded executions of the transaction code without their calls
+func GetTranCodeRecordings(name string, DBCon *documents.DocDB) ([]types.ExecutionRecording, error) {
+	items, err := DBCon.QueryCollection(TranCodeRecordingCollection, bson.M{"trancodename": name}, bson.M{"calls": 0, "clock": 0})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonString, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	recordings := []types.ExecutionRecording{}
+	if err := json.Unmarshal(jsonString, &recordings); err != nil {
+		return nil, err
+	}
+	return recordings, nil
+}
+
+// ReplayTranCodeRecording runs the recorded execution again on the recorded version of the transaction code.
+// The nondeterministic calls return their recorded results and the database changes are rolled back.
+// The debug events of the replay go to the debug session, a session named after the recording if none is given.
+func ReplayTranCodeRecording(recording *types.ExecutionRecording, sessionID string, DBCon *documents.DocDB) (*types.ReplayResult, error) {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeRecording"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("engine.TranCode.ReplayTranCodeRecording", elapsed)
+	}()
+
+	tcode, err := getTranCodeDataByVersion(recording.TranCodeName, recording.Version, DBCon)
+	if err != nil {
+		return nil, err
+	}
+	if dbconn.DB == nil {
+		return nil, fmt.Errorf("there is no database to replay the recording in a transaction")
+	}
+	tx, err := dbconn.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			iLog.Error(fmt.Sprintf("Error during the rollback of the replay of recording %s: %s", recording.ID, rollbackErr.Error()))
+		}
+	}()
+
+	if sessionID == "" {
+		sessionID = "replay-" + recording.ID
+	}
+	systemSession := copySession(recording.SystemSession)
+	systemSession["SessionID"] = sessionID
+
+	replayer := funcs.NewReplayRecorder(recording)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = funcs.WithExecutionRecorder(ctx, replayer)
+
+	iLog.Info(fmt.Sprintf("Replay the recording %s of transaction code %s version %s in debug session %s", recording.ID, recording.TranCodeName, recording.Version, sessionID))
+	tf := NewTranFlow(tcode, copySession(recording.Inputs), systemSession, ctx, cancel, tx)
+	tf.DocDBCon = DBCon
+	outputs, runErr := tf.Execute()
+	if runErr == nil {
+		runErr = tf.failure
+	}
+	if runErr == nil && tf.ErrorMessage != "" {
+		runErr = errors.New(tf.ErrorMessage)
+	}
+
+	result := &types.ReplayResult{
+		RecordingID:        recording.ID,
+		SessionID:          sessionID,
+		Outputs:            outputs,
+		MissingCalls:       replayer.Missing(),
+		ExecutedFuncGroups: tf.executedGroups,
+		Mismatches:         []types.ContractViolation{},
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+
+	expected := recording.ToTestData("")
+	switch {
+	case expected.WantErr && runErr == nil:
+		result.Mismatches = append(result.Mismatches, types.ContractViolation{Path: "error", Message: fmt.Sprintf("the recorded error %s did not occur", recording.Error)})
+	case expected.WantErr:
+		if matched, _ := expected.MatchError(runErr); !matched {
+			result.Mismatches = append(result.Mismatches, types.ContractViolation{Path: "error", Message: fmt.Sprintf("is %s, recorded %s", runErr.Error(), recording.Error)})
+		}
+	case runErr != nil:
+		result.Mismatches = append(result.Mismatches, types.ContractViolation{Path: "error", Message: "the replay failed, the recorded execution succeeded"})
+	default:
+		result.Mismatches = expected.CompareOutputs(outputs)
+	}
+	result.Reproduced = len(result.Mismatches) == 0 && len(result.MissingCalls) == 0
+	result.Duration = time.Since(startTime).Milliseconds()
+	return result, nil
+}
+
+// AddRecordingAsTestData adds the recorded execution as test data to the recorded version of the transaction code
+func AddRecordingAsTestData(recording *types.ExecutionRecording, name string, DBCon *documents.DocDB) (types.TestData, error) {
+	testdata := recording.ToTestData(name)
+	filter := bson.M{"trancodename": recording.TranCodeName, "version": recording.Version}
+	if err := DBCon.UpdateCollection("Transaction_Code", filter, bson.M{"$push": bson.M{"testdatas": testdata}}, nil); err != nil {
+		return testdata, err
+	}
+	InvalidateTranCode(recording.TranCodeName)
+	return testdata, nil
+}
+
+// copySession returns a copy of the session map, so the recording keeps the values the execution started with
+func copySession(session map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(session))
+	for key, value := range session {
+		copied[key] = value
+	}
+	return copied
+}
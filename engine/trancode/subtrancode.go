@@ -0,0 +1,200 @@
+package trancode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdaxf/iac-signalr/signalr"
+	"github.com/mdaxf/iac/com"
+	"github.com/mdaxf/iac/documents"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// EXECUTION IDENTITY DESIGN: every execution gets an execution id and carries its identity in the context.
+// A sub transaction code inherits the correlation id of its parent, an execution started by a request is
+// its own correlation. Sub transaction codes that run outside of the transaction of the parent keep a child
+// execution record, so the children of an execution and all executions of a correlation can be queried.
+
+// ChildExecutionCollection is the document collection that keeps the child execution records
+const ChildExecutionCollection = "TranCode_ChildExecution"
+
+func init() {
+	funcs.RegisterChildTranCodeExecutor(ExecuteChildTranCode)
+}
+
+// startExecution gives the execution its identity. A flow that is executed again keeps its execution id.
+func (t *TranFlow) startExecution() {
+	if t.ExecutionID == "" {
+		t.ExecutionID = uuid.New().String()
+	}
+
+	t.identity = &types.ExecutionIdentity{ExecutionID: t.ExecutionID, CorrelationID: t.ExecutionID, TranCodeName: t.Tcode.Name}
+	if parent := funcs.ExecutionIdentityFromContext(t.Ctx); parent != nil && parent.ExecutionID != t.ExecutionID {
+		t.identity.CorrelationID = parent.CorrelationID
+		t.identity.ParentExecutionID = parent.ExecutionID
+	}
+	t.Ctx = funcs.WithExecutionIdentity(t.Ctx, t.identity)
+	t.ilog.Debug(fmt.Sprintf("Execution %s of transaction code %s, correlation %s", t.identity.ExecutionID, t.Tcode.Name, t.identity.CorrelationID))
+}
+
+// ExecuteChildTranCode runs the sub transaction code of the request in its own transaction and keeps its child
+// execution record. It runs the awaited sub transaction codes and the jobs of the sub transaction codes queued
+// after the commit of their parent, a retried job updates the record of its execution.
+func ExecuteChildTranCode(ctx context.Context, request *types.SubTranCodeRequest, sc signalr.Client, DBCon *documents.DocDB) (outputs map[string]interface{}, err error) {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "SubTranCode"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("engine.TranCode.ExecuteChildTranCode", elapsed)
+	}()
+
+	if DBCon == nil {
+		DBCon = documents.DocDBCon
+	}
+	if request.UserNo != "" {
+		iLog.User = request.UserNo
+	}
+
+	record := getChildExecution(request.ExecutionID, DBCon)
+	if record == nil {
+		record = &types.ChildExecution{
+			ExecutionID:       request.ExecutionID,
+			CorrelationID:     request.CorrelationID,
+			ParentExecutionID: request.ParentExecutionID,
+			ParentTranCode:    request.ParentTranCode,
+			TranCodeName:      request.TranCodeName,
+			Mode:              request.Mode,
+		}
+	}
+	record.JobID = request.JobID
+	record.Status = types.ChildExecutionRunning
+	record.Attempts++
+	record.Error = ""
+	record.StartedOn = startTime.UTC()
+	record.CompletedOn = time.Time{}
+	saveChildExecution(record, DBCon)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		record.CompletedOn = time.Now().UTC()
+		record.Status = types.ChildExecutionCompleted
+		if err != nil {
+			record.Status = types.ChildExecutionFailed
+			record.Error = err.Error()
+			iLog.Error(fmt.Sprintf("Sub transaction code %s execution %s of parent %s failed: %s", request.TranCodeName, request.ExecutionID, request.ParentExecutionID, err.Error()))
+		}
+		saveChildExecution(record, DBCon)
+	}()
+
+	systemSession := map[string]interface{}{}
+	if request.UserNo != "" {
+		systemSession["UserNo"] = request.UserNo
+	}
+	if request.ClientID != "" {
+		systemSession["ClientID"] = request.ClientID
+	}
+
+	tf, err := NewRoutedTranFlow(request.TranCodeName, "", request.Inputs, systemSession, DBCon)
+	if err != nil {
+		return nil, err
+	}
+	if sc != nil {
+		tf.SignalRClient = sc
+	}
+	tf.ExecutionID = request.ExecutionID
+
+	parent := request.Parent()
+	ctx = funcs.WithExecutionIdentity(ctx, &parent)
+	if _, ok := ctx.Deadline(); ok {
+		tf.Ctx, tf.CtxCancel = context.WithCancel(ctx)
+	} else {
+		tf.Ctx, tf.CtxCancel = context.WithTimeout(ctx, time.Second*time.Duration(com.TransactionTimeout))
+	}
+	defer tf.CtxCancel()
+
+	iLog.Info(fmt.Sprintf("Execute sub transaction code %s as execution %s of parent %s in mode %s", request.TranCodeName, request.ExecutionID, request.ParentExecutionID, request.Mode))
+	outputs, err = tf.Execute()
+	if err == nil {
+		err = tf.failure
+	}
+	if err == nil && tf.ErrorMessage != "" {
+		err = errors.New(tf.ErrorMessage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+func getChildExecution(executionID string, DBCon *documents.DocDB) *types.ChildExecution {
+	if DBCon == nil {
+		return nil
+	}
+	records, err := queryChildExecutions(bson.M{"executionid": executionID}, DBCon)
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+	return &records[0]
+}
+
+// saveChildExecution inserts or replaces the child execution record, a failure is logged without failing the execution
+func saveChildExecution(record *types.ChildExecution, DBCon *documents.DocDB) {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "SubTranCode"}
+	defer func() {
+		if r := recover(); r != nil {
+			iLog.Error(fmt.Sprintf("Failed to save the child execution %s: %v", record.ExecutionID, r))
+		}
+	}()
+	if DBCon == nil {
+		return
+	}
+
+	filter := bson.M{"executionid": record.ExecutionID}
+	items, err := DBCon.QueryCollection(ChildExecutionCollection, filter, nil)
+	if err == nil {
+		if len(items) == 0 {
+			_, err = DBCon.InsertCollection(ChildExecutionCollection, record)
+		} else {
+			err = DBCon.UpdateCollection(ChildExecutionCollection, filter, nil, record)
+		}
+	}
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Failed to save the child execution %s: %s", record.ExecutionID, err.Error()))
+	}
+}
+
+// GetChildExecutions returns the sub transaction codes the execution ran outside of its transaction
+func GetChildExecutions(parentExecutionID string, DBCon *documents.DocDB) ([]types.ChildExecution, error) {
+	return queryChildExecutions(bson.M{"parentexecutionid": parentExecutionID}, DBCon)
+}
+
+// GetCorrelatedExecutions returns the sub transaction codes of all executions with the correlation id
+func GetCorrelatedExecutions(correlationID string, DBCon *documents.DocDB) ([]types.ChildExecution, error) {
+	return queryChildExecutions(bson.M{"correlationid": correlationID}, DBCon)
+}
+
+func queryChildExecutions(filter bson.M, DBCon *documents.DocDB) ([]types.ChildExecution, error) {
+	items, err := DBCon.QueryCollection(ChildExecutionCollection, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonString, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	records := []types.ChildExecution{}
+	if err := json.Unmarshal(jsonString, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,238 @@
+package trancode
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mdaxf/iac/com"
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TEST RUNNER DESIGN: every test data runs in its own database transaction that is always rolled back, so tests
+// never change the live data. Functions with effects outside of the database are stubbed with the recorded
+// responses of the test data, which reach the functions through the context of the execution. A failed test
+// compensates nothing, the context with the mocks keeps the compensations from committing their own transactions.
+// The function groups run by the tests are collected for the coverage report of the transaction code.
+
+// TestRunner runs the test data of transaction codes isolated from the live data
+type TestRunner struct {
+	DB            *sql.DB
+	DocDBCon      *documents.DocDB
+	SystemSession map[string]interface{}
+	Timeout       time.Duration
+	TestwithSc    bool // send the function results of the tests to the message bus, like ExecuteUnitTest
+	iLog          logger.Log
+}
+
+// NewTestRunner creates a test runner on the database and document database of the application
+func NewTestRunner() *TestRunner {
+	return &TestRunner{
+		DB:            dbconn.DB,
+		DocDBCon:      documents.DocDBCon,
+		SystemSession: map[string]interface{}{"UserNo": "System", "ClientID": "TestRunner"},
+		Timeout:       time.Second * time.Duration(com.TransactionTimeout),
+		iLog:          logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeTestRunner"},
+	}
+}
+
+// RunTestData runs one test data of the transaction code and checks the outputs or the error against the expected ones
+func (r *TestRunner) RunTestData(tcode types.TranCode, testdata types.TestData) types.TestResult {
+	result, _ := r.runTestData(tcode, testdata)
+	return result
+}
+
+// runTestData runs the test data and returns its result with the transaction flow that ran it
+func (r *TestRunner) runTestData(tcode types.TranCode, testdata types.TestData) (types.TestResult, *TranFlow) {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		r.iLog.PerformanceWithDuration("engine.TranCode.TestRunner.RunTestData", elapsed)
+	}()
+
+	result := types.TestResult{
+		Name:               testdata.Name,
+		Result:             types.TestFail,
+		ExpectError:        testdata.WantErr,
+		ExpectedError:      testdata.WantedErr,
+		Mismatches:         []types.ContractViolation{},
+		ExecutedFuncGroups: []string{},
+		UnmockedFunctions:  []string{},
+	}
+	defer func() {
+		result.Duration = time.Since(startTime)
+	}()
+
+	if r.DB == nil {
+		result.ActualError = "there is no database to run the test in a transaction"
+		return result, nil
+	}
+	tx, err := r.DB.Begin()
+	if err != nil {
+		result.ActualError = fmt.Sprintf("failed to begin the test transaction: %s", err.Error())
+		return result, nil
+	}
+	// the test never commits, a failed execution has already rolled back the transaction
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			r.iLog.Error(fmt.Sprintf("Error during the rollback of test %s: %s", testdata.Name, rollbackErr.Error()))
+		}
+	}()
+
+	mocks := funcs.NewFunctionMocks(testdata.Mocks)
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	ctx = funcs.WithFunctionMocks(ctx, mocks)
+
+	systemSession := make(map[string]interface{}, len(r.SystemSession))
+	for key, value := range r.SystemSession {
+		systemSession[key] = value
+	}
+
+	r.iLog.Info(fmt.Sprintf("Run test %s of transaction code %s", testdata.Name, tcode.Name))
+	tf := NewTranFlow(tcode, convertInputsToMap(testdata.Inputs), systemSession, ctx, cancel, tx)
+	tf.TestwithSc = r.TestwithSc
+	if r.DocDBCon != nil {
+		tf.DocDBCon = r.DocDBCon
+	}
+
+	outputs, runErr := func() (outputs map[string]interface{}, err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("%v", rec)
+			}
+		}()
+		return tf.Execute()
+	}()
+	if runErr == nil {
+		runErr = tf.failure
+	}
+	if runErr == nil && tf.ErrorMessage != "" {
+		runErr = errors.New(tf.ErrorMessage)
+	}
+
+	result.ActualOutputs = outputs
+	result.ExecutedFuncGroups = append(result.ExecutedFuncGroups, tf.executedGroups...)
+	result.UnmockedFunctions = mocks.Unmocked()
+	if runErr != nil {
+		result.ActualError = runErr.Error()
+	}
+
+	switch {
+	case testdata.WantErr && runErr == nil:
+		result.Mismatches = append(result.Mismatches, types.ContractViolation{Path: "error", Message: "the expected error did not occur"})
+	case testdata.WantErr:
+		matched, matchErr := testdata.MatchError(runErr)
+		if matchErr != nil {
+			result.Mismatches = append(result.Mismatches, types.ContractViolation{Path: "error", Message: matchErr.Error()})
+		} else if !matched {
+			result.Mismatches = append(result.Mismatches, types.ContractViolation{Path: "error", Message: fmt.Sprintf("is %s, want %s", runErr.Error(), testdata.WantedErr)})
+		}
+	case runErr != nil:
+		result.Mismatches = append(result.Mismatches, types.ContractViolation{Path: "error", Message: "the execution failed unexpectedly"})
+	default:
+		result.Mismatches = testdata.CompareOutputs(outputs)
+	}
+	if len(result.Mismatches) == 0 {
+		result.Result = types.TestPass
+	}
+
+	r.iLog.Info(fmt.Sprintf("Test %s of transaction code %s: %s", testdata.Name, tcode.Name, result.Result))
+	if result.Result == types.TestFail {
+		r.iLog.Debug(fmt.Sprintf("Test %s mismatches: %s", testdata.Name, logger.ConvertJson(result.Mismatches)))
+	}
+	return result, tf
+}
+
+// RunTranCode runs all test data of the transaction code
+func (r *TestRunner) RunTranCode(tcode types.TranCode) types.TranCodeTestReport {
+	results := make([]types.TestResult, 0, len(tcode.TestDatas))
+	for _, testdata := range tcode.TestDatas {
+		results = append(results, r.RunTestData(tcode, testdata))
+	}
+	return types.NewTranCodeTestReport(&tcode, results)
+}
+
+// RunDirectory runs the tests of all transaction code files (*.json) in the directory and its subdirectories.
+// JSON files that are no transaction codes are skipped, files that cannot be loaded are reported as errors.
+func (r *TestRunner) RunDirectory(dir string) (types.TestSuiteReport, error) {
+	startTime := time.Now()
+	suite := types.TestSuiteReport{TranCodes: []types.TranCodeTestReport{}}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var header struct {
+			Name string `json:"trancodename"`
+		}
+		if json.Unmarshal(data, &header) != nil || header.Name == "" {
+			r.iLog.Debug(fmt.Sprintf("Skip %s, it is no transaction code", path))
+			return nil
+		}
+
+		tcode, loadErr := Bytetoobj(data)
+		if loadErr != nil {
+			suite.Add(types.TranCodeTestReport{TranCode: header.Name, Error: fmt.Sprintf("%s: %s", path, loadErr.Error())})
+			return nil
+		}
+		suite.Add(r.RunTranCode(tcode))
+		return nil
+	})
+
+	suite.Duration = time.Since(startTime)
+	return suite, err
+}
+
+// RunDocDB runs the tests of the default versions of the transaction codes in the document database,
+// of all transaction codes if no names are given
+func (r *TestRunner) RunDocDB(names ...string) (types.TestSuiteReport, error) {
+	startTime := time.Now()
+	suite := types.TestSuiteReport{TranCodes: []types.TranCodeTestReport{}}
+	if r.DocDBCon == nil {
+		return suite, fmt.Errorf("there is no document database to load the transaction codes from")
+	}
+
+	if len(names) == 0 {
+		tcodes, err := r.DocDBCon.QueryCollection("Transaction_Code", bson.M{"isdefault": true}, bson.M{"trancodename": 1})
+		if err != nil {
+			return suite, err
+		}
+		for _, tcode := range tcodes {
+			if name, ok := tcode["trancodename"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		tcode, err := getTranCodeData(name, r.DocDBCon)
+		if err != nil {
+			suite.Add(types.TranCodeTestReport{TranCode: name, Error: err.Error()})
+			continue
+		}
+		suite.Add(r.RunTranCode(tcode))
+	}
+
+	suite.Duration = time.Since(startTime)
+	return suite, nil
+}
@@ -0,0 +1,76 @@
+package trancode
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mdaxf/iac/engine/types"
+)
+
+// newTestTestRunner returns a test runner on the test database
+func newTestTestRunner() *TestRunner {
+	runner := NewTestRunner()
+	runner.Timeout = time.Minute
+	return runner
+}
+
+func TestTestRunner_RollsBackTheTest(t *testing.T) {
+	db := newTranCodeTestDB(t)
+
+	tcode := types.TranCode{Name: "Ship", Firstfuncgroup: "Main", Functiongroups: []types.FuncGroup{
+		testGroup("Main", "", testFunction("Insert", "TranCodeTest.Insert", map[string]string{"Name": "main"})),
+	}}
+	result := newTestTestRunner().RunTestData(tcode, types.TestData{Name: "ships"})
+	if result.Result != types.TestPass {
+		t.Errorf("RunTestData() = %+v, want the test passed", result)
+	}
+	if steps := testSteps(t, db); len(steps) != 0 {
+		t.Errorf("steps = %v, want the transaction of the test rolled back", steps)
+	}
+}
+
+func TestTestRunner_FailedTestCompensatesNothing(t *testing.T) {
+	db := newTranCodeTestDB(t)
+	notifications := atomic.LoadInt64(&tranCodeTestNotifications)
+
+	notify := testFunction("Notify", "TranCodeTest.Notify", nil)
+	compensation := testFunction("Recall", "TranCodeTest.Insert", map[string]string{"Name": "compensated"})
+	notify.Compensation = &compensation
+	tcode := types.TranCode{Name: "Ship", Firstfuncgroup: "Main", Functiongroups: []types.FuncGroup{
+		testGroup("Main", "", notify, testFunction("Fail", "TranCodeTest.Fail", map[string]string{"Message": "order is locked"})),
+	}}
+	testdata := types.TestData{Name: "locked", WantErr: true, WantedErr: "order is locked",
+		Mocks: []types.FunctionMock{{Function: "Notify", Outputs: map[string]interface{}{}}}}
+
+	result := newTestTestRunner().RunTestData(tcode, testdata)
+	if result.Result != types.TestPass || len(result.UnmockedFunctions) != 0 {
+		t.Errorf("RunTestData() = %+v, want the expected error with every external effect mocked", result)
+	}
+	if steps := testSteps(t, db); len(steps) != 0 {
+		t.Errorf("steps = %v, want no compensation committed by the test", steps)
+	}
+	if runs := atomic.LoadInt64(&tranCodeTestNotifications) - notifications; runs != 0 {
+		t.Errorf("the test ran the mocked notification %d times", runs)
+	}
+}
+
+func TestTestRunner_MocksCollectionWrites(t *testing.T) {
+	newTranCodeTestDB(t)
+
+	tcode := types.TranCode{Name: "Archive", Firstfuncgroup: "Main", Functiongroups: []types.FuncGroup{
+		testGroup("Main", "", types.Function{Name: "Store", Functype: types.CollectionInsert, Content: "Orders"}),
+	}}
+	testdata := types.TestData{Name: "stores", Mocks: []types.FunctionMock{{Type: types.CollectionInsert.String(), Outputs: map[string]interface{}{}}}}
+
+	result := newTestTestRunner().RunTestData(tcode, testdata)
+	if result.Result != types.TestPass || len(result.UnmockedFunctions) != 0 {
+		t.Errorf("RunTestData() = %+v, want the collection write mocked instead of reaching the document database", result)
+	}
+
+	testdata.Mocks = nil
+	result = newTestTestRunner().RunTestData(tcode, testdata)
+	if len(result.UnmockedFunctions) != 1 || result.UnmockedFunctions[0] != "Store" {
+		t.Errorf("UnmockedFunctions = %v, want the collection write reported", result.UnmockedFunctions)
+	}
+}
@@ -3,6 +3,7 @@ package trancode
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"time"
@@ -12,6 +13,7 @@ import (
 	dbconn "github.com/mdaxf/iac/databases"
 	"github.com/mdaxf/iac/documents"
 
+	funcs "github.com/mdaxf/iac/engine/function"
 	funcgroup "github.com/mdaxf/iac/engine/funcgroup"
 
 	"github.com/mdaxf/iac/engine/debug"
@@ -39,6 +41,15 @@ type TranFlow struct {
 	ErrorMessage    string
 	TestwithSc      bool
 	TestResults     map[string]interface{}
+	compensations   *types.CompensationLog
+	compensated     bool
+	trackVersion    bool
+	executedGroups  []string // function groups run by the last execution, for the test coverage
+	failure         error    // error that failed the last execution
+	recorder        *funcs.ExecutionRecorder
+	recording       *types.ExecutionRecording
+	ExecutionID     string                   // id of the execution, a new id is assigned if it is empty
+	identity        *types.ExecutionIdentity // identity of the running execution
 }
 
 func Execute(trancode string, data map[string]interface{}, systemsessions map[string]interface{}) (map[string]interface{}, error) {
@@ -175,8 +186,10 @@ func ExecuteUnitTestWithTestData(trancode string, testcase map[string]interface{
 // The outputs map contains the outputs of the transaction code.
 // The error contains the error message if any.
 // The function also logs the performance of the transaction code execution.
+// An optional version pins the execution to that version of the transaction code,
+// otherwise the routing policy of the transaction code selects the version.
 
-func ExecutebyExternal(trancode string, data map[string]interface{}, DBTx *sql.Tx, DBCon *documents.DocDB, sc signalr.Client) (map[string]interface{}, error) {
+func ExecutebyExternal(trancode string, data map[string]interface{}, DBTx *sql.Tx, DBCon *documents.DocDB, sc signalr.Client, version ...string) (map[string]interface{}, error) {
 	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TransCode"}
 	startTime := time.Now()
 	defer func() {
@@ -192,12 +205,10 @@ func ExecutebyExternal(trancode string, data map[string]interface{}, DBTx *sql.T
 		}
 	}()
 
-	tranobj, err := getTranCodeData(trancode, DBCon)
+	tf, err := NewRoutedTranFlow(trancode, append(version, "")[0], data, map[string]interface{}{}, DBCon, DBTx)
 	if err != nil {
 		return nil, err
 	}
-	tf := NewTranFlow(tranobj, data, map[string]interface{}{}, nil, nil, DBTx)
-	tf.DocDBCon = DBCon
 	tf.SignalRClient = sc
 
 	if callback_mgr.CallBackMap["TranCode_Execute"] == nil {
@@ -292,13 +303,23 @@ func NewTranFlow(tcode types.TranCode, externalinputs, systemSession map[string]
 // It executes the first function group of the transaction code and iterates through subsequent function groups until the code is no longer 1.
 // It commits the database transaction if it was started in this function.
 // It returns the external outputs and nil error if successful.
-func (t *TranFlow) Execute() (map[string]interface{}, error) {
+func (t *TranFlow) Execute() (outputs map[string]interface{}, err error) {
 	startTime := time.Now()
 	defer func() {
 		elapsed := time.Since(startTime)
 		t.ilog.PerformanceWithDuration("engine.TranCode.Execute", elapsed)
 	}()
 
+	// registered first so the outcome is known after the rollback defers below have run
+	if t.trackVersion {
+		defer func() {
+			t.recordVersionExecution(startTime, err)
+		}()
+	}
+	defer func() {
+		t.finishRecording(startTime, outputs, err)
+	}()
+
 	// Initialize debug helper
 	var debugHelper *debug.DebugHelper
 	var sessionID string
@@ -316,6 +337,14 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 
 	// Initialize transaction state for tracking
 	txState := types.TransactionRunning
+	t.executedGroups = []string{}
+	t.failure = nil
+
+	// COMPENSATION DESIGN: side effects outside of the database transaction are undone by the
+	// compensations recorded during execution. The log is kept after the rollback defer below has run.
+	t.compensations = types.NewCompensationLog(&t.Tcode)
+	t.compensated = false
+	defer t.keepCompensationLog()
 
 	// ROLLBACK DESIGN: This defer/recover pattern is intentional.
 	// When any function fails or ThrowError executes with iserror=true,
@@ -335,6 +364,7 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 				// Log the formatted error
 				t.ilog.Error(bpmErr.GetFormattedError())
 				t.ErrorMessage = bpmErr.Error()
+				t.failure = bpmErr
 
 				// Update rollback reason
 				if bpmErr.RollbackReason == "" {
@@ -364,6 +394,7 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 					WithRollbackReason(fmt.Sprintf("Unexpected error in transaction code %s", t.Tcode.Name))
 
 				t.ilog.Error(structuredErr.GetFormattedError())
+				t.failure = structuredErr
 			}
 
 			// Rollback the transaction due to panic
@@ -383,6 +414,7 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 			if t.CtxCancel != nil {
 				t.CtxCancel()
 			}
+			t.compensate()
 			return
 		}
 	}()
@@ -391,11 +423,21 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 	t.ilog.Debug(fmt.Sprintf("systemSession: %s", logger.ConvertJson(t.SystemSession)))
 	t.ilog.Debug(fmt.Sprintf("externalinputs: %s", logger.ConvertJson(t.Externalinputs)))
 	t.ilog.Debug(fmt.Sprintf("externaloutputs: %s", logger.ConvertJson(t.externaloutputs)))
+
+	// CONTRACT DESIGN: the declared inputs are checked before any function runs and the declared
+	// outputs before the transaction is committed, every violation is reported in one validation error
+	validInputs, err := t.Tcode.ValidateInputs(t.Externalinputs)
+	if err != nil {
+		t.ilog.Error(err.Error())
+		t.ErrorMessage = err.Error()
+		return map[string]interface{}{}, err
+	}
+	t.Externalinputs = validInputs
+
 	systemSession := t.SystemSession
 	externalinputs := t.Externalinputs
 	externaloutputs := t.externaloutputs
 	userSession := map[string]interface{}{}
-	var err error
 	newTransaction := false
 
 	// TRANSACTION MANAGEMENT: Proper coordination of transaction lifecycle
@@ -431,12 +473,35 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 		}()
 	}
 
+	// interactive debug sessions pause executions at breakpoints, sub transaction codes get the debugger with the context
+	debugger := debug.DebuggerFromContext(t.Ctx)
+	if debugger == nil && sessionID != "" {
+		debugger = debug.GetGlobalDebugSessionManager().GetDebugger(sessionID)
+	}
+
 	if t.Ctx == nil {
-		t.Ctx, t.CtxCancel = context.WithTimeout(context.Background(), time.Second*time.Duration(com.TransactionTimeout))
+		if debugger != nil {
+			// a paused execution keeps its transaction open, the debugger's pause timeout limits every pause and its
+			// maximum execution time the execution with all pauses
+			t.Ctx, t.CtxCancel = context.WithTimeout(context.Background(), debugger.MaxExecution)
+		} else {
+			t.Ctx, t.CtxCancel = context.WithTimeout(context.Background(), time.Second*time.Duration(com.TransactionTimeout))
+		}
 
 		defer t.CtxCancel()
 	}
 
+	t.startExecution()
+	t.startRecording()
+
+	if debugger != nil {
+		if debug.DebuggerFromContext(t.Ctx) == nil {
+			t.Ctx = debug.WithDebugger(t.Ctx, debugger)
+		}
+		debugger.EnterTranCode()
+		defer debugger.ExitTranCode()
+	}
+
 	if t.TestwithSc {
 		t.TestResults = map[string]interface{}{}
 		t.TestResults["Name"] = t.Tcode.Name
@@ -456,16 +521,36 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 	fgroup, code := t.getFGbyName(t.Tcode.Firstfuncgroup)
 	t.ilog.Debug(fmt.Sprintf("start first function group:", logger.ConvertJson(fgroup)))
 
+	loopGuard := types.NewLoopGuard(&t.Tcode, t.Tcode.Firstfuncgroup)
+
 	for code == 1 {
+		t.executedGroups = append(t.executedGroups, fgroup.Name)
+
 		// Emit funcgroup start event
 		fgStartTime := time.Now()
 		if debugHelper != nil {
 			debugHelper.EmitFuncGroupStart(fgroup.Name)
 		}
 
+		if debugger != nil && debugger.Pausing(t.Tcode.Name, fgroup.Name, "") {
+			if pauseErr := debugger.Pause(&debug.PausePoint{
+				TranCodeName:    t.Tcode.Name,
+				TranCodeVersion: t.Tcode.Version,
+				FuncGroup:       fgroup.Name,
+				SystemSession:   systemSession,
+				UserSession:     userSession,
+				Inputs:          externalinputs,
+				Ctx:             t.Ctx,
+			}); pauseErr != nil {
+				panic(pauseErr)
+			}
+		}
+
 		fg := funcgroup.NewFGroup(t.DocDBCon, t.SignalRClient, t.DBTx, fgroup, "", systemSession, userSession, externalinputs, externaloutputs, t.Ctx, t.CtxCancel)
 
 		fg.TestwithSc = t.TestwithSc
+		fg.Compensations = t.compensations
+		fg.TranCodeName = t.Tcode.Name
 
 		fg.Execute()
 
@@ -473,6 +558,15 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 			t.TestResults["FunctionGroups"] = append(t.TestResults["FunctionGroups"].([]map[string]interface{}), fg.TestResults)
 		}
 
+		// a failed function group cancelled the execution, its transaction is rolled back and the
+		// routing, the output contract and the commit do not apply
+		if fg.ErrorMessage != "" {
+			t.ilog.Error(fmt.Sprintf("Function group %s of transaction code %s failed: %s", fgroup.Name, t.Tcode.Name, fg.ErrorMessage))
+			t.ErrorMessage = fg.ErrorMessage
+			t.compensate()
+			return map[string]interface{}{}, errors.New(fg.ErrorMessage)
+		}
+
 		externalinputs = fg.Externalinputs
 		externaloutputs = fg.Externaloutputs
 		userSession = fg.UserSession
@@ -486,6 +580,23 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 			code = 0
 			break
 		} else {
+			// Stop runaway routing before the next function group writes to the transaction
+			if routeErr := loopGuard.Route(fgroup.Name, fg.Nextfuncgroup); routeErr != nil {
+				routeErr.WithContext(&types.ExecutionContext{
+					TranCodeName:    t.Tcode.Name,
+					TranCodeVersion: t.Tcode.Version,
+					FunctionGroup:   fgroup.Name,
+					ExecutionTime:   startTime,
+				}).WithRollbackReason(fmt.Sprintf("Routing loop in transaction code %s", t.Tcode.Name))
+				t.ilog.Error(routeErr.GetFormattedError())
+				t.ErrorMessage = routeErr.Error()
+				if t.CtxCancel != nil {
+					t.CtxCancel()
+				}
+				t.compensate()
+				return map[string]interface{}{}, routeErr
+			}
+
 			// Emit funcgroup routing event
 			if debugHelper != nil {
 				debugHelper.EmitFuncGroupRouting(fgroup.Name, fg.Nextfuncgroup, fg.Nextfuncgroup)
@@ -496,6 +607,16 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 		}
 	}
 
+	if err := t.Tcode.ValidateOutputs(externaloutputs); err != nil {
+		t.ilog.Error(err.Error())
+		t.ErrorMessage = err.Error()
+		if t.CtxCancel != nil {
+			t.CtxCancel()
+		}
+		t.compensate()
+		return map[string]interface{}{}, err
+	}
+
 	// Commit the transaction if we started it
 	if newTransaction {
 		t.ilog.Info(fmt.Sprintf("Committing transaction for %s", t.Tcode.Name))
@@ -506,6 +627,7 @@ func (t *TranFlow) Execute() (map[string]interface{}, error) {
 			if t.CtxCancel != nil {
 				t.CtxCancel()
 			}
+			t.compensate()
 			return map[string]interface{}{}, err
 		}
 		// Mark transaction as committed so defer won't rollback
@@ -586,14 +708,29 @@ func GetTransCode(name string) (types.TranCode, error) {
 	log.Info(fmt.Sprintf("Start get transaction code %s", name))
 
 	log.Info(fmt.Sprintf("./%s/%s%s", "trancodes", name, ".json"))
-	data, err := ioutil.ReadFile(fmt.Sprintf("./%s/%s%s", "trancodes", name, ".json"))
+	path := fmt.Sprintf("./%s/%s%s", "trancodes", name, ".json")
+	revision := tranCodeRevision(name)
+	modTime, err := fileModTime(path)
+	if err == nil {
+		if tcode, ok := getCachedTranCode("file:"+name, "", revision, modTime); ok {
+			log.Debug(fmt.Sprintf("Use the cached transaction code file %s", path))
+			return tcode, nil
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		log.Error(fmt.Sprintf("failed to read configuration file: %v", err))
 		return types.TranCode{}, fmt.Errorf("failed to read configuration file: %v", err)
 	}
 	log.Debug(fmt.Sprintf("Read the tran code configuration:%s", string(data)))
 	//	fmt.Println(string(data))
-	return Bytetoobj(data)
+	tcode, err := Bytetoobj(data)
+	if err != nil {
+		return types.TranCode{}, err
+	}
+	putCachedTranCode("file:"+name, "", revision, modTime, tcode)
+	return tcode, nil
 }
 
 // Bytetoobj converts a byte slice to a TranCode object.
@@ -623,6 +760,20 @@ func Bytetoobj(config []byte) (types.TranCode, error) {
 	if err := json.Unmarshal(config, &tranCode); err != nil {
 		return types.TranCode{}, fmt.Errorf("failed to parse configuration file: %v", err)
 	}
+
+	if err := tranCode.CompileRouters(); err != nil {
+		log.Error(fmt.Sprintf("Invalid router definitions in transaction code %s: %s", tranCode.Name, err.Error()))
+		return types.TranCode{}, err
+	}
+
+	if err := tranCode.ValidateRouting(); err != nil {
+		log.Error(fmt.Sprintf("Invalid function group routing in transaction code %s: %s", tranCode.Name, err.Error()))
+		return types.TranCode{}, err
+	}
+	if err := funcs.ValidateFunctions(&tranCode); err != nil {
+		log.Error(fmt.Sprintf("Invalid functions in transaction code %s: %s", tranCode.Name, err.Error()))
+		return types.TranCode{}, err
+	}
 	log.Debug(fmt.Sprintf("Parse the tran code configuration:%s", logger.ConvertJson(tranCode)))
 	return tranCode, nil
 }
@@ -674,6 +825,10 @@ func (t *TranFlowstr) Execute(tcode string, inputs map[string]interface{}, sc si
 	return tf.Execute()
 }
 
+// UnitTestbyTestData runs the test data with the test runner: the execution is rolled back, the mocks of the
+// test data stub their functions and the outputs or the error are compared with the expected ones.
+// The returned map holds the fields of the test result listed at ExecuteUnitTest and the mismatches,
+// the executed function groups and the functions with external effects that ran without a mock.
 func (t *TranFlow) UnitTestbyTestData(testdata types.TestData) (map[string]interface{}, error) {
 
 	startTime := time.Now()
@@ -686,71 +841,51 @@ func (t *TranFlow) UnitTestbyTestData(testdata types.TestData) (map[string]inter
 		if r := recover(); r != nil {
 			t.ilog.Error(fmt.Sprintf("Error in Trancode.UnitTestbyTestData: %s", r))
 			t.ErrorMessage = fmt.Sprintf("Error in Trancode.UnitTestbyTestData: %s", r)
-			t.DBTx.Rollback()
-			t.CtxCancel()
 			return
 		}
 	}()
 
-	t.ilog.Debug(fmt.Sprintf("Start process transaction code %s's with test data: %s ", t.Tcode.Name, testdata))
+	t.ilog.Debug(fmt.Sprintf("Start process transaction code %s's with test data: %s ", t.Tcode.Name, testdata.Name))
 	t.ilog.Debug(fmt.Sprintf("externalinputs: %s", logger.ConvertJson(testdata.Inputs)))
 	t.ilog.Debug(fmt.Sprintf("expected externaloutputs: %s", logger.ConvertJson(testdata.Outputs)))
 
 	t.Externalinputs = convertInputsToMap(testdata.Inputs)
 	t.externaloutputs = map[string]interface{}{}
-	testresult := map[string]interface{}{}
-	testresult["Name"] = testdata.Name
-	testresult["Inputs"] = testdata.Inputs
-	testresult["ExpectedOutputs"] = testdata.Outputs
-	testresult["ExpectError"] = testdata.WantErr
-	testresult["ExpectedError"] = testdata.WantedErr
 
 	tcom.SendTestResultMessageBus(t.Tcode.Name, "", "", "UnitTest", "Start",
 		t.Externalinputs, t.externaloutputs, t.SystemSession, map[string]interface{}{}, nil, t.SystemSession["ClientID"].(string), t.SystemSession["UserNo"].(string))
 
-	outputs, err := t.Execute()
-
-	tcom.SendTestResultMessageBus(t.Tcode.Name, "", "", "UnitTest", "Complete",
-		t.Externalinputs, outputs, t.SystemSession, map[string]interface{}{}, err, t.SystemSession["ClientID"].(string), t.SystemSession["UserNo"].(string))
-
-	t.ilog.Debug(fmt.Sprintf("actual externaloutputs: %v, expected outputs: %v", outputs, testdata.Outputs))
-	if err != nil {
-		t.ilog.Error(fmt.Sprintf("Error in Trancode.Execute: %s", err.Error()))
-
-		if testdata.WantErr {
-			if testdata.WantedErr == err.Error() {
-				testresult["ActualError"] = err.Error()
-				testresult["Result"] = "Pass"
-
-			} else {
-				testresult["ActualError"] = err.Error()
-				testresult["Result"] = "Pass"
-
-			}
-
-		} else {
-			testresult["ActualError"] = err.Error()
-			testresult["Result"] = "Fail"
-
-		}
+	runner := NewTestRunner()
+	runner.SystemSession = t.SystemSession
+	runner.DocDBCon = t.DocDBCon
+	runner.TestwithSc = t.TestwithSc
+	result, tf := runner.runTestData(t.Tcode, testdata)
+	if tf != nil {
+		t.TestResults = tf.TestResults
+		t.ErrorMessage = tf.ErrorMessage
 	}
 
-	if !testdata.WantErr {
-		if !compareMap(outputs, convertOutputsToMap(testdata.Outputs)) {
-			testresult["ActualOutputs"] = outputs
-			testresult["Result"] = "Fail"
-
-		} else {
-			testresult["ActualOutputs"] = outputs
-			testresult["Result"] = "Pass"
+	var err error
+	if result.ActualError != "" {
+		err = errors.New(result.ActualError)
+	}
+	tcom.SendTestResultMessageBus(t.Tcode.Name, "", "", "UnitTest", "Complete",
+		t.Externalinputs, result.ActualOutputs, t.SystemSession, map[string]interface{}{}, err, t.SystemSession["ClientID"].(string), t.SystemSession["UserNo"].(string))
 
-		}
-	} else {
-		testresult["Result"] = "Fail"
-		testresult["ActualOutputs"] = outputs
-		testresult["ActualError"] = ""
+	t.ilog.Debug(fmt.Sprintf("actual externaloutputs: %v, expected outputs: %v", result.ActualOutputs, testdata.Outputs))
 
-	}
+	testresult := map[string]interface{}{}
+	testresult["Name"] = testdata.Name
+	testresult["Inputs"] = testdata.Inputs
+	testresult["ExpectedOutputs"] = testdata.Outputs
+	testresult["ExpectError"] = testdata.WantErr
+	testresult["ExpectedError"] = testdata.WantedErr
+	testresult["ActualOutputs"] = result.ActualOutputs
+	testresult["ActualError"] = result.ActualError
+	testresult["Result"] = result.Result
+	testresult["Mismatches"] = result.Mismatches
+	testresult["ExecutedFuncGroups"] = result.ExecutedFuncGroups
+	testresult["UnmockedFunctions"] = result.UnmockedFunctions
 
 	return testresult, nil
 }
@@ -810,6 +945,11 @@ func GetTranCodeDatabyCode(Code string) (types.TranCode, error) {
 }
 
 func getTranCodeData(Code string, DBConn *documents.DocDB) (types.TranCode, error) {
+	return getTranCodeDataByVersion(Code, "", DBConn)
+}
+
+// getTranCodeDataByVersion loads the given version of the transaction code, or the default version if version is empty
+func getTranCodeDataByVersion(Code string, version string, DBConn *documents.DocDB) (types.TranCode, error) {
 	iLog := logger.Log{ModuleName: logger.API, User: "System", ControllerName: "TranCode"}
 
 	startTime := time.Now()
@@ -824,11 +964,20 @@ func getTranCodeData(Code string, DBConn *documents.DocDB) (types.TranCode, erro
 		}
 	}()
 
-	iLog.Info(fmt.Sprintf("Get the trancode code for %s ", Code))
+	iLog.Info(fmt.Sprintf("Get the trancode code for %s version %s", Code, version))
 
 	iLog.Info(fmt.Sprintf("Start process transaction code %s's %s ", Code, "Execute"))
 
+	revision := tranCodeRevision(Code)
+	if trancodeobj, ok := getCachedTranCode(Code, version, revision, time.Time{}); ok {
+		iLog.Debug(fmt.Sprintf("Use the cached transaction code %s version %s", Code, version))
+		return trancodeobj, nil
+	}
+
 	filter := bson.M{"trancodename": Code, "isdefault": true}
+	if version != "" {
+		filter = bson.M{"trancodename": Code, "version": version}
+	}
 
 	tcode, err := DBConn.QueryCollection("Transaction_Code", filter, nil)
 
@@ -837,6 +986,9 @@ func getTranCodeData(Code string, DBConn *documents.DocDB) (types.TranCode, erro
 
 		return types.TranCode{}, err
 	}
+	if len(tcode) == 0 {
+		return types.TranCode{}, fmt.Errorf("transaction code %s version %s not found", Code, version)
+	}
 	iLog.Debug(fmt.Sprintf("transaction code %s's data: %s", Code, tcode))
 	jsonString, err := json.Marshal(tcode[0])
 	if err != nil {
@@ -858,6 +1010,7 @@ func getTranCodeData(Code string, DBConn *documents.DocDB) (types.TranCode, erro
 		return types.TranCode{}, err
 	}
 
+	putCachedTranCode(Code, version, revision, time.Time{}, trancodeobj)
 	return trancodeobj, nil
 }
 
@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"reflect"
+	"sync/atomic"
 	"testing"
 
+	"github.com/mdaxf/iac/com"
 	"github.com/mdaxf/iac/documents"
 	"github.com/mdaxf/iac/engine/types"
 	"github.com/mdaxf/iac-signalr/signalr"
@@ -283,3 +285,66 @@ func Test_getTranCodeData(t *testing.T) {
 		})
 	}
 }
+
+func TestTranFlow_ExecuteFailedFunctionGroup(t *testing.T) {
+	db := newTranCodeTestDB(t)
+
+	tcode := types.TranCode{Name: "Ship", Firstfuncgroup: "Main", Functiongroups: []types.FuncGroup{
+		testGroup("Main", "After",
+			testFunction("Insert", "TranCodeTest.Insert", map[string]string{"Name": "main"}),
+			testFunction("Fail", "TranCodeTest.Fail", map[string]string{"Message": "order is locked"})),
+		testGroup("After", "", testFunction("Insert", "TranCodeTest.Insert", map[string]string{"Name": "after"})),
+	}}
+	if _, err := executeTestTranCode(tcode, map[string]interface{}{}); err == nil || err.Error() != "order is locked" {
+		t.Errorf("Execute() = %v, want the error of the function group", err)
+	}
+	if steps := testSteps(t, db); len(steps) != 0 {
+		t.Errorf("steps = %v, want the transaction rolled back before routing to the next group", steps)
+	}
+}
+
+func TestTranFlow_ExecuteTryGroupRoutesToErrorHandler(t *testing.T) {
+	db := newTranCodeTestDB(t)
+
+	main := testGroup("Main", "After",
+		testFunction("Insert", "TranCodeTest.Insert", map[string]string{"Name": "main"}),
+		testFunction("Fail", "TranCodeTest.Fail", map[string]string{"Message": "order is locked"}))
+	main.Try, main.Errorhandler = true, "Handle"
+	tcode := types.TranCode{Name: "Ship", Firstfuncgroup: "Before", Functiongroups: []types.FuncGroup{
+		testGroup("Before", "Main", testFunction("Insert", "TranCodeTest.Insert", map[string]string{"Name": "before"})),
+		main,
+		testGroup("Handle", "", testFunction("Insert", "TranCodeTest.Insert", map[string]string{"Name": "handled"})),
+		testGroup("After", "", testFunction("Insert", "TranCodeTest.Insert", map[string]string{"Name": "after"})),
+	}}
+	if _, err := executeTestTranCode(tcode, map[string]interface{}{}); err != nil {
+		t.Fatalf("Execute() error = %v, want the failure handled by the error handler", err)
+	}
+	if steps := testSteps(t, db); !reflect.DeepEqual(steps, []string{"before", "handled"}) {
+		t.Errorf("steps = %v, want the work of the try group rolled back to its savepoint and the error handler committed", steps)
+	}
+}
+
+func TestTranFlow_ExecuteParallelTransactionalFunctions(t *testing.T) {
+	db := newTranCodeTestDB(t)
+	timeout := com.TransactionTimeout
+	com.TransactionTimeout = 60
+	t.Cleanup(func() { com.TransactionTimeout = timeout })
+	overlaps := atomic.LoadInt64(&tranCodeTestOverlaps)
+
+	// the later functions are faster, without the order of the transaction they would insert first
+	main := testGroup("Main", "",
+		testFunction("First", "TranCodeTest.Insert", map[string]string{"Name": "first", "Delay": "30"}),
+		testFunction("Second", "TranCodeTest.Insert", map[string]string{"Name": "second", "Delay": "10"}),
+		testFunction("Third", "TranCodeTest.Insert", map[string]string{"Name": "third"}))
+	main.Executionsequence = "Parallel"
+	tcode := types.TranCode{Name: "Ship", Firstfuncgroup: "Main", Functiongroups: []types.FuncGroup{main}}
+	if _, err := executeTestTranCode(tcode, map[string]interface{}{}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if steps := testSteps(t, db); !reflect.DeepEqual(steps, []string{"first", "second", "third"}) {
+		t.Errorf("steps = %v, want the functions of the transaction in declaration order", steps)
+	}
+	if got := atomic.LoadInt64(&tranCodeTestOverlaps) - overlaps; got != 0 {
+		t.Errorf("%d functions of the transaction ran while another one was running", got)
+	}
+}
@@ -0,0 +1,233 @@
+package trancode
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// VERSION ROUTING DESIGN: a caller can pin an execution to a version of the transaction code. Without a pinned
+// version the routing policy of the transaction code decides between the default and the canary version.
+// Executions of transaction codes with a routing policy are counted per version and node, so a bad rollout
+// shows up in the statistics and can be reverted by disabling the policy.
+
+// TranCodeRoutingCollection is the document collection that keeps the routing policies
+const TranCodeRoutingCollection = "TranCode_Routing"
+
+// TranCodeVersionStatsCollection is the document collection that keeps the execution statistics per version
+const TranCodeVersionStatsCollection = "TranCode_Version_Stats"
+
+const tranCodeRoutingKeyPrefix = "routing:"
+
+var (
+	versionStatsNode    = uuid.New().String()
+	versionStatsMu      sync.Mutex
+	versionStatsCreated = map[string]bool{}
+)
+
+type cachedRoutingPolicy struct {
+	policy   *types.TranCodeRoutingPolicy
+	revision string
+}
+
+// NewRoutedTranFlow creates the transaction flow for the requested version of the transaction code.
+// Without a version the routing policy selects the version for the user and client of the system session.
+func NewRoutedTranFlow(name string, version string, externalinputs, systemSession map[string]interface{}, DBCon *documents.DocDB, dbTx ...*sql.Tx) (*TranFlow, error) {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeRouting"}
+
+	policy := getRoutingPolicy(name, DBCon)
+	routed := ""
+	if version == "" {
+		user, _ := systemSession["UserNo"].(string)
+		clientid, _ := systemSession["ClientID"].(string)
+		routed = policy.SelectVersion(user, clientid, rand.Float64()*100)
+		version = routed
+	}
+
+	tcode, err := getTranCodeDataByVersion(name, version, DBCon)
+	if err != nil && routed != "" {
+		iLog.Error(fmt.Sprintf("Failed to load the canary version %s of transaction code %s, using the default version: %s", routed, name, err.Error()))
+		tcode, err = getTranCodeDataByVersion(name, "", DBCon)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if routed != "" {
+		iLog.Debug(fmt.Sprintf("Routed transaction code %s to the canary version %s", name, routed))
+	}
+
+	tf := NewTranFlow(tcode, externalinputs, systemSession, nil, nil, dbTx...)
+	tf.DocDBCon = DBCon
+	tf.trackVersion = policy != nil
+	return tf, nil
+}
+
+// GetTranCodeDatabyVersion returns the given version of the transaction code, or the default version if version is empty
+func GetTranCodeDatabyVersion(Code string, version string) (types.TranCode, error) {
+	return getTranCodeDataByVersion(Code, version, documents.DocDBCon)
+}
+
+// getRoutingPolicy returns the cached routing policy of the transaction code, nil if it has none
+func getRoutingPolicy(name string, DBCon *documents.DocDB) *types.TranCodeRoutingPolicy {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeRouting"}
+
+	revision := tranCodeRevision(name)
+	key := tranCodeRoutingKeyPrefix + name
+	if value, err := tranCodeLocalCache.Get(context.Background(), key); err == nil {
+		if entry, ok := value.(*cachedRoutingPolicy); ok && entry.revision == revision {
+			return entry.policy
+		}
+	}
+	if DBCon == nil {
+		return nil
+	}
+
+	policy, err := GetTranCodeRoutingPolicy(name, DBCon)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Failed to load the routing policy of transaction code %s: %s", name, err.Error()))
+		return nil
+	}
+	tranCodeLocalCache.Put(context.Background(), key, &cachedRoutingPolicy{policy: policy, revision: revision}, tranCodeCacheTimeout)
+	return policy
+}
+
+// GetTranCodeRoutingPolicy loads the routing policy of the transaction code, nil if it has none
+func GetTranCodeRoutingPolicy(name string, DBCon *documents.DocDB) (*types.TranCodeRoutingPolicy, error) {
+	items, err := DBCon.QueryCollection(TranCodeRoutingCollection, bson.M{"trancodename": name}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	jsonString, err := json.Marshal(items[0])
+	if err != nil {
+		return nil, err
+	}
+	policy := &types.TranCodeRoutingPolicy{}
+	if err := json.Unmarshal(jsonString, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SaveTranCodeRoutingPolicy inserts or replaces the routing policy of the transaction code and
+// drops the cached policy on all nodes
+func SaveTranCodeRoutingPolicy(policy *types.TranCodeRoutingPolicy, DBCon *documents.DocDB) error {
+	if policy.TranCodeName == "" {
+		return fmt.Errorf("the routing policy has no transaction code name")
+	}
+	if policy.Percentage < 0 || policy.Percentage > 100 {
+		return fmt.Errorf("the canary percentage %v of transaction code %s must be between 0 and 100", policy.Percentage, policy.TranCodeName)
+	}
+	if policy.Enabled && policy.CanaryVersion == "" {
+		return fmt.Errorf("the routing policy of transaction code %s has no canary version", policy.TranCodeName)
+	}
+
+	filter := bson.M{"trancodename": policy.TranCodeName}
+	items, err := DBCon.QueryCollection(TranCodeRoutingCollection, filter, nil)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		_, err = DBCon.InsertCollection(TranCodeRoutingCollection, policy)
+	} else {
+		err = DBCon.UpdateCollection(TranCodeRoutingCollection, filter, nil, policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	InvalidateTranCode(policy.TranCodeName)
+	return nil
+}
+
+// GetTranCodeVersionStats returns the execution statistics of all nodes per version of the transaction code
+func GetTranCodeVersionStats(name string, DBCon *documents.DocDB) ([]types.TranCodeVersionStats, error) {
+	items, err := DBCon.QueryCollection(TranCodeVersionStatsCollection, bson.M{"trancodename": name}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonString, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	stats := []types.TranCodeVersionStats{}
+	if err := json.Unmarshal(jsonString, &stats); err != nil {
+		return nil, err
+	}
+	return types.MergeVersionStats(stats), nil
+}
+
+// recordVersionExecution counts the execution of the transaction flow for its version without delaying the caller
+func (t *TranFlow) recordVersionExecution(startTime time.Time, err error) {
+	failed := err != nil || t.ErrorMessage != ""
+	go recordVersionStats(t.Tcode.Name, t.Tcode.Version, failed, time.Since(startTime), t.DocDBCon)
+}
+
+func recordVersionStats(name, version string, failed bool, duration time.Duration, DBCon *documents.DocDB) {
+	iLog := logger.Log{ModuleName: logger.TranCode, User: "System", ControllerName: "TranCodeRouting"}
+	defer func() {
+		if r := recover(); r != nil {
+			iLog.Error(fmt.Sprintf("Failed to record the execution of transaction code %s version %s: %v", name, version, r))
+		}
+	}()
+	if DBCon == nil {
+		return
+	}
+
+	filter := bson.M{"trancodename": name, "version": version, "node": versionStatsNode}
+	if err := ensureVersionStats(name, version, filter, DBCon); err != nil {
+		iLog.Error(fmt.Sprintf("Failed to create the execution statistics of transaction code %s version %s: %s", name, version, err.Error()))
+		return
+	}
+
+	failures := 0
+	if failed {
+		failures = 1
+	}
+	elapsed := duration.Milliseconds()
+	update := bson.M{
+		"$inc": bson.M{"executions": 1, "failures": failures, "totalduration": elapsed},
+		"$max": bson.M{"maxduration": elapsed},
+		"$set": bson.M{"lastexecuted": time.Now().UTC()},
+	}
+	if err := DBCon.UpdateCollection(TranCodeVersionStatsCollection, filter, update, nil); err != nil {
+		iLog.Error(fmt.Sprintf("Failed to record the execution of transaction code %s version %s: %s", name, version, err.Error()))
+	}
+}
+
+// ensureVersionStats creates the statistics document of this node for the version once
+func ensureVersionStats(name, version string, filter bson.M, DBCon *documents.DocDB) error {
+	versionStatsMu.Lock()
+	defer versionStatsMu.Unlock()
+
+	key := tranCodeCacheKey(name, version)
+	if versionStatsCreated[key] {
+		return nil
+	}
+	items, err := DBCon.QueryCollection(TranCodeVersionStatsCollection, filter, nil)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		stats := &types.TranCodeVersionStats{TranCodeName: name, Version: version, Node: versionStatsNode}
+		if _, err := DBCon.InsertCollection(TranCodeVersionStatsCollection, stats); err != nil {
+			return err
+		}
+	}
+	versionStatsCreated[key] = true
+	return nil
+}
@@ -0,0 +1,56 @@
+package types
+
+// Clone returns a copy of the transaction code that can be executed without affecting the original.
+// The engine writes input values into the function definitions during execution, so every execution of a
+// shared (cached) definition needs its own function groups, functions, inputs and outputs.
+// Compiled router expressions are read-only and stay shared.
+func (t TranCode) Clone() TranCode {
+	clone := t
+	clone.Inputs = append([]Input(nil), t.Inputs...)
+	clone.Outputs = cloneOutputs(t.Outputs)
+	clone.Loops = append([]Loop(nil), t.Loops...)
+	if t.Functiongroups != nil {
+		clone.Functiongroups = make([]FuncGroup, len(t.Functiongroups))
+		for i, fgroup := range t.Functiongroups {
+			clone.Functiongroups[i] = fgroup.Clone()
+		}
+	}
+	return clone
+}
+
+// Clone returns a copy of the function group with its own functions
+func (fg FuncGroup) Clone() FuncGroup {
+	clone := fg
+	if fg.Functions != nil {
+		clone.Functions = make([]Function, len(fg.Functions))
+		for i, fobj := range fg.Functions {
+			clone.Functions[i] = fobj.Clone()
+		}
+	}
+	return clone
+}
+
+// Clone returns a copy of the function with its own inputs, outputs and compensation
+func (f Function) Clone() Function {
+	clone := f
+	clone.Inputs = append([]Input(nil), f.Inputs...)
+	clone.Outputs = cloneOutputs(f.Outputs)
+	if f.Compensation != nil {
+		compensation := f.Compensation.Clone()
+		clone.Compensation = &compensation
+	}
+	return clone
+}
+
+func cloneOutputs(outputs []Output) []Output {
+	if outputs == nil {
+		return nil
+	}
+	clone := make([]Output, len(outputs))
+	for i, output := range outputs {
+		clone[i] = output
+		clone[i].Outputdest = append([]OutputDest(nil), output.Outputdest...)
+		clone[i].Aliasname = append([]string(nil), output.Aliasname...)
+	}
+	return clone
+}
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestTranCode_Clone(t *testing.T) {
+	tc := TranCode{
+		Name: "CloneTC",
+		Functiongroups: []FuncGroup{{
+			Name: "Main",
+			Functions: []Function{{
+				Name:         "Reserve",
+				Inputs:       []Input{{Name: "qty", Value: "1"}},
+				Outputs:      []Output{{Name: "id", Outputdest: []OutputDest{Tosession}, Aliasname: []string{"reservation"}}},
+				Compensation: &Function{Name: "Release", Inputs: []Input{{Name: "id", Value: ""}}},
+			}},
+		}},
+	}
+
+	clone := tc.Clone()
+	fobj := &clone.Functiongroups[0].Functions[0]
+	fobj.Inputs[0].Value = "2"
+	fobj.Outputs[0].Aliasname[0] = "changed"
+	fobj.Compensation.Inputs[0].Value = "R1"
+	clone.Functiongroups[0].Name = "Other"
+
+	orig := tc.Functiongroups[0].Functions[0]
+	if orig.Inputs[0].Value != "1" || orig.Outputs[0].Aliasname[0] != "reservation" || orig.Compensation.Inputs[0].Value != "" || tc.Functiongroups[0].Name != "Main" {
+		t.Errorf("Clone() shares data with the original: %+v", tc.Functiongroups[0])
+	}
+}
@@ -0,0 +1,150 @@
+package types
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CompensationStatus is the state of a compensation entry or of a whole compensation log
+type CompensationStatus string
+
+const (
+	CompensationPending   CompensationStatus = "Pending"
+	CompensationCompleted CompensationStatus = "Completed"
+	CompensationFailed    CompensationStatus = "Failed"
+)
+
+// CompensationEntry records a completed side-effecting function together with the function that undoes it.
+// Inputs and Outputs are the mapped inputs and the outputs of the original execution, the compensation
+// reads them as external inputs and as the outputs of the original function.
+type CompensationEntry struct {
+	Sequence      int                    `json:"sequence"`
+	FunctionGroup string                 `json:"functiongroup"`
+	FunctionName  string                 `json:"functionname"`
+	FunctionType  string                 `json:"functiontype"`
+	Inputs        map[string]interface{} `json:"inputs"`
+	Outputs       map[string]interface{} `json:"outputs"`
+	Compensation  Function               `json:"compensation"`
+	Status        CompensationStatus     `json:"status"`
+	Attempts      int                    `json:"attempts"`
+	Error         string                 `json:"error"`
+	RecordedOn    time.Time              `json:"recordedon"`
+	CompletedOn   time.Time              `json:"completedon"`
+}
+
+// Complete marks the compensation as executed successfully
+func (e *CompensationEntry) Complete() {
+	e.Attempts++
+	e.Status = CompensationCompleted
+	e.Error = ""
+	e.CompletedOn = time.Now()
+}
+
+// Fail marks the compensation attempt as failed so it can be retried later
+func (e *CompensationEntry) Fail(err error) {
+	e.Attempts++
+	e.Status = CompensationFailed
+	e.Error = err.Error()
+}
+
+// CompensationLog keeps the compensation entries of one transaction code execution in execution order
+type CompensationLog struct {
+	ID              string               `json:"id"`
+	TranCodeName    string               `json:"trancodename"`
+	TranCodeVersion string               `json:"trancodeversion"`
+	Status          CompensationStatus   `json:"status"`
+	Entries         []*CompensationEntry `json:"entries"`
+	CreatedOn       time.Time            `json:"createdon"`
+	ModifiedOn      time.Time            `json:"modifiedon"`
+	mu              sync.Mutex
+}
+
+// NewCompensationLog creates an empty compensation log for an execution of the transaction code
+func NewCompensationLog(tcode *TranCode) *CompensationLog {
+	return &CompensationLog{
+		ID:              uuid.New().String(),
+		TranCodeName:    tcode.Name,
+		TranCodeVersion: tcode.Version,
+		Status:          CompensationPending,
+		Entries:         []*CompensationEntry{},
+		CreatedOn:       time.Now(),
+		ModifiedOn:      time.Now(),
+	}
+}
+
+// Record adds a completed function to the log. Functions without a compensation are ignored.
+func (l *CompensationLog) Record(fgroup string, fobj Function, inputs, outputs map[string]interface{}) {
+	if fobj.Compensation == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Entries = append(l.Entries, &CompensationEntry{
+		Sequence:      len(l.Entries) + 1,
+		FunctionGroup: fgroup,
+		FunctionName:  fobj.Name,
+		FunctionType:  fobj.Functype.String(),
+		Inputs:        inputs,
+		Outputs:       outputs,
+		Compensation:  *fobj.Compensation,
+		Status:        CompensationPending,
+		RecordedOn:    time.Now(),
+	})
+}
+
+// Len returns the number of recorded entries
+func (l *CompensationLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.Entries)
+}
+
+// Pending returns the entries from index from onwards that still have to be compensated, in reverse execution order
+func (l *CompensationLog) Pending(from int) []*CompensationEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := []*CompensationEntry{}
+	for i := len(l.Entries) - 1; i >= from && i >= 0; i-- {
+		if l.Entries[i].Status != CompensationCompleted {
+			entries = append(entries, l.Entries[i])
+		}
+	}
+	return entries
+}
+
+// Attempted reports whether any compensation has been executed
+func (l *CompensationLog) Attempted() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, entry := range l.Entries {
+		if entry.Attempts > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateStatus derives the status of the log from its entries: Failed if any compensation failed,
+// Completed if every entry was compensated and Pending otherwise.
+func (l *CompensationLog) UpdateStatus() CompensationStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status := CompensationCompleted
+	for _, entry := range l.Entries {
+		if entry.Status == CompensationFailed {
+			status = CompensationFailed
+			break
+		}
+		if entry.Status == CompensationPending {
+			status = CompensationPending
+		}
+	}
+	l.Status = status
+	l.ModifiedOn = time.Now()
+	return status
+}
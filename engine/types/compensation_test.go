@@ -0,0 +1,57 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompensationLog_Pending(t *testing.T) {
+	tc := TranCode{Name: "SagaTC", Version: "1"}
+	log := NewCompensationLog(&tc)
+
+	undo := &Function{Name: "Undo"}
+	log.Record("G1", Function{Name: "Reserve", Compensation: undo}, map[string]interface{}{"qty": 1}, nil)
+	log.Record("G1", Function{Name: "Read"}, nil, nil)
+	log.Record("G2", Function{Name: "Notify", Compensation: undo}, nil, nil)
+	log.Record("G2", Function{Name: "Ship", Compensation: undo}, nil, nil)
+
+	if log.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", log.Len())
+	}
+
+	pending := log.Pending(0)
+	names := []string{}
+	for _, entry := range pending {
+		names = append(names, entry.FunctionName)
+	}
+	if len(names) != 3 || names[0] != "Ship" || names[1] != "Notify" || names[2] != "Reserve" {
+		t.Fatalf("Pending(0) = %v, want reverse execution order", names)
+	}
+
+	if got := log.Pending(1); len(got) != 2 || got[1].FunctionName != "Notify" {
+		t.Errorf("Pending(1) returned %d entries, want Ship and Notify", len(got))
+	}
+
+	pending[0].Complete()
+	pending[1].Fail(errors.New("broker unavailable"))
+	if log.UpdateStatus() != CompensationFailed {
+		t.Errorf("UpdateStatus() = %s, want %s", log.Status, CompensationFailed)
+	}
+	pending[1].Status = CompensationPending
+	if log.UpdateStatus() != CompensationPending {
+		t.Errorf("UpdateStatus() = %s, want %s", log.Status, CompensationPending)
+	}
+	pending[1].Status = CompensationFailed
+	pending[2].Complete()
+	if log.UpdateStatus() != CompensationFailed {
+		t.Errorf("UpdateStatus() = %s, want %s", log.Status, CompensationFailed)
+	}
+	if got := log.Pending(0); len(got) != 1 || got[0].FunctionName != "Notify" || got[0].Attempts != 1 {
+		t.Errorf("Pending(0) after compensation = %v, want the failed Notify entry", got)
+	}
+
+	pending[1].Complete()
+	if log.UpdateStatus() != CompensationCompleted || !log.Attempted() {
+		t.Errorf("UpdateStatus() = %s, want %s", log.Status, CompensationCompleted)
+	}
+}
@@ -0,0 +1,354 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaField describes a property of an Object input or output
+type SchemaField struct {
+	Name        string        `json:"name"`
+	Datatype    DataType      `json:"datatype"`
+	List        bool          `json:"list"`
+	Required    bool          `json:"required"`
+	Fields      []SchemaField `json:"fields"`
+	Description string        `json:"description"`
+}
+
+// ContractViolation is a value that does not match the declared inputs or outputs of a transaction code
+type ContractViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// contractFormats are the datetime formats accepted for DateTime values
+var contractFormats = []string{DateTimeFormat, time.RFC3339, "2006-01-02"}
+
+// extendedTypeMessages are the violation messages of values that cannot be converted to an extended data type
+var extendedTypeMessages = map[DataType]string{
+	Decimal:  "must be a decimal number",
+	Date:     fmt.Sprintf("must be a date formatted as %s", DateFormat),
+	Time:     "must be a time formatted as 15:04:05",
+	Duration: "must be an ISO 8601 duration or a number of milliseconds",
+	Binary:   "must be base64 encoded",
+}
+
+// ValidateInputs checks the external inputs against the declared inputs of the transaction code.
+// It returns the inputs with the declared values coerced to their data types, or one validation error
+// listing every violation. Transaction codes without declared inputs accept any inputs.
+func (t *TranCode) ValidateInputs(inputs map[string]interface{}) (map[string]interface{}, error) {
+	if len(t.Inputs) == 0 {
+		return inputs, nil
+	}
+
+	coerced := make(map[string]interface{}, len(inputs))
+	for k, v := range inputs {
+		coerced[k] = v
+	}
+
+	violations := []ContractViolation{}
+	for _, input := range t.Inputs {
+		field := SchemaField{Name: input.Name, Datatype: input.Datatype, List: input.List, Required: input.Required, Fields: input.Schema}
+		value, ok := coerced[input.Name]
+		if !ok || value == nil {
+			if input.Required {
+				violations = append(violations, ContractViolation{Path: input.Name, Message: "is required"})
+			}
+			continue
+		}
+		coerced[input.Name] = coerceField(field, value, input.Name, &violations)
+	}
+
+	if len(violations) > 0 {
+		return inputs, NewContractError(fmt.Sprintf("Transaction code %s received invalid inputs", t.Name), violations)
+	}
+	return coerced, nil
+}
+
+// ValidateOutputs checks the external outputs against the declared outputs of the transaction code.
+// The outputs are not changed, a value only has to be convertible to its declared data type.
+func (t *TranCode) ValidateOutputs(outputs map[string]interface{}) error {
+	violations := []ContractViolation{}
+	for _, output := range t.Outputs {
+		field := SchemaField{Name: output.Name, Datatype: output.Datatype, List: output.List, Required: output.Required, Fields: output.Schema}
+		value, ok := outputs[output.Name]
+		if !ok || value == nil {
+			if output.Required {
+				violations = append(violations, ContractViolation{Path: output.Name, Message: "is required"})
+			}
+			continue
+		}
+		coerceField(field, value, output.Name, &violations)
+	}
+
+	if len(violations) > 0 {
+		return NewContractError(fmt.Sprintf("Transaction code %s returned invalid outputs", t.Name), violations)
+	}
+	return nil
+}
+
+// NewContractError creates a validation error listing every violation in its message and details
+func NewContractError(message string, violations []ContractViolation) *BPMError {
+	parts := make([]string, len(violations))
+	for i, v := range violations {
+		parts[i] = fmt.Sprintf("%s %s", v.Path, v.Message)
+	}
+	err := NewValidationError(fmt.Sprintf("%s: %s", message, strings.Join(parts, "; ")), nil)
+	for _, v := range violations {
+		err.WithDetail(v.Path, v.Message)
+	}
+	return err
+}
+
+// coerceField converts the value to the declared shape of the field and records the violations under path
+func coerceField(field SchemaField, value interface{}, path string, violations *[]ContractViolation) interface{} {
+	if !field.List {
+		return coerceValue(field, value, path, violations)
+	}
+
+	if s, ok := value.(string); ok {
+		var list []interface{}
+		if err := json.Unmarshal([]byte(s), &list); err != nil {
+			*violations = append(*violations, ContractViolation{Path: path, Message: "must be a list"})
+			return value
+		}
+		value = list
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		*violations = append(*violations, ContractViolation{Path: path, Message: "must be a list"})
+		return value
+	}
+	result := make([]interface{}, len(list))
+	for i, item := range list {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if item == nil {
+			*violations = append(*violations, ContractViolation{Path: itemPath, Message: "must not be empty"})
+			continue
+		}
+		result[i] = coerceValue(field, item, itemPath, violations)
+	}
+	return result
+}
+
+// coerceValue converts a single value to the data type of the field.
+// Numbers and booleans may be sent as strings, integers as whole floating point numbers (JSON numbers)
+// and objects as JSON strings.
+func coerceValue(field SchemaField, value interface{}, path string, violations *[]ContractViolation) interface{} {
+	invalid := func(message string) interface{} {
+		*violations = append(*violations, ContractViolation{Path: path, Message: message})
+		return value
+	}
+
+	switch field.Datatype {
+	case Integer:
+		switch v := value.(type) {
+		case int, int32, int64:
+			return v
+		case float64:
+			if v != math.Trunc(v) {
+				return invalid("must be an integer")
+			}
+			return int64(v)
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return invalid("must be an integer")
+			}
+			return n
+		}
+		return invalid("must be an integer")
+
+	case Float:
+		switch v := value.(type) {
+		case float64:
+			return v
+		case float32:
+			return float64(v)
+		case int:
+			return float64(v)
+		case int64:
+			return float64(v)
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return invalid("must be a number")
+			}
+			return f
+		}
+		return invalid("must be a number")
+
+	case Bool:
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return invalid("must be true or false")
+			}
+			return b
+		}
+		return invalid("must be true or false")
+
+	case DateTime:
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format(DateTimeFormat)
+		case string:
+			for _, format := range contractFormats {
+				if _, err := time.Parse(format, v); err == nil {
+					return v
+				}
+			}
+		}
+		return invalid(fmt.Sprintf("must be a datetime formatted as %s", DateTimeFormat))
+
+	case Object:
+		if s, ok := value.(string); ok {
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(s), &obj); err != nil {
+				return invalid("must be an object")
+			}
+			value = obj
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("must be an object")
+		}
+		if len(field.Fields) == 0 {
+			return obj
+		}
+		result := make(map[string]interface{}, len(obj))
+		for k, v := range obj {
+			result[k] = v
+		}
+		for _, child := range field.Fields {
+			childPath := path + "." + child.Name
+			v, ok := obj[child.Name]
+			if !ok || v == nil {
+				if child.Required {
+					*violations = append(*violations, ContractViolation{Path: childPath, Message: "is required"})
+				}
+				continue
+			}
+			result[child.Name] = coerceField(child, v, childPath, violations)
+		}
+		return result
+
+	case Decimal, Date, Time, Duration, Binary:
+		v, err := ConvertValue(value, field.Datatype)
+		if err != nil {
+			return invalid(extendedTypeMessages[field.Datatype])
+		}
+		return v
+
+	default:
+		switch v := value.(type) {
+		case string:
+			return v
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return invalid("must be a string")
+			}
+			return string(b)
+		}
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// InputSchema returns the JSON Schema of the declared inputs of the transaction code
+func (t *TranCode) InputSchema() map[string]interface{} {
+	fields := make([]SchemaField, len(t.Inputs))
+	for i, input := range t.Inputs {
+		fields[i] = SchemaField{Name: input.Name, Datatype: input.Datatype, List: input.List, Required: input.Required, Fields: input.Schema, Description: input.Description}
+	}
+	return contractSchema(fmt.Sprintf("%s inputs", t.Name), t.Version, fields)
+}
+
+// OutputSchema returns the JSON Schema of the declared outputs of the transaction code
+func (t *TranCode) OutputSchema() map[string]interface{} {
+	fields := make([]SchemaField, len(t.Outputs))
+	for i, output := range t.Outputs {
+		fields[i] = SchemaField{Name: output.Name, Datatype: output.Datatype, List: output.List, Required: output.Required, Fields: output.Schema, Description: output.Description}
+	}
+	return contractSchema(fmt.Sprintf("%s outputs", t.Name), t.Version, fields)
+}
+
+func contractSchema(title, version string, fields []SchemaField) map[string]interface{} {
+	schema := objectSchema(fields)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = title
+	if version != "" {
+		schema["version"] = version
+	}
+	return schema
+}
+
+func objectSchema(fields []SchemaField) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, field := range fields {
+		properties[field.Name] = fieldSchema(field)
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+	sort.Strings(required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func fieldSchema(field SchemaField) map[string]interface{} {
+	var schema map[string]interface{}
+	switch field.Datatype {
+	case Integer:
+		schema = map[string]interface{}{"type": "integer"}
+	case Float:
+		schema = map[string]interface{}{"type": "number"}
+	case Bool:
+		schema = map[string]interface{}{"type": "boolean"}
+	case DateTime:
+		schema = map[string]interface{}{"type": "string", "format": "date-time"}
+	case Decimal:
+		// decimals are sent as strings so clients do not round them to floating point numbers
+		schema = map[string]interface{}{"type": "string", "format": "decimal", "pattern": `^[-+]?\d*\.?\d+([eE][-+]?\d+)?$`}
+	case Date:
+		schema = map[string]interface{}{"type": "string", "format": "date"}
+	case Time:
+		schema = map[string]interface{}{"type": "string", "format": "time"}
+	case Duration:
+		schema = map[string]interface{}{"type": "string", "format": "duration"}
+	case Binary:
+		schema = map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	case Object:
+		if len(field.Fields) > 0 {
+			schema = objectSchema(field.Fields)
+		} else {
+			schema = map[string]interface{}{"type": "object"}
+		}
+	default:
+		schema = map[string]interface{}{"type": "string"}
+	}
+
+	if field.List {
+		schema = map[string]interface{}{"type": "array", "items": schema}
+	}
+	if field.Description != "" {
+		schema["description"] = field.Description
+	}
+	return schema
+}
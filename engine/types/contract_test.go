@@ -0,0 +1,114 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func contractTranCode() TranCode {
+	return TranCode{
+		Name: "Order",
+		Inputs: []Input{
+			{Name: "qty", Datatype: Integer, Required: true},
+			{Name: "price", Datatype: Float},
+			{Name: "rush", Datatype: Bool},
+			{Name: "due", Datatype: DateTime},
+			{Name: "lines", Datatype: Integer, List: true},
+			{Name: "customer", Datatype: Object, Required: true, Schema: []SchemaField{
+				{Name: "id", Datatype: String, Required: true},
+				{Name: "level", Datatype: Integer},
+			}},
+		},
+		Outputs: []Output{
+			{Name: "orderid", Datatype: String, Required: true},
+			{Name: "total", Datatype: Float},
+		},
+	}
+}
+
+func TestTranCode_ValidateInputs(t *testing.T) {
+	tc := contractTranCode()
+
+	got, err := tc.ValidateInputs(map[string]interface{}{
+		"qty":      float64(3),
+		"price":    "9.5",
+		"rush":     "true",
+		"due":      "2024-05-01 08:00:00",
+		"lines":    []interface{}{"1", float64(2)},
+		"customer": `{"id": "C1", "level": "2"}`,
+		"extra":    "kept",
+	})
+	if err != nil {
+		t.Fatalf("ValidateInputs() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"qty":      int64(3),
+		"price":    9.5,
+		"rush":     true,
+		"due":      "2024-05-01 08:00:00",
+		"lines":    []interface{}{int64(1), int64(2)},
+		"customer": map[string]interface{}{"id": "C1", "level": int64(2)},
+		"extra":    "kept",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateInputs() = %v, want %v", got, want)
+	}
+}
+
+func TestTranCode_ValidateInputs_Violations(t *testing.T) {
+	tc := contractTranCode()
+
+	_, err := tc.ValidateInputs(map[string]interface{}{
+		"qty":      1.5,
+		"rush":     "maybe",
+		"due":      "tomorrow",
+		"lines":    "1,2",
+		"customer": map[string]interface{}{"level": "high"},
+	})
+	bpmErr, ok := err.(*BPMError)
+	if !ok || bpmErr.Category != ErrorCategoryValidation {
+		t.Fatalf("ValidateInputs() error = %v, want a validation error", err)
+	}
+	want := map[string]string{
+		"qty":            "must be an integer",
+		"rush":           "must be true or false",
+		"due":            "must be a datetime formatted as 2006-01-02 15:04:05",
+		"lines":          "must be a list",
+		"customer.id":    "is required",
+		"customer.level": "must be an integer",
+	}
+	if !reflect.DeepEqual(bpmErr.Details, want) {
+		t.Errorf("violations = %v, want %v", bpmErr.Details, want)
+	}
+}
+
+func TestTranCode_ValidateOutputs(t *testing.T) {
+	tc := contractTranCode()
+
+	if err := tc.ValidateOutputs(map[string]interface{}{"orderid": "O1", "total": 12}); err != nil {
+		t.Errorf("ValidateOutputs() error = %v", err)
+	}
+	err := tc.ValidateOutputs(map[string]interface{}{"total": "n/a"})
+	bpmErr, ok := err.(*BPMError)
+	if !ok || len(bpmErr.Details) != 2 {
+		t.Errorf("ValidateOutputs() error = %v, want 2 violations", err)
+	}
+}
+
+func TestTranCode_InputSchema(t *testing.T) {
+	tc := contractTranCode()
+	schema := tc.InputSchema()
+
+	if !reflect.DeepEqual(schema["required"], []string{"customer", "qty"}) {
+		t.Errorf("required = %v", schema["required"])
+	}
+	properties := schema["properties"].(map[string]interface{})
+	lines := properties["lines"].(map[string]interface{})
+	if lines["type"] != "array" || !reflect.DeepEqual(lines["items"], map[string]interface{}{"type": "integer"}) {
+		t.Errorf("lines schema = %v", lines)
+	}
+	customer := properties["customer"].(map[string]interface{})
+	if customer["type"] != "object" || !reflect.DeepEqual(customer["required"], []string{"id"}) {
+		t.Errorf("customer schema = %v", customer)
+	}
+}
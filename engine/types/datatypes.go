@@ -0,0 +1,651 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DATA TYPE DESIGN: Decimal, Date, Time, Duration and Binary values are kept in their own value types in the
+// sessions and function inputs and outputs. Every value type formats itself for JSON and implements
+// driver.Valuer and sql.Scanner, so it can be bound to queries and read back without going through float64
+// or time.Time. Script engines get plain strings and numbers (see ScriptValue), expressions get numbers, times and
+// durations they can calculate with (see ExprValue).
+
+// IsExtended reports whether values of the data type are represented by the engine's own value types
+func (dt DataType) IsExtended() bool {
+	switch dt {
+	case Decimal, Date, Time, Duration, Binary:
+		return true
+	}
+	return false
+}
+
+// DecimalValue is an arbitrary precision decimal number, the unscaled integer divided by 10^scale
+type DecimalValue struct {
+	unscaled *big.Int
+	scale    int32
+}
+
+var tenInt = big.NewInt(10)
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(tenInt, big.NewInt(int64(n)), nil)
+}
+
+// ParseDecimal parses a decimal number like "-12.345" or "1.5e3" without losing precision
+func ParseDecimal(s string) (DecimalValue, error) {
+	str := strings.TrimSpace(s)
+	exp := int64(0)
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(str[i+1:], 10, 32)
+		if err != nil {
+			return DecimalValue{}, fmt.Errorf("invalid decimal value '%s'", s)
+		}
+		exp = e
+		str = str[:i]
+	}
+
+	sign := ""
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		if str[0] == '-' {
+			sign = "-"
+		}
+		str = str[1:]
+	}
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return DecimalValue{}, fmt.Errorf("invalid decimal value '%s'", s)
+	}
+
+	unscaled, _ := new(big.Int).SetString(sign+digits, 10)
+	scale := int64(len(fracPart)) - exp
+	if scale > math.MaxInt32 || scale < math.MinInt32 {
+		return DecimalValue{}, fmt.Errorf("decimal value '%s' is out of range", s)
+	}
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(int32(-scale)))
+		scale = 0
+	}
+	return DecimalValue{unscaled: unscaled, scale: int32(scale)}, nil
+}
+
+// NewDecimalFromInt returns the decimal value of an integer
+func NewDecimalFromInt(i int64) DecimalValue {
+	return DecimalValue{unscaled: big.NewInt(i)}
+}
+
+// NewDecimalFromFloat returns the decimal value of the shortest representation of the float,
+// so 0.1 becomes exactly 0.1
+func NewDecimalFromFloat(f float64) (DecimalValue, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return DecimalValue{}, fmt.Errorf("%v is not a decimal value", f)
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func (d DecimalValue) int() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+// Scale returns the number of digits after the decimal point
+func (d DecimalValue) Scale() int32 {
+	return d.scale
+}
+
+// String returns the decimal in plain notation with all its digits
+func (d DecimalValue) String() string {
+	digits := new(big.Int).Abs(d.int()).String()
+	sign := ""
+	if d.int().Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= int(d.scale) {
+		digits = strings.Repeat("0", int(d.scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// rescale returns the unscaled values of both decimals at the larger scale
+func rescale(a, b DecimalValue) (*big.Int, *big.Int, int32) {
+	x, y := a.int(), b.int()
+	switch {
+	case a.scale > b.scale:
+		y = new(big.Int).Mul(y, pow10(a.scale-b.scale))
+		return x, y, a.scale
+	case b.scale > a.scale:
+		x = new(big.Int).Mul(x, pow10(b.scale-a.scale))
+		return x, y, b.scale
+	}
+	return x, y, a.scale
+}
+
+// Add returns d + other
+func (d DecimalValue) Add(other DecimalValue) DecimalValue {
+	x, y, scale := rescale(d, other)
+	return DecimalValue{unscaled: new(big.Int).Add(x, y), scale: scale}
+}
+
+// Sub returns d - other
+func (d DecimalValue) Sub(other DecimalValue) DecimalValue {
+	x, y, scale := rescale(d, other)
+	return DecimalValue{unscaled: new(big.Int).Sub(x, y), scale: scale}
+}
+
+// Mul returns d * other
+func (d DecimalValue) Mul(other DecimalValue) DecimalValue {
+	return DecimalValue{unscaled: new(big.Int).Mul(d.int(), other.int()), scale: d.scale + other.scale}
+}
+
+// Div returns d / other rounded half away from zero to the given number of decimal places
+func (d DecimalValue) Div(other DecimalValue, places int32) (DecimalValue, error) {
+	if other.int().Sign() == 0 {
+		return DecimalValue{}, fmt.Errorf("division by zero")
+	}
+	r := new(big.Rat).Quo(d.Rat(), other.Rat())
+	return roundRat(r, places), nil
+}
+
+// Round rounds half away from zero to the given number of decimal places
+func (d DecimalValue) Round(places int32) DecimalValue {
+	if places >= d.scale {
+		return d
+	}
+	return roundRat(d.Rat(), places)
+}
+
+func roundRat(r *big.Rat, places int32) DecimalValue {
+	num := new(big.Int).Mul(new(big.Int).Abs(r.Num()), pow10(places))
+	q, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return DecimalValue{unscaled: q, scale: places}
+}
+
+// Cmp returns -1, 0 or 1 if d is less than, equal to or greater than other
+func (d DecimalValue) Cmp(other DecimalValue) int {
+	x, y, _ := rescale(d, other)
+	return x.Cmp(y)
+}
+
+// Sign returns -1, 0 or 1 for negative, zero and positive values
+func (d DecimalValue) Sign() int {
+	return d.int().Sign()
+}
+
+// Rat returns the exact rational value
+func (d DecimalValue) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.int(), pow10(d.scale))
+}
+
+// Float64 returns the nearest float, for calculations that do not need exact results
+func (d DecimalValue) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// MarshalJSON writes the decimal as a string so clients do not round it to a float
+func (d DecimalValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a decimal from a JSON string or number
+func (d *DecimalValue) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	value, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = value
+	return nil
+}
+
+// Value implements driver.Valuer, databases convert the plain notation to their decimal types exactly
+func (d DecimalValue) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan implements sql.Scanner
+func (d *DecimalValue) Scan(src interface{}) error {
+	value, err := ConvertValue(src, Decimal)
+	if err != nil {
+		return err
+	}
+	*d = value.(DecimalValue)
+	return nil
+}
+
+// DateValue is a calendar date without time of day and time zone
+type DateValue struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateFormat is the format of DateValue strings
+const DateFormat = "2006-01-02"
+
+// DateOf returns the date of the time in its own location
+func DateOf(t time.Time) DateValue {
+	y, m, d := t.Date()
+	return DateValue{Year: y, Month: m, Day: d}
+}
+
+// ParseDate parses a date. Datetimes are accepted and reduced to their date.
+func ParseDate(s string) (DateValue, error) {
+	str := strings.TrimSpace(s)
+	for _, format := range []string{DateFormat, DateTimeFormat, time.RFC3339Nano} {
+		if t, err := time.Parse(format, str); err == nil {
+			return DateOf(t), nil
+		}
+	}
+	return DateValue{}, fmt.Errorf("invalid date value '%s', expected %s", s, DateFormat)
+}
+
+// String returns the date formatted as 2006-01-02
+func (d DateValue) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// Time returns midnight UTC of the date
+func (d DateValue) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// MarshalJSON writes the date as a 2006-01-02 string
+func (d DateValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a date from a JSON string
+func (d *DateValue) UnmarshalJSON(data []byte) error {
+	value, err := ParseDate(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*d = value
+	return nil
+}
+
+// Value implements driver.Valuer
+func (d DateValue) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan implements sql.Scanner
+func (d *DateValue) Scan(src interface{}) error {
+	value, err := ConvertValue(src, Date)
+	if err != nil {
+		return err
+	}
+	*d = value.(DateValue)
+	return nil
+}
+
+// TimeValue is a time of day without date and time zone
+type TimeValue struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// TimeOf returns the time of day of the time in its own location
+func TimeOf(t time.Time) TimeValue {
+	return TimeValue{Hour: t.Hour(), Minute: t.Minute(), Second: t.Second(), Nanosecond: t.Nanosecond()}
+}
+
+// ParseTimeOfDay parses a time of day like 15:04, 15:04:05 or 15:04:05.123. Datetimes are reduced to their time of day.
+func ParseTimeOfDay(s string) (TimeValue, error) {
+	str := strings.TrimSpace(s)
+	for _, format := range []string{"15:04:05.999999999", "15:04", DateTimeFormat, time.RFC3339Nano} {
+		if t, err := time.Parse(format, str); err == nil {
+			return TimeOf(t), nil
+		}
+	}
+	return TimeValue{}, fmt.Errorf("invalid time value '%s', expected 15:04:05", s)
+}
+
+// String returns the time formatted as 15:04:05 with the fraction of the second if there is one
+func (t TimeValue) String() string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+	if t.Nanosecond != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%09d", t.Nanosecond), "0")
+	}
+	return s
+}
+
+// MarshalJSON writes the time as a 15:04:05 string
+func (t TimeValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON reads a time of day from a JSON string
+func (t *TimeValue) UnmarshalJSON(data []byte) error {
+	value, err := ParseTimeOfDay(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*t = value
+	return nil
+}
+
+// Value implements driver.Valuer
+func (t TimeValue) Value() (driver.Value, error) {
+	return t.String(), nil
+}
+
+// Scan implements sql.Scanner
+func (t *TimeValue) Scan(src interface{}) error {
+	value, err := ConvertValue(src, Time)
+	if err != nil {
+		return err
+	}
+	*t = value.(TimeValue)
+	return nil
+}
+
+// DurationValue is a length of time. It is written as an ISO 8601 duration (PT1H30M) in JSON,
+// and as a number of milliseconds to databases and scripts.
+type DurationValue time.Duration
+
+var isoDurationPattern = regexp.MustCompile(`^(-)?P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseDuration parses an ISO 8601 duration (P1DT2H, PT90S), a Go duration (1h30m) or a number of milliseconds
+func ParseDuration(s string) (DurationValue, error) {
+	str := strings.TrimSpace(s)
+	if ms, err := strconv.ParseFloat(str, 64); err == nil {
+		return DurationValue(ms * float64(time.Millisecond)), nil
+	}
+	if m := isoDurationPattern.FindStringSubmatch(str); m != nil && str != "P" && str != "-P" && !strings.HasSuffix(str, "T") {
+		total := 0.0
+		for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+			if m[i+2] == "" {
+				continue
+			}
+			n, _ := strconv.ParseFloat(m[i+2], 64)
+			total += n * float64(unit)
+		}
+		if m[1] == "-" {
+			total = -total
+		}
+		return DurationValue(total), nil
+	}
+	if d, err := time.ParseDuration(str); err == nil {
+		return DurationValue(d), nil
+	}
+	return 0, fmt.Errorf("invalid duration value '%s', expected an ISO 8601 duration like PT1H30M", s)
+}
+
+// Milliseconds returns the duration as a number of milliseconds
+func (d DurationValue) Milliseconds() int64 {
+	return time.Duration(d).Milliseconds()
+}
+
+// String returns the duration in ISO 8601 format
+func (d DurationValue) String() string {
+	if d == 0 {
+		return "PT0S"
+	}
+	sign := ""
+	n := time.Duration(d)
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+	var sb strings.Builder
+	sb.WriteString(sign + "PT")
+	if h := n / time.Hour; h > 0 {
+		sb.WriteString(fmt.Sprintf("%dH", h))
+	}
+	if m := n % time.Hour / time.Minute; m > 0 {
+		sb.WriteString(fmt.Sprintf("%dM", m))
+	}
+	if s := n % time.Minute; s > 0 {
+		sec := fmt.Sprintf("%d", s/time.Second)
+		if frac := s % time.Second; frac > 0 {
+			sec += strings.TrimRight(fmt.Sprintf(".%09d", frac), "0")
+		}
+		sb.WriteString(sec + "S")
+	}
+	return sb.String()
+}
+
+// MarshalJSON writes the duration as an ISO 8601 string
+func (d DurationValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a duration from a JSON string or a number of milliseconds
+func (d *DurationValue) UnmarshalJSON(data []byte) error {
+	value, err := ParseDuration(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*d = value
+	return nil
+}
+
+// Value implements driver.Valuer, the duration is stored as milliseconds
+func (d DurationValue) Value() (driver.Value, error) {
+	return d.Milliseconds(), nil
+}
+
+// Scan implements sql.Scanner
+func (d *DurationValue) Scan(src interface{}) error {
+	value, err := ConvertValue(src, Duration)
+	if err != nil {
+		return err
+	}
+	*d = value.(DurationValue)
+	return nil
+}
+
+// ParseBinary decodes base64 (standard or URL alphabet) or 0x prefixed hexadecimal data
+func ParseBinary(s string) ([]byte, error) {
+	str := strings.TrimSpace(s)
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+		return hex.DecodeString(str[2:])
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err := encoding.DecodeString(str); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid binary value, expected base64 or 0x prefixed hexadecimal")
+}
+
+// ConvertValue converts a value to the value type of an extended data type: DecimalValue, DateValue,
+// TimeValue, DurationValue or []byte. Values of other data types are returned unchanged.
+func ConvertValue(value interface{}, datatype DataType) (interface{}, error) {
+	if value == nil || !datatype.IsExtended() {
+		return value, nil
+	}
+	if b, ok := value.([]byte); ok && datatype != Binary {
+		// databases return decimal and date columns as text
+		value = string(b)
+	}
+
+	switch datatype {
+	case Decimal:
+		switch v := value.(type) {
+		case DecimalValue:
+			return v, nil
+		case string:
+			return ParseDecimal(v)
+		case json.Number:
+			return ParseDecimal(v.String())
+		case float64:
+			return NewDecimalFromFloat(v)
+		case float32:
+			return NewDecimalFromFloat(float64(v))
+		case int:
+			return NewDecimalFromInt(int64(v)), nil
+		case int32:
+			return NewDecimalFromInt(int64(v)), nil
+		case int64:
+			return NewDecimalFromInt(v), nil
+		}
+	case Date:
+		switch v := value.(type) {
+		case DateValue:
+			return v, nil
+		case time.Time:
+			return DateOf(v), nil
+		case string:
+			return ParseDate(v)
+		}
+	case Time:
+		switch v := value.(type) {
+		case TimeValue:
+			return v, nil
+		case time.Time:
+			return TimeOf(v), nil
+		case string:
+			return ParseTimeOfDay(v)
+		}
+	case Duration:
+		switch v := value.(type) {
+		case DurationValue:
+			return v, nil
+		case time.Duration:
+			return DurationValue(v), nil
+		case string:
+			return ParseDuration(v)
+		case float64:
+			return DurationValue(v * float64(time.Millisecond)), nil
+		case int:
+			return DurationValue(time.Duration(v) * time.Millisecond), nil
+		case int64:
+			return DurationValue(time.Duration(v) * time.Millisecond), nil
+		}
+	case Binary:
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return ParseBinary(v)
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %T to data type %d", value, datatype)
+}
+
+// ConvertValues converts a value or every item of a list with ConvertValue
+func ConvertValues(value interface{}, datatype DataType) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return ConvertValue(value, datatype)
+	}
+	result := make([]interface{}, len(list))
+	for i, item := range list {
+		converted, err := ConvertValue(item, datatype)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		result[i] = converted
+	}
+	return result, nil
+}
+
+// ScriptValue converts the extended value types to plain values that script engines understand:
+// decimals, dates and times become strings, durations milliseconds and binary data base64 strings.
+// Decimals stay strings to keep their digits, a JavaScript or Python function converts them itself before
+// calculating, e.g. Number(price) or Decimal(price).
+// Lists and objects are converted recursively, all other values are returned unchanged.
+func ScriptValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case DecimalValue, DateValue, TimeValue:
+		return fmt.Sprint(v)
+	case DurationValue:
+		return v.Milliseconds()
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = ScriptValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		return ScriptValues(v)
+	}
+	return value
+}
+
+// ScriptValues converts all values of the map with ScriptValue
+func ScriptValues(values map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		result[k] = ScriptValue(v)
+	}
+	return result
+}
+
+// ExprValue converts the extended value types to values the operators of expressions work on: decimals become
+// float64, dates time.Time at midnight UTC and durations time.Duration. Times of day become "15:04:05" strings,
+// which compare in order. Lists and objects are converted recursively, all other values are returned unchanged.
+func ExprValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case DecimalValue:
+		return v.Float64()
+	case DateValue:
+		return v.Time()
+	case TimeValue:
+		return v.String()
+	case DurationValue:
+		return time.Duration(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = ExprValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		return ExprValues(v)
+	}
+	return value
+}
+
+// ExprValues converts all values of the map with ExprValue
+func ExprValues(values map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		result[k] = ExprValue(v)
+	}
+	return result
+}
+
+// SQLValue converts the text form of a value of an extended data type to the value bound to a query parameter
+func SQLValue(text string, datatype DataType) (interface{}, error) {
+	value, err := ConvertValue(text, datatype)
+	if err != nil {
+		return nil, err
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		return valuer.Value()
+	}
+	return value, nil
+}
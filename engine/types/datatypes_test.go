@@ -0,0 +1,227 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"12.340", "12.340"},
+		{"-0.05", "-0.05"},
+		{"+7", "7"},
+		{".5", "0.5"},
+		{"1.5e3", "1500"},
+		{"25e-4", "0.0025"},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890.123456789"},
+	}
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if err != nil {
+			t.Errorf("ParseDecimal(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "-", "1.2.3", "abc", "1e"} {
+		if _, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) expected an error", in)
+		}
+	}
+}
+
+func TestDecimalValue_Arithmetic(t *testing.T) {
+	a, _ := ParseDecimal("0.1")
+	b, _ := ParseDecimal("0.2")
+	if got := a.Add(b).String(); got != "0.3" {
+		t.Errorf("0.1 + 0.2 = %s, want 0.3", got)
+	}
+	if got := a.Sub(b).String(); got != "-0.1" {
+		t.Errorf("0.1 - 0.2 = %s, want -0.1", got)
+	}
+	price, _ := ParseDecimal("19.99")
+	if got := price.Mul(NewDecimalFromInt(3)).String(); got != "59.97" {
+		t.Errorf("19.99 * 3 = %s, want 59.97", got)
+	}
+	third, err := NewDecimalFromInt(2).Div(NewDecimalFromInt(3), 4)
+	if err != nil || third.String() != "0.6667" {
+		t.Errorf("2 / 3 = %v, %v, want 0.6667", third, err)
+	}
+	if _, err := a.Div(DecimalValue{}, 2); err == nil {
+		t.Errorf("division by zero expected an error")
+	}
+	neg, _ := ParseDecimal("-2.345")
+	if got := neg.Round(2).String(); got != "-2.35" {
+		t.Errorf("Round(-2.345, 2) = %s, want -2.35", got)
+	}
+	if a.Cmp(b) != -1 || b.Cmp(a) != 1 || a.Cmp(a.Add(DecimalValue{})) != 0 {
+		t.Errorf("Cmp() returned unexpected results")
+	}
+}
+
+func TestExtendedValues_JSON(t *testing.T) {
+	dec, _ := ParseDecimal("1234.5600")
+	values := map[string]interface{}{
+		"decimal":  dec,
+		"date":     DateValue{Year: 2024, Month: time.May, Day: 1},
+		"time":     TimeValue{Hour: 8, Minute: 30, Nanosecond: 500000000},
+		"duration": DurationValue(90*time.Minute + 1500*time.Millisecond),
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `{"date":"2024-05-01","decimal":"1234.5600","duration":"PT1H30M1.5S","time":"08:30:00.5"}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+
+	var decoded struct {
+		Decimal  DecimalValue  `json:"decimal"`
+		Date     DateValue     `json:"date"`
+		Time     TimeValue     `json:"time"`
+		Duration DurationValue `json:"duration"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if decoded.Decimal.String() != "1234.5600" || decoded.Date != values["date"] || decoded.Time != values["time"] || decoded.Duration != values["duration"] {
+		t.Errorf("json.Unmarshal() = %+v", decoded)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"PT1H30M", 90 * time.Minute},
+		{"P1DT2H", 26 * time.Hour},
+		{"PT0.5S", 500 * time.Millisecond},
+		{"-PT10S", -10 * time.Second},
+		{"1500", 1500 * time.Millisecond},
+		{"2h15m", 135 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if err != nil || time.Duration(got) != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.in, time.Duration(got), err, tt.want)
+		}
+	}
+	for _, in := range []string{"P", "PT", "1 hour"} {
+		if _, err := ParseDuration(in); err == nil {
+			t.Errorf("ParseDuration(%q) expected an error", in)
+		}
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		datatype DataType
+		want     interface{}
+	}{
+		{"decimal from float", 0.1, Decimal, "0.1"},
+		{"decimal from db text", []byte("10.25"), Decimal, "10.25"},
+		{"date from datetime", "2024-05-01 23:59:59", Date, "2024-05-01"},
+		{"date from time", time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), Date, "2024-02-29"},
+		{"time from text", "07:05", Time, "07:05:00"},
+		{"duration from milliseconds", float64(2500), Duration, "PT2.5S"},
+		{"binary from base64", "aGVsbG8=", Binary, []byte("hello")},
+		{"binary from hex", "0x68656c6c6f", Binary, []byte("hello")},
+		{"other data types unchanged", "abc", String, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertValue(tt.value, tt.datatype)
+			if err != nil {
+				t.Fatalf("ConvertValue() error = %v", err)
+			}
+			if s, ok := tt.want.(string); ok && tt.datatype.IsExtended() {
+				if str, ok := got.(interface{ String() string }); !ok || str.String() != s {
+					t.Errorf("ConvertValue() = %v, want %s", got, s)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ConvertValue(true, Decimal); err == nil {
+		t.Errorf("ConvertValue(true, Decimal) expected an error")
+	}
+}
+
+func TestScriptValues(t *testing.T) {
+	dec, _ := ParseDecimal("9.90")
+	got := ScriptValues(map[string]interface{}{
+		"price": dec,
+		"wait":  DurationValue(time.Second),
+		"data":  []byte("hi"),
+		"dates": []interface{}{DateValue{Year: 2024, Month: time.January, Day: 2}},
+		"qty":   3,
+	})
+	want := map[string]interface{}{
+		"price": "9.90",
+		"wait":  int64(1000),
+		"data":  "aGk=",
+		"dates": []interface{}{"2024-01-02"},
+		"qty":   3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScriptValues() = %v, want %v", got, want)
+	}
+}
+
+func TestSQLValue(t *testing.T) {
+	tests := []struct {
+		text     string
+		datatype DataType
+		want     interface{}
+	}{
+		{"123.4500", Decimal, "123.4500"},
+		{"2024-05-01", Date, "2024-05-01"},
+		{"PT1M", Duration, int64(60000)},
+		{"aGk=", Binary, []byte("hi")},
+	}
+	for _, tt := range tests {
+		got, err := SQLValue(tt.text, tt.datatype)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SQLValue(%q, %d) = %v, %v, want %v", tt.text, tt.datatype, got, err, tt.want)
+		}
+	}
+}
+
+func TestExprValues(t *testing.T) {
+	dec, _ := ParseDecimal("9.90")
+	got := ExprValues(map[string]interface{}{
+		"price": dec,
+		"due":   DateValue{Year: 2024, Month: time.January, Day: 2},
+		"shift": TimeValue{Hour: 6},
+		"wait":  DurationValue(time.Second),
+		"items": []interface{}{dec},
+		"qty":   3,
+	})
+	want := map[string]interface{}{
+		"price": 9.9,
+		"due":   time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC),
+		"shift": "06:00:00",
+		"wait":  time.Second,
+		"items": []interface{}{9.9},
+		"qty":   3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExprValues() = %v, want %v", got, want)
+	}
+}
@@ -70,6 +70,8 @@ const (
 	ErrorCategoryNetwork       ErrorCategory = "NETWORK"
 	ErrorCategorySystem        ErrorCategory = "SYSTEM"
 	ErrorCategoryBusiness      ErrorCategory = "BUSINESS"
+	ErrorCategoryRouting       ErrorCategory = "ROUTING"
+	ErrorCategoryDevice        ErrorCategory = "DEVICE"
 )
 
 // ExecutionContext contains information about where an error occurred
@@ -158,6 +160,33 @@ func (e *BPMError) WithDetail(key, value string) *BPMError {
 	return e
 }
 
+// ToMap returns the error details as a map so they can be stored in a session and read by functions and routers
+func (e *BPMError) ToMap() map[string]interface{} {
+	details := map[string]interface{}{}
+	for k, v := range e.Details {
+		details[k] = v
+	}
+
+	result := map[string]interface{}{
+		"category":        string(e.Category),
+		"severity":        e.Severity.String(),
+		"message":         e.Message,
+		"error":           e.Error(),
+		"rollback_reason": e.RollbackReason,
+		"timestamp":       e.Timestamp,
+		"details":         details,
+	}
+	if e.Context != nil {
+		result["functiongroup"] = e.Context.FunctionGroup
+		result["function"] = e.Context.FunctionName
+		result["functiontype"] = e.Context.FunctionType
+	}
+	if e.OriginalError != nil {
+		result["original_error"] = e.OriginalError.Error()
+	}
+	return result
+}
+
 // GetFormattedError returns a formatted error message with full context
 func (e *BPMError) GetFormattedError() string {
 	var sb strings.Builder
@@ -236,6 +265,20 @@ func NewDatabaseError(operation, message string, originalErr error) *BPMError {
 	return err
 }
 
+// NewDeviceError creates an error of the communication with equipment, like an OPC UA server
+func NewDeviceError(operation, message string, originalErr error) *BPMError {
+	err := NewBPMError(ErrorCategoryDevice, ErrorSeverityError, message, originalErr)
+	err.WithDetail("operation", operation)
+	return err
+}
+
+// NewNetworkError creates an error of the communication with another service, like a failed HTTP request
+func NewNetworkError(operation, message string, originalErr error) *BPMError {
+	err := NewBPMError(ErrorCategoryNetwork, ErrorSeverityError, message, originalErr)
+	err.WithDetail("operation", operation)
+	return err
+}
+
 // NewExecutionError creates an execution error
 func NewExecutionError(message string, originalErr error) *BPMError {
 	return NewBPMError(ErrorCategoryExecution, ErrorSeverityError, message, originalErr)
@@ -264,3 +307,10 @@ func NewScriptError(scriptType, message string, originalErr error) *BPMError {
 func NewBusinessError(message string) *BPMError {
 	return NewBPMError(ErrorCategoryBusiness, ErrorSeverityWarning, message, nil)
 }
+
+// NewRoutingError creates a function group routing error
+func NewRoutingError(message string, path []string) *BPMError {
+	err := NewBPMError(ErrorCategoryRouting, ErrorSeverityError, message, nil)
+	err.WithDetail("path", strings.Join(path, " -> "))
+	return err
+}
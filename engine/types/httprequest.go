@@ -0,0 +1,211 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authentication types of HTTP connection profiles
+const (
+	HTTPAuthNone   = "none"
+	HTTPAuthAPIKey = "apikey"
+	HTTPAuthBasic  = "basic"
+	HTTPAuthOAuth2 = "oauth2" // OAuth2 client credentials
+)
+
+// Body formats of HTTP requests
+const (
+	HTTPBodyJSON = "json"
+	HTTPBodyXML  = "xml"
+	HTTPBodyForm = "form"
+	HTTPBodyText = "text"
+)
+
+// HTTPIdempotencyAuto derives the idempotency key from the method, the URL and the body of the request
+const HTTPIdempotencyAuto = "auto"
+
+// DefaultHTTPRetryStatusCodes are the status codes retried if the retry settings do not list any
+var DefaultHTTPRetryStatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// HTTPProfile is a named connection to an HTTP service. The HTTP functions refer to it by name,
+// so the address, the credentials and the certificates are configured once per installation
+// and are not part of the transaction codes.
+type HTTPProfile struct {
+	Name           string             `json:"name"`
+	BaseURL        string             `json:"baseurl"`
+	Headers        map[string]string  `json:"headers"` // sent with every request of the profile
+	Timeout        int                `json:"timeout"` // seconds
+	Auth           HTTPAuth           `json:"auth"`
+	TLS            HTTPTLS            `json:"tls"`
+	Retry          HTTPRetry          `json:"retry"`
+	CircuitBreaker HTTPCircuitBreaker `json:"circuitbreaker"`
+}
+
+// HTTPAuth is the authentication of a profile
+type HTTPAuth struct {
+	Type         string   `json:"type"`
+	APIKey       string   `json:"apikey"`
+	APIKeyHeader string   `json:"apikeyheader"` // header of the api key, X-API-Key by default
+	APIKeyQuery  string   `json:"apikeyquery"`  // query parameter of the api key, instead of the header
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	TokenURL     string   `json:"tokenurl"`
+	ClientID     string   `json:"clientid"`
+	ClientSecret string   `json:"clientsecret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// HTTPTLS are the certificates of a profile. With a certificate and a key the client authenticates with mTLS.
+type HTTPTLS struct {
+	CertFile           string `json:"certfile"`
+	KeyFile            string `json:"keyfile"`
+	CAFile             string `json:"cafile"`
+	InsecureSkipVerify bool   `json:"insecureskipverify"`
+}
+
+// HTTPRetry are the retries of failed requests. Network errors and the listed status codes are retried,
+// the waits double from Backoff up to MaxBackoff milliseconds or follow the Retry-After header.
+type HTTPRetry struct {
+	Attempts    int   `json:"attempts"` // attempts including the first one, 1 without retries
+	Backoff     int   `json:"backoff"`
+	MaxBackoff  int   `json:"maxbackoff"`
+	StatusCodes []int `json:"statuscodes"`
+}
+
+// HTTPCircuitBreaker stops the requests to a profile after the given number of failed requests in a row
+// for OpenSeconds, after which one request is let through to test the service
+type HTTPCircuitBreaker struct {
+	Failures    int `json:"failures"` // 0 turns the circuit breaker off
+	OpenSeconds int `json:"openseconds"`
+}
+
+// HTTPRequestDefinition is the request of an HTTP function, kept in the content of the function.
+// The path, the headers, the query parameters, the body and the idempotency key are templates
+// of the function inputs, like {{.OrderNo}}. Response maps outputs to JSONPath ($.order.id)
+// or XPath (/order/id) expressions of the response body.
+type HTTPRequestDefinition struct {
+	Profile           string            `json:"profile"`
+	Method            string            `json:"method"`
+	Path              string            `json:"path"` // relative to the base URL of the profile, or an absolute URL
+	Headers           map[string]string `json:"headers"`
+	Query             map[string]string `json:"query"`
+	BodyFormat        string            `json:"bodyformat"`
+	Body              string            `json:"body"`
+	Timeout           int               `json:"timeout"` // seconds, overrides the timeout of the profile
+	Retry             *HTTPRetry        `json:"retry"`   // overrides the retries of the profile
+	IdempotencyKey    string            `json:"idempotencykey"`
+	IdempotencyHeader string            `json:"idempotencyheader"` // Idempotency-Key by default
+	Response          map[string]string `json:"response"`
+}
+
+// ParseHTTPRequestDefinition parses the HTTP request of a function content
+func ParseHTTPRequestDefinition(content string) (*HTTPRequestDefinition, error) {
+	definition := &HTTPRequestDefinition{}
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("the function has no HTTP request definition")
+	}
+	if err := json.Unmarshal([]byte(content), definition); err != nil {
+		return nil, fmt.Errorf("invalid HTTP request definition: %v", err)
+	}
+	if definition.Method == "" {
+		definition.Method = http.MethodGet
+	}
+	definition.Method = strings.ToUpper(definition.Method)
+	if definition.BodyFormat == "" {
+		definition.BodyFormat = HTTPBodyJSON
+	}
+	if definition.IdempotencyHeader == "" {
+		definition.IdempotencyHeader = "Idempotency-Key"
+	}
+	return definition, definition.Validate()
+}
+
+// Validate checks the request definition
+func (d *HTTPRequestDefinition) Validate() error {
+	if d.Profile == "" && !strings.HasPrefix(d.Path, "http://") && !strings.HasPrefix(d.Path, "https://") {
+		return fmt.Errorf("the HTTP request needs a profile or an absolute URL")
+	}
+	switch d.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return fmt.Errorf("unsupported HTTP method %s", d.Method)
+	}
+	switch d.BodyFormat {
+	case HTTPBodyJSON, HTTPBodyXML, HTTPBodyForm, HTTPBodyText:
+	default:
+		return fmt.Errorf("unsupported body format %s", d.BodyFormat)
+	}
+	for output, path := range d.Response {
+		if !strings.HasPrefix(path, "$") && !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("output %s has the response path %s, it must be a JSONPath starting with $ or an XPath starting with /", output, path)
+		}
+	}
+	if d.Retry != nil {
+		return d.Retry.Validate()
+	}
+	return nil
+}
+
+// Validate checks the profile
+func (p *HTTPProfile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("the HTTP profile has no name")
+	}
+	switch p.Auth.Type {
+	case "", HTTPAuthNone, HTTPAuthBasic:
+	case HTTPAuthAPIKey:
+		if p.Auth.APIKey == "" {
+			return fmt.Errorf("HTTP profile %s has no api key", p.Name)
+		}
+	case HTTPAuthOAuth2:
+		if p.Auth.TokenURL == "" || p.Auth.ClientID == "" {
+			return fmt.Errorf("HTTP profile %s needs the token URL and the client id for OAuth2", p.Name)
+		}
+	default:
+		return fmt.Errorf("HTTP profile %s has the unsupported authentication %s", p.Name, p.Auth.Type)
+	}
+	if (p.TLS.CertFile == "") != (p.TLS.KeyFile == "") {
+		return fmt.Errorf("HTTP profile %s needs both the certificate and the key file for mTLS", p.Name)
+	}
+	return p.Retry.Validate()
+}
+
+// Validate checks the retry settings
+func (r *HTTPRetry) Validate() error {
+	if r.Attempts < 0 || r.Backoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("the retry settings cannot be negative")
+	}
+	return nil
+}
+
+// Retries reports whether a response with the status code is retried
+func (r *HTTPRetry) Retries(statusCode int) bool {
+	codes := r.StatusCodes
+	if len(codes) == 0 {
+		codes = DefaultHTTPRetryStatusCodes
+	}
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait returns the wait before the retry after the given failed attempt, counted from 1
+func (r *HTTPRetry) Wait(attempt int) time.Duration {
+	wait := time.Duration(r.Backoff) * time.Millisecond
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if r.MaxBackoff > 0 && wait >= time.Duration(r.MaxBackoff)*time.Millisecond {
+			break
+		}
+	}
+	if r.MaxBackoff > 0 && wait > time.Duration(r.MaxBackoff)*time.Millisecond {
+		wait = time.Duration(r.MaxBackoff) * time.Millisecond
+	}
+	return wait
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHTTPRequestDefinition(t *testing.T) {
+	definition, err := ParseHTTPRequestDefinition(`{"profile":"erp","path":"/orders","response":{"ID":"$.id"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if definition.Method != "GET" || definition.BodyFormat != HTTPBodyJSON || definition.IdempotencyHeader != "Idempotency-Key" {
+		t.Errorf("ParseHTTPRequestDefinition() defaults = %+v", definition)
+	}
+
+	invalid := []string{
+		``,
+		`{"path":"/orders"}`,
+		`{"profile":"erp","method":"FETCH"}`,
+		`{"profile":"erp","response":{"ID":"id"}}`,
+		`{"profile":"erp","retry":{"attempts":-1}}`,
+	}
+	for _, content := range invalid {
+		if _, err := ParseHTTPRequestDefinition(content); err == nil {
+			t.Errorf("ParseHTTPRequestDefinition(%s) expected an error", content)
+		}
+	}
+}
+
+func TestHTTPRetry(t *testing.T) {
+	retry := HTTPRetry{Attempts: 5, Backoff: 100, MaxBackoff: 300}
+	if !retry.Retries(503) || retry.Retries(500) {
+		t.Errorf("Retries() must use the default status codes")
+	}
+	waits := []time.Duration{retry.Wait(1), retry.Wait(2), retry.Wait(3)}
+	if waits[0] != 100*time.Millisecond || waits[1] != 200*time.Millisecond || waits[2] != 300*time.Millisecond {
+		t.Errorf("Wait() = %v, want doubling waits up to the maximum", waits)
+	}
+}
+
+func TestHTTPProfile_Validate(t *testing.T) {
+	profiles := []HTTPProfile{
+		{},
+		{Name: "erp", Auth: HTTPAuth{Type: HTTPAuthAPIKey}},
+		{Name: "erp", Auth: HTTPAuth{Type: HTTPAuthOAuth2, ClientID: "iac"}},
+		{Name: "erp", TLS: HTTPTLS{CertFile: "client.pem"}},
+	}
+	for _, profile := range profiles {
+		if profile.Validate() == nil {
+			t.Errorf("Validate(%+v) expected an error", profile)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Delivery modes of a message function
+const (
+	MessageDeliveryDirect = "direct" // published while the transaction code runs
+	MessageDeliveryOutbox = "outbox" // written to the outbox in the transaction, published after the commit
+)
+
+// Channels a message is published on
+const (
+	MessageChannelBus   = "messagebus"
+	MessageChannelKafka = "kafka"
+	MessageChannelMQTT  = "mqtt"
+	MessageChannelAMQP  = "amqp"
+)
+
+// DefaultOutboxMaxAttempts is the number of times the relay tries to publish a message before it is dead lettered
+const DefaultOutboxMaxAttempts = 10
+
+// MessageDelivery is the content of a message function, the function publishes directly without content
+type MessageDelivery struct {
+	Mode        string `json:"delivery"`
+	Key         string `json:"key"`         // input whose value orders the messages of the topic
+	MaxAttempts int    `json:"maxattempts"` // attempts of the relay before the message is dead lettered
+}
+
+// messageFunctionTypes are the function types that send messages, in the outbox mode to the outbox
+var messageFunctionTypes = []FunctionType{SendMessage, SendMessagebyKafka, SendMessagebyMQTT, SendMessagebyAQMP}
+
+// WritesToOutbox reports whether the function is a message function in the outbox mode, which writes its message
+// to the database transaction of the transaction code
+func (f *Function) WritesToOutbox() bool {
+	typename := f.TypeName()
+	for _, functype := range messageFunctionTypes {
+		if typename != functype.String() {
+			continue
+		}
+		delivery, err := ParseMessageDelivery(f.Content)
+		return err == nil && delivery.Mode == MessageDeliveryOutbox
+	}
+	return false
+}
+
+// ParseMessageDelivery parses the content of a message function and applies the defaults
+func ParseMessageDelivery(content string) (*MessageDelivery, error) {
+	delivery := &MessageDelivery{}
+	if strings.TrimSpace(content) != "" {
+		if err := json.Unmarshal([]byte(content), delivery); err != nil {
+			return nil, fmt.Errorf("the message delivery is not valid JSON: %v", err)
+		}
+	}
+
+	delivery.Mode = strings.ToLower(delivery.Mode)
+	switch delivery.Mode {
+	case "":
+		delivery.Mode = MessageDeliveryDirect
+	case MessageDeliveryDirect, MessageDeliveryOutbox:
+	default:
+		return nil, fmt.Errorf("the message delivery mode %s is not supported", delivery.Mode)
+	}
+	if delivery.MaxAttempts < 0 {
+		return nil, fmt.Errorf("the attempts of a message delivery cannot be negative")
+	}
+	if delivery.MaxAttempts == 0 {
+		delivery.MaxAttempts = DefaultOutboxMaxAttempts
+	}
+	return delivery, nil
+}
+
+// OutboundMessage is a message of a transaction code on its way to a channel
+type OutboundMessage struct {
+	MessageID     string `json:"messageid"`
+	Channel       string `json:"channel"`
+	Topic         string `json:"topic"`
+	Server        string `json:"server"`
+	Key           string `json:"key"`
+	Payload       string `json:"payload"`
+	MaxAttempts   int    `json:"maxattempts"`
+	ExecutionID   string `json:"executionid"`
+	CorrelationID string `json:"correlationid"`
+	TranCodeName  string `json:"trancodename"`
+	CreatedBy     string `json:"createdby"`
+}
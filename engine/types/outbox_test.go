@@ -0,0 +1,36 @@
+package types
+
+import "testing"
+
+func TestParseMessageDelivery(t *testing.T) {
+	delivery, err := ParseMessageDelivery("")
+	if err != nil || delivery.Mode != MessageDeliveryDirect {
+		t.Errorf("ParseMessageDelivery() = %+v, %v, want the direct mode", delivery, err)
+	}
+
+	delivery, err = ParseMessageDelivery(`{"delivery":"Outbox","key":"OrderNo"}`)
+	if err != nil || delivery.Mode != MessageDeliveryOutbox || delivery.Key != "OrderNo" || delivery.MaxAttempts != DefaultOutboxMaxAttempts {
+		t.Errorf("ParseMessageDelivery() = %+v, %v", delivery, err)
+	}
+
+	for _, content := range []string{`{"delivery":"later"}`, `{"delivery":"outbox","maxattempts":-1}`, `outbox`} {
+		if _, err := ParseMessageDelivery(content); err == nil {
+			t.Errorf("ParseMessageDelivery(%s) expected an error", content)
+		}
+	}
+}
+
+func TestFunction_WritesToOutbox(t *testing.T) {
+	if !(&Function{Functype: SendMessagebyKafka, Content: `{"delivery":"outbox"}`}).WritesToOutbox() {
+		t.Error("WritesToOutbox() = false for a message function in the outbox mode")
+	}
+	for _, fobj := range []Function{
+		{Functype: SendMessagebyKafka},
+		{Functype: SendMessagebyKafka, Content: `{"delivery":"direct"}`},
+		{Functype: Query, Content: `{"delivery":"outbox"}`},
+	} {
+		if fobj.WritesToOutbox() {
+			t.Errorf("WritesToOutbox() = true for %s with the content %q", fobj.Functype, fobj.Content)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"strings"
+)
+
+const (
+	ExecutionSequential = "sequential"
+	ExecutionParallel   = "parallel"
+)
+
+// IsParallel reports whether the functions of the group run concurrently in dependency order
+func (fg *FuncGroup) IsParallel() bool {
+	return strings.EqualFold(fg.Executionsequence, ExecutionParallel)
+}
+
+// FunctionDependencies infers for every function of the group the functions of the same group it depends on.
+// A function depends on the functions named in its Prefunction inputs, and on the functions declared before it
+// that write a user session variable it reads, so it sees the same values as in sequential execution.
+// A function for which sharesTransaction reports true depends on the previous such function, the functions working
+// on the database transaction run one after the other in declaration order, as in sequential execution.
+func (fg *FuncGroup) FunctionDependencies(sharesTransaction func(fobj *Function) bool) map[string][]string {
+	declared := map[string]bool{}
+	for _, fobj := range fg.Functions {
+		declared[fobj.Name] = true
+	}
+
+	dependencies := map[string][]string{}
+	sessionWriters := map[string][]string{}
+	lastTransactional := ""
+	for i := range fg.Functions {
+		fobj := fg.Functions[i]
+		deps := []string{}
+		seen := map[string]bool{}
+		add := func(name string) {
+			if name == fobj.Name || !declared[name] || seen[name] {
+				return
+			}
+			seen[name] = true
+			deps = append(deps, name)
+		}
+
+		for _, input := range fobj.Inputs {
+			switch input.Source {
+			case Prefunction:
+				arr := strings.Split(input.Aliasname, ".")
+				if len(arr) == 2 {
+					add(arr[0])
+				}
+			case Fromusersession:
+				for _, writer := range sessionWriters[input.Aliasname] {
+					add(writer)
+				}
+			}
+		}
+		if sharesTransaction != nil && sharesTransaction(&fg.Functions[i]) {
+			add(lastTransactional)
+			lastTransactional = fobj.Name
+		}
+		dependencies[fobj.Name] = deps
+
+		for _, output := range fobj.Outputs {
+			for j, dest := range output.Outputdest {
+				if dest == Tosession && j < len(output.Aliasname) {
+					sessionWriters[output.Aliasname[j]] = append(sessionWriters[output.Aliasname[j]], fobj.Name)
+				}
+			}
+		}
+	}
+	return dependencies
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuncGroup_FunctionDependencies(t *testing.T) {
+	fg := FuncGroup{
+		Name:              "Load",
+		Executionsequence: "Parallel",
+		Functions: []Function{
+			{Name: "GetOrder", Functype: Query, Outputs: []Output{{Name: "status", Outputdest: []OutputDest{Tosession}, Aliasname: []string{"orderstatus"}}}},
+			{Name: "GetRate", Functype: WebServiceCall, Inputs: []Input{{Name: "currency", Source: Fromexternal, Aliasname: "currency"}}},
+			{Name: "Price", Functype: GoExpr, Inputs: []Input{
+				{Name: "qty", Source: Prefunction, Aliasname: "GetOrder.qty"},
+				{Name: "rate", Source: Prefunction, Aliasname: "GetRate.rate"},
+				{Name: "old", Source: Prefunction, Aliasname: "Previous.value"},
+			}},
+			{Name: "Check", Functype: Javascript, Inputs: []Input{{Name: "status", Source: Fromusersession, Aliasname: "orderstatus"}}},
+		},
+	}
+
+	if !fg.IsParallel() {
+		t.Fatal("IsParallel() = false, want true")
+	}
+
+	want := map[string][]string{
+		"GetOrder": {},
+		"GetRate":  {},
+		"Price":    {"GetOrder", "GetRate"},
+		"Check":    {"GetOrder"},
+	}
+	if got := fg.FunctionDependencies(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("FunctionDependencies() = %v, want %v", got, want)
+	}
+}
+
+func TestFuncGroup_FunctionDependenciesOfTransactionalFunctions(t *testing.T) {
+	fg := FuncGroup{
+		Name:              "Ship",
+		Executionsequence: "Parallel",
+		Functions: []Function{
+			{Name: "Reserve", Functype: TableUpdate},
+			{Name: "GetRate", Functype: WebServiceCall},
+			{Name: "Notify", Functype: SendMessagebyMQTT, Content: `{"delivery":"outbox"}`},
+			{Name: "Log", Functype: TableInsert, Inputs: []Input{{Name: "rate", Source: Prefunction, Aliasname: "GetRate.rate"}}},
+		},
+	}
+
+	want := map[string][]string{
+		"Reserve": {},
+		"GetRate": {},
+		"Notify":  {"Reserve"},
+		"Log":     {"GetRate", "Notify"},
+	}
+	sharesTransaction := func(fobj *Function) bool {
+		return fobj.Functype.SharesTransaction() || fobj.WritesToOutbox()
+	}
+	if got := fg.FunctionDependencies(sharesTransaction); !reflect.DeepEqual(got, want) {
+		t.Errorf("FunctionDependencies() = %v, want %v", got, want)
+	}
+}
+
+func TestFunctionType_SharesTransaction(t *testing.T) {
+	if !Query.SharesTransaction() || !TableInsert.SharesTransaction() || !SubTranCode.SharesTransaction() || !Javascript.SharesTransaction() {
+		t.Error("SharesTransaction() = false for a function type with database access")
+	}
+	if WebServiceCall.SharesTransaction() || SendEmail.SharesTransaction() {
+		t.Error("SharesTransaction() = true for a function type without database access")
+	}
+}
+
+func TestFunction_TypeName(t *testing.T) {
+	if got := (&Function{Functype: Query}).TypeName(); got != "Query" {
+		t.Errorf("TypeName() = %s, want Query", got)
+	}
+	if got := (&Function{Functype: Query, Typename: "Plant.WeighScale"}).TypeName(); got != "Plant.WeighScale" {
+		t.Errorf("TypeName() = %s, want Plant.WeighScale", got)
+	}
+}
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reasons a recording was kept
+const (
+	RecordingSampled = "sampled"
+	RecordingFailure = "failure"
+)
+
+// TranCodeRecordingPolicy turns on the recording of the executions of a transaction code.
+// The given percentage of the executions is recorded, with OnFailure every failed execution is recorded too.
+type TranCodeRecordingPolicy struct {
+	TranCodeName string    `json:"trancodename"`
+	Enabled      bool      `json:"enabled"`
+	Percentage   float64   `json:"percentage"`
+	OnFailure    bool      `json:"onfailure"`
+	ModifiedBy   string    `json:"modifiedby"`
+	ModifiedOn   time.Time `json:"modifiedon"`
+}
+
+// Records reports whether an execution has to be recorded, because it may be kept. sample is a random number in [0, 100).
+func (p *TranCodeRecordingPolicy) Records(sample float64) bool {
+	return p.Sampled(sample) || (p != nil && p.Enabled && p.OnFailure)
+}
+
+// Sampled reports whether the execution is kept whatever its outcome. sample is a random number in [0, 100).
+func (p *TranCodeRecordingPolicy) Sampled(sample float64) bool {
+	return p != nil && p.Enabled && sample < p.Percentage
+}
+
+// Validate checks the settings of the policy
+func (p *TranCodeRecordingPolicy) Validate() error {
+	if p.TranCodeName == "" {
+		return fmt.Errorf("the recording policy has no transaction code name")
+	}
+	if p.Percentage < 0 || p.Percentage > 100 {
+		return fmt.Errorf("the recording percentage %v of transaction code %s must be between 0 and 100", p.Percentage, p.TranCodeName)
+	}
+	return nil
+}
+
+// RecordedCall is the result of a call of a function whose result cannot be reproduced by running it again,
+// like a query, a web service call or an insert returning a generated id. Sequence counts the calls of the function from 1.
+type RecordedCall struct {
+	Function string                 `json:"function"`
+	Type     string                 `json:"type"`
+	Sequence int                    `json:"sequence"`
+	Outputs  map[string]interface{} `json:"outputs"`
+	Error    string                 `json:"error"`
+	Category ErrorCategory          `json:"category"` // category of the error
+}
+
+// RecordedTime is the current time a call of a function got in its system session
+type RecordedTime struct {
+	Function string    `json:"function"`
+	Sequence int       `json:"sequence"`
+	Time     time.Time `json:"time"`
+}
+
+// ExecutionRecording is an execution of a transaction code with everything needed to replay it:
+// the external inputs, the system session and the results of the calls that are not deterministic
+type ExecutionRecording struct {
+	ID            string                 `json:"id"`
+	TranCodeName  string                 `json:"trancodename"`
+	Version       string                 `json:"version"`
+	ExecutionID   string                 `json:"executionid"`
+	CorrelationID string                 `json:"correlationid"`
+	Reason        string                 `json:"reason"`
+	RecordedOn    time.Time              `json:"recordedon"`
+	Duration      int64                  `json:"duration"` // milliseconds
+	Inputs        map[string]interface{} `json:"inputs"`
+	SystemSession map[string]interface{} `json:"systemsession"`
+	Outputs       map[string]interface{} `json:"outputs"`
+	Error         string                 `json:"error"`
+	Calls         []RecordedCall         `json:"calls"`
+	Clock         []RecordedTime         `json:"clock"`
+}
+
+// ToTestData turns the recording into test data of the transaction code: the recorded inputs, the recorded
+// outputs or error as the expected ones and the recorded calls as mocks of their functions
+func (r *ExecutionRecording) ToTestData(name string) TestData {
+	if name == "" {
+		name = fmt.Sprintf("Recording %s", r.ID)
+	}
+	testdata := TestData{Name: name, Inputs: []Input{}, Outputs: []Output{}, Mocks: []FunctionMock{}}
+
+	for _, key := range sortedRecordingKeys(r.Inputs) {
+		testdata.Inputs = append(testdata.Inputs, Input{Name: key, Value: recordingValue(r.Inputs[key])})
+	}
+	if r.Error != "" {
+		testdata.WantErr = true
+		testdata.WantedErr = r.Error
+		testdata.ErrorMatch = ErrorMatchExact
+	} else {
+		for _, key := range sortedRecordingKeys(r.Outputs) {
+			testdata.Outputs = append(testdata.Outputs, Output{Name: key, Value: recordingValue(r.Outputs[key])})
+		}
+	}
+	for _, call := range r.Calls {
+		testdata.Mocks = append(testdata.Mocks, FunctionMock{
+			Function: call.Function,
+			Type:     call.Type,
+			Sequence: call.Sequence,
+			Outputs:  call.Outputs,
+			Error:    call.Error,
+		})
+	}
+	return testdata
+}
+
+func sortedRecordingKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// recordingValue returns the value as it is written in test data, strings unchanged and other values as JSON
+func recordingValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	if value == nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// ReplayResult is the outcome of the replay of a recording compared with the recorded outcome
+type ReplayResult struct {
+	RecordingID        string                 `json:"recordingid"`
+	SessionID          string                 `json:"sessionid"` // debug session the events of the replay were sent to
+	Outputs            map[string]interface{} `json:"outputs"`
+	Error              string                 `json:"error"`
+	Reproduced         bool                   `json:"reproduced"` // the replay ended with the recorded outputs or error
+	Mismatches         []ContractViolation    `json:"mismatches"`
+	MissingCalls       []string               `json:"missingcalls"` // calls without a recorded result, the replay diverged at them
+	ExecutedFuncGroups []string               `json:"executedfuncgroups"`
+	Duration           int64                  `json:"duration"` // milliseconds
+}
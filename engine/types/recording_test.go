@@ -0,0 +1,48 @@
+package types
+
+import "testing"
+
+func TestTranCodeRecordingPolicy_Records(t *testing.T) {
+	var none *TranCodeRecordingPolicy
+	if none.Records(0) {
+		t.Errorf("a missing policy must not record")
+	}
+
+	policy := &TranCodeRecordingPolicy{TranCodeName: "Order", Enabled: true, Percentage: 10}
+	if !policy.Sampled(5) || policy.Sampled(50) || policy.Records(50) {
+		t.Errorf("sampling of %v percent is wrong", policy.Percentage)
+	}
+	policy.OnFailure = true
+	if !policy.Records(50) || policy.Sampled(50) {
+		t.Errorf("OnFailure must record every execution without sampling it")
+	}
+
+	policy.Percentage = 120
+	if policy.Validate() == nil {
+		t.Errorf("Validate() accepted a percentage of 120")
+	}
+}
+
+func TestExecutionRecording_ToTestData(t *testing.T) {
+	recording := &ExecutionRecording{
+		ID:      "r1",
+		Inputs:  map[string]interface{}{"Qty": 2.0, "Item": "A"},
+		Outputs: map[string]interface{}{"Lines": []interface{}{"A"}},
+		Calls:   []RecordedCall{{Function: "GetPrice", Type: "Query", Sequence: 1, Outputs: map[string]interface{}{"Price": 3.0}}},
+	}
+	testdata := recording.ToTestData("")
+	if testdata.Name != "Recording r1" || len(testdata.Inputs) != 2 || testdata.Inputs[0].Name != "Item" || testdata.Inputs[1].Value != "2" {
+		t.Errorf("ToTestData() inputs = %v", testdata.Inputs)
+	}
+	if len(testdata.Outputs) != 1 || testdata.Outputs[0].Value != `["A"]` {
+		t.Errorf("ToTestData() outputs = %v", testdata.Outputs)
+	}
+	if len(testdata.Mocks) != 1 || testdata.Mocks[0].Sequence != 1 || testdata.Mocks[0].Function != "GetPrice" {
+		t.Errorf("ToTestData() mocks = %v", testdata.Mocks)
+	}
+
+	recording.Error = "no stock"
+	if testdata = recording.ToTestData("stock"); !testdata.WantErr || testdata.WantedErr != "no stock" || len(testdata.Outputs) != 0 {
+		t.Errorf("ToTestData() of a failed execution = %v", testdata)
+	}
+}
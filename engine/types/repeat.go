@@ -0,0 +1,149 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Repeat modes, how the repeat inputs of a function are combined into iterations
+const (
+	RepeatZip       = "zip"       // iteration i uses element i of every repeat list, the lists must have the same length
+	RepeatProduct   = "product"   // one iteration for every combination of the elements of the repeat lists
+	RepeatBroadcast = "broadcast" // like zip, but single values and lists with one element are used in every iteration
+)
+
+// Repeat error policies, what happens when an iteration fails
+const (
+	RepeatFailFast = "failfast" // the function fails with the error of the iteration
+	RepeatContinue = "continue" // the remaining iterations run, the errors are returned by iteration
+	RepeatSkip     = "skip"     // the failed iteration has no outputs, the remaining iterations run
+)
+
+// RepeatInput is a repeat input of a function with the number of elements of its value, -1 for a single value
+type RepeatInput struct {
+	Name   string
+	Length int
+}
+
+// RepeatMode returns the repeat mode of the function, zip if none is set
+func (f *Function) RepeatMode() string {
+	if f.Repeatmode == "" {
+		return RepeatZip
+	}
+	return strings.ToLower(f.Repeatmode)
+}
+
+// RepeatErrorPolicy returns the repeat error policy of the function, failfast if none is set
+func (f *Function) RepeatErrorPolicy() string {
+	if f.Repeaterrorpolicy == "" {
+		return RepeatFailFast
+	}
+	return strings.ToLower(f.Repeaterrorpolicy)
+}
+
+// ValidateRepeat checks the repeat settings of the function
+func (f *Function) ValidateRepeat() error {
+	switch f.RepeatMode() {
+	case RepeatZip, RepeatProduct, RepeatBroadcast:
+	default:
+		return fmt.Errorf("has unknown repeat mode %s", f.Repeatmode)
+	}
+	switch f.RepeatErrorPolicy() {
+	case RepeatFailFast, RepeatContinue, RepeatSkip:
+	default:
+		return fmt.Errorf("has unknown repeat error policy %s", f.Repeaterrorpolicy)
+	}
+	if f.Repeatconcurrency < 0 {
+		return fmt.Errorf("has a negative repeat concurrency")
+	}
+	return nil
+}
+
+// RepeatIterations returns for every iteration the element index to use of each repeat list input.
+// Single values are not indexed, they are passed unchanged to every iteration.
+// Lists with different lengths are an error in the zip mode, in the broadcast mode unless they have one element.
+func (f *Function) RepeatIterations(inputs []RepeatInput) ([]map[string]int, error) {
+	if err := f.ValidateRepeat(); err != nil {
+		return nil, fmt.Errorf("function %s %s", f.Name, err.Error())
+	}
+
+	lists := []RepeatInput{}
+	for _, input := range inputs {
+		if input.Length >= 0 {
+			lists = append(lists, input)
+		}
+	}
+
+	switch f.RepeatMode() {
+	case RepeatProduct:
+		count := 1
+		for _, list := range lists {
+			count *= list.Length
+		}
+		iterations := make([]map[string]int, count)
+		for i := range iterations {
+			indexes := make(map[string]int, len(lists))
+			// the last list changes fastest, like nested loops in the order of the inputs
+			rest := i
+			for j := len(lists) - 1; j >= 0; j-- {
+				indexes[lists[j].Name] = rest % lists[j].Length
+				rest /= lists[j].Length
+			}
+			iterations[i] = indexes
+		}
+		return iterations, nil
+
+	case RepeatBroadcast:
+		count := -1
+		for _, list := range lists {
+			if list.Length == 1 {
+				continue
+			}
+			if count >= 0 && list.Length != count {
+				return nil, fmt.Errorf("function %s repeat input %s has %d elements, the other repeat lists have %d", f.Name, list.Name, list.Length, count)
+			}
+			count = list.Length
+		}
+		if count < 0 {
+			count = 1
+		}
+		iterations := make([]map[string]int, count)
+		for i := range iterations {
+			indexes := make(map[string]int, len(lists))
+			for _, list := range lists {
+				if list.Length == 1 {
+					indexes[list.Name] = 0
+				} else {
+					indexes[list.Name] = i
+				}
+			}
+			iterations[i] = indexes
+		}
+		return iterations, nil
+
+	default:
+		count := -1
+		for _, input := range inputs {
+			length := input.Length
+			if length < 0 {
+				length = 1
+			}
+			if count >= 0 && length != count {
+				return nil, fmt.Errorf("function %s repeat input %s has %d elements, the other repeat inputs have %d", f.Name, input.Name, length, count)
+			}
+			count = length
+		}
+		if count < 0 {
+			count = 1
+		}
+		iterations := make([]map[string]int, count)
+		for i := range iterations {
+			indexes := make(map[string]int, len(lists))
+			for _, list := range lists {
+				indexes[list.Name] = i
+			}
+			iterations[i] = indexes
+		}
+		return iterations, nil
+	}
+}
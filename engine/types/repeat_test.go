@@ -0,0 +1,51 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFunction_RepeatIterations(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		inputs  []RepeatInput
+		want    []map[string]int
+		wantErr bool
+	}{
+		{"zip", RepeatZip, []RepeatInput{{"a", 2}, {"b", 2}}, []map[string]int{{"a": 0, "b": 0}, {"a": 1, "b": 1}}, false},
+		{"zip with different lengths", "", []RepeatInput{{"a", 2}, {"b", 3}}, nil, true},
+		{"zip with a single value", RepeatZip, []RepeatInput{{"a", 2}, {"s", -1}}, nil, true},
+		{"zip of empty lists", RepeatZip, []RepeatInput{{"a", 0}}, []map[string]int{}, false},
+		{"product", RepeatProduct, []RepeatInput{{"a", 2}, {"s", -1}, {"b", 3}}, []map[string]int{
+			{"a": 0, "b": 0}, {"a": 0, "b": 1}, {"a": 0, "b": 2},
+			{"a": 1, "b": 0}, {"a": 1, "b": 1}, {"a": 1, "b": 2},
+		}, false},
+		{"broadcast", RepeatBroadcast, []RepeatInput{{"a", 3}, {"one", 1}, {"s", -1}}, []map[string]int{
+			{"a": 0, "one": 0}, {"a": 1, "one": 0}, {"a": 2, "one": 0},
+		}, false},
+		{"broadcast with different lengths", RepeatBroadcast, []RepeatInput{{"a", 3}, {"b", 2}}, nil, true},
+		{"unknown mode", "diagonal", []RepeatInput{{"a", 1}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Function{Name: "F1", Repeatmode: tt.mode}
+			got, err := f.RepeatIterations(tt.inputs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RepeatIterations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RepeatIterations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunction_ValidateRepeat(t *testing.T) {
+	if err := (&Function{Repeatmode: "Product", Repeaterrorpolicy: "Continue", Repeatconcurrency: 4}).ValidateRepeat(); err != nil {
+		t.Errorf("ValidateRepeat() error = %v", err)
+	}
+	if err := (&Function{Repeaterrorpolicy: "ignore"}).ValidateRepeat(); err == nil {
+		t.Error("ValidateRepeat() expected an error for an unknown error policy")
+	}
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+const (
+	RouterModeValue      = "value"
+	RouterModeExpression = "expression"
+)
+
+// IsExpression reports whether the router selects the next function group by evaluating expressions
+func (r *RouterDef) IsExpression() bool {
+	return strings.EqualFold(r.Mode, RouterModeExpression)
+}
+
+// Compile compiles the router expressions so they can be evaluated without parsing on every execution.
+// Routers in value mode are left untouched.
+func (r *RouterDef) Compile() error {
+	if !r.IsExpression() {
+		return nil
+	}
+
+	if len(r.Expressions) != len(r.Nextfuncgroups) {
+		return fmt.Errorf("router has %d expressions but %d next function groups", len(r.Expressions), len(r.Nextfuncgroups))
+	}
+
+	programs := make([]*vm.Program, len(r.Expressions))
+	for i, expression := range r.Expressions {
+		if strings.TrimSpace(expression) == "" {
+			return fmt.Errorf("expression %d for next function group %s is empty", i, r.Nextfuncgroups[i])
+		}
+
+		program, err := expr.Compile(expression, expr.AllowUndefinedVariables(), expr.AsBool())
+		if err != nil {
+			return fmt.Errorf("expression %d (%s) for next function group %s is invalid: %v", i, expression, r.Nextfuncgroups[i], err)
+		}
+		programs[i] = program
+	}
+	r.programs = programs
+	return nil
+}
+
+// Evaluate runs the compiled expressions against env in order and returns the next function group
+// of the first expression that is true, or Defaultfuncgroup if none matches.
+func (r *RouterDef) Evaluate(env map[string]interface{}) (string, error) {
+	if r.programs == nil {
+		if err := r.Compile(); err != nil {
+			return "", err
+		}
+	}
+
+	for i, program := range r.programs {
+		result, err := expr.Run(program, env)
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate router expression %s: %v", r.Expressions[i], err)
+		}
+		if matched, ok := result.(bool); ok && matched {
+			return r.Nextfuncgroups[i], nil
+		}
+	}
+
+	return r.Defaultfuncgroup, nil
+}
+
+// CompileRouters compiles the router of every function group in the transaction code.
+// All invalid routers are reported in a single validation error.
+func (t *TranCode) CompileRouters() error {
+	var bpmErr *BPMError
+	for i := range t.Functiongroups {
+		if err := t.Functiongroups[i].RouterDef.Compile(); err != nil {
+			if bpmErr == nil {
+				bpmErr = NewValidationError(fmt.Sprintf("Transaction code %s has invalid router definitions", t.Name), nil)
+			}
+			bpmErr.WithDetail(t.Functiongroups[i].Name, err.Error())
+		}
+	}
+
+	if bpmErr != nil {
+		return bpmErr
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestRouterDef_Evaluate(t *testing.T) {
+	router := RouterDef{
+		Mode:             RouterModeExpression,
+		Expressions:      []string{`GetOrder.qty > 100 && status == "HOLD"`, `system.UserNo == "admin"`},
+		Nextfuncgroups:   []string{"Hold", "Admin"},
+		Defaultfuncgroup: "Default",
+	}
+	if err := router.Compile(); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]interface{}
+		want string
+	}{
+		{
+			name: "first expression matches",
+			env: map[string]interface{}{
+				"GetOrder": map[string]interface{}{"qty": 150},
+				"status":   "HOLD",
+				"system":   map[string]interface{}{"UserNo": "admin"},
+			},
+			want: "Hold",
+		},
+		{
+			name: "second expression matches",
+			env: map[string]interface{}{
+				"GetOrder": map[string]interface{}{"qty": 50},
+				"status":   "HOLD",
+				"system":   map[string]interface{}{"UserNo": "admin"},
+			},
+			want: "Admin",
+		},
+		{
+			name: "default when nothing matches",
+			env: map[string]interface{}{
+				"GetOrder": map[string]interface{}{"qty": 50},
+				"status":   "OPEN",
+				"system":   map[string]interface{}{"UserNo": "user"},
+			},
+			want: "Default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := router.Evaluate(tt.env)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranCode_CompileRouters(t *testing.T) {
+	tc := TranCode{
+		Name: "TestTC",
+		Functiongroups: []FuncGroup{
+			{Name: "ok", RouterDef: RouterDef{Mode: RouterModeExpression, Expressions: []string{"a > 1"}, Nextfuncgroups: []string{"next"}}},
+			{Name: "syntax", RouterDef: RouterDef{Mode: RouterModeExpression, Expressions: []string{"a >"}, Nextfuncgroups: []string{"next"}}},
+			{Name: "mismatch", RouterDef: RouterDef{Mode: RouterModeExpression, Expressions: []string{"a > 1", "b"}, Nextfuncgroups: []string{"next"}}},
+			{Name: "value", RouterDef: RouterDef{Variable: "a", Values: []string{"1"}, Nextfuncgroups: []string{"next"}}},
+		},
+	}
+
+	err := tc.CompileRouters()
+	if err == nil {
+		t.Fatal("CompileRouters() expected error")
+	}
+	bpmErr, ok := err.(*BPMError)
+	if !ok {
+		t.Fatalf("CompileRouters() error type = %T, want *BPMError", err)
+	}
+	if len(bpmErr.Details) != 2 || bpmErr.Details["syntax"] == "" || bpmErr.Details["mismatch"] == "" {
+		t.Errorf("CompileRouters() details = %v, want syntax and mismatch", bpmErr.Details)
+	}
+}
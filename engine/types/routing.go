@@ -0,0 +1,199 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxLoopIterations is used for declared loops without an explicit MaxIterations
+const DefaultMaxLoopIterations = 100
+
+// RouteTargets returns the function groups the router can route to, in declaration order and without duplicates
+func (r *RouterDef) RouteTargets() []string {
+	targets := []string{}
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, r.Nextfuncgroups...), r.Defaultfuncgroup) {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		targets = append(targets, name)
+	}
+	return targets
+}
+
+// GetLoop returns the declared loop for the routing edge from -> to, if any
+func (t *TranCode) GetLoop(from, to string) (Loop, bool) {
+	for _, loop := range t.Loops {
+		if loop.From == from && loop.To == to {
+			return loop, true
+		}
+	}
+	return Loop{}, false
+}
+
+// ValidateRouting checks the function group routing graph.
+// Every cycle must go through a declared loop, and every declared loop must match an existing routing edge.
+func (t *TranCode) ValidateRouting() error {
+	edges := map[string][]string{}
+	for _, fgroup := range t.Functiongroups {
+		edges[fgroup.Name] = fgroup.RouterDef.RouteTargets()
+		if fgroup.Errorhandler != "" {
+			edges[fgroup.Name] = append(edges[fgroup.Name], fgroup.Errorhandler)
+		}
+	}
+
+	for _, fgroup := range t.Functiongroups {
+		if !fgroup.Try {
+			continue
+		}
+		if fgroup.Errorhandler == "" {
+			return NewValidationError(fmt.Sprintf("Function group %s is marked as try but has no error handler", fgroup.Name), nil)
+		}
+		if _, ok := edges[fgroup.Errorhandler]; !ok {
+			return NewValidationError(fmt.Sprintf("Function group %s uses unknown error handler %s", fgroup.Name, fgroup.Errorhandler), nil)
+		}
+	}
+
+	for _, loop := range t.Loops {
+		targets, ok := edges[loop.From]
+		if !ok {
+			return NewValidationError(fmt.Sprintf("Loop %s starts from unknown function group %s", loop.Name, loop.From), nil)
+		}
+		if _, ok := edges[loop.To]; !ok {
+			return NewValidationError(fmt.Sprintf("Loop %s routes to unknown function group %s", loop.Name, loop.To), nil)
+		}
+		found := false
+		for _, target := range targets {
+			if target == loop.To {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return NewValidationError(fmt.Sprintf("Loop %s: function group %s does not route to %s", loop.Name, loop.From, loop.To), nil)
+		}
+		if loop.MaxIterations < 0 {
+			return NewValidationError(fmt.Sprintf("Loop %s has a negative maximum iteration count", loop.Name), nil)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	stack := []string{}
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, next := range edges[name] {
+			if _, exists := edges[next]; !exists {
+				continue
+			}
+			if _, declared := t.GetLoop(name, next); declared {
+				continue
+			}
+			switch state[next] {
+			case visiting:
+				for i, n := range stack {
+					if n == next {
+						return append(append([]string{}, stack[i:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, fgroup := range t.Functiongroups {
+		if state[fgroup.Name] == unvisited {
+			if cycle := visit(fgroup.Name); cycle != nil {
+				return NewRoutingError(fmt.Sprintf("Transaction code %s has an undeclared routing cycle: %s", t.Name, strings.Join(cycle, " -> ")), cycle)
+			}
+		}
+	}
+	return nil
+}
+
+// LoopGuard tracks function group routing at runtime and stops runaway loops
+type LoopGuard struct {
+	tcode      *TranCode
+	path       []string
+	seen       map[string]bool
+	iterations map[string]int
+}
+
+// NewLoopGuard creates a loop guard for one execution of the transaction code, starting at the first function group
+func NewLoopGuard(tcode *TranCode, first string) *LoopGuard {
+	return &LoopGuard{
+		tcode:      tcode,
+		path:       []string{first},
+		seen:       map[string]bool{first: true},
+		iterations: map[string]int{},
+	}
+}
+
+// Path returns the function groups executed so far
+func (g *LoopGuard) Path() []string {
+	return g.path
+}
+
+// Route records the transition from -> to and returns a routing error if it exceeds a declared loop's
+// maximum iteration count or revisits a function group outside of a declared loop.
+func (g *LoopGuard) Route(from, to string) *BPMError {
+	loop, declared := g.tcode.GetLoop(from, to)
+
+	if !declared {
+		for _, l := range g.tcode.Loops {
+			if l.To == to {
+				// a fresh entry into the loop restarts its iteration count
+				delete(g.iterations, l.Name+"|"+l.From+"|"+l.To)
+			}
+		}
+		if g.seen[to] {
+			cycle := append(g.loopPath(to), to)
+			return NewRoutingError(fmt.Sprintf("Function group %s was reached again outside of a declared loop", to), cycle)
+		}
+		g.path = append(g.path, to)
+		g.seen[to] = true
+		return nil
+	}
+
+	max := loop.MaxIterations
+	if max == 0 {
+		max = DefaultMaxLoopIterations
+	}
+	key := loop.Name + "|" + loop.From + "|" + loop.To
+	g.iterations[key]++
+	if g.iterations[key] > max {
+		cycle := append(g.loopPath(to), to)
+		err := NewRoutingError(fmt.Sprintf("Loop %s exceeded the maximum of %d iterations", loop.Name, max), cycle)
+		err.WithDetail("loop", loop.Name)
+		err.WithDetail("max_iterations", fmt.Sprintf("%d", max))
+		return err
+	}
+
+	g.path = append(g.path, to)
+	g.seen = map[string]bool{to: true}
+	return nil
+}
+
+// loopPath returns the executed path starting at the last visit of name
+func (g *LoopGuard) loopPath(name string) []string {
+	for i := len(g.path) - 1; i >= 0; i-- {
+		if g.path[i] == name {
+			return append([]string{}, g.path[i:]...)
+		}
+	}
+	return append([]string{}, g.path...)
+}
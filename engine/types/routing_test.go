@@ -0,0 +1,85 @@
+package types
+
+import (
+	"testing"
+)
+
+func routingTranCode(loops ...Loop) TranCode {
+	return TranCode{
+		Name:           "RoutingTC",
+		Firstfuncgroup: "Start",
+		Functiongroups: []FuncGroup{
+			{Name: "Start", RouterDef: RouterDef{Defaultfuncgroup: "Check"}},
+			{Name: "Check", RouterDef: RouterDef{Values: []string{"done"}, Nextfuncgroups: []string{"End"}, Defaultfuncgroup: "Process"}},
+			{Name: "Process", RouterDef: RouterDef{Defaultfuncgroup: "Check"}},
+			{Name: "End"},
+		},
+		Loops: loops,
+	}
+}
+
+func tryTranCode(errorhandler string) TranCode {
+	tc := routingTranCode(Loop{Name: "retry", From: "Process", To: "Check"})
+	tc.Functiongroups[2].Try = true
+	tc.Functiongroups[2].Errorhandler = errorhandler
+	return tc
+}
+
+func TestTranCode_ValidateRouting(t *testing.T) {
+	tests := []struct {
+		name    string
+		tc      TranCode
+		wantErr bool
+	}{
+		{name: "undeclared cycle", tc: routingTranCode(), wantErr: true},
+		{name: "declared loop", tc: routingTranCode(Loop{Name: "retry", From: "Process", To: "Check", MaxIterations: 3}), wantErr: false},
+		{name: "loop without edge", tc: routingTranCode(Loop{Name: "bad", From: "End", To: "Start"}), wantErr: true},
+		{name: "loop with unknown group", tc: routingTranCode(Loop{Name: "bad", From: "Missing", To: "Start"}), wantErr: true},
+		{name: "try without error handler", tc: tryTranCode(""), wantErr: true},
+		{name: "try with unknown error handler", tc: tryTranCode("Missing"), wantErr: true},
+		{name: "try with error handler", tc: tryTranCode("End"), wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tc.ValidateRouting()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRouting() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoopGuard_Route(t *testing.T) {
+	tc := routingTranCode(Loop{Name: "retry", From: "Process", To: "Check", MaxIterations: 2})
+	guard := NewLoopGuard(&tc, "Start")
+
+	steps := [][2]string{{"Start", "Check"}, {"Check", "Process"}, {"Process", "Check"}, {"Check", "Process"}, {"Process", "Check"}, {"Check", "Process"}}
+	for _, step := range steps {
+		if err := guard.Route(step[0], step[1]); err != nil {
+			t.Fatalf("Route(%s, %s) unexpected error = %v", step[0], step[1], err)
+		}
+	}
+
+	err := guard.Route("Process", "Check")
+	if err == nil {
+		t.Fatal("Route() expected error after exceeding the maximum iterations")
+	}
+	if err.Category != ErrorCategoryRouting || err.Details["path"] != "Check -> Process -> Check" {
+		t.Errorf("Route() error = %v, path %q", err, err.Details["path"])
+	}
+}
+
+func TestLoopGuard_RouteUndeclaredRevisit(t *testing.T) {
+	tc := routingTranCode()
+	guard := NewLoopGuard(&tc, "Start")
+
+	if err := guard.Route("Start", "Check"); err != nil {
+		t.Fatalf("Route() unexpected error = %v", err)
+	}
+	if err := guard.Route("Check", "Process"); err != nil {
+		t.Fatalf("Route() unexpected error = %v", err)
+	}
+	if err := guard.Route("Process", "Check"); err == nil {
+		t.Error("Route() expected error for undeclared revisit")
+	}
+}
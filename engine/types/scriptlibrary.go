@@ -0,0 +1,68 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ScriptLibraryJavascript is the language of the script libraries, they are CommonJS modules
+const ScriptLibraryJavascript = "javascript"
+
+var scriptLibraryNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-/]*$`)
+
+// ScriptLibrary is a version of a shared script that the script functions load with require("name") or
+// require("name@version"). Without a version the default version is loaded. A saved version is not changed anymore,
+// a change is saved as a new version.
+type ScriptLibrary struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Language    string    `json:"language"`
+	Description string    `json:"description"`
+	Content     string    `json:"content"`
+	IsDefault   bool      `json:"isdefault"`
+	CreatedBy   string    `json:"createdby"`
+	CreatedOn   time.Time `json:"createdon"`
+}
+
+// Key returns the name and version of the library as name@version
+func (l *ScriptLibrary) Key() string {
+	return l.Name + "@" + l.Version
+}
+
+// Validate checks the library before it is saved
+func (l *ScriptLibrary) Validate() error {
+	if !scriptLibraryNamePattern.MatchString(l.Name) {
+		return fmt.Errorf("the script library name %q is not valid", l.Name)
+	}
+	if l.Version == "" || strings.Contains(l.Version, "@") {
+		return fmt.Errorf("the script library %s has no valid version", l.Name)
+	}
+	if l.Language == "" {
+		l.Language = ScriptLibraryJavascript
+	}
+	if l.Language != ScriptLibraryJavascript {
+		return fmt.Errorf("the language %s of script library %s is not supported", l.Language, l.Name)
+	}
+	if strings.TrimSpace(l.Content) == "" {
+		return fmt.Errorf("the script library %s has no content", l.Key())
+	}
+	return nil
+}
+
+// ParseScriptLibraryReference splits the argument of require into the library name and version,
+// the version is empty for the default version
+func ParseScriptLibraryReference(reference string) (string, string, error) {
+	name, version := strings.TrimSpace(reference), ""
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		name, version = name[:i], name[i+1:]
+		if version == "" {
+			return "", "", fmt.Errorf("the script library reference %q has an empty version", reference)
+		}
+	}
+	if !scriptLibraryNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("the script library reference %q is not valid", reference)
+	}
+	return name, version, nil
+}
@@ -0,0 +1,35 @@
+package types
+
+import "testing"
+
+func TestParseScriptLibraryReference(t *testing.T) {
+	name, version, err := ParseScriptLibraryReference("common/dates@1.2")
+	if err != nil || name != "common/dates" || version != "1.2" {
+		t.Errorf("ParseScriptLibraryReference() = %s, %s, %v", name, version, err)
+	}
+	if name, version, _ := ParseScriptLibraryReference("units"); name != "units" || version != "" {
+		t.Errorf("ParseScriptLibraryReference() = %s, %s, want the default version", name, version)
+	}
+	for _, reference := range []string{"", "units@", "../units", "1units"} {
+		if _, _, err := ParseScriptLibraryReference(reference); err == nil {
+			t.Errorf("ParseScriptLibraryReference(%q) expected an error", reference)
+		}
+	}
+}
+
+func TestScriptLibrary_Validate(t *testing.T) {
+	library := ScriptLibrary{Name: "units", Version: "1", Content: "exports.x = 1;"}
+	if err := library.Validate(); err != nil || library.Language != ScriptLibraryJavascript {
+		t.Errorf("Validate() = %v, language %s", err, library.Language)
+	}
+	invalid := []ScriptLibrary{
+		{Name: "units", Content: "exports.x = 1;"},
+		{Name: "units", Version: "1", Language: "python", Content: "x = 1"},
+		{Name: "units", Version: "1", Content: " "},
+	}
+	for _, library := range invalid {
+		if library.Validate() == nil {
+			t.Errorf("Validate(%+v) expected an error", library)
+		}
+	}
+}
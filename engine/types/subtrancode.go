@@ -0,0 +1,112 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Invocation modes of a sub transaction code
+const (
+	SubTranCodeSync        = "sync"        // runs in the transaction of the parent
+	SubTranCodeAfterCommit = "aftercommit" // queued as a job that runs once the parent has committed
+	SubTranCodeAwait       = "await"       // runs in its own transaction, the parent waits for its outputs
+)
+
+// Statuses of a child execution
+const (
+	ChildExecutionRunning   = "running"
+	ChildExecutionCompleted = "completed"
+	ChildExecutionFailed    = "failed"
+)
+
+// DefaultSubTranCodeTimeout is the time in seconds the parent waits for an awaited sub transaction code
+const DefaultSubTranCodeTimeout = 60
+
+// SubTranCodeInvocation is the content of a sub transaction code function, the function runs the transaction
+// code synchronously without content
+type SubTranCodeInvocation struct {
+	Mode       string `json:"mode"`
+	Timeout    int    `json:"timeout"`    // seconds the parent waits in the await mode
+	Priority   int    `json:"priority"`   // priority of the job in the aftercommit mode
+	MaxRetries int    `json:"maxretries"` // retries of the job in the aftercommit mode
+}
+
+// ParseSubTranCodeInvocation parses the content of a sub transaction code function and applies the defaults
+func ParseSubTranCodeInvocation(content string) (*SubTranCodeInvocation, error) {
+	invocation := &SubTranCodeInvocation{}
+	if strings.TrimSpace(content) != "" {
+		if err := json.Unmarshal([]byte(content), invocation); err != nil {
+			return nil, fmt.Errorf("the sub transaction code invocation is not valid JSON: %v", err)
+		}
+	}
+
+	invocation.Mode = strings.ToLower(invocation.Mode)
+	switch invocation.Mode {
+	case "":
+		invocation.Mode = SubTranCodeSync
+	case SubTranCodeSync, SubTranCodeAfterCommit, SubTranCodeAwait:
+	default:
+		return nil, fmt.Errorf("the sub transaction code invocation mode %s is not supported", invocation.Mode)
+	}
+	if invocation.Timeout < 0 || invocation.Priority < 0 || invocation.MaxRetries < 0 {
+		return nil, fmt.Errorf("the timeout, priority and retries of a sub transaction code invocation cannot be negative")
+	}
+	if invocation.Timeout == 0 {
+		invocation.Timeout = DefaultSubTranCodeTimeout
+	}
+	if invocation.Priority == 0 {
+		invocation.Priority = 5
+	}
+	if invocation.MaxRetries == 0 {
+		invocation.MaxRetries = 3
+	}
+	return invocation, nil
+}
+
+// ExecutionIdentity identifies an execution of a transaction code. All executions started by the same
+// request share its correlation id, the execution started by the request is its own correlation.
+type ExecutionIdentity struct {
+	ExecutionID       string `json:"executionid"`
+	CorrelationID     string `json:"correlationid"`
+	ParentExecutionID string `json:"parentexecutionid"`
+	TranCodeName      string `json:"trancodename"`
+}
+
+// SubTranCodeRequest is an execution of a sub transaction code outside of the transaction of its parent
+type SubTranCodeRequest struct {
+	ExecutionID       string                 `json:"executionid"`
+	CorrelationID     string                 `json:"correlationid"`
+	ParentExecutionID string                 `json:"parentexecutionid"`
+	ParentTranCode    string                 `json:"parenttrancode"`
+	TranCodeName      string                 `json:"trancodename"`
+	Mode              string                 `json:"mode"`
+	Inputs            map[string]interface{} `json:"inputs"`
+	UserNo            string                 `json:"userno"`
+	ClientID          string                 `json:"clientid"`
+	Priority          int                    `json:"priority"`
+	MaxRetries        int                    `json:"maxretries"`
+	JobID             string                 `json:"jobid"` // queue job of the aftercommit mode
+}
+
+// Parent returns the identity of the parent execution
+func (r *SubTranCodeRequest) Parent() ExecutionIdentity {
+	return ExecutionIdentity{ExecutionID: r.ParentExecutionID, CorrelationID: r.CorrelationID, TranCodeName: r.ParentTranCode}
+}
+
+// ChildExecution is the record of a sub transaction code that ran outside of the transaction of its parent
+type ChildExecution struct {
+	ExecutionID       string    `json:"executionid"`
+	CorrelationID     string    `json:"correlationid"`
+	ParentExecutionID string    `json:"parentexecutionid"`
+	ParentTranCode    string    `json:"parenttrancode"`
+	TranCodeName      string    `json:"trancodename"`
+	Mode              string    `json:"mode"`
+	JobID             string    `json:"jobid"`
+	Status            string    `json:"status"`
+	Attempts          int       `json:"attempts"`
+	Error             string    `json:"error"`
+	StartedOn         time.Time `json:"startedon"`
+	CompletedOn       time.Time `json:"completedon"`
+}
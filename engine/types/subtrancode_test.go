@@ -0,0 +1,21 @@
+package types
+
+import "testing"
+
+func TestParseSubTranCodeInvocation(t *testing.T) {
+	invocation, err := ParseSubTranCodeInvocation("")
+	if err != nil || invocation.Mode != SubTranCodeSync {
+		t.Errorf("ParseSubTranCodeInvocation() = %+v, %v, want the sync mode", invocation, err)
+	}
+
+	invocation, err = ParseSubTranCodeInvocation(`{"mode":"Await","timeout":5}`)
+	if err != nil || invocation.Mode != SubTranCodeAwait || invocation.Timeout != 5 || invocation.MaxRetries != 3 {
+		t.Errorf("ParseSubTranCodeInvocation() = %+v, %v", invocation, err)
+	}
+
+	for _, content := range []string{`{"mode":"later"}`, `{"mode":"await","timeout":-1}`, `mode=await`} {
+		if _, err := ParseSubTranCodeInvocation(content); err == nil {
+			t.Errorf("ParseSubTranCodeInvocation(%s) expected an error", content)
+		}
+	}
+}
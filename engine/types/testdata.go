@@ -0,0 +1,275 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Error match modes of the expected error of test data
+const (
+	ErrorMatchExact = "exact" // the error text or the message of the structured error equals the expected error
+	ErrorMatchRegex = "regex" // the error text matches the expected error as a regular expression
+)
+
+// Test results
+const (
+	TestPass = "Pass"
+	TestFail = "Fail"
+)
+
+// FunctionMock is a recorded response of a function that is stubbed in a test.
+// It applies to the function with the name Function, or to every function of the type Type if no name is set.
+// With a Sequence it applies to that call of the function only, counted from 1.
+// A mock with an Error fails the function with that error, otherwise the function returns the Outputs.
+type FunctionMock struct {
+	Function string                 `json:"function"`
+	Type     string                 `json:"type"`
+	Sequence int                    `json:"sequence"`
+	Outputs  map[string]interface{} `json:"outputs"`
+	Error    string                 `json:"error"`
+}
+
+// Matches reports whether the mock applies to the function
+func (m *FunctionMock) Matches(fobj *Function) bool {
+	if m.Function != "" {
+		return m.Function == fobj.Name && (m.Type == "" || strings.EqualFold(m.Type, fobj.TypeName()))
+	}
+	return m.Type != "" && strings.EqualFold(m.Type, fobj.TypeName())
+}
+
+// TestResult is the result of running one test data of a transaction code
+type TestResult struct {
+	Name               string                 `json:"name"`
+	Result             string                 `json:"result"`
+	ExpectError        bool                   `json:"expecterror"`
+	ExpectedError      string                 `json:"expectederror"`
+	ActualError        string                 `json:"actualerror"`
+	ActualOutputs      map[string]interface{} `json:"actualoutputs"`
+	Mismatches         []ContractViolation    `json:"mismatches"`
+	ExecutedFuncGroups []string               `json:"executedfuncgroups"`
+	UnmockedFunctions  []string               `json:"unmockedfunctions"` // functions with external effects that ran without a mock
+	Duration           time.Duration          `json:"duration"`
+}
+
+// TranCodeTestReport is the result of running all test data of a transaction code
+type TranCodeTestReport struct {
+	TranCode          string       `json:"trancode"`
+	Version           string       `json:"version"`
+	Results           []TestResult `json:"results"`
+	Passed            int          `json:"passed"`
+	Failed            int          `json:"failed"`
+	FuncGroups        int          `json:"funcgroups"`
+	CoveredFuncGroups []string     `json:"coveredfuncgroups"`
+	MissedFuncGroups  []string     `json:"missedfuncgroups"`
+	Coverage          float64      `json:"coverage"` // share of the function groups executed by at least one test, 0 to 1
+	Error             string       `json:"error"`    // the transaction code could not be loaded or tested
+}
+
+// TestSuiteReport is the result of running the tests of several transaction codes
+type TestSuiteReport struct {
+	TranCodes []TranCodeTestReport `json:"trancodes"`
+	Passed    int                  `json:"passed"`
+	Failed    int                  `json:"failed"`
+	Errors    int                  `json:"errors"`
+	Duration  time.Duration        `json:"duration"`
+}
+
+// Succeeded reports whether every test passed and every transaction code could be tested
+func (r *TestSuiteReport) Succeeded() bool {
+	return r.Failed == 0 && r.Errors == 0
+}
+
+// Add adds the report of a transaction code to the suite
+func (r *TestSuiteReport) Add(report TranCodeTestReport) {
+	r.TranCodes = append(r.TranCodes, report)
+	r.Passed += report.Passed
+	r.Failed += report.Failed
+	if report.Error != "" {
+		r.Errors++
+	}
+}
+
+// NewTranCodeTestReport sums up the test results of the transaction code and computes the function group coverage
+func NewTranCodeTestReport(tcode *TranCode, results []TestResult) TranCodeTestReport {
+	report := TranCodeTestReport{TranCode: tcode.Name, Version: tcode.Version, Results: results, FuncGroups: len(tcode.Functiongroups)}
+
+	executed := map[string]bool{}
+	for _, result := range results {
+		if result.Result == TestPass {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		for _, name := range result.ExecutedFuncGroups {
+			executed[name] = true
+		}
+	}
+
+	report.CoveredFuncGroups = []string{}
+	report.MissedFuncGroups = []string{}
+	for _, fgroup := range tcode.Functiongroups {
+		if executed[fgroup.Name] {
+			report.CoveredFuncGroups = append(report.CoveredFuncGroups, fgroup.Name)
+		} else {
+			report.MissedFuncGroups = append(report.MissedFuncGroups, fgroup.Name)
+		}
+	}
+	if report.FuncGroups > 0 {
+		report.Coverage = float64(len(report.CoveredFuncGroups)) / float64(report.FuncGroups)
+	}
+	return report
+}
+
+// MatchError reports whether the error of a test run is the expected error of the test data
+func (td *TestData) MatchError(err error) (bool, error) {
+	if err == nil {
+		return false, nil
+	}
+	switch strings.ToLower(td.ErrorMatch) {
+	case "", ErrorMatchExact:
+		if td.WantedErr == "" || err.Error() == td.WantedErr {
+			return true, nil
+		}
+		if bpmErr, ok := err.(*BPMError); ok && bpmErr.Message == td.WantedErr {
+			return true, nil
+		}
+		return false, nil
+	case ErrorMatchRegex:
+		re, compileErr := regexp.Compile(td.WantedErr)
+		if compileErr != nil {
+			return false, fmt.Errorf("test data %s has an invalid expected error pattern: %s", td.Name, compileErr.Error())
+		}
+		return re.MatchString(err.Error()), nil
+	default:
+		return false, fmt.Errorf("test data %s has unknown error match mode %s", td.Name, td.ErrorMatch)
+	}
+}
+
+// CompareOutputs compares the actual outputs of a test run with the expected outputs of the test data and
+// returns every difference. Expected values holding JSON are compared structurally with the actual values.
+// With PartialOutputs only the expected outputs and object properties are compared, otherwise additional
+// actual outputs and properties are differences too. The IgnoreOutputs paths are never compared.
+func (td *TestData) CompareOutputs(actual map[string]interface{}) []ContractViolation {
+	ignored := map[string]bool{}
+	for _, path := range td.IgnoreOutputs {
+		ignored[path] = true
+	}
+
+	mismatches := []ContractViolation{}
+	expected := map[string]bool{}
+	for _, output := range td.Outputs {
+		expected[output.Name] = true
+		if ignored[output.Name] {
+			continue
+		}
+		value, exists := actual[output.Name]
+		if !exists {
+			mismatches = append(mismatches, ContractViolation{Path: output.Name, Message: "is missing"})
+			continue
+		}
+		mismatches = td.compareValue(output.Name, expectedTestValue(output.Value), normalizeTestValue(value), ignored, mismatches)
+	}
+
+	if !td.PartialOutputs {
+		names := make([]string, 0, len(actual))
+		for name := range actual {
+			if !expected[name] && !ignored[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			mismatches = append(mismatches, ContractViolation{Path: name, Message: "is not expected"})
+		}
+	}
+	return mismatches
+}
+
+func (td *TestData) compareValue(path string, want, got interface{}, ignored map[string]bool, mismatches []ContractViolation) []ContractViolation {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		gotMap, ok := got.(map[string]interface{})
+		if !ok {
+			return append(mismatches, ContractViolation{Path: path, Message: fmt.Sprintf("is %v, want an object", got)})
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := path + "." + key
+			if ignored[keyPath] {
+				continue
+			}
+			value, exists := gotMap[key]
+			if !exists {
+				mismatches = append(mismatches, ContractViolation{Path: keyPath, Message: "is missing"})
+				continue
+			}
+			mismatches = td.compareValue(keyPath, want[key], value, ignored, mismatches)
+		}
+		if !td.PartialOutputs {
+			extra := []string{}
+			for key := range gotMap {
+				if _, exists := want[key]; !exists && !ignored[path+"."+key] {
+					extra = append(extra, key)
+				}
+			}
+			sort.Strings(extra)
+			for _, key := range extra {
+				mismatches = append(mismatches, ContractViolation{Path: path + "." + key, Message: "is not expected"})
+			}
+		}
+		return mismatches
+
+	case []interface{}:
+		gotList, ok := got.([]interface{})
+		if !ok {
+			return append(mismatches, ContractViolation{Path: path, Message: fmt.Sprintf("is %v, want a list", got)})
+		}
+		if len(gotList) != len(want) {
+			return append(mismatches, ContractViolation{Path: path, Message: fmt.Sprintf("has %d elements, want %d", len(gotList), len(want))})
+		}
+		for i := range want {
+			mismatches = td.compareValue(fmt.Sprintf("%s.%d", path, i), want[i], gotList[i], ignored, mismatches)
+		}
+		return mismatches
+
+	default:
+		if reflect.DeepEqual(want, got) || fmt.Sprint(want) == fmt.Sprint(got) || (want == "" && got == nil) {
+			return mismatches
+		}
+		return append(mismatches, ContractViolation{Path: path, Message: fmt.Sprintf("is %v, want %v", got, want)})
+	}
+}
+
+// expectedTestValue parses an expected output value holding a JSON object, list or scalar, other values stay strings
+func expectedTestValue(value string) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		return parsed
+	}
+	return value
+}
+
+// normalizeTestValue converts an actual output value to its JSON form, so it compares like the expected values
+func normalizeTestValue(value interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		return expectedTestValue(s)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTestData_MatchError(t *testing.T) {
+	bpmErr := NewBusinessError("order is closed").WithContext(&ExecutionContext{TranCodeName: "Order"})
+	tests := []struct {
+		name     string
+		testdata TestData
+		err      error
+		want     bool
+		wantErr  bool
+	}{
+		{"exact text", TestData{WantedErr: "boom"}, errors.New("boom"), true, false},
+		{"exact message of a structured error", TestData{WantedErr: "order is closed"}, bpmErr, true, false},
+		{"different text", TestData{WantedErr: "boom"}, errors.New("bang"), false, false},
+		{"regex", TestData{WantedErr: `\[BUSINESS\] order is (open|closed)`, ErrorMatch: "Regex"}, bpmErr, true, false},
+		{"regex without match", TestData{WantedErr: `^timeout`, ErrorMatch: ErrorMatchRegex}, bpmErr, false, false},
+		{"invalid regex", TestData{WantedErr: `(`, ErrorMatch: ErrorMatchRegex}, bpmErr, false, true},
+		{"no error", TestData{WantedErr: "boom"}, nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.testdata.MatchError(tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MatchError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestData_CompareOutputs(t *testing.T) {
+	actual := map[string]interface{}{
+		"orderid": "O-1",
+		"qty":     5,
+		"order":   map[string]interface{}{"status": "open", "created": "2024-05-01 08:00:00", "lines": []interface{}{"a", "b"}},
+		"trace":   "x",
+	}
+	outputs := []Output{
+		{Name: "orderid", Value: "O-1"},
+		{Name: "qty", Value: "5"},
+		{Name: "order", Value: `{"status": "open", "lines": ["a", "b"]}`},
+	}
+
+	tests := []struct {
+		name     string
+		testdata TestData
+		want     []ContractViolation
+	}{
+		{"exact", TestData{Outputs: outputs}, []ContractViolation{
+			{Path: "order.created", Message: "is not expected"},
+			{Path: "trace", Message: "is not expected"},
+		}},
+		{"partial", TestData{Outputs: outputs, PartialOutputs: true}, []ContractViolation{}},
+		{"ignored", TestData{Outputs: outputs, IgnoreOutputs: []string{"order.created", "trace"}}, []ContractViolation{}},
+		{"different values", TestData{PartialOutputs: true, Outputs: []Output{
+			{Name: "qty", Value: "6"},
+			{Name: "order", Value: `{"lines": ["a"]}`},
+			{Name: "total"},
+		}}, []ContractViolation{
+			{Path: "qty", Message: "is 5, want 6"},
+			{Path: "order.lines", Message: "has 2 elements, want 1"},
+			{Path: "total", Message: "is missing"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.testdata.CompareOutputs(actual); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareOutputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTranCodeTestReport(t *testing.T) {
+	tcode := &TranCode{Name: "Order", Functiongroups: []FuncGroup{{Name: "FG1"}, {Name: "FG2"}, {Name: "FG3"}, {Name: "FG4"}}}
+	report := NewTranCodeTestReport(tcode, []TestResult{
+		{Name: "T1", Result: TestPass, ExecutedFuncGroups: []string{"FG1", "FG2"}},
+		{Name: "T2", Result: TestFail, ExecutedFuncGroups: []string{"FG1", "FG3"}},
+	})
+	if report.Passed != 1 || report.Failed != 1 || report.Coverage != 0.75 {
+		t.Errorf("report = %d passed, %d failed, coverage %v", report.Passed, report.Failed, report.Coverage)
+	}
+	if !reflect.DeepEqual(report.MissedFuncGroups, []string{"FG4"}) {
+		t.Errorf("MissedFuncGroups = %v, want [FG4]", report.MissedFuncGroups)
+	}
+
+	mock := FunctionMock{Type: "webservicecall"}
+	if !mock.Matches(&Function{Name: "Call", Functype: WebServiceCall}) || mock.Matches(&Function{Name: "Call", Functype: Query}) {
+		t.Error("a mock by type must match the functions of the type only")
+	}
+}
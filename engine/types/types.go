@@ -1,5 +1,7 @@
 package types
 
+import "github.com/antonmedv/expr/vm"
+
 type TranCode struct {
 	ID             string                 "json:'_id'"
 	UUID           string                 "json:'uuid'"
@@ -15,14 +17,28 @@ type TranCode struct {
 	SystemData     SystemData             "json:'system'"
 	Description    string                 "json:'description'"
 	TestDatas      []TestData             "json:'testdatas'"
+	Loops          []Loop                 "json:'loops'"
+}
+
+// Loop declares an intentional routing cycle: the function group From routes back to To
+// at most MaxIterations times each time the loop is entered.
+type Loop struct {
+	Name          string "json:'name'"
+	From          string "json:'from'"
+	To            string "json:'to'"
+	MaxIterations int    "json:'maxiterations'"
 }
 
 type TestData struct {
-	Name      string   "json:'name'"
-	Inputs    []Input  "json:'inputs'"
-	Outputs   []Output "json:'outputs'"
-	WantErr   bool     "json:'wanterr'"
-	WantedErr string   "json:'wantederr'"
+	Name           string         "json:'name'"
+	Inputs         []Input        "json:'inputs'"
+	Outputs        []Output       "json:'outputs'"
+	WantErr        bool           "json:'wanterr'"
+	WantedErr      string         "json:'wantederr'"
+	ErrorMatch     string         "json:'errormatch'"     // exact (default) or regex
+	PartialOutputs bool           "json:'partialoutputs'" // only the expected outputs and object properties are compared
+	IgnoreOutputs  []string       "json:'ignoreoutputs'"  // dotted paths of outputs or properties that are not compared
+	Mocks          []FunctionMock "json:'mocks'"          // recorded responses of functions stubbed in the test
 }
 
 type SystemData struct {
@@ -39,6 +55,8 @@ type FuncGroup struct {
 	Executionsequence string                 "json:'sequence'"
 	Session           map[string]interface{} "json:'session'"
 	RouterDef         RouterDef              "json:'routerdef'"
+	Try               bool                   "json:'try'"          // run inside a savepoint and route to Errorhandler on failure
+	Errorhandler      string                 "json:'errorhandler'" // function group to continue with when a try group fails
 	functiongroupname string                 "json:'functiongroupname'"
 	Description       string                 "json:'description'"
 	routing           bool                   "json:'routing'"
@@ -55,53 +73,65 @@ type RouterDef struct {
 	Values           []string "json:'values'"
 	Nextfuncgroups   []string "json:'nextfuncgroups'"
 	Defaultfuncgroup string   "json:'defaultfuncgroup'"
+	Mode             string   "json:'mode'"        // "" or "value": match Variable against Values, "expression": evaluate Expressions
+	Expressions      []string "json:'expressions'" // boolean expressions, paired by index with Nextfuncgroups
+	programs         []*vm.Program
 }
 
 type Function struct {
-	ID           string                 "json:'id'"
-	Name         string                 "json:'name'"
-	Version      string                 "json:'version'"
-	Status       Status                 "json:'status'"
-	Functype     FunctionType           "json:'functype'"
-	Inputs       []Input                "json:'inputs'"
-	Outputs      []Output               "json:'outputs'"
-	Content      string                 "json:'content'"
-	Script       string                 "json:'script'"
-	Mapdata      map[string]interface{} "json:'mapdata'"
-	FunctionName string                 "json:'functionname'"
-	Description  string                 "json:'description'"
-	Type         string                 "json:'type'"
-	x            int                    "json:'x'"
-	y            int                    "json:'y'"
-	width        int                    "json:'width'"
-	height       int                    "json:'height'"
+	ID                string                 "json:'id'"
+	Name              string                 "json:'name'"
+	Version           string                 "json:'version'"
+	Status            Status                 "json:'status'"
+	Functype          FunctionType           "json:'functype'"
+	Typename          string                 "json:'typename'" // registered function type, takes precedence over Functype
+	Inputs            []Input                "json:'inputs'"
+	Outputs           []Output               "json:'outputs'"
+	Content           string                 "json:'content'"
+	Script            string                 "json:'script'"
+	Mapdata           map[string]interface{} "json:'mapdata'"
+	FunctionName      string                 "json:'functionname'"
+	Description       string                 "json:'description'"
+	Type              string                 "json:'type'"
+	Compensation      *Function              "json:'compensation'"      // undoes the side effect of the function when the transaction is rolled back
+	Repeatmode        string                 "json:'repeatmode'"        // how repeat inputs form the iterations: zip (default), product or broadcast
+	Repeaterrorpolicy string                 "json:'repeaterrorpolicy'" // failfast (default), continue or skip
+	Repeatconcurrency int                    "json:'repeatconcurrency'" // iterations run in parallel, functions sharing the transaction always run one at a time
+	x                 int                    "json:'x'"
+	y                 int                    "json:'y'"
+	width             int                    "json:'width'"
+	height            int                    "json:'height'"
 }
 
 type Input struct {
-	ID           string      "json:'id'"
-	Name         string      "json:'name'"
-	Source       InputSource "json:'source'"   // 0: constant, 1: function, 2: session
-	Datatype     DataType    "json:'datatype'" // 0: string, 1: int, 2: float, 3: bool, 4: datetime	5: object (json)
-	Inivalue     string      "json:'initialvalue'"
-	Defaultvalue string      "json:'defaultvalue'"
-	Value        string      "json:'value'"
-	List         bool        "json:'list'"
-	Repeat       bool        "json:'repeat'"
-	Aliasname    string      "json:'aliasname'"
-	Description  string      "json:'description'"
+	ID           string        "json:'id'"
+	Name         string        "json:'name'"
+	Source       InputSource   "json:'source'"   // 0: constant, 1: function, 2: session
+	Datatype     DataType      "json:'datatype'" // 0: string, 1: int, 2: float, 3: bool, 4: datetime	5: object (json) 6: decimal 7: date 8: time 9: duration 10: binary
+	Inivalue     string        "json:'initialvalue'"
+	Defaultvalue string        "json:'defaultvalue'"
+	Value        string        "json:'value'"
+	List         bool          "json:'list'"
+	Repeat       bool          "json:'repeat'"
+	Aliasname    string        "json:'aliasname'"
+	Description  string        "json:'description'"
+	Required     bool          "json:'required'" // transaction code inputs only
+	Schema       []SchemaField "json:'schema'"   // properties of an object input
 }
 
 type Output struct {
-	ID           string       "json:'id'"
-	Name         string       "json:'name'"
-	Outputdest   []OutputDest "json:'outputdest'" // 0:none, 1:session, 2:engine
-	Datatype     DataType     "json:'datatype'"   // 0: string, 1: int, 2: float, 3: bool, 4: datetime	5: object (json)
-	Inivalue     string       "json:'initialvalue'"
-	Defaultvalue string       "json:'defaultvalue'"
-	Value        string       "json:'value'"
-	List         bool         "json:'list'"
-	Aliasname    []string     "json:'aliasname'"
-	Description  string       "json:'description'"
+	ID           string        "json:'id'"
+	Name         string        "json:'name'"
+	Outputdest   []OutputDest  "json:'outputdest'" // 0:none, 1:session, 2:engine
+	Datatype     DataType      "json:'datatype'"   // 0: string, 1: int, 2: float, 3: bool, 4: datetime	5: object (json) 6: decimal 7: date 8: time 9: duration 10: binary
+	Inivalue     string        "json:'initialvalue'"
+	Defaultvalue string        "json:'defaultvalue'"
+	Value        string        "json:'value'"
+	List         bool          "json:'list'"
+	Aliasname    []string      "json:'aliasname'"
+	Description  string        "json:'description'"
+	Required     bool          "json:'required'" // transaction code outputs only
+	Schema       []SchemaField "json:'schema'"   // properties of an object output
 }
 
 type FunctionType int
@@ -189,6 +219,48 @@ func (ft FunctionType) String() string {
 	}
 }
 
+// TypeName returns the name of the function type: the registered type name, or the name of the numeric type
+func (f *Function) TypeName() string {
+	if f.Typename != "" {
+		return f.Typename
+	}
+	return f.Functype.String()
+}
+
+// SharesTransaction reports whether functions of this type work on the database transaction of the
+// transaction code, so they must not run concurrently with each other. The scripts query it with iac.query.
+func (ft FunctionType) SharesTransaction() bool {
+	switch ft {
+	case Query, StoreProcedure, SubTranCode, TableInsert, TableUpdate, TableDelete, ThrowError, Javascript, GoExpr:
+		return true
+	default:
+		return false
+	}
+}
+
+// HasExternalEffect reports whether functions of the type have effects outside of the database transaction
+// that a test cannot roll back, like calls of other systems, messages and writes to the document database
+func (ft FunctionType) HasExternalEffect() bool {
+	switch ft {
+	case SubTranCode, SendMessage, SendEmail, SendMessagebyKafka, SendMessagebyMQTT, SendMessagebyAQMP, WebServiceCall,
+		CollectionInsert, CollectionUpdate, CollectionDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsNondeterministic reports whether running a function of the type again can give another result,
+// because it reads data that changes or returns generated ids
+func (ft FunctionType) IsNondeterministic() bool {
+	switch ft {
+	case Query, StoreProcedure, SubTranCode, TableInsert, CollectionInsert, WebServiceCall:
+		return true
+	default:
+		return false
+	}
+}
+
 type Status int
 
 const (
@@ -207,6 +279,11 @@ const (
 	Bool
 	DateTime
 	Object
+	Decimal  // arbitrary precision number, DecimalValue
+	Date     // date without time of day, DateValue
+	Time     // time of day without date, TimeValue
+	Duration // DurationValue
+	Binary   // []byte, base64 in JSON
 )
 
 type InputSource int
@@ -247,5 +324,8 @@ const (
 
 var DateTimeFormat string = "2006-01-02 15:04:05"
 
+// ErrorSessionKey is the user session variable holding the BPMError details when a try function group fails
+const ErrorSessionKey string = "BPMError"
+
 const TranCodeTestProcessMessageBus string = "IAC_TRANCODE_TEST_PROCESS"
 const TranCodeTestResultMessageBus string = "IAC_TRANCODE_TEST_RESULT"
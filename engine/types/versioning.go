@@ -0,0 +1,94 @@
+package types
+
+import (
+	"time"
+)
+
+// TranCodeRoutingPolicy routes the executions of a transaction code between its default version and a canary version.
+// Listed users and clients always get the canary version, all other executions get it with the given percentage.
+type TranCodeRoutingPolicy struct {
+	TranCodeName  string    `json:"trancodename"`
+	Enabled       bool      `json:"enabled"`
+	CanaryVersion string    `json:"canaryversion"`
+	Percentage    float64   `json:"percentage"`
+	Users         []string  `json:"users"`
+	Clients       []string  `json:"clients"`
+	ModifiedBy    string    `json:"modifiedby"`
+	ModifiedOn    time.Time `json:"modifiedon"`
+}
+
+// SelectVersion returns the canary version if the execution for the user and client is routed to it,
+// otherwise "" for the default version. sample is a random number in [0, 100).
+func (p *TranCodeRoutingPolicy) SelectVersion(user, clientid string, sample float64) string {
+	if p == nil || !p.Enabled || p.CanaryVersion == "" {
+		return ""
+	}
+	for _, u := range p.Users {
+		if u != "" && u == user {
+			return p.CanaryVersion
+		}
+	}
+	for _, c := range p.Clients {
+		if c != "" && c == clientid {
+			return p.CanaryVersion
+		}
+	}
+	if sample < p.Percentage {
+		return p.CanaryVersion
+	}
+	return ""
+}
+
+// TranCodeVersionStats holds the execution count, failures and latency of a transaction code version
+type TranCodeVersionStats struct {
+	TranCodeName  string    `json:"trancodename"`
+	Version       string    `json:"version"`
+	Node          string    `json:"node"`
+	Executions    int64     `json:"executions"`
+	Failures      int64     `json:"failures"`
+	TotalDuration int64     `json:"totalduration"` // milliseconds
+	MaxDuration   int64     `json:"maxduration"`   // milliseconds
+	LastExecuted  time.Time `json:"lastexecuted"`
+}
+
+// SuccessRate returns the share of successful executions between 0 and 1
+func (s *TranCodeVersionStats) SuccessRate() float64 {
+	if s.Executions == 0 {
+		return 0
+	}
+	return float64(s.Executions-s.Failures) / float64(s.Executions)
+}
+
+// AverageDuration returns the average execution time in milliseconds
+func (s *TranCodeVersionStats) AverageDuration() float64 {
+	if s.Executions == 0 {
+		return 0
+	}
+	return float64(s.TotalDuration) / float64(s.Executions)
+}
+
+// MergeVersionStats sums the statistics of all nodes per version, keeping the versions in the order they first appear
+func MergeVersionStats(stats []TranCodeVersionStats) []TranCodeVersionStats {
+	merged := []TranCodeVersionStats{}
+	index := map[string]int{}
+	for _, s := range stats {
+		i, ok := index[s.Version]
+		if !ok {
+			index[s.Version] = len(merged)
+			s.Node = ""
+			merged = append(merged, s)
+			continue
+		}
+		m := &merged[i]
+		m.Executions += s.Executions
+		m.Failures += s.Failures
+		m.TotalDuration += s.TotalDuration
+		if s.MaxDuration > m.MaxDuration {
+			m.MaxDuration = s.MaxDuration
+		}
+		if s.LastExecuted.After(m.LastExecuted) {
+			m.LastExecuted = s.LastExecuted
+		}
+	}
+	return merged
+}
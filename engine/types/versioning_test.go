@@ -0,0 +1,60 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestTranCodeRoutingPolicy_SelectVersion(t *testing.T) {
+	policy := &TranCodeRoutingPolicy{
+		TranCodeName:  "Order",
+		Enabled:       true,
+		CanaryVersion: "2",
+		Percentage:    10,
+		Users:         []string{"tester"},
+		Clients:       []string{"pilot"},
+	}
+
+	tests := []struct {
+		name     string
+		policy   *TranCodeRoutingPolicy
+		user     string
+		clientid string
+		sample   float64
+		want     string
+	}{
+		{"listed user", policy, "tester", "c1", 99, "2"},
+		{"listed client", policy, "u1", "pilot", 99, "2"},
+		{"sample in percentage", policy, "u1", "c1", 9.9, "2"},
+		{"sample above percentage", policy, "u1", "c1", 10, ""},
+		{"no policy", nil, "tester", "pilot", 0, ""},
+		{"disabled policy", &TranCodeRoutingPolicy{CanaryVersion: "2", Percentage: 100, Users: []string{"tester"}}, "tester", "", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.SelectVersion(tt.user, tt.clientid, tt.sample); got != tt.want {
+				t.Errorf("SelectVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeVersionStats(t *testing.T) {
+	stats := MergeVersionStats([]TranCodeVersionStats{
+		{Version: "1", Node: "a", Executions: 8, Failures: 0, TotalDuration: 80, MaxDuration: 20},
+		{Version: "2", Node: "a", Executions: 2, Failures: 1, TotalDuration: 60, MaxDuration: 50},
+		{Version: "1", Node: "b", Executions: 2, Failures: 1, TotalDuration: 40, MaxDuration: 30},
+	})
+
+	if len(stats) != 2 || stats[0].Version != "1" || stats[1].Version != "2" {
+		t.Fatalf("MergeVersionStats() = %+v, want versions 1 and 2", stats)
+	}
+	if stats[0].Executions != 10 || stats[0].MaxDuration != 30 || stats[0].Node != "" {
+		t.Errorf("merged stats of version 1 = %+v", stats[0])
+	}
+	if got := stats[0].SuccessRate(); got != 0.9 {
+		t.Errorf("SuccessRate() = %v, want 0.9", got)
+	}
+	if got := stats[0].AverageDuration(); got != 12 {
+		t.Errorf("AverageDuration() = %v, want 12", got)
+	}
+}
@@ -7,7 +7,10 @@ import (
 
 	"github.com/mdaxf/iac/config"
 	"github.com/mdaxf/iac/documents"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/trancode"
 	"github.com/mdaxf/iac/framework/cache"
+	"github.com/mdaxf/iac/framework/callback_mgr"
 	"github.com/mdaxf/iac/logger"
 	"github.com/mdaxf/iac-signalr/signalr"
 )
@@ -18,6 +21,8 @@ var (
 	GlobalJobWorker      *JobWorker
 	GlobalJobScheduler   *JobScheduler
 	GlobalJobCreator     *IntegrationJobCreator
+	GlobalOutboxRelay    *OutboxRelay
+	GlobalTimerScheduler *WorkflowTimerScheduler
 	JobSystemInitialized bool
 )
 
@@ -91,6 +96,27 @@ func InitializeJobSystem(
 	GlobalJobCreator = NewIntegrationJobCreator(db, GlobalQueueManager)
 	logger.Info("Initialized integration job creator")
 
+	// Queue failed transaction code compensations for retry
+	callback_mgr.RegisterCallBack(trancode.CompensationRetryCallback, GlobalJobCreator.CreateCompensationJob)
+
+	// Run the sub transaction codes queued after the commit of their parent
+	funcs.RegisterSubTranCodeEnqueuer(GlobalJobCreator.CreateSubTranCodeJob)
+
+	// Publish the messages of the transaction codes after their commit
+	GlobalOutboxRelay = NewOutboxRelay(db, workerID)
+	if err := GlobalOutboxRelay.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start outbox relay: %w", err)
+	}
+	funcs.RegisterOutboxWriter(GlobalOutboxRelay.CreateOutboxMessage)
+	logger.Info("Started outbox relay")
+
+	// Fire the reminders, escalations and timers of the workflows
+	GlobalTimerScheduler = NewWorkflowTimerScheduler(db, workerID)
+	if err := GlobalTimerScheduler.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start workflow timer scheduler: %w", err)
+	}
+	logger.Info("Started workflow timer scheduler")
+
 	JobSystemInitialized = true
 	logger.Info("Background job system initialized successfully")
 
@@ -125,6 +151,21 @@ func ShutdownJobSystem() error {
 		}
 	}
 
+	// Stop outbox relay
+	if GlobalOutboxRelay != nil {
+		funcs.RegisterOutboxWriter(nil)
+		if err := GlobalOutboxRelay.Stop(); err != nil {
+			logger.Error(fmt.Sprintf("Error stopping outbox relay: %v", err))
+		}
+	}
+
+	// Stop workflow timer scheduler
+	if GlobalTimerScheduler != nil {
+		if err := GlobalTimerScheduler.Stop(); err != nil {
+			logger.Error(fmt.Sprintf("Error stopping workflow timer scheduler: %v", err))
+		}
+	}
+
 	JobSystemInitialized = false
 	logger.Info("Background job system shut down successfully")
 
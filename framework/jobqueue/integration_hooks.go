@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mdaxf/iac/engine/types"
 	"github.com/mdaxf/iac/logger"
 	"github.com/mdaxf/iac/models"
 	"github.com/mdaxf/iac/services"
@@ -120,6 +122,87 @@ func (ijc *IntegrationJobCreator) CreateJobFromMessage(
 	return job, nil
 }
 
+// CreateCompensationJob creates a job that retries the failed compensations of a rolled back transaction code.
+// It is registered as the trancode compensation retry callback.
+func (ijc *IntegrationJobCreator) CreateCompensationJob(logID string, tranCode string) error {
+	ctx := context.Background()
+
+	job := &models.QueueJob{
+		TypeID:      int(models.JobTypeCompensation),
+		Method:      "compensate",
+		Protocol:    "internal",
+		Direction:   models.JobDirectionInternal,
+		Handler:     tranCode,
+		Payload:     fmt.Sprintf(`{"compensationlogid":"%s"}`, logID),
+		Metadata:    models.JobMetadata{"source": "compensation", "trancode": tranCode, "compensationlogid": logID},
+		Priority:    5,
+		MaxRetries:  3,
+		StatusID:    int(models.JobStatusPending),
+		CreatedBy:   "compensation",
+		ReferenceID: logID,
+	}
+
+	if err := ijc.jobService.CreateQueueJob(ctx, job); err != nil {
+		ijc.logger.Error(fmt.Sprintf("Failed to create compensation job for log %s: %v", logID, err))
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	if ijc.queueManager != nil {
+		if err := ijc.queueManager.EnqueueJob(ctx, job.ID, job.Priority); err != nil {
+			ijc.logger.Error(fmt.Sprintf("Failed to enqueue job %s: %v", job.ID, err))
+		}
+	}
+
+	ijc.logger.Info(fmt.Sprintf("Created compensation job %s for transaction code %s (log: %s)", job.ID, tranCode, logID))
+	return nil
+}
+
+// CreateSubTranCodeJob writes the job of a sub transaction code that runs after the commit of its parent.
+// The job is written in the transaction of the parent, so it only exists once the parent has committed. It is
+// not put into the cache queue for the same reason, the worker picks it up from the database.
+// It is registered as the sub transaction code enqueuer of the function engine.
+func (ijc *IntegrationJobCreator) CreateSubTranCodeJob(tx *sql.Tx, request *types.SubTranCodeRequest) error {
+	ctx := context.Background()
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	job := &models.QueueJob{
+		ID:        uuid.New().String(),
+		TypeID:    int(models.JobTypeSubTranCode),
+		Method:    "execute",
+		Protocol:  "internal",
+		Direction: models.JobDirectionInternal,
+		Handler:   request.TranCodeName,
+		Payload:   string(payload),
+		Metadata: models.JobMetadata{
+			"source":            "subtrancode",
+			"trancode":          request.TranCodeName,
+			"executionid":       request.ExecutionID,
+			"correlationid":     request.CorrelationID,
+			"parentexecutionid": request.ParentExecutionID,
+			"parenttrancode":    request.ParentTranCode,
+		},
+		Priority:    request.Priority,
+		MaxRetries:  request.MaxRetries,
+		StatusID:    int(models.JobStatusPending),
+		CreatedBy:   "subtrancode",
+		ReferenceID: request.ExecutionID,
+	}
+	request.JobID = job.ID
+
+	if err := ijc.jobService.CreateQueueJobTx(ctx, tx, job); err != nil {
+		ijc.logger.Error(fmt.Sprintf("Failed to create the job of sub transaction code %s: %v", request.TranCodeName, err))
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	ijc.logger.Info(fmt.Sprintf("Created sub transaction code job %s for transaction code %s (execution: %s, parent: %s)",
+		job.ID, request.TranCodeName, request.ExecutionID, request.ParentExecutionID))
+	return nil
+}
+
 // CreateInboundJob creates a job for inbound integration messages
 func (ijc *IntegrationJobCreator) CreateInboundJob(
 	ctx context.Context,
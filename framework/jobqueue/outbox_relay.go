@@ -0,0 +1,204 @@
+package jobqueue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/config"
+	funcs "github.com/mdaxf/iac/engine/function"
+	"github.com/mdaxf/iac/engine/types"
+	"github.com/mdaxf/iac/logger"
+	"github.com/mdaxf/iac/models"
+	"github.com/mdaxf/iac/services"
+)
+
+const (
+	outboxBatchSize      = 100
+	outboxLockDuration   = 2 * time.Minute
+	outboxPublishTimeout = 30 * time.Second
+)
+
+// OutboxRelay publishes the messages of the transactional outbox once the transaction codes that wrote them have
+// committed. Messages with the same key on the same channel and topic are published in the order they were written:
+// while a message waits for its retry the later messages of its key wait too. A message that fails its last attempt
+// is dead lettered and no longer holds up its key.
+type OutboxRelay struct {
+	outboxService *services.OutboxService
+	instanceID    string
+	logger        logger.Log
+	pollInterval  time.Duration
+	running       bool
+	mu            sync.RWMutex
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+// NewOutboxRelay creates a new outbox relay
+func NewOutboxRelay(db *sql.DB, instanceID string) *OutboxRelay {
+	pollInterval := time.Duration(config.GlobalConfiguration.JobsConfig.OutboxPollInterval) * time.Second
+	if pollInterval == 0 {
+		pollInterval = 2 * time.Second
+	}
+
+	return &OutboxRelay{
+		outboxService: services.NewOutboxService(db),
+		instanceID:    instanceID,
+		logger:        logger.Log{ModuleName: logger.Framework, User: "System", ControllerName: "OutboxRelay"},
+		pollInterval:  pollInterval,
+	}
+}
+
+// Start starts the outbox relay
+func (or *OutboxRelay) Start(ctx context.Context) error {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	if or.running {
+		return fmt.Errorf("outbox relay already running")
+	}
+
+	or.ctx, or.cancel = context.WithCancel(ctx)
+	or.running = true
+
+	go or.run()
+
+	or.logger.Info(fmt.Sprintf("Outbox relay %s started", or.instanceID))
+	return nil
+}
+
+// Stop stops the outbox relay
+func (or *OutboxRelay) Stop() error {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	if !or.running {
+		return fmt.Errorf("outbox relay not running")
+	}
+
+	or.cancel()
+	or.running = false
+	or.logger.Info(fmt.Sprintf("Outbox relay %s stopped", or.instanceID))
+	return nil
+}
+
+// CreateOutboxMessage writes the message of a transaction code to the outbox in its transaction.
+// It is registered as the outbox writer of the function engine.
+func (or *OutboxRelay) CreateOutboxMessage(tx *sql.Tx, message *types.OutboundMessage) error {
+	outboxMessage := &models.OutboxMessage{
+		MessageID:     message.MessageID,
+		Channel:       message.Channel,
+		Topic:         message.Topic,
+		Server:        message.Server,
+		MessageKey:    message.Key,
+		Payload:       message.Payload,
+		MaxAttempts:   message.MaxAttempts,
+		ExecutionID:   message.ExecutionID,
+		CorrelationID: message.CorrelationID,
+		TranCode:      message.TranCodeName,
+		CreatedBy:     message.CreatedBy,
+	}
+
+	return or.outboxService.CreateOutboxMessageTx(context.Background(), tx, outboxMessage)
+}
+
+// run is the main relay loop
+func (or *OutboxRelay) run() {
+	ticker := time.NewTicker(or.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-or.ctx.Done():
+			return
+
+		case <-ticker.C:
+			or.relayPendingMessages()
+		}
+	}
+}
+
+// relayPendingMessages publishes the pending messages that are due, in the order of their keys
+func (or *OutboxRelay) relayPendingMessages() {
+	messages, err := or.outboxService.GetPendingOutboxMessages(or.ctx, outboxBatchSize)
+	if err != nil {
+		or.logger.Error(fmt.Sprintf("Failed to get pending outbox messages: %v", err))
+		return
+	}
+
+	held := map[string]bool{}
+	for _, message := range selectDueOutboxMessages(messages, time.Now()) {
+		if or.ctx.Err() != nil {
+			return
+		}
+
+		key := message.OrderingKey()
+		if key != "" && held[key] {
+			continue
+		}
+		if !or.relayMessage(message) && key != "" {
+			held[key] = true
+		}
+	}
+}
+
+// relayMessage publishes one message and records the outcome. It reports whether the later messages of the key
+// can follow, which is the case once the message is published or dead lettered.
+func (or *OutboxRelay) relayMessage(message *models.OutboxMessage) bool {
+	claimed, err := or.outboxService.ClaimOutboxMessage(or.ctx, message.Sequence, or.instanceID, time.Now().Add(outboxLockDuration))
+	if err != nil || !claimed {
+		return false
+	}
+
+	return runRetryAttempt(or.logger, retryAttempt{
+		name:        fmt.Sprintf("outbox message %s to %s topic %s", message.MessageID, message.Channel, message.Topic),
+		attempts:    message.Attempts,
+		maxAttempts: message.MaxAttempts,
+		run: func() error {
+			ctx, cancel := context.WithTimeout(or.ctx, outboxPublishTimeout)
+			defer cancel()
+
+			return funcs.PublishMessage(ctx, &types.OutboundMessage{
+				MessageID: message.MessageID,
+				Channel:   message.Channel,
+				Topic:     message.Topic,
+				Server:    message.Server,
+				Key:       message.MessageKey,
+				Payload:   message.Payload,
+			})
+		},
+		succeeded: func() error {
+			return or.outboxService.MarkOutboxMessagePublished(or.ctx, message.Sequence)
+		},
+		failed: func(nextAttemptAt time.Time, lastError string, deadLetter bool) error {
+			return or.outboxService.MarkOutboxMessageFailed(or.ctx, message.Sequence, nextAttemptAt, lastError, deadLetter)
+		},
+	})
+}
+
+// selectDueOutboxMessages returns the messages to publish now, in their write order. A message of a key that is not
+// due or is locked by another relay holds up the later messages of its key.
+func selectDueOutboxMessages(messages []*models.OutboxMessage, now time.Time) []*models.OutboxMessage {
+	held := map[string]bool{}
+	due := []*models.OutboxMessage{}
+
+	for _, message := range messages {
+		key := message.OrderingKey()
+		if key != "" && held[key] {
+			continue
+		}
+
+		locked := message.LockedUntil != nil && message.LockedUntil.After(now)
+		if message.NextAttemptAt.After(now) || locked {
+			if key != "" {
+				held[key] = true
+			}
+			continue
+		}
+		due = append(due, message)
+	}
+
+	return due
+}
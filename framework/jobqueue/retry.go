@@ -0,0 +1,66 @@
+package jobqueue
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mdaxf/iac/logger"
+)
+
+const retryMaxBackoff = 10 * time.Minute
+
+// retryAttempt is one attempt on an item the outbox relay or the workflow timer scheduler has claimed
+type retryAttempt struct {
+	name        string // the item in the log, like outbox message <id>
+	attempts    int    // the attempts made before this one
+	maxAttempts int    // 0 retries the item until it succeeds
+	run         func() error
+	succeeded   func() error
+	failed      func(nextAttemptAt time.Time, lastError string, final bool) error
+}
+
+// runRetryAttempt runs the attempt and records its outcome. A failed attempt is retried after a backoff that doubles
+// with every attempt, the last attempt fails the item for good. It reports whether the item is done, which is the case
+// once it succeeded or failed its last attempt.
+func runRetryAttempt(log logger.Log, attempt retryAttempt) bool {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%s panicked: %v", attempt.name, r)
+			}
+		}()
+		return attempt.run()
+	}()
+
+	if err == nil {
+		if err := attempt.succeeded(); err != nil {
+			log.Error(fmt.Sprintf("Processed %s but failed to mark it: %v", attempt.name, err))
+		}
+		log.Debug(fmt.Sprintf("Processed %s", attempt.name))
+		return true
+	}
+
+	attempts := attempt.attempts + 1
+	final := attempt.maxAttempts > 0 && attempts >= attempt.maxAttempts
+	nextAttemptAt := time.Now().Add(retryBackoff(attempts))
+	if final {
+		log.Error(fmt.Sprintf("Gave up on %s after %d attempts: %v", attempt.name, attempts, err))
+	} else {
+		log.Info(fmt.Sprintf("Failed to process %s (attempt %d), retry at %v: %v", attempt.name, attempts, nextAttemptAt, err))
+	}
+
+	if err := attempt.failed(nextAttemptAt, err.Error(), final); err != nil {
+		log.Error(fmt.Sprintf("Failed to record the attempt of %s: %v", attempt.name, err))
+	}
+	return final
+}
+
+// retryBackoff returns the wait before the next attempt, doubling with every attempt up to the maximum
+func retryBackoff(attempts int) time.Duration {
+	backoff := time.Duration(math.Pow(2, float64(attempts))) * time.Second
+	if backoff <= 0 || backoff > retryMaxBackoff {
+		return retryMaxBackoff
+	}
+	return backoff
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/mdaxf/iac/config"
 	"github.com/mdaxf/iac/documents"
 	"github.com/mdaxf/iac/engine/trancode"
+	"github.com/mdaxf/iac/engine/types"
 	"github.com/mdaxf/iac/logger"
 	"github.com/mdaxf/iac-signalr/signalr"
 	"github.com/mdaxf/iac/models"
@@ -309,6 +310,32 @@ func (jw *JobWorker) executeJob(ctx context.Context, worker *Worker, job *models
 
 // executeJobHandler executes the job handler (transaction code or command)
 func (jw *JobWorker) executeJobHandler(ctx context.Context, job *models.QueueJob) (string, error) {
+	// Compensation jobs retry the failed compensations of a rolled back transaction code
+	if job.TypeID == int(models.JobTypeCompensation) {
+		if err := trancode.RetryCompensation(job.ReferenceID, jw.docDB, jw.signalRClient); err != nil {
+			return "", fmt.Errorf("compensation failed: %w", err)
+		}
+		return "", nil
+	}
+
+	// Sub transaction code jobs run the child of a committed parent in its own transaction
+	if job.TypeID == int(models.JobTypeSubTranCode) {
+		var request types.SubTranCodeRequest
+		if err := json.Unmarshal([]byte(job.Payload), &request); err != nil {
+			return "", fmt.Errorf("invalid sub transaction code payload: %w", err)
+		}
+		request.JobID = job.ID
+		outputs, err := trancode.ExecuteChildTranCode(ctx, &request, jw.signalRClient, jw.docDB)
+		if err != nil {
+			return "", fmt.Errorf("sub transaction code failed: %w", err)
+		}
+		outputJSON, err := json.Marshal(outputs)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal outputs: %w", err)
+		}
+		return string(outputJSON), nil
+	}
+
 	// Parse payload
 	var payloadData map[string]interface{}
 	if job.Payload != "" {
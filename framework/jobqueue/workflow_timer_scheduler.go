@@ -0,0 +1,166 @@
+package jobqueue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mdaxf/iac/config"
+	"github.com/mdaxf/iac/logger"
+	"github.com/mdaxf/iac/models"
+	"github.com/mdaxf/iac/services"
+	"github.com/mdaxf/iac/workflow"
+)
+
+const (
+	workflowTimerBatchSize   = 100
+	workflowTimerLockTime    = 5 * time.Minute
+	workflowTimerMaxAttempts = 5
+)
+
+// WorkflowTimerScheduler fires the reminders, escalations, timer nodes and timer starts of the workflows at their
+// due time. A timer is claimed in the database before it fires, so it fires on one instance only.
+type WorkflowTimerScheduler struct {
+	timerService  *services.WorkflowTimerService
+	instanceID    string
+	logger        logger.Log
+	pollInterval  time.Duration
+	startInterval time.Duration
+	running       bool
+	mu            sync.RWMutex
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+// NewWorkflowTimerScheduler creates a new workflow timer scheduler
+func NewWorkflowTimerScheduler(db *sql.DB, instanceID string) *WorkflowTimerScheduler {
+	pollInterval := time.Duration(config.GlobalConfiguration.JobsConfig.WorkflowTimerInterval) * time.Second
+	if pollInterval == 0 {
+		pollInterval = 10 * time.Second
+	}
+
+	return &WorkflowTimerScheduler{
+		timerService:  services.NewWorkflowTimerService(db),
+		instanceID:    instanceID,
+		logger:        logger.Log{ModuleName: logger.Framework, User: "System", ControllerName: "WorkflowTimerScheduler"},
+		pollInterval:  pollInterval,
+		startInterval: time.Duration(config.GlobalConfiguration.JobsConfig.SchedulerCheckInterval) * time.Second,
+	}
+}
+
+// Start starts the workflow timer scheduler
+func (wts *WorkflowTimerScheduler) Start(ctx context.Context) error {
+	wts.mu.Lock()
+	defer wts.mu.Unlock()
+
+	if wts.running {
+		return fmt.Errorf("workflow timer scheduler already running")
+	}
+
+	wts.ctx, wts.cancel = context.WithCancel(ctx)
+	wts.running = true
+
+	go wts.run()
+
+	wts.logger.Info(fmt.Sprintf("Workflow timer scheduler %s started", wts.instanceID))
+	return nil
+}
+
+// Stop stops the workflow timer scheduler
+func (wts *WorkflowTimerScheduler) Stop() error {
+	wts.mu.Lock()
+	defer wts.mu.Unlock()
+
+	if !wts.running {
+		return fmt.Errorf("workflow timer scheduler not running")
+	}
+
+	wts.cancel()
+	wts.running = false
+	wts.logger.Info(fmt.Sprintf("Workflow timer scheduler %s stopped", wts.instanceID))
+	return nil
+}
+
+// run is the main scheduler loop
+func (wts *WorkflowTimerScheduler) run() {
+	if wts.startInterval <= 0 {
+		wts.startInterval = time.Minute
+	}
+
+	ticker := time.NewTicker(wts.pollInterval)
+	defer ticker.Stop()
+	startTicker := time.NewTicker(wts.startInterval)
+	defer startTicker.Stop()
+
+	wts.scheduleTimerStarts()
+
+	for {
+		select {
+		case <-wts.ctx.Done():
+			return
+
+		case <-ticker.C:
+			wts.fireDueTimers()
+
+		case <-startTicker.C:
+			wts.scheduleTimerStarts()
+		}
+	}
+}
+
+// scheduleTimerStarts schedules the next occurrence of the workflows with a timer start node
+func (wts *WorkflowTimerScheduler) scheduleTimerStarts() {
+	workflows, err := workflow.GetTimerStartWorkFlows("System")
+	if err != nil {
+		wts.logger.Error(fmt.Sprintf("Failed to get the workflows with a timer start: %v", err))
+		return
+	}
+
+	for _, wf := range workflows {
+		if err := workflow.ScheduleTimerStart(wf, time.Now().UTC(), "System"); err != nil {
+			// another instance may have scheduled the same occurrence
+			wts.logger.Debug(fmt.Sprintf("Failed to schedule the timer start of workflow %s: %v", wf.Name, err))
+		}
+	}
+}
+
+// fireDueTimers fires the timers that are due
+func (wts *WorkflowTimerScheduler) fireDueTimers() {
+	timers, err := wts.timerService.GetDueWorkflowTimers(wts.ctx, time.Now().UTC(), workflowTimerBatchSize)
+	if err != nil {
+		wts.logger.Error(fmt.Sprintf("Failed to get due workflow timers: %v", err))
+		return
+	}
+
+	for _, timer := range timers {
+		if wts.ctx.Err() != nil {
+			return
+		}
+		wts.fireTimer(timer)
+	}
+}
+
+// fireTimer claims and fires one timer and records the outcome
+func (wts *WorkflowTimerScheduler) fireTimer(timer *models.WorkflowTimer) {
+	claimed, err := wts.timerService.ClaimWorkflowTimer(wts.ctx, timer.ID, wts.instanceID, time.Now().UTC().Add(workflowTimerLockTime))
+	if err != nil || !claimed {
+		return
+	}
+
+	runRetryAttempt(wts.logger, retryAttempt{
+		name:        fmt.Sprintf("%s workflow timer %s", timer.TimerKind, timer.TimerKey),
+		attempts:    timer.Attempts,
+		maxAttempts: workflowTimerMaxAttempts,
+		run: func() error {
+			return workflow.FireTimer(timer, "System")
+		},
+		succeeded: func() error {
+			return wts.timerService.MarkWorkflowTimerFired(wts.ctx, timer.ID)
+		},
+		failed: func(nextAttemptAt time.Time, lastError string, failed bool) error {
+			return wts.timerService.MarkWorkflowTimerFailed(wts.ctx, timer.ID, nextAttemptAt.UTC(), lastError, failed)
+		},
+	})
+}
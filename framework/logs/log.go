@@ -316,13 +316,14 @@ func (bl *IACLogger) Write(p []byte) (n int, err error) {
 }
 
 func (bl *IACLogger) writeMsg(lm *LogMsg) error {
-	if !bl.init {
-		bl.lock.Lock()
+	// a logger without adapters writes to the console until SetLogger sets its adapters, under the lock for parallel writers
+	bl.lock.Lock()
+	if !bl.init && len(bl.outputs) == 0 {
 		bl.setLogger(AdapterConsole)
-		bl.lock.Unlock()
 		bl.Perf = true
 		bl.Threhold = 10
 	}
+	bl.lock.Unlock()
 
 	var (
 		file string
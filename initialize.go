@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strings"
 	"time"
 
@@ -41,8 +42,11 @@ import (
 
 	"github.com/mdaxf/iac/logger"
 
+	funcs "github.com/mdaxf/iac/engine/function"
 	"github.com/mdaxf/iac/engine/trancode"
+	"github.com/mdaxf/iac/engine/types"
 	"github.com/mdaxf/iac/framework/callback_mgr"
+	"github.com/mdaxf/iac/workflow"
 
 	"github.com/mdaxf/iac/integration/activemq"
 	"github.com/mdaxf/iac/integration/kafka"
@@ -90,6 +94,12 @@ func initialize() {
 
 	initializedDocuments()
 
+	initializeHTTPProfiles()
+
+	initializePythonWorkers()
+
+	initializeWorkflow()
+
 	//	initializeIACMessageBus()
 	wg.Add(1)
 	go func() {
@@ -449,6 +459,8 @@ func initializecache() {
 	}
 	config.ObjectCache = config.SessionCache
 	config.TestSessionCache = config.SessionCache
+
+	trancode.InitTranCodeCache(config.ObjectCache, time.Duration(config.ObjectCacheTimeout)*time.Second)
 }
 
 // initializeloger initializes the logger based on the global configuration.
@@ -850,3 +862,69 @@ func initializeActiveMQConnection() {
 	}()
 
 }
+
+// HTTPProfilesConfig is the configuration file "httpprofiles.json" of the connection profiles of the HTTP functions
+type HTTPProfilesConfig struct {
+	Profiles []types.HTTPProfile `json:"profiles"`
+}
+
+// initializeHTTPProfiles registers the connection profiles of the HTTP functions configured in "httpprofiles.json".
+// The secrets of the profiles can refer to environment variables, like "clientsecret": "${ERP_CLIENT_SECRET}".
+func initializeHTTPProfiles() {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		ilog.PerformanceWithDuration("main.initializeHTTPProfiles", elapsed)
+	}()
+
+	data, err := ioutil.ReadFile("httpprofiles.json")
+	if err != nil {
+		ilog.Debug(fmt.Sprintf("no HTTP profiles configured: %v", err))
+		return
+	}
+
+	var profilesConfig HTTPProfilesConfig
+	if err := json.Unmarshal(data, &profilesConfig); err != nil {
+		ilog.Error(fmt.Sprintf("failed to unmarshal the configuration file httpprofiles.json: %v", err))
+		return
+	}
+
+	for _, profile := range profilesConfig.Profiles {
+		profile.Auth.APIKey = os.ExpandEnv(profile.Auth.APIKey)
+		profile.Auth.Password = os.ExpandEnv(profile.Auth.Password)
+		profile.Auth.ClientSecret = os.ExpandEnv(profile.Auth.ClientSecret)
+		if err := funcs.RegisterHTTPProfile(profile); err != nil {
+			ilog.Error(fmt.Sprintf("failed to register the HTTP profile %s: %v", profile.Name, err))
+			continue
+		}
+		ilog.Debug(fmt.Sprintf("registered the HTTP profile %s with base URL %s", profile.Name, profile.BaseURL))
+	}
+}
+
+// initializeWorkflow applies the "workflow" configuration, the business calendar of the due dates
+// and the roles that administer workflow entities
+func initializeWorkflow() {
+	workflowConfig := config.GlobalConfiguration.WorkflowConfig
+	workflow.ConfigureBusinessCalendar(workflowConfig.WorkDays, workflowConfig.Holidays)
+	workflow.ConfigureAdminRoles(workflowConfig.AdminRoles)
+}
+
+// initializePythonWorkers applies the "pythonworkers" configuration to the Python functions
+// and starts their workers in the background
+func initializePythonWorkers() {
+	pythonConfig := config.GlobalConfiguration.PythonWorkers
+	funcs.ConfigurePythonWorkers(funcs.PythonWorkerSettings{
+		PythonPath:     pythonConfig.PythonPath,
+		PoolSize:       pythonConfig.PoolSize,
+		MaxCalls:       pythonConfig.MaxCalls,
+		AllowedModules: pythonConfig.AllowedModules,
+	})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := funcs.WarmPythonWorkers(); err != nil {
+			ilog.Warn(fmt.Sprintf("failed to start the Python workers: %v", err))
+		}
+	}()
+}
@@ -100,10 +100,8 @@ package opcclient
 import (
 	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
@@ -112,6 +110,7 @@ import (
 	"github.com/gopcua/opcua"
 	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
+	funcs "github.com/mdaxf/iac/engine/function"
 	"github.com/mdaxf/iac/logger"
 )
 
@@ -184,6 +183,9 @@ type OPCClient struct {
 	cancel       context.CancelFunc
 }
 
+// the OPC function types of the transaction codes run on the client
+var _ funcs.OPCConnection = (*OPCClient)(nil)
+
 type SubGroup struct {
 	TriggerTags []string                    `json:"triggerTags"`
 	ReportTags  []string                    `json:"reportTags"`
@@ -200,6 +202,7 @@ func Initialize(configurations OPCClient) error {
 	// Create OPC client instance
 	opcclient := &OPCClient{
 		Endpoint:     configurations.Endpoint,
+		Name:         configurations.Name,
 		Namespace:    configurations.Namespace,
 		CertFile:     configurations.CertFile,
 		KeyFile:      configurations.KeyFile,
@@ -223,6 +226,10 @@ func Initialize(configurations OPCClient) error {
 		return err
 	}
 
+	// make the client available to the OPC function types of the transaction codes
+	funcs.RegisterOPCConnection(opcclient.Name, opcclient)
+	defer funcs.UnregisterOPCConnection(opcclient.Name)
+
 	// Create subscriptions for configured subgroups
 	for _, subgroup := range opcclient.SubGroups {
 		callbackfunc := func(tag string, v *ua.DataValue) {
@@ -731,6 +738,43 @@ func (c *OPCClient) BatchRead(nodeIDs []string) (map[string]interface{}, error)
 	return results, nil
 }
 
+// ReadValues reads multiple nodes in a single request and returns the data values in the order of the node IDs,
+// including the status code and the timestamps of each value
+func (c *OPCClient) ReadValues(nodeIDs []string) ([]*ua.DataValue, error) {
+	c.iLog.Debug(fmt.Sprintf("Reading %d node data values", len(nodeIDs)))
+
+	if len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("no node IDs provided")
+	}
+
+	nodesToRead := make([]*ua.ReadValueID, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		id, err := ua.ParseNodeID(nodeID)
+		if err != nil {
+			c.iLog.Error(fmt.Sprintf("Invalid node ID %s: %v", nodeID, err))
+			return nil, err
+		}
+		nodesToRead[i] = &ua.ReadValueID{NodeID: id}
+	}
+
+	req := &ua.ReadRequest{
+		MaxAge:             2000,
+		NodesToRead:        nodesToRead,
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+	}
+
+	resp, err := c.retryableRead(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Results) != len(nodeIDs) {
+		return nil, fmt.Errorf("read returned %d results for %d nodes", len(resp.Results), len(nodeIDs))
+	}
+
+	return resp.Results, nil
+}
+
 // BatchWrite writes multiple node values in a single request
 func (c *OPCClient) BatchWrite(nodeValues map[string]interface{}) (map[string]ua.StatusCode, error) {
 	c.iLog.Debug(fmt.Sprintf("Batch writing %d nodes", len(nodeValues)))
@@ -814,29 +858,24 @@ func (c *OPCClient) CallMethod(objectID, methodID string, inputArgs ...interface
 		inputVariants[i] = v
 	}
 
-	req := &ua.CallRequest{
-		MethodsToCall: []*ua.CallMethodRequest{
-			{
-				ObjectID:       objID,
-				MethodID:       methID,
-				InputArguments: inputVariants,
-			},
-		},
+	req := &ua.CallMethodRequest{
+		ObjectID:       objID,
+		MethodID:       methID,
+		InputArguments: inputVariants,
 	}
 
-	resp, err := c.Client.Call(c.ctx, req)
+	result, err := c.Client.Call(c.ctx, req)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("Method call failed: %v", err))
 		return nil, err
 	}
 
-	if len(resp.Results) == 0 {
+	if result == nil {
 		return nil, fmt.Errorf("no results returned from method call")
 	}
 
-	result := resp.Results[0]
 	if result.StatusCode != ua.StatusOK {
-		err := fmt.Errorf("method call failed with status: %v", result.StatusCode)
+		err := fmt.Errorf("method call failed with status: %w", result.StatusCode)
 		c.iLog.Error(err.Error())
 		return nil, err
 	}
@@ -906,23 +945,15 @@ func (c *OPCClient) ReadHistory(nodeID string, startTime, endTime time.Time, max
 		return nil, err
 	}
 
-	req := &ua.HistoryReadRequest{
-		TimestampsToReturn: ua.TimestampsToReturnBoth,
-		HistoryReadDetails: &ua.ReadRawModifiedDetails{
-			IsReadModified:   false,
-			StartTime:        startTime,
-			EndTime:          endTime,
-			NumValuesPerNode: maxValues,
-			ReturnBounds:     true,
-		},
-		NodesToRead: []*ua.HistoryReadValueID{
-			{
-				NodeID: id,
-			},
-		},
+	details := &ua.ReadRawModifiedDetails{
+		IsReadModified:   false,
+		StartTime:        startTime,
+		EndTime:          endTime,
+		NumValuesPerNode: maxValues,
+		ReturnBounds:     true,
 	}
 
-	resp, err := c.Client.HistoryRead(c.ctx, req)
+	resp, err := c.Client.HistoryReadRawModified(c.ctx, []*ua.HistoryReadValueID{{NodeID: id}}, details)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History read failed: %v", err))
 		return nil, err
@@ -940,7 +971,7 @@ func (c *OPCClient) ReadHistory(nodeID string, startTime, endTime time.Time, max
 	}
 
 	// Extract data values from history data
-	historyData, ok := result.HistoryData.(*ua.HistoryData)
+	historyData, ok := historyDataValue(result).(*ua.HistoryData)
 	if !ok {
 		return nil, fmt.Errorf("unexpected history data type")
 	}
@@ -959,23 +990,15 @@ func (c *OPCClient) ReadHistoryModified(nodeID string, startTime, endTime time.T
 		return nil, err
 	}
 
-	req := &ua.HistoryReadRequest{
-		TimestampsToReturn: ua.TimestampsToReturnBoth,
-		HistoryReadDetails: &ua.ReadRawModifiedDetails{
-			IsReadModified:   true, // Read modified values
-			StartTime:        startTime,
-			EndTime:          endTime,
-			NumValuesPerNode: maxValues,
-			ReturnBounds:     true,
-		},
-		NodesToRead: []*ua.HistoryReadValueID{
-			{
-				NodeID: id,
-			},
-		},
+	details := &ua.ReadRawModifiedDetails{
+		IsReadModified:   true, // Read modified values
+		StartTime:        startTime,
+		EndTime:          endTime,
+		NumValuesPerNode: maxValues,
+		ReturnBounds:     true,
 	}
 
-	resp, err := c.Client.HistoryRead(c.ctx, req)
+	resp, err := c.Client.HistoryReadRawModified(c.ctx, []*ua.HistoryReadValueID{{NodeID: id}}, details)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History read modified failed: %v", err))
 		return nil, err
@@ -992,7 +1015,7 @@ func (c *OPCClient) ReadHistoryModified(nodeID string, startTime, endTime time.T
 		return nil, err
 	}
 
-	historyData, ok := result.HistoryData.(*ua.HistoryData)
+	historyData, ok := historyDataValue(result).(*ua.HistoryData)
 	if !ok {
 		return nil, fmt.Errorf("unexpected history data type")
 	}
@@ -1014,22 +1037,14 @@ func (c *OPCClient) ReadHistoryProcessed(nodeID string, startTime, endTime time.
 	// Create aggregate node ID
 	aggregateNodeID := ua.NewNumericNodeID(0, uint32(aggregateType))
 
-	req := &ua.HistoryReadRequest{
-		TimestampsToReturn: ua.TimestampsToReturnBoth,
-		HistoryReadDetails: &ua.ReadProcessedDetails{
-			StartTime:          startTime,
-			EndTime:            endTime,
-			ProcessingInterval: float64(processingInterval.Milliseconds()),
-			AggregateType:      []*ua.NodeID{aggregateNodeID},
-		},
-		NodesToRead: []*ua.HistoryReadValueID{
-			{
-				NodeID: id,
-			},
-		},
+	details := &ua.ReadProcessedDetails{
+		StartTime:          startTime,
+		EndTime:            endTime,
+		ProcessingInterval: float64(processingInterval.Milliseconds()),
+		AggregateType:      []*ua.NodeID{aggregateNodeID},
 	}
 
-	resp, err := c.Client.HistoryRead(c.ctx, req)
+	resp, err := c.Client.HistoryReadProcessed(c.ctx, []*ua.HistoryReadValueID{{NodeID: id}}, details)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History read processed failed: %v", err))
 		return nil, err
@@ -1046,7 +1061,7 @@ func (c *OPCClient) ReadHistoryProcessed(nodeID string, startTime, endTime time.
 		return nil, err
 	}
 
-	historyData, ok := result.HistoryData.(*ua.HistoryData)
+	historyData, ok := historyDataValue(result).(*ua.HistoryData)
 	if !ok {
 		return nil, fmt.Errorf("unexpected history data type")
 	}
@@ -1065,20 +1080,12 @@ func (c *OPCClient) ReadHistoryAtTime(nodeID string, timestamps []time.Time, use
 		return nil, err
 	}
 
-	req := &ua.HistoryReadRequest{
-		TimestampsToReturn: ua.TimestampsToReturnBoth,
-		HistoryReadDetails: &ua.ReadAtTimeDetails{
-			ReqTimes:        timestamps,
-			UseSimpleBounds: useSimpleBounds,
-		},
-		NodesToRead: []*ua.HistoryReadValueID{
-			{
-				NodeID: id,
-			},
-		},
+	details := &ua.ReadAtTimeDetails{
+		ReqTimes:        timestamps,
+		UseSimpleBounds: useSimpleBounds,
 	}
 
-	resp, err := c.Client.HistoryRead(c.ctx, req)
+	resp, err := c.Client.HistoryReadAtTime(c.ctx, []*ua.HistoryReadValueID{{NodeID: id}}, details)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History read at time failed: %v", err))
 		return nil, err
@@ -1095,7 +1102,7 @@ func (c *OPCClient) ReadHistoryAtTime(nodeID string, timestamps []time.Time, use
 		return nil, err
 	}
 
-	historyData, ok := result.HistoryData.(*ua.HistoryData)
+	historyData, ok := historyDataValue(result).(*ua.HistoryData)
 	if !ok {
 		return nil, fmt.Errorf("unexpected history data type")
 	}
@@ -1119,22 +1126,14 @@ func (c *OPCClient) ReadHistoryEvents(nodeID string, startTime, endTime time.Tim
 		eventFilter = createDefaultEventFilter()
 	}
 
-	req := &ua.HistoryReadRequest{
-		TimestampsToReturn: ua.TimestampsToReturnBoth,
-		HistoryReadDetails: &ua.ReadEventDetails{
-			StartTime:        startTime,
-			EndTime:          endTime,
-			NumValuesPerNode: maxValues,
-			Filter:           eventFilter,
-		},
-		NodesToRead: []*ua.HistoryReadValueID{
-			{
-				NodeID: id,
-			},
-		},
+	details := &ua.ReadEventDetails{
+		StartTime:        startTime,
+		EndTime:          endTime,
+		NumValuesPerNode: maxValues,
+		Filter:           eventFilter,
 	}
 
-	resp, err := c.Client.HistoryRead(c.ctx, req)
+	resp, err := c.Client.HistoryReadEvent(c.ctx, []*ua.HistoryReadValueID{{NodeID: id}}, details)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History read events failed: %v", err))
 		return nil, err
@@ -1151,7 +1150,7 @@ func (c *OPCClient) ReadHistoryEvents(nodeID string, startTime, endTime time.Tim
 		return nil, err
 	}
 
-	historyEvent, ok := result.HistoryData.(*ua.HistoryEvent)
+	historyEvent, ok := historyDataValue(result).(*ua.HistoryEvent)
 	if !ok {
 		return nil, fmt.Errorf("unexpected history data type, expected HistoryEvent")
 	}
@@ -1172,21 +1171,15 @@ func (c *OPCClient) WriteHistory(nodeID string, dataValues []*ua.DataValue) ([]u
 
 	req := &ua.HistoryUpdateRequest{
 		HistoryUpdateDetails: []*ua.ExtensionObject{
-			{
-				EncodingMask: ua.ExtensionObjectBinary,
-				TypeID: &ua.ExpandedNodeID{
-					NodeID: ua.NewNumericNodeID(0, id.UpdateDataDetails_Encoding_DefaultBinary),
-				},
-				Value: &ua.UpdateDataDetails{
-					NodeID:               id,
-					PerformInsertReplace: ua.PerformUpdateTypeInsert,
-					UpdateValues:         dataValues,
-				},
-			},
+			ua.NewExtensionObject(&ua.UpdateDataDetails{
+				NodeID:               id,
+				PerformInsertReplace: ua.PerformUpdateTypeInsert,
+				UpdateValues:         dataValues,
+			}),
 		},
 	}
 
-	resp, err := c.Client.HistoryUpdate(c.ctx, req)
+	resp, err := c.historyUpdate(req)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History write failed: %v", err))
 		return nil, err
@@ -1196,11 +1189,7 @@ func (c *OPCClient) WriteHistory(nodeID string, dataValues []*ua.DataValue) ([]u
 		return nil, fmt.Errorf("no results returned from history write")
 	}
 
-	result := resp.Results[0]
-	updateResult, ok := result.(*ua.HistoryUpdateResult)
-	if !ok {
-		return nil, fmt.Errorf("unexpected result type")
-	}
+	updateResult := resp.Results[0]
 
 	c.iLog.Debug(fmt.Sprintf("History write completed with status: %v", updateResult.StatusCode))
 	return updateResult.OperationResults, nil
@@ -1218,21 +1207,15 @@ func (c *OPCClient) UpdateHistory(nodeID string, dataValues []*ua.DataValue) ([]
 
 	req := &ua.HistoryUpdateRequest{
 		HistoryUpdateDetails: []*ua.ExtensionObject{
-			{
-				EncodingMask: ua.ExtensionObjectBinary,
-				TypeID: &ua.ExpandedNodeID{
-					NodeID: ua.NewNumericNodeID(0, id.UpdateDataDetails_Encoding_DefaultBinary),
-				},
-				Value: &ua.UpdateDataDetails{
-					NodeID:               id,
-					PerformInsertReplace: ua.PerformUpdateTypeReplace,
-					UpdateValues:         dataValues,
-				},
-			},
+			ua.NewExtensionObject(&ua.UpdateDataDetails{
+				NodeID:               id,
+				PerformInsertReplace: ua.PerformUpdateTypeReplace,
+				UpdateValues:         dataValues,
+			}),
 		},
 	}
 
-	resp, err := c.Client.HistoryUpdate(c.ctx, req)
+	resp, err := c.historyUpdate(req)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History update failed: %v", err))
 		return nil, err
@@ -1242,11 +1225,7 @@ func (c *OPCClient) UpdateHistory(nodeID string, dataValues []*ua.DataValue) ([]
 		return nil, fmt.Errorf("no results returned from history update")
 	}
 
-	result := resp.Results[0]
-	updateResult, ok := result.(*ua.HistoryUpdateResult)
-	if !ok {
-		return nil, fmt.Errorf("unexpected result type")
-	}
+	updateResult := resp.Results[0]
 
 	c.iLog.Debug(fmt.Sprintf("History update completed with status: %v", updateResult.StatusCode))
 	return updateResult.OperationResults, nil
@@ -1264,22 +1243,16 @@ func (c *OPCClient) DeleteHistory(nodeID string, startTime, endTime time.Time) (
 
 	req := &ua.HistoryUpdateRequest{
 		HistoryUpdateDetails: []*ua.ExtensionObject{
-			{
-				EncodingMask: ua.ExtensionObjectBinary,
-				TypeID: &ua.ExpandedNodeID{
-					NodeID: ua.NewNumericNodeID(0, id.DeleteRawModifiedDetails_Encoding_DefaultBinary),
-				},
-				Value: &ua.DeleteRawModifiedDetails{
-					NodeID:           id,
-					IsDeleteModified: false,
-					StartTime:        startTime,
-					EndTime:          endTime,
-				},
-			},
+			ua.NewExtensionObject(&ua.DeleteRawModifiedDetails{
+				NodeID:           id,
+				IsDeleteModified: false,
+				StartTime:        startTime,
+				EndTime:          endTime,
+			}),
 		},
 	}
 
-	resp, err := c.Client.HistoryUpdate(c.ctx, req)
+	resp, err := c.historyUpdate(req)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History delete failed: %v", err))
 		return ua.StatusBad, err
@@ -1289,11 +1262,7 @@ func (c *OPCClient) DeleteHistory(nodeID string, startTime, endTime time.Time) (
 		return ua.StatusBad, fmt.Errorf("no results returned from history delete")
 	}
 
-	result := resp.Results[0]
-	updateResult, ok := result.(*ua.HistoryUpdateResult)
-	if !ok {
-		return ua.StatusBad, fmt.Errorf("unexpected result type")
-	}
+	updateResult := resp.Results[0]
 
 	c.iLog.Debug(fmt.Sprintf("History delete completed with status: %v", updateResult.StatusCode))
 	return updateResult.StatusCode, nil
@@ -1311,20 +1280,14 @@ func (c *OPCClient) DeleteHistoryAtTime(nodeID string, timestamps []time.Time) (
 
 	req := &ua.HistoryUpdateRequest{
 		HistoryUpdateDetails: []*ua.ExtensionObject{
-			{
-				EncodingMask: ua.ExtensionObjectBinary,
-				TypeID: &ua.ExpandedNodeID{
-					NodeID: ua.NewNumericNodeID(0, id.DeleteAtTimeDetails_Encoding_DefaultBinary),
-				},
-				Value: &ua.DeleteAtTimeDetails{
-					NodeID:   id,
-					ReqTimes: timestamps,
-				},
-			},
+			ua.NewExtensionObject(&ua.DeleteAtTimeDetails{
+				NodeID:   id,
+				ReqTimes: timestamps,
+			}),
 		},
 	}
 
-	resp, err := c.Client.HistoryUpdate(c.ctx, req)
+	resp, err := c.historyUpdate(req)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History delete at time failed: %v", err))
 		return nil, err
@@ -1334,11 +1297,7 @@ func (c *OPCClient) DeleteHistoryAtTime(nodeID string, timestamps []time.Time) (
 		return nil, fmt.Errorf("no results returned from history delete at time")
 	}
 
-	result := resp.Results[0]
-	updateResult, ok := result.(*ua.HistoryUpdateResult)
-	if !ok {
-		return nil, fmt.Errorf("unexpected result type")
-	}
+	updateResult := resp.Results[0]
 
 	c.iLog.Debug(fmt.Sprintf("History delete at time completed with status: %v", updateResult.StatusCode))
 	return updateResult.OperationResults, nil
@@ -1356,20 +1315,14 @@ func (c *OPCClient) DeleteHistoryEvents(nodeID string, eventIDs [][]byte) ([]ua.
 
 	req := &ua.HistoryUpdateRequest{
 		HistoryUpdateDetails: []*ua.ExtensionObject{
-			{
-				EncodingMask: ua.ExtensionObjectBinary,
-				TypeID: &ua.ExpandedNodeID{
-					NodeID: ua.NewNumericNodeID(0, id.DeleteEventDetails_Encoding_DefaultBinary),
-				},
-				Value: &ua.DeleteEventDetails{
-					NodeID:   id,
-					EventIDs: eventIDs,
-				},
-			},
+			ua.NewExtensionObject(&ua.DeleteEventDetails{
+				NodeID:   id,
+				EventIDs: eventIDs,
+			}),
 		},
 	}
 
-	resp, err := c.Client.HistoryUpdate(c.ctx, req)
+	resp, err := c.historyUpdate(req)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("History delete events failed: %v", err))
 		return nil, err
@@ -1379,11 +1332,7 @@ func (c *OPCClient) DeleteHistoryEvents(nodeID string, eventIDs [][]byte) ([]ua.
 		return nil, fmt.Errorf("no results returned from history delete events")
 	}
 
-	result := resp.Results[0]
-	updateResult, ok := result.(*ua.HistoryUpdateResult)
-	if !ok {
-		return nil, fmt.Errorf("unexpected result type")
-	}
+	updateResult := resp.Results[0]
 
 	c.iLog.Debug(fmt.Sprintf("History delete events completed with status: %v", updateResult.StatusCode))
 	return updateResult.OperationResults, nil
@@ -1409,6 +1358,27 @@ func createDefaultEventFilter() *ua.EventFilter {
 	}
 }
 
+// historyDataValue returns the decoded history data of a history read result
+func historyDataValue(result *ua.HistoryReadResult) interface{} {
+	if result.HistoryData == nil {
+		return nil
+	}
+	return result.HistoryData.Value
+}
+
+// historyUpdate sends a history update request, the client has no typed call for it
+func (c *OPCClient) historyUpdate(req *ua.HistoryUpdateRequest) (*ua.HistoryUpdateResponse, error) {
+	var resp *ua.HistoryUpdateResponse
+	err := c.Client.Send(c.ctx, req, func(v interface{}) error {
+		var ok bool
+		if resp, ok = v.(*ua.HistoryUpdateResponse); !ok {
+			return fmt.Errorf("invalid response: got %T want %T", v, resp)
+		}
+		return nil
+	})
+	return resp, err
+}
+
 // BrowseWithPath translates a browse path to node IDs
 func (c *OPCClient) BrowseWithPath(startingNode string, relativePath []string) ([]string, error) {
 	c.iLog.Debug(fmt.Sprintf("Translating browse path from %s: %v", startingNode, relativePath))
@@ -1448,7 +1418,14 @@ func (c *OPCClient) BrowseWithPath(startingNode string, relativePath []string) (
 		}
 	}
 
-	resp, err := c.Client.TranslateBrowsePathsToNodeIDs(c.ctx, req)
+	var resp *ua.TranslateBrowsePathsToNodeIDsResponse
+	err = c.Client.Send(c.ctx, req, func(v interface{}) error {
+		var ok bool
+		if resp, ok = v.(*ua.TranslateBrowsePathsToNodeIDsResponse); !ok {
+			return fmt.Errorf("invalid response: got %T want %T", v, resp)
+		}
+		return nil
+	})
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("Translate browse paths failed: %v", err))
 		return nil, err
@@ -1508,11 +1485,7 @@ func (c *OPCClient) BrowseNext(continuationPoint []byte) ([]*ua.ReferenceDescrip
 func (c *OPCClient) FindServers() ([]*ua.ApplicationDescription, error) {
 	c.iLog.Debug(fmt.Sprintf("Finding servers at %s", c.Endpoint))
 
-	req := &ua.FindServersRequest{
-		EndpointURL: c.Endpoint,
-	}
-
-	resp, err := c.Client.FindServers(c.ctx, req)
+	resp, err := c.Client.FindServers(c.ctx)
 	if err != nil {
 		c.iLog.Error(fmt.Sprintf("FindServers failed: %v", err))
 		return nil, err
@@ -324,6 +324,16 @@ func loadpluginControllerModule(controllerPath string) (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Failed to lookup symbol in controller module %s: %v", modulePath, err)
 	}
+
+	// a plugin can provide new function types for the transaction codes with a RegisterFunctionTypes function
+	if register, err := module.Lookup("RegisterFunctionTypes"); err == nil {
+		if registerFunctionTypes, ok := register.(func() error); ok {
+			if err := registerFunctionTypes(); err != nil {
+				return nil, fmt.Errorf("Failed to register the function types of controller module %s: %v", modulePath, err)
+			}
+			ilog.Info(fmt.Sprintf("Registered the function types of plugin %s", controllerPath))
+		}
+	}
 	return sym, nil
 }
 
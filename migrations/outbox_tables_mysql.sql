@@ -0,0 +1,32 @@
+-- MySQL Migration Script for the Transactional Outbox
+-- Execute this script to create the outbox table of the messages sent from transaction codes
+
+-- Table: outbox_messages
+-- Stores the messages written in the transaction of a transaction code until the relay has published them
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    sequence BIGINT AUTO_INCREMENT PRIMARY KEY,
+    messageid VARCHAR(255) NOT NULL,
+    channel VARCHAR(50) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    server VARCHAR(255) NOT NULL DEFAULT '',
+    messagekey VARCHAR(255) NOT NULL DEFAULT '',
+    payload LONGTEXT,
+    statusid INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    maxattempts INT NOT NULL DEFAULT 10,
+    nextattemptat DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    lockedby VARCHAR(255),
+    lockeduntil DATETIME NULL,
+    lasterror TEXT,
+    publishedat DATETIME NULL,
+    executionid VARCHAR(255),
+    correlationid VARCHAR(255),
+    trancode VARCHAR(255),
+    createdby VARCHAR(255),
+    createdon DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modifiedon DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_outbox_messageid (messageid),
+    INDEX idx_outbox_status_sequence (statusid, sequence),
+    INDEX idx_outbox_key (channel, topic, messagekey),
+    INDEX idx_outbox_correlationid (correlationid)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,34 @@
+-- PostgreSQL Migration Script for the Transactional Outbox
+-- Execute this script to create the outbox table of the messages sent from transaction codes
+
+-- Table: outbox_messages
+-- Stores the messages written in the transaction of a transaction code until the relay has published them
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    sequence BIGSERIAL PRIMARY KEY,
+    messageid VARCHAR(255) NOT NULL,
+    channel VARCHAR(50) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    server VARCHAR(255) NOT NULL DEFAULT '',
+    messagekey VARCHAR(255) NOT NULL DEFAULT '',
+    payload TEXT,
+    statusid INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    maxattempts INT NOT NULL DEFAULT 10,
+    nextattemptat TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    lockedby VARCHAR(255),
+    lockeduntil TIMESTAMP NULL,
+    lasterror TEXT,
+    publishedat TIMESTAMP NULL,
+    executionid VARCHAR(255),
+    correlationid VARCHAR(255),
+    trancode VARCHAR(255),
+    createdby VARCHAR(255),
+    createdon TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modifiedon TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes for outbox_messages
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_messages_messageid ON outbox_messages(messageid);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_status_sequence ON outbox_messages(statusid, sequence);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_key ON outbox_messages(channel, topic, messagekey);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_correlationid ON outbox_messages(correlationid);
@@ -0,0 +1,46 @@
+-- MySQL Migration Script for the Workflow Assignments
+-- Execute this script to add the claims, delegations, substitutes and approvals of the workflow tasks
+
+-- Table: workflow_tasks
+-- claimedby holds the user the task is reserved for, version is incremented by every claim change and the completion
+-- requiredapprovals is the number of approvals of a multi-instance approval task, foureyes excludes the initiator
+ALTER TABLE workflow_tasks ADD COLUMN claimedby VARCHAR(255) NULL;
+ALTER TABLE workflow_tasks ADD COLUMN claimedon DATETIME NULL;
+ALTER TABLE workflow_tasks ADD COLUMN version INT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_tasks ADD COLUMN requiredapprovals INT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_tasks ADD COLUMN foureyes INT NOT NULL DEFAULT 0;
+
+-- Table: workflow_task_assignments
+-- delegatedby holds the user who delegated the task to the assigned user
+ALTER TABLE workflow_task_assignments ADD COLUMN delegatedby VARCHAR(255) NULL;
+
+-- Table: workflow_substitutes
+-- Stores the out-of-office substitutes acting for a user between the start and end date
+CREATE TABLE IF NOT EXISTS workflow_substitutes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    userid BIGINT NOT NULL,
+    substituteid BIGINT NOT NULL,
+    startdate DATETIME NOT NULL,
+    enddate DATETIME NOT NULL,
+    createdby VARCHAR(255),
+    createdon DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modifiedby VARCHAR(255),
+    modifiedon DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_workflow_substitutes_user (userid, startdate, enddate),
+    INDEX idx_workflow_substitutes_substitute (substituteid)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Table: workflow_task_approvals
+-- Stores the decisions of the assignees of the multi-instance approval tasks, one per assignee. loginname is the
+-- user who decided, onbehalfof the assignee the decision is made for, the user or the assignee a substitute acts for
+CREATE TABLE IF NOT EXISTS workflow_task_approvals (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    workflowtaskid BIGINT NOT NULL,
+    loginname VARCHAR(255) NOT NULL,
+    onbehalfof VARCHAR(255) NOT NULL,
+    approved INT NOT NULL DEFAULT 0,
+    comments TEXT,
+    createdby VARCHAR(255),
+    createdon DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_workflow_task_approvals_assignee (workflowtaskid, onbehalfof)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
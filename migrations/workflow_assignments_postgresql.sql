@@ -0,0 +1,48 @@
+-- PostgreSQL Migration Script for the Workflow Assignments
+-- Execute this script to add the claims, delegations, substitutes and approvals of the workflow tasks
+
+-- Table: workflow_tasks
+-- claimedby holds the user the task is reserved for, version is incremented by every claim change and the completion
+-- requiredapprovals is the number of approvals of a multi-instance approval task, foureyes excludes the initiator
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS claimedby VARCHAR(255) NULL;
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS claimedon TIMESTAMP NULL;
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS requiredapprovals INT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS foureyes INT NOT NULL DEFAULT 0;
+
+-- Table: workflow_task_assignments
+-- delegatedby holds the user who delegated the task to the assigned user
+ALTER TABLE workflow_task_assignments ADD COLUMN IF NOT EXISTS delegatedby VARCHAR(255) NULL;
+
+-- Table: workflow_substitutes
+-- Stores the out-of-office substitutes acting for a user between the start and end date
+CREATE TABLE IF NOT EXISTS workflow_substitutes (
+    id BIGSERIAL PRIMARY KEY,
+    userid BIGINT NOT NULL,
+    substituteid BIGINT NOT NULL,
+    startdate TIMESTAMP NOT NULL,
+    enddate TIMESTAMP NOT NULL,
+    createdby VARCHAR(255),
+    createdon TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modifiedby VARCHAR(255),
+    modifiedon TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: workflow_task_approvals
+-- Stores the decisions of the assignees of the multi-instance approval tasks, one per assignee. loginname is the
+-- user who decided, onbehalfof the assignee the decision is made for, the user or the assignee a substitute acts for
+CREATE TABLE IF NOT EXISTS workflow_task_approvals (
+    id BIGSERIAL PRIMARY KEY,
+    workflowtaskid BIGINT NOT NULL,
+    loginname VARCHAR(255) NOT NULL,
+    onbehalfof VARCHAR(255) NOT NULL,
+    approved INT NOT NULL DEFAULT 0,
+    comments TEXT,
+    createdby VARCHAR(255),
+    createdon TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes for workflow_substitutes and workflow_task_approvals
+CREATE INDEX IF NOT EXISTS idx_workflow_substitutes_user ON workflow_substitutes(userid, startdate, enddate);
+CREATE INDEX IF NOT EXISTS idx_workflow_substitutes_substitute ON workflow_substitutes(substituteid);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_task_approvals_assignee ON workflow_task_approvals(workflowtaskid, onbehalfof);
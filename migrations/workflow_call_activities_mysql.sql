@@ -0,0 +1,10 @@
+-- MySQL Migration Script for the Workflow Call Activities
+-- Execute this script to link the child workflow entities to the call activities of their parents
+
+-- Table: workflow_entities
+-- parententityid and parenttaskid hold the parent workflow entity and the call activity task of a child workflow
+ALTER TABLE workflow_entities ADD COLUMN parententityid BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_entities ADD COLUMN parenttaskid BIGINT NOT NULL DEFAULT 0;
+
+-- Finds the child workflows of a call activity task
+CREATE INDEX idx_workflow_entities_parenttask ON workflow_entities (parenttaskid);
@@ -0,0 +1,10 @@
+-- PostgreSQL Migration Script for the Workflow Call Activities
+-- Execute this script to link the child workflow entities to the call activities of their parents
+
+-- Table: workflow_entities
+-- parententityid and parenttaskid hold the parent workflow entity and the call activity task of a child workflow
+ALTER TABLE workflow_entities ADD COLUMN IF NOT EXISTS parententityid BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_entities ADD COLUMN IF NOT EXISTS parenttaskid BIGINT NOT NULL DEFAULT 0;
+
+-- Finds the child workflows of a call activity task
+CREATE INDEX IF NOT EXISTS idx_workflow_entities_parenttask ON workflow_entities(parenttaskid);
@@ -0,0 +1,9 @@
+-- PostgreSQL Migration Script for the Workflow Gateways
+-- Execute this script to add the state of the join gateways to the workflow tasks
+
+-- Table: workflow_tasks
+-- joinarrivals holds the JSON list of the source nodes whose branches arrived at a waiting join gateway
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS joinarrivals TEXT NULL;
+
+-- Finds the waiting join of a workflow entity
+CREATE INDEX IF NOT EXISTS idx_workflow_tasks_entity_node_status ON workflow_tasks(workflowentityid, workflownodeid, status);
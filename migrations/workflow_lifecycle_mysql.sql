@@ -0,0 +1,7 @@
+-- MySQL Migration Script for the Workflow Lifecycle
+-- Execute this script to record the reason of the cancel, suspend, resume and restart of the workflow entities
+
+-- Table: workflow_task_histories
+-- reasoncode and comments explain the lifecycle operation of the history row
+ALTER TABLE workflow_task_histories ADD COLUMN reasoncode VARCHAR(255) NULL;
+ALTER TABLE workflow_task_histories ADD COLUMN comments TEXT NULL;
@@ -0,0 +1,33 @@
+-- MySQL Migration Script for the Workflow Timers
+-- Execute this script to create the timer table of the workflows and the due date of the workflow tasks
+
+-- Table: workflow_tasks
+-- duedate holds the due date of a task with a timer
+ALTER TABLE workflow_tasks ADD COLUMN duedate DATETIME NULL;
+
+-- Table: workflow_timers
+-- Stores the reminders, escalations, timer nodes and timer starts until the scheduler fires them
+CREATE TABLE IF NOT EXISTS workflow_timers (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    timerkey VARCHAR(255) NOT NULL,
+    timerkind VARCHAR(50) NOT NULL,
+    workflowentityid BIGINT NOT NULL DEFAULT 0,
+    workflowtaskid BIGINT NOT NULL DEFAULT 0,
+    workflowuuid VARCHAR(255),
+    workflownodeid VARCHAR(255),
+    dueat DATETIME NOT NULL,
+    statusid INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    nextattemptat DATETIME NULL,
+    lockedby VARCHAR(255),
+    lockeduntil DATETIME NULL,
+    lasterror TEXT,
+    firedat DATETIME NULL,
+    createdby VARCHAR(255),
+    createdon DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modifiedon DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_workflow_timers_timerkey (timerkey),
+    INDEX idx_workflow_timers_status_dueat (statusid, dueat),
+    INDEX idx_workflow_timers_task (workflowtaskid, statusid),
+    INDEX idx_workflow_timers_workflow (workflowuuid, timerkind, statusid)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,35 @@
+-- PostgreSQL Migration Script for the Workflow Timers
+-- Execute this script to create the timer table of the workflows and the due date of the workflow tasks
+
+-- Table: workflow_tasks
+-- duedate holds the due date of a task with a timer
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS duedate TIMESTAMP NULL;
+
+-- Table: workflow_timers
+-- Stores the reminders, escalations, timer nodes and timer starts until the scheduler fires them
+CREATE TABLE IF NOT EXISTS workflow_timers (
+    id BIGSERIAL PRIMARY KEY,
+    timerkey VARCHAR(255) NOT NULL,
+    timerkind VARCHAR(50) NOT NULL,
+    workflowentityid BIGINT NOT NULL DEFAULT 0,
+    workflowtaskid BIGINT NOT NULL DEFAULT 0,
+    workflowuuid VARCHAR(255),
+    workflownodeid VARCHAR(255),
+    dueat TIMESTAMP NOT NULL,
+    statusid INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    nextattemptat TIMESTAMP NULL,
+    lockedby VARCHAR(255),
+    lockeduntil TIMESTAMP NULL,
+    lasterror TEXT,
+    firedat TIMESTAMP NULL,
+    createdby VARCHAR(255),
+    createdon TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modifiedon TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes for workflow_timers
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_timers_timerkey ON workflow_timers(timerkey);
+CREATE INDEX IF NOT EXISTS idx_workflow_timers_status_dueat ON workflow_timers(statusid, dueat);
+CREATE INDEX IF NOT EXISTS idx_workflow_timers_task ON workflow_timers(workflowtaskid, statusid);
+CREATE INDEX IF NOT EXISTS idx_workflow_timers_workflow ON workflow_timers(workflowuuid, timerkind, statusid);
@@ -28,6 +28,8 @@ const (
 	JobTypeScheduled
 	JobTypeManual
 	JobTypeSystem
+	JobTypeCompensation
+	JobTypeSubTranCode
 )
 
 // JobDirection represents the direction of message flow
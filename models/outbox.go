@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// OutboxStatus represents the status of an outbox message
+type OutboxStatus int
+
+const (
+	OutboxStatusPending OutboxStatus = iota
+	OutboxStatusPublished
+	OutboxStatusDeadLetter
+)
+
+// OutboxMessage represents a message written by a transaction code that the relay publishes after the commit
+type OutboxMessage struct {
+	Sequence      int64      `json:"sequence" db:"sequence"` // Write order, messages of a key are published in this order
+	MessageID     string     `json:"messageid" db:"messageid"`
+	Channel       string     `json:"channel" db:"channel"` // messagebus, kafka, mqtt or amqp
+	Topic         string     `json:"topic" db:"topic"`
+	Server        string     `json:"server" db:"server"`
+	MessageKey    string     `json:"messagekey" db:"messagekey"` // Ordering key, empty for unordered messages
+	Payload       string     `json:"payload" db:"payload"`
+	StatusID      int        `json:"statusid" db:"statusid"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	MaxAttempts   int        `json:"maxattempts" db:"maxattempts"`
+	NextAttemptAt time.Time  `json:"nextattemptat" db:"nextattemptat"` // Backoff of the retries
+	LockedBy      string     `json:"lockedby" db:"lockedby"`           // Relay instance publishing the message
+	LockedUntil   *time.Time `json:"lockeduntil" db:"lockeduntil"`
+	LastError     string     `json:"lasterror" db:"lasterror"`
+	PublishedAt   *time.Time `json:"publishedat" db:"publishedat"`
+	ExecutionID   string     `json:"executionid" db:"executionid"`
+	CorrelationID string     `json:"correlationid" db:"correlationid"`
+	TranCode      string     `json:"trancode" db:"trancode"`
+	CreatedBy     string     `json:"createdby" db:"createdby"`
+	CreatedOn     time.Time  `json:"createdon" db:"createdon"`
+	ModifiedOn    time.Time  `json:"modifiedon" db:"modifiedon"`
+}
+
+// OrderingKey returns the key the relay keeps the order of, empty for unordered messages
+func (m *OutboxMessage) OrderingKey() string {
+	if m.MessageKey == "" {
+		return ""
+	}
+	return m.Channel + "|" + m.Topic + "|" + m.MessageKey
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// WorkflowTimerStatus represents the status of a workflow timer
+type WorkflowTimerStatus int
+
+const (
+	WorkflowTimerStatusPending WorkflowTimerStatus = iota
+	WorkflowTimerStatusFired
+	WorkflowTimerStatusCancelled
+	WorkflowTimerStatusFailed
+	WorkflowTimerStatusSuspended // the workflow is suspended, the timer is pending again when it resumes
+)
+
+// WorkflowTimer represents a reminder, escalation, timer node or timer start the scheduler fires at its due time
+type WorkflowTimer struct {
+	ID               int64      `json:"id" db:"id"`
+	TimerKey         string     `json:"timerkey" db:"timerkey"`   // Unique, a timer is scheduled once across the instances
+	TimerKind        string     `json:"timerkind" db:"timerkind"` // reminder, escalation, timer or timerstart
+	WorkflowEntityID int64      `json:"workflowentityid" db:"workflowentityid"`
+	WorkflowTaskID   int64      `json:"workflowtaskid" db:"workflowtaskid"`
+	WorkflowUUID     string     `json:"workflowuuid" db:"workflowuuid"`
+	WorkflowNodeID   string     `json:"workflownodeid" db:"workflownodeid"`
+	DueAt            time.Time  `json:"dueat" db:"dueat"`
+	StatusID         int        `json:"statusid" db:"statusid"`
+	Attempts         int        `json:"attempts" db:"attempts"`
+	NextAttemptAt    *time.Time `json:"nextattemptat" db:"nextattemptat"` // Backoff of the retries
+	LockedBy         string     `json:"lockedby" db:"lockedby"`           // Scheduler instance firing the timer
+	LockedUntil      *time.Time `json:"lockeduntil" db:"lockeduntil"`
+	LastError        string     `json:"lasterror" db:"lasterror"`
+	FiredAt          *time.Time `json:"firedat" db:"firedat"`
+	CreatedBy        string     `json:"createdby" db:"createdby"`
+	CreatedOn        time.Time  `json:"createdon" db:"createdon"`
+	ModifiedOn       time.Time  `json:"modifiedon" db:"modifiedon"`
+}
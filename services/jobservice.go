@@ -30,6 +30,24 @@ func NewJobService(db *sql.DB) *JobService {
 
 // CreateQueueJob creates a new job in the queue
 func (js *JobService) CreateQueueJob(ctx context.Context, job *models.QueueJob) error {
+	return js.createQueueJob(ctx, js.db, job)
+}
+
+// CreateQueueJobTx creates a new job in the queue within the transaction, the job exists only if the transaction commits.
+// It fails without a transaction, a job that must not outlive a rollback is never written on its own.
+func (js *JobService) CreateQueueJobTx(ctx context.Context, tx *sql.Tx, job *models.QueueJob) error {
+	if tx == nil {
+		return fmt.Errorf("no transaction to create the job for %s in", job.Handler)
+	}
+	return js.createQueueJob(ctx, tx, job)
+}
+
+// jobExecer is the database or the transaction a job is written with
+type jobExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (js *JobService) createQueueJob(ctx context.Context, db jobExecer, job *models.QueueJob) error {
 	startTime := time.Now()
 	defer func() {
 		js.iLog.Debug(fmt.Sprintf("CreateQueueJob completed in %v", time.Since(startTime)))
@@ -68,7 +86,7 @@ func (js *JobService) CreateQueueJob(ctx context.Context, job *models.QueueJob)
 		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err = js.db.ExecContext(ctx, query,
+	_, err = db.ExecContext(ctx, query,
 		job.ID, job.TypeID, job.Method, job.Protocol, job.Direction, job.Handler, string(metadataJSON), job.Payload,
 		job.Result, job.StatusID, job.Priority, job.MaxRetries, job.RetryCount, job.ScheduledAt,
 		job.StartedAt, job.CompletedAt, job.LastError, job.ParentJobID, job.Active, job.ReferenceID,
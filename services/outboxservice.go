@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mdaxf/iac/logger"
+	"github.com/mdaxf/iac/models"
+)
+
+// OutboxService provides methods for managing the messages of the transactional outbox
+type OutboxService struct {
+	db   *sql.DB
+	iLog logger.Log
+}
+
+// NewOutboxService creates a new outbox service instance
+func NewOutboxService(db *sql.DB) *OutboxService {
+	return &OutboxService{
+		db:   db,
+		iLog: logger.Log{ModuleName: logger.Framework, User: "System", ControllerName: "OutboxService"},
+	}
+}
+
+const outboxColumns = `sequence, messageid, channel, topic, server, messagekey, payload, statusid, attempts, maxattempts,
+		       nextattemptat, lockedby, lockeduntil, lasterror, publishedat, executionid, correlationid, trancode,
+		       createdby, createdon, modifiedon`
+
+// CreateOutboxMessageTx writes a message to the outbox within the transaction of the transaction code,
+// the message exists only if the transaction commits
+func (ob *OutboxService) CreateOutboxMessageTx(ctx context.Context, tx *sql.Tx, message *models.OutboxMessage) error {
+	if tx == nil {
+		return fmt.Errorf("an outbox message must be written within a transaction")
+	}
+
+	now := time.Now()
+	message.StatusID = int(models.OutboxStatusPending)
+	message.CreatedOn = now
+	message.ModifiedOn = now
+	if message.NextAttemptAt.IsZero() {
+		message.NextAttemptAt = now
+	}
+
+	query := `
+		INSERT INTO outbox_messages (
+			messageid, channel, topic, server, messagekey, payload, statusid, attempts, maxattempts,
+			nextattemptat, lasterror, executionid, correlationid, trancode, createdby, createdon, modifiedon
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.ExecContext(ctx, query,
+		message.MessageID, message.Channel, message.Topic, message.Server, message.MessageKey, message.Payload, message.StatusID,
+		message.Attempts, message.MaxAttempts, message.NextAttemptAt, message.LastError, message.ExecutionID, message.CorrelationID,
+		message.TranCode, message.CreatedBy, message.CreatedOn, message.ModifiedOn,
+	)
+
+	if err != nil {
+		ob.iLog.Error(fmt.Sprintf("Failed to create outbox message: %v", err))
+		return fmt.Errorf("failed to create outbox message: %w", err)
+	}
+
+	ob.iLog.Debug(fmt.Sprintf("Created outbox message: %s (Channel: %s, Topic: %s, Key: %s)", message.MessageID, message.Channel, message.Topic, message.MessageKey))
+	return nil
+}
+
+// GetPendingOutboxMessages retrieves the pending messages in the order they were written
+func (ob *OutboxService) GetPendingOutboxMessages(ctx context.Context, limit int) ([]*models.OutboxMessage, error) {
+	query := `SELECT ` + outboxColumns + `
+		FROM outbox_messages
+		WHERE statusid = ?
+		ORDER BY sequence ASC
+		LIMIT ?
+	`
+
+	return ob.queryOutboxMessages(ctx, query, int(models.OutboxStatusPending), limit)
+}
+
+// GetStuckOutboxMessages retrieves the dead lettered messages and the messages still pending since before the time
+func (ob *OutboxService) GetStuckOutboxMessages(ctx context.Context, pendingSince time.Time, limit int) ([]*models.OutboxMessage, error) {
+	query := `SELECT ` + outboxColumns + `
+		FROM outbox_messages
+		WHERE statusid = ? OR (statusid = ? AND createdon < ?)
+		ORDER BY sequence ASC
+		LIMIT ?
+	`
+
+	return ob.queryOutboxMessages(ctx, query, int(models.OutboxStatusDeadLetter), int(models.OutboxStatusPending), pendingSince, limit)
+}
+
+// ClaimOutboxMessage locks a pending message for the relay instance until the time.
+// It returns false when another instance holds the lock or the message is no longer pending.
+func (ob *OutboxService) ClaimOutboxMessage(ctx context.Context, sequence int64, owner string, until time.Time) (bool, error) {
+	now := time.Now()
+	query := `
+		UPDATE outbox_messages SET lockedby = ?, lockeduntil = ?, modifiedon = ?
+		WHERE sequence = ? AND statusid = ? AND (lockeduntil IS NULL OR lockeduntil < ? OR lockedby = ?)
+	`
+
+	result, err := ob.db.ExecContext(ctx, query, owner, until, now, sequence, int(models.OutboxStatusPending), now, owner)
+	if err != nil {
+		ob.iLog.Error(fmt.Sprintf("Failed to claim outbox message %d: %v", sequence, err))
+		return false, fmt.Errorf("failed to claim outbox message: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox message: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// MarkOutboxMessagePublished marks a message as published and releases its lock
+func (ob *OutboxService) MarkOutboxMessagePublished(ctx context.Context, sequence int64) error {
+	now := time.Now()
+	query := `
+		UPDATE outbox_messages SET statusid = ?, attempts = attempts + 1, publishedat = ?, lasterror = ?,
+		       lockedby = ?, lockeduntil = NULL, modifiedon = ?
+		WHERE sequence = ?
+	`
+
+	_, err := ob.db.ExecContext(ctx, query, int(models.OutboxStatusPublished), now, "", "", now, sequence)
+	if err != nil {
+		ob.iLog.Error(fmt.Sprintf("Failed to mark outbox message %d as published: %v", sequence, err))
+		return fmt.Errorf("failed to mark outbox message as published: %w", err)
+	}
+
+	return nil
+}
+
+// MarkOutboxMessageFailed records a failed attempt and releases the lock. The message is retried at the next attempt
+// time, a dead lettered message is no longer retried.
+func (ob *OutboxService) MarkOutboxMessageFailed(ctx context.Context, sequence int64, nextAttemptAt time.Time, errorMsg string, deadLetter bool) error {
+	statusID := int(models.OutboxStatusPending)
+	if deadLetter {
+		statusID = int(models.OutboxStatusDeadLetter)
+	}
+
+	query := `
+		UPDATE outbox_messages SET statusid = ?, attempts = attempts + 1, nextattemptat = ?, lasterror = ?,
+		       lockedby = ?, lockeduntil = NULL, modifiedon = ?
+		WHERE sequence = ?
+	`
+
+	_, err := ob.db.ExecContext(ctx, query, statusID, nextAttemptAt, errorMsg, "", time.Now(), sequence)
+	if err != nil {
+		ob.iLog.Error(fmt.Sprintf("Failed to record the failed attempt of outbox message %d: %v", sequence, err))
+		return fmt.Errorf("failed to record the failed attempt of outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// RequeueOutboxMessage puts a dead lettered or stuck message back for publishing with fresh attempts
+func (ob *OutboxService) RequeueOutboxMessage(ctx context.Context, messageID string) error {
+	now := time.Now()
+	query := `
+		UPDATE outbox_messages SET statusid = ?, attempts = 0, nextattemptat = ?, lockedby = ?, lockeduntil = NULL, modifiedon = ?
+		WHERE messageid = ? AND statusid <> ?
+	`
+
+	result, err := ob.db.ExecContext(ctx, query, int(models.OutboxStatusPending), now, "", now, messageID, int(models.OutboxStatusPublished))
+	if err != nil {
+		ob.iLog.Error(fmt.Sprintf("Failed to requeue outbox message %s: %v", messageID, err))
+		return fmt.Errorf("failed to requeue outbox message: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("outbox message not found or already published: %s", messageID)
+	}
+
+	ob.iLog.Info(fmt.Sprintf("Requeued outbox message: %s", messageID))
+	return nil
+}
+
+func (ob *OutboxService) queryOutboxMessages(ctx context.Context, query string, args ...interface{}) ([]*models.OutboxMessage, error) {
+	rows, err := ob.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		ob.iLog.Error(fmt.Sprintf("Failed to get outbox messages: %v", err))
+		return nil, fmt.Errorf("failed to get outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []*models.OutboxMessage{}
+	for rows.Next() {
+		message := &models.OutboxMessage{}
+		var lockedBy, lastError, executionID, correlationID, tranCode, createdBy sql.NullString
+
+		err := rows.Scan(
+			&message.Sequence, &message.MessageID, &message.Channel, &message.Topic, &message.Server, &message.MessageKey,
+			&message.Payload, &message.StatusID, &message.Attempts, &message.MaxAttempts, &message.NextAttemptAt, &lockedBy,
+			&message.LockedUntil, &lastError, &message.PublishedAt, &executionID, &correlationID, &tranCode,
+			&createdBy, &message.CreatedOn, &message.ModifiedOn,
+		)
+		if err != nil {
+			ob.iLog.Error(fmt.Sprintf("Failed to scan outbox message: %v", err))
+			continue
+		}
+
+		message.LockedBy = lockedBy.String
+		message.LastError = lastError.String
+		message.ExecutionID = executionID.String
+		message.CorrelationID = correlationID.String
+		message.TranCode = tranCode.String
+		message.CreatedBy = createdBy.String
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
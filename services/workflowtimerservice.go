@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mdaxf/iac/logger"
+	"github.com/mdaxf/iac/models"
+)
+
+// WorkflowTimerService provides methods for the scheduler of the workflow timers
+type WorkflowTimerService struct {
+	db   *sql.DB
+	iLog logger.Log
+}
+
+// NewWorkflowTimerService creates a new workflow timer service instance
+func NewWorkflowTimerService(db *sql.DB) *WorkflowTimerService {
+	return &WorkflowTimerService{
+		db:   db,
+		iLog: logger.Log{ModuleName: logger.Framework, User: "System", ControllerName: "WorkflowTimerService"},
+	}
+}
+
+const workflowTimerColumns = `id, timerkey, timerkind, workflowentityid, workflowtaskid, workflowuuid, workflownodeid, dueat, statusid,
+			       attempts, nextattemptat, lockedby, lockeduntil, lasterror, firedat, createdby, createdon, modifiedon`
+
+// GetDueWorkflowTimers retrieves the pending timers due at the time, the earliest first
+func (wts *WorkflowTimerService) GetDueWorkflowTimers(ctx context.Context, now time.Time, limit int) ([]*models.WorkflowTimer, error) {
+	query := `SELECT ` + workflowTimerColumns + `
+		FROM workflow_timers
+		WHERE statusid = ? AND dueat <= ? AND (nextattemptat IS NULL OR nextattemptat <= ?)
+		  AND (lockeduntil IS NULL OR lockeduntil < ?)
+		ORDER BY dueat ASC
+		LIMIT ?
+	`
+
+	rows, err := wts.db.QueryContext(ctx, query, int(models.WorkflowTimerStatusPending), now, now, now, limit)
+	if err != nil {
+		wts.iLog.Error(fmt.Sprintf("Failed to get due workflow timers: %v", err))
+		return nil, fmt.Errorf("failed to get due workflow timers: %w", err)
+	}
+	defer rows.Close()
+
+	timers := []*models.WorkflowTimer{}
+	for rows.Next() {
+		timer := &models.WorkflowTimer{}
+		var workflowUUID, workflowNodeID, lockedBy, lastError, createdBy sql.NullString
+
+		err := rows.Scan(
+			&timer.ID, &timer.TimerKey, &timer.TimerKind, &timer.WorkflowEntityID, &timer.WorkflowTaskID, &workflowUUID,
+			&workflowNodeID, &timer.DueAt, &timer.StatusID, &timer.Attempts, &timer.NextAttemptAt, &lockedBy,
+			&timer.LockedUntil, &lastError, &timer.FiredAt, &createdBy, &timer.CreatedOn, &timer.ModifiedOn,
+		)
+		if err != nil {
+			wts.iLog.Error(fmt.Sprintf("Failed to scan workflow timer: %v", err))
+			continue
+		}
+
+		timer.WorkflowUUID = workflowUUID.String
+		timer.WorkflowNodeID = workflowNodeID.String
+		timer.LockedBy = lockedBy.String
+		timer.LastError = lastError.String
+		timer.CreatedBy = createdBy.String
+		timers = append(timers, timer)
+	}
+
+	return timers, rows.Err()
+}
+
+// ClaimWorkflowTimer locks a pending timer for the scheduler instance until the time.
+// It returns false when another instance holds the lock or the timer is no longer pending.
+func (wts *WorkflowTimerService) ClaimWorkflowTimer(ctx context.Context, id int64, owner string, until time.Time) (bool, error) {
+	now := time.Now().UTC()
+	query := `
+		UPDATE workflow_timers SET lockedby = ?, lockeduntil = ?, modifiedon = ?
+		WHERE id = ? AND statusid = ? AND (lockeduntil IS NULL OR lockeduntil < ? OR lockedby = ?)
+	`
+
+	result, err := wts.db.ExecContext(ctx, query, owner, until, now, id, int(models.WorkflowTimerStatusPending), now, owner)
+	if err != nil {
+		wts.iLog.Error(fmt.Sprintf("Failed to claim workflow timer %d: %v", id, err))
+		return false, fmt.Errorf("failed to claim workflow timer: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim workflow timer: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// MarkWorkflowTimerFired marks a timer as fired and releases its lock. A timer that was suspended or cancelled
+// with its workflow entity while it was claimed keeps its status.
+func (wts *WorkflowTimerService) MarkWorkflowTimerFired(ctx context.Context, id int64) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE workflow_timers SET statusid = CASE WHEN statusid = ? THEN ? ELSE statusid END, attempts = attempts + 1, firedat = ?, lasterror = ?,
+		       lockedby = ?, lockeduntil = NULL, modifiedon = ?
+		WHERE id = ?
+	`
+
+	_, err := wts.db.ExecContext(ctx, query, int(models.WorkflowTimerStatusPending), int(models.WorkflowTimerStatusFired), now, "", "", now, id)
+	if err != nil {
+		wts.iLog.Error(fmt.Sprintf("Failed to mark workflow timer %d as fired: %v", id, err))
+		return fmt.Errorf("failed to mark workflow timer as fired: %w", err)
+	}
+
+	return nil
+}
+
+// MarkWorkflowTimerFailed records a failed attempt and releases the lock. The timer is retried at the next attempt
+// time, a failed timer is no longer retried.
+func (wts *WorkflowTimerService) MarkWorkflowTimerFailed(ctx context.Context, id int64, nextAttemptAt time.Time, errorMsg string, failed bool) error {
+	statusID := int(models.WorkflowTimerStatusPending)
+	if failed {
+		statusID = int(models.WorkflowTimerStatusFailed)
+	}
+
+	query := `
+		UPDATE workflow_timers SET statusid = ?, attempts = attempts + 1, nextattemptat = ?, lasterror = ?,
+		       lockedby = ?, lockeduntil = NULL, modifiedon = ?
+		WHERE id = ?
+	`
+
+	_, err := wts.db.ExecContext(ctx, query, statusID, nextAttemptAt, errorMsg, "", time.Now().UTC(), id)
+	if err != nil {
+		wts.iLog.Error(fmt.Sprintf("Failed to record the failed attempt of workflow timer %d: %v", id, err))
+		return fmt.Errorf("failed to record the failed attempt of workflow timer: %w", err)
+	}
+
+	return nil
+}
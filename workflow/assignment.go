@@ -0,0 +1,633 @@
+package workflow
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/logger"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+/*
+ASSIGNMENT DESIGN:
+
+A task is assigned to roles and users (workflow_task_assignments). Every assignee sees the task, and to keep
+two assignees from working on it at the same time an assignee claims it first:
+
+  - claim: the task is reserved for the user, the other assignees can not start or complete it
+  - unclaim: the claimant or a workflow administrator releases the task
+  - delegate: an assignee hands the task to another user, who is assigned to it and holds the claim
+
+Claim, unclaim and delegate carry the version of the task the user read (workflow_tasks.version). The update
+only succeeds for that version and increments it, so the second of two concurrent claims fails instead of
+overwriting the first.
+
+An out-of-office substitute (workflow_substitutes) acts for a user between the start and end date. The
+substitute sees and may claim, approve and complete the tasks of the user in that range.
+
+A node with an approval is a multi-instance approval: the assignees approve or reject the task one by one
+(workflow_task_approvals) and it completes with the outcome approved when the required approvals are reached,
+or rejected as soon as they can no longer be reached. Every assignee has one decision, made in person or by a
+substitute, the decision records the assignee it is made for (onbehalfof). A four-eyes node can not be claimed,
+approved or completed by the initiator of the workflow.
+*/
+
+// ClaimTask reserves the task for the user. The version is the version of the task the user read.
+func (wft *WorkFlowTask) ClaimTask(Version int64) error {
+	return wft.changeTaskAssignment("claim task", Version, "", func(dbop *dbconn.DBOperation, task map[string]interface{}) (string, error) {
+		if toInt64(task["requiredapprovals"]) > 0 {
+			return "", fmt.Errorf("the approval task %d is approved by every assignee and can not be claimed", wft.WorkFlowTaskID)
+		}
+		if err := checkTaskAssignee(dbop, wft.WorkFlowTaskID, task, wft.UserName); err != nil {
+			return "", err
+		}
+		return wft.UserName, nil
+	})
+}
+
+// UnclaimTask releases the task claimed by the user. A workflow administrator may release any claim.
+func (wft *WorkFlowTask) UnclaimTask(Version int64) error {
+	return wft.changeTaskAssignment("unclaim task", Version, "", func(dbop *dbconn.DBOperation, task map[string]interface{}) (string, error) {
+		claimedby := stringValue(task["claimedby"])
+		if claimedby == "" {
+			return "", fmt.Errorf("the task %d is not claimed", wft.WorkFlowTaskID)
+		}
+		if claimedby != wft.UserName {
+			isAdmin, err := isWorkFlowAdmin(dbop, wft.UserName)
+			if err != nil {
+				return "", err
+			}
+			if !isAdmin {
+				return "", fmt.Errorf("the task %d is claimed by %s", wft.WorkFlowTaskID, claimedby)
+			}
+		}
+		return "", nil
+	})
+}
+
+// DelegateTask assigns the task to the user ToUser, who holds the claim of the task
+func (wft *WorkFlowTask) DelegateTask(Version int64, ToUser string, Comments string) error {
+	return wft.changeTaskAssignment("delegate task", Version, Comments, func(dbop *dbconn.DBOperation, task map[string]interface{}) (string, error) {
+		if err := checkTaskAssignee(dbop, wft.WorkFlowTaskID, task, wft.UserName); err != nil {
+			return "", err
+		}
+
+		rows, err := dbop.Query_Json(fmt.Sprintf("select id, loginname from users where loginname = '%s'", sqlString(ToUser)))
+		if err != nil {
+			return "", err
+		}
+		if len(rows) == 0 {
+			return "", fmt.Errorf("the user %s does not exist", ToUser)
+		}
+		loginname := stringValue(rows[0]["loginname"])
+		if loginname == wft.UserName {
+			return "", fmt.Errorf("the task %d can not be delegated to the user itself", wft.WorkFlowTaskID)
+		}
+		if toInt64(task["foureyes"]) == 1 && loginname == stringValue(task["initiator"]) {
+			return "", fmt.Errorf("the task %d can not be delegated to the initiator of the workflow", wft.WorkFlowTaskID)
+		}
+
+		now := time.Now().UTC().Format("2006-01-02 15:04:05")
+		columns := []string{"workflowtaskid", "userid", "delegatedby", "createdby", "createdon", "modifiedby", "modifiedon"}
+		values := []string{fmt.Sprintf("%d", wft.WorkFlowTaskID), fmt.Sprint(rows[0]["id"]), wft.UserName, wft.UserName, now, wft.UserName, now}
+		if _, err = dbop.TableInsert("workflow_task_assignments", columns, values); err != nil {
+			return "", err
+		}
+		return loginname, nil
+	})
+}
+
+// changeTaskAssignment checks the task and its version, lets the operation decide the new claimant and updates the
+// claim of the task with the next version and a history record in one transaction
+func (wft *WorkFlowTask) changeTaskAssignment(operation string, Version int64, Comments string,
+	apply func(dbop *dbconn.DBOperation, task map[string]interface{}) (string, error)) error {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		wft.iLog.PerformanceWithDuration("changeTaskAssignment", elapsed)
+	}()
+
+	wft.iLog.Debug(fmt.Sprintf("%s %d version %d", operation, wft.WorkFlowTaskID, Version))
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+		return err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(wft.UserName, DBTx, logger.Framework)
+
+	if err = checkTaskActive(dbop, wft.WorkFlowTaskID); err != nil {
+		return err
+	}
+
+	task, err := getTaskAssignment(dbop, wft.WorkFlowTaskID)
+	if err != nil {
+		return err
+	}
+
+	status := toInt64(task["status"])
+	if status != wftype.TaskStatusCreated && status != wftype.TaskStatusStarted {
+		return fmt.Errorf("can not %s %d in the status %d", operation, wft.WorkFlowTaskID, status)
+	}
+	if toInt64(task["version"]) != Version {
+		return fmt.Errorf("the task %d was changed by another user, reload it and try again", wft.WorkFlowTaskID)
+	}
+	if claimedby := stringValue(task["claimedby"]); claimedby != "" && claimedby != wft.UserName && operation != "unclaim task" {
+		return fmt.Errorf("the task %d is claimed by %s", wft.WorkFlowTaskID, claimedby)
+	}
+
+	claimant, err := apply(dbop, task)
+	if err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in %s %d: %s", operation, wft.WorkFlowTaskID, err))
+		return err
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	// a released task has no claim, NULL on every database
+	var claimedby, claimedon interface{}
+	if claimant != "" {
+		claimedby, claimedon = claimant, now
+	}
+
+	Columns := []string{"claimedby", "claimedon", "version", "modifiedby", "modifiedon"}
+	Values := []interface{}{claimedby, claimedon, Version + 1, wft.UserName, now}
+	datatypes := []int{int(0), int(0), int(1), int(0), int(0)}
+	idColumn := dbop.QuoteIdentifier("id")
+	Where := fmt.Sprintf("%s = %d AND version = %d", idColumn, wft.WorkFlowTaskID, Version)
+	count, err := dbop.TableUpdate_v2("workflow_tasks", Columns, Values, datatypes, Where)
+	if err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in updating workflow tasks: %s", err))
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("the task %d was changed by another user, reload it and try again", wft.WorkFlowTaskID)
+	}
+
+	if err = addTaskHistory(dbop, task, wft.WorkFlowTaskID, operation, Comments, wft.UserName); err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in adding the history record: %s", err))
+		return err
+	}
+
+	return DBTx.Commit()
+}
+
+// ApproveTask records the approval or rejection of the user for a multi-instance approval task. The task
+// completes with the outcome in the process data once the outcome is decided.
+func (wft *WorkFlowTask) ApproveTask(Approved bool, Comments string) error {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		wft.iLog.PerformanceWithDuration("ApproveTask", elapsed)
+	}()
+
+	wft.iLog.Debug(fmt.Sprintf("ApproveTask by workflowtaskid: %d approved: %t", wft.WorkFlowTaskID, Approved))
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+		return err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(wft.UserName, DBTx, logger.Framework)
+
+	if err = checkTaskActive(dbop, wft.WorkFlowTaskID); err != nil {
+		return err
+	}
+
+	idColumn := dbop.QuoteIdentifier("id")
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	// lock the task against concurrent approvals deciding the outcome twice
+	_, err = dbop.TableUpdate("workflow_tasks", []string{"modifiedon"}, []string{now}, []int{int(0)}, fmt.Sprintf("%s = %d", idColumn, wft.WorkFlowTaskID))
+	if err != nil {
+		return err
+	}
+
+	task, err := getTaskAssignment(dbop, wft.WorkFlowTaskID)
+	if err != nil {
+		return err
+	}
+
+	required := toInt64(task["requiredapprovals"])
+	if required <= 0 {
+		return fmt.Errorf("the task %d is not an approval task", wft.WorkFlowTaskID)
+	}
+	if status := toInt64(task["status"]); status != wftype.TaskStatusCreated && status != wftype.TaskStatusStarted {
+		return fmt.Errorf("the approval task %d is already decided", wft.WorkFlowTaskID)
+	}
+	if err = checkTaskAssignee(dbop, wft.WorkFlowTaskID, task, wft.UserName); err != nil {
+		return err
+	}
+
+	onbehalfof, err := approvalSeat(dbop, wft.WorkFlowTaskID, wft.UserName)
+	if err != nil {
+		return err
+	}
+	if onbehalfof == "" {
+		return fmt.Errorf("the user %s has already decided the approval task %d", wft.UserName, wft.WorkFlowTaskID)
+	}
+
+	approved := "0"
+	operation := "reject task"
+	if Approved {
+		approved = "1"
+		operation = "approve task"
+	}
+	columns := []string{"workflowtaskid", "loginname", "onbehalfof", "approved", "comments", "createdby", "createdon"}
+	values := []string{fmt.Sprintf("%d", wft.WorkFlowTaskID), wft.UserName, onbehalfof, approved, Comments, wft.UserName, now}
+	if _, err = dbop.TableInsert("workflow_task_approvals", columns, values); err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in adding the approval: %s", err))
+		return err
+	}
+
+	if err = addTaskHistory(dbop, task, wft.WorkFlowTaskID, operation, Comments, wft.UserName); err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in adding the history record: %s", err))
+		return err
+	}
+
+	rows, err := dbop.Query_Json(fmt.Sprintf("select approved, count(*) as decisions from workflow_task_approvals where workflowtaskid = %d group by approved", wft.WorkFlowTaskID))
+	if err != nil {
+		return err
+	}
+	var approvals, rejections int64
+	for _, row := range rows {
+		if toInt64(row["approved"]) == 1 {
+			approvals = toInt64(row["decisions"])
+		} else {
+			rejections = toInt64(row["decisions"])
+		}
+	}
+
+	assignees, err := countTaskAssignees(dbop, wft.WorkFlowTaskID, toInt64(task["foureyes"]) == 1)
+	if err != nil {
+		return err
+	}
+
+	outcome := approvalOutcome(approvals, rejections, required, assignees)
+	wft.iLog.Debug(fmt.Sprintf("approval task %d: %d approvals, %d rejections of %d assignees, %d required, outcome: %s", wft.WorkFlowTaskID, approvals, rejections, assignees, required, outcome))
+
+	if outcome != "" {
+		wft.DBTx = DBTx
+		wft.approvalDecided = true
+		defer func() {
+			wft.DBTx = nil
+			wft.approvalDecided = false
+		}()
+
+		err = wft.UpdateProcessData(map[string]interface{}{"approval": outcome, "approvals": approvals, "rejections": rejections})
+		if err != nil {
+			return err
+		}
+		if err = wft.CompleteTask(); err != nil {
+			return err
+		}
+	}
+
+	return DBTx.Commit()
+}
+
+// approvalOutcome decides a multi-instance approval, approved when the required approvals are reached and
+// rejected when the remaining assignees can no longer reach them. It is empty while the approval is open.
+func approvalOutcome(approvals int64, rejections int64, required int64, assignees int64) string {
+	if approvals >= required {
+		return wftype.ApprovalApproved
+	}
+	if assignees-rejections < required {
+		return wftype.ApprovalRejected
+	}
+	return ""
+}
+
+// checkTaskActor returns an error when the task is claimed by another user, or the user is the initiator of a
+// four-eyes task
+func checkTaskActor(dbop *dbconn.DBOperation, taskid int64, UserName string) (map[string]interface{}, error) {
+	task, err := getTaskAssignment(dbop, taskid)
+	if err != nil {
+		return nil, err
+	}
+
+	if claimedby := stringValue(task["claimedby"]); claimedby != "" && claimedby != UserName {
+		return nil, fmt.Errorf("the task %d is claimed by %s", taskid, claimedby)
+	}
+	if toInt64(task["foureyes"]) == 1 && stringValue(task["initiator"]) == UserName {
+		return nil, fmt.Errorf("the initiator %s of the workflow can not act on the four-eyes task %d", UserName, taskid)
+	}
+	return task, nil
+}
+
+// checkTaskAssignee returns an error when the user is neither assigned to the task nor a substitute of an assignee,
+// or the user is the initiator of a four-eyes task
+func checkTaskAssignee(dbop *dbconn.DBOperation, taskid int64, task map[string]interface{}, UserName string) error {
+	if toInt64(task["foureyes"]) == 1 && stringValue(task["initiator"]) == UserName {
+		return fmt.Errorf("the initiator %s of the workflow can not act on the four-eyes task %d", UserName, taskid)
+	}
+
+	rows, err := dbop.Query_Json(fmt.Sprintf("select wt.id from workflow_tasks wt where wt.id = %d AND %s", taskid, assigneeCondition(UserName)))
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("the task %d is not assigned to the user %s", taskid, UserName)
+	}
+	return nil
+}
+
+// assigneeCondition is the condition on the workflow task wt that the user is assigned to it by a role or
+// directly, or is the substitute of an assignee today
+func assigneeCondition(UserName string) string {
+	return fmt.Sprintf(`exists (Select 1 FROM workflow_task_assignments wts
+				LEFT JOIN user_roles ur on ur.roleid = wts.roleid
+				INNER JOIN users u on u.ID = wts.userid OR ur.userid = u.id
+				where wts.workflowtaskid = wt.id AND %s)`, actsForCondition(UserName))
+}
+
+// actsForCondition is the condition on the user u that the user UserName is u or the substitute of u today
+func actsForCondition(UserName string) string {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	user := sqlString(UserName)
+
+	return fmt.Sprintf(`(u.loginname = '%s' OR exists (Select 1 FROM workflow_substitutes ws
+					INNER JOIN users su on su.id = ws.substituteid
+					where ws.userid = u.id AND su.loginname = '%s' AND ws.startdate <= '%s' AND ws.enddate >= '%s'))`, user, user, now, now)
+}
+
+// undecidedSeats is the query of the login names of the assignees of the task in the SQL expression task that the
+// user acts for and that have not decided its approval. The initiator of a four-eyes task has no decision.
+func undecidedSeats(task string, UserName string) string {
+	return fmt.Sprintf(`Select u.loginname FROM workflow_task_assignments wts
+				LEFT JOIN user_roles ur on ur.roleid = wts.roleid
+				INNER JOIN users u on u.ID = wts.userid OR ur.userid = u.id
+				where wts.workflowtaskid = %s AND %s
+				AND NOT exists (Select 1 FROM workflow_task_approvals wa where wa.workflowtaskid = wts.workflowtaskid AND wa.onbehalfof = u.loginname)
+				AND NOT exists (Select 1 FROM workflow_tasks t
+					INNER JOIN workflow_entities e on e.id = t.workflowentityid
+					where t.id = wts.workflowtaskid AND t.foureyes = 1 AND e.createdby = u.loginname)`, task, actsForCondition(UserName))
+}
+
+// approvalSeat returns the assignee the user decides the approval task for, the user when the user is an
+// undecided assignee, otherwise the first undecided assignee the user substitutes. It is empty when every
+// assignee the user acts for has decided.
+func approvalSeat(dbop *dbconn.DBOperation, taskid int64, UserName string) (string, error) {
+	rows, err := dbop.Query_Json(fmt.Sprintf("select distinct seats.loginname from (%s) seats order by seats.loginname", undecidedSeats(fmt.Sprintf("%d", taskid), UserName)))
+	if err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		if stringValue(row["loginname"]) == UserName {
+			return UserName, nil
+		}
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	return stringValue(rows[0]["loginname"]), nil
+}
+
+// countTaskAssignees returns the number of users assigned to the task directly or by a role. The initiator of
+// the workflow does not count on a four-eyes task, as the initiator can not approve it.
+func countTaskAssignees(dbop *dbconn.DBOperation, taskid int64, foureyes bool) (int64, error) {
+	initiatorCondition := ""
+	if foureyes {
+		initiatorCondition = `AND NOT exists (Select 1 FROM workflow_tasks t
+					INNER JOIN workflow_entities e on e.id = t.workflowentityid
+					where t.id = wts.workflowtaskid AND e.createdby = u.loginname)`
+	}
+
+	rows, err := dbop.Query_Json(fmt.Sprintf(`select count(distinct u.id) as assignees FROM workflow_task_assignments wts
+				LEFT JOIN user_roles ur on ur.roleid = wts.roleid
+				INNER JOIN users u on u.ID = wts.userid OR ur.userid = u.id
+				where wts.workflowtaskid = %d %s`, taskid, initiatorCondition))
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return toInt64(rows[0]["assignees"]), nil
+}
+
+// getTaskAssignment returns the status, claim, version and approval settings of the task with the initiator of its workflow
+func getTaskAssignment(dbop *dbconn.DBOperation, taskid int64) (map[string]interface{}, error) {
+	rows, err := dbop.Query_Json(fmt.Sprintf(`select t.workflowentityid, t.status, t.claimedby, t.version, t.requiredapprovals, t.foureyes, e.createdby as initiator
+			from workflow_tasks t inner join workflow_entities e on e.id = t.workflowentityid where t.id = %d`, taskid))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the workflow task %d does not exist", taskid)
+	}
+	return rows[0], nil
+}
+
+// addTaskHistory records an assignment operation on the task
+func addTaskHistory(dbop *dbconn.DBOperation, task map[string]interface{}, taskid int64, operation string, Comments string, UserName string) error {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	columns := []string{"workflowentityid", "workflowtaskid", "typecode", "status", "comments", "createdby", "createdon", "modifiedby", "modifiedon"}
+	values := []string{fmt.Sprint(task["workflowentityid"]), fmt.Sprintf("%d", taskid), operation, fmt.Sprint(task["status"]), Comments, UserName, now, UserName, now}
+	_, err := dbop.TableInsert("workflow_task_histories", columns, values)
+	return err
+}
+
+// setTaskApproval stores the approval settings of the node on its task. The required approvals default to
+// every assignee of the task.
+func setTaskApproval(dbop *dbconn.DBOperation, node wftype.Node, taskid int64) error {
+	var required int64
+	if node.Approval != nil {
+		required = int64(node.Approval.Required)
+		if required <= 0 {
+			assignees, err := countTaskAssignees(dbop, taskid, node.FourEyes)
+			if err != nil {
+				return err
+			}
+			required = assignees
+		}
+		if required <= 0 {
+			required = 1
+		}
+	}
+
+	foureyes := "0"
+	if node.FourEyes {
+		foureyes = "1"
+	}
+
+	Columns := []string{"requiredapprovals", "foureyes"}
+	Values := []string{fmt.Sprintf("%d", required), foureyes}
+	datatypes := []int{int(1), int(1)}
+	Where := fmt.Sprintf("%s = %d", dbop.QuoteIdentifier("id"), taskid)
+	_, err := dbop.TableUpdate("workflow_tasks", Columns, Values, datatypes, Where)
+	return err
+}
+
+// AddSubstitute makes the user SubstituteUser the out-of-office substitute of the user between the dates
+func AddSubstitute(SubstituteUser string, StartDate time.Time, EndDate time.Time, UserName string) (int64, error) {
+	iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow substitutes"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("AddSubstitute", elapsed)
+	}()
+
+	if !EndDate.After(StartDate) {
+		return 0, fmt.Errorf("the end date %v of the substitute must be after the start date %v", EndDate, StartDate)
+	}
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+		return 0, err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
+
+	userid, err := getUserID(dbop, UserName)
+	if err != nil {
+		return 0, err
+	}
+	substituteid, err := getUserID(dbop, SubstituteUser)
+	if err != nil {
+		return 0, err
+	}
+	if userid == substituteid {
+		return 0, fmt.Errorf("the user %s can not be the own substitute", UserName)
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	columns := []string{"userid", "substituteid", "startdate", "enddate", "createdby", "createdon", "modifiedby", "modifiedon"}
+	values := []string{fmt.Sprintf("%d", userid), fmt.Sprintf("%d", substituteid), StartDate.UTC().Format("2006-01-02 15:04:05"),
+		EndDate.UTC().Format("2006-01-02 15:04:05"), UserName, now, UserName, now}
+	id, err := dbop.TableInsert("workflow_substitutes", columns, values)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in adding the substitute: %s", err))
+		return 0, err
+	}
+
+	return id, DBTx.Commit()
+}
+
+// RemoveSubstitute removes a substitute of the user
+func RemoveSubstitute(id int64, UserName string) error {
+	iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow substitutes"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("RemoveSubstitute", elapsed)
+	}()
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+		return err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
+
+	userid, err := getUserID(dbop, UserName)
+	if err != nil {
+		return err
+	}
+
+	count, err := dbop.TableDelete("workflow_substitutes", fmt.Sprintf("%s = %d AND userid = %d", dbop.QuoteIdentifier("id"), id, userid))
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in removing the substitute: %s", err))
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("the substitute %d of the user %s does not exist", id, UserName)
+	}
+
+	return DBTx.Commit()
+}
+
+// GetSubstitutes returns the substitutes of the user and the users the user substitutes
+func GetSubstitutes(UserName string) ([]map[string]interface{}, error) {
+	iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow substitutes"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("GetSubstitutes", elapsed)
+	}()
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+		return nil, err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
+
+	result, err := dbop.Query_Json(fmt.Sprintf(`select ws.id, u.loginname as username, su.loginname as substitute, ws.startdate, ws.enddate
+			from workflow_substitutes ws
+			INNER JOIN users u on u.id = ws.userid
+			INNER JOIN users su on su.id = ws.substituteid
+			where u.loginname = '%s' OR su.loginname = '%s'`, sqlString(UserName), sqlString(UserName)))
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in getting the substitutes: %s", err))
+		return nil, err
+	}
+
+	DBTx.Commit()
+
+	return result, nil
+}
+
+// getUserID returns the id of the user by the login name
+func getUserID(dbop *dbconn.DBOperation, UserName string) (int64, error) {
+	rows, err := dbop.Query_Json(fmt.Sprintf("select id from users where loginname = '%s'", sqlString(UserName)))
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("the user %s does not exist", UserName)
+	}
+	return toInt64(rows[0]["id"]), nil
+}
+
+// sqlString escapes the quotes of a string literal in a query
+func sqlString(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+// stringValue returns the string of a query value, empty for NULL
+func stringValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case sql.NullString:
+		return v.String
+	}
+	return fmt.Sprint(value)
+}
+
+// toInt64 returns the integer of a query value, 0 for NULL or a value that is not a number
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+
+	result, err := strconv.ParseFloat(strings.TrimSpace(stringValue(value)), 64)
+	if err != nil {
+		return 0
+	}
+	return int64(result)
+}
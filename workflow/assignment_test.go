@@ -0,0 +1,209 @@
+package workflow
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+func TestApprovalOutcome(t *testing.T) {
+	tests := []struct {
+		approvals, rejections, required, assignees int64
+		outcome                                    string
+	}{
+		{0, 0, 2, 3, ""},
+		{1, 0, 2, 3, ""},
+		{2, 0, 2, 3, wftype.ApprovalApproved},
+		{1, 1, 2, 3, ""},
+		{0, 2, 2, 3, wftype.ApprovalRejected},
+		{0, 1, 3, 3, wftype.ApprovalRejected},
+		{1, 0, 1, 1, wftype.ApprovalApproved},
+		// a four-eyes task of three assignees with the initiator among them
+		{0, 1, 2, 2, wftype.ApprovalRejected},
+	}
+	for _, tt := range tests {
+		if outcome := approvalOutcome(tt.approvals, tt.rejections, tt.required, tt.assignees); outcome != tt.outcome {
+			t.Errorf("approvalOutcome(%d, %d, %d, %d) = %q, want %q", tt.approvals, tt.rejections, tt.required, tt.assignees, outcome, tt.outcome)
+		}
+	}
+}
+
+func TestToInt64(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		result int64
+	}{
+		{int64(3), 3},
+		{float64(2), 2},
+		{[]byte("5"), 5},
+		{"7", 7},
+		{nil, 0},
+		{"abc", 0},
+	}
+	for _, tt := range tests {
+		if result := toInt64(tt.value); result != tt.result {
+			t.Errorf("toInt64(%v) = %d, want %d", tt.value, result, tt.result)
+		}
+	}
+}
+
+func TestTaskClaim_VersionConflicts(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	entity := tdb.addEntity(wftype.WorkFlow{Name: "ECN", UUID: "ecn-uuid"}, "alice", 0, 0)
+	task := tdb.addTask(entity, "review", wftype.TaskStatusCreated, nil)
+	tdb.assign(task, tdb.addUser("bob"))
+	tdb.assign(task, tdb.addUser("carol"))
+
+	if err := NewWorkFlowTaskType(task, "bob").ClaimTask(0); err != nil {
+		t.Fatalf("ClaimTask() error = %v", err)
+	}
+	if got := tdb.value("SELECT claimedby FROM workflow_tasks WHERE id = ?", task); got != "bob" {
+		t.Errorf("claimedby = %v, want bob", got)
+	}
+
+	// carol read the task before the claim of bob
+	err := NewWorkFlowTaskType(task, "carol").ClaimTask(0)
+	if err == nil || !strings.Contains(err.Error(), "changed by another user") {
+		t.Errorf("ClaimTask() with a stale version error = %v, want a version conflict", err)
+	}
+	err = NewWorkFlowTaskType(task, "carol").ClaimTask(1)
+	if err == nil || !strings.Contains(err.Error(), "claimed by bob") {
+		t.Errorf("ClaimTask() of a claimed task error = %v, want the claim of bob", err)
+	}
+	err = NewWorkFlowTaskType(task, "carol").UnclaimTask(1)
+	if err == nil || !strings.Contains(err.Error(), "claimed by bob") {
+		t.Errorf("UnclaimTask() of the claim of another user error = %v, want the claim of bob", err)
+	}
+
+	if tasks, err := GetTasksbyUser("carol"); err != nil || len(tasks) != 0 {
+		t.Errorf("GetTasksbyUser() of carol = %v, %v, want no tasks while bob holds the claim", tasks, err)
+	}
+
+	if err := NewWorkFlowTaskType(task, "bob").UnclaimTask(1); err != nil {
+		t.Fatalf("UnclaimTask() error = %v", err)
+	}
+	if got := toInt64(tdb.value("SELECT count(*) FROM workflow_tasks WHERE id = ? AND claimedby IS NULL AND claimedon IS NULL AND version = 2", task)); got != 1 {
+		t.Errorf("the released task has a claim or the version is not 2")
+	}
+	if tasks, err := GetTasksbyUser("carol"); err != nil || len(tasks) != 1 {
+		t.Errorf("GetTasksbyUser() of carol = %v, %v, want the released task", tasks, err)
+	}
+}
+
+func TestGetTasksbyUser_EmptyClaim(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	entity := tdb.addEntity(wftype.WorkFlow{Name: "ECN", UUID: "ecn-uuid"}, "alice", 0, 0)
+	task := tdb.addTask(entity, "review", wftype.TaskStatusCreated, nil)
+	tdb.assign(task, tdb.addUser("bob"))
+	tdb.exec("UPDATE workflow_tasks SET claimedby = '' WHERE id = ?", task)
+
+	if tasks, err := GetTasksbyUser("bob"); err != nil || len(tasks) != 1 {
+		t.Errorf("GetTasksbyUser() = %v, %v, want the task with the empty claim", tasks, err)
+	}
+}
+
+func TestApproveTask_FourEyes(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	entity := tdb.addEntity(wftype.WorkFlow{Name: "ECN", UUID: "ecn-uuid"}, "alice", 0, 0)
+	task := tdb.addTask(entity, "approve", wftype.TaskStatusCreated, nil)
+	for _, user := range []string{"alice", "bob", "carol"} {
+		tdb.addUser(user, "Engineer")
+	}
+	tdb.exec("INSERT INTO workflow_task_assignments (workflowtaskid, roleid) SELECT ?, id FROM roles WHERE name = 'Engineer'", task)
+	tdb.exec("UPDATE workflow_tasks SET requiredapprovals = 2, foureyes = 1 WHERE id = ?", task)
+
+	err := tdb.inTx(func(tx *sql.Tx, dbop *dbconn.DBOperation) error {
+		for _, tt := range []struct {
+			foureyes  bool
+			assignees int64
+		}{{false, 3}, {true, 2}} {
+			assignees, err := countTaskAssignees(dbop, task, tt.foureyes)
+			if err != nil {
+				return err
+			}
+			if assignees != tt.assignees {
+				t.Errorf("countTaskAssignees(foureyes %t) = %d, want %d", tt.foureyes, assignees, tt.assignees)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("countTaskAssignees() error = %v", err)
+	}
+
+	err = NewWorkFlowTaskType(task, "alice").ApproveTask(true, "")
+	if err == nil || !strings.Contains(err.Error(), "four-eyes") {
+		t.Errorf("ApproveTask() by the initiator error = %v, want the four-eyes check", err)
+	}
+
+	// the first approval leaves the outcome open, carol can still approve
+	if err := NewWorkFlowTaskType(task, "bob").ApproveTask(true, "looks good"); err != nil {
+		t.Fatalf("ApproveTask() error = %v", err)
+	}
+	if got := tdb.taskStatus(task); got != wftype.TaskStatusCreated {
+		t.Errorf("task status = %d, want the open approval %d", got, wftype.TaskStatusCreated)
+	}
+	err = NewWorkFlowTaskType(task, "bob").ApproveTask(true, "")
+	if err == nil || !strings.Contains(err.Error(), "already decided") {
+		t.Errorf("ApproveTask() twice error = %v, want the decision of bob", err)
+	}
+}
+
+func TestApproveTask_SubstituteDecidesForTheAssignee(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	entity := tdb.addEntity(wftype.WorkFlow{Name: "ECN", UUID: "ecn-uuid"}, "alice", 0, 0)
+	task := tdb.addTask(entity, "approve", wftype.TaskStatusCreated, nil)
+	bob := tdb.addUser("bob")
+	tdb.assign(task, bob)
+	tdb.assign(task, tdb.addUser("carol"))
+	dave := tdb.addUser("dave")
+	tdb.exec("UPDATE workflow_tasks SET requiredapprovals = 2 WHERE id = ?", task)
+	tdb.exec("INSERT INTO workflow_substitutes (userid, substituteid, startdate, enddate) VALUES (?, ?, ?, ?)", bob, dave,
+		time.Now().UTC().Add(-time.Hour).Format("2006-01-02 15:04:05"), time.Now().UTC().Add(time.Hour).Format("2006-01-02 15:04:05"))
+
+	if err := NewWorkFlowTaskType(task, "dave").ApproveTask(true, "for bob"); err != nil {
+		t.Fatalf("ApproveTask() by the substitute error = %v", err)
+	}
+	if got := tdb.value("SELECT onbehalfof FROM workflow_task_approvals WHERE workflowtaskid = ? AND loginname = 'dave'", task); got != "bob" {
+		t.Errorf("onbehalfof = %v, want the decision recorded for bob", got)
+	}
+
+	// the seat of bob is decided, neither bob nor the substitute decides it again
+	for _, user := range []string{"bob", "dave"} {
+		err := NewWorkFlowTaskType(task, user).ApproveTask(true, "")
+		if err == nil || !strings.Contains(err.Error(), "already decided") {
+			t.Errorf("ApproveTask() by %s error = %v, want the decision of bob", user, err)
+		}
+		if tasks, err := GetTasksbyUser(user); err != nil || len(tasks) != 0 {
+			t.Errorf("GetTasksbyUser() of %s = %v, %v, want no undecided approval", user, tasks, err)
+		}
+	}
+	if got := tdb.taskStatus(task); got != wftype.TaskStatusCreated {
+		t.Errorf("task status = %d, want the approval open with one of two approvals", got)
+	}
+}
+
+func TestCompleteTask_CompletedTask(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	entity := tdb.addEntity(wftype.WorkFlow{Name: "ECN", UUID: "ecn-uuid"}, "alice", 0, 0)
+	task := tdb.addTask(entity, "review", wftype.TaskStatusCompleted, nil)
+	tdb.assign(task, tdb.addUser("bob"))
+
+	// the task was completed by a concurrent request, the workflow is not routed a second time
+	err := NewWorkFlowTaskType(task, "bob").CompleteTask()
+	if err == nil || !strings.Contains(err.Error(), "completed or changed") {
+		t.Errorf("CompleteTask() of a completed task error = %v, want the completion refused", err)
+	}
+	if got := toInt64(tdb.value("SELECT version FROM workflow_tasks WHERE id = ?", task)); got != 0 {
+		t.Errorf("version = %d, want the completed task unchanged", got)
+	}
+}
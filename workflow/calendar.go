@@ -0,0 +1,157 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+// BusinessCalendar counts the business days of the workflow timers, the work days of the week without the holidays
+type BusinessCalendar struct {
+	WorkDays map[time.Weekday]bool
+	Holidays map[string]bool // dates as 2006-01-02
+}
+
+// NewBusinessCalendar creates a business calendar, Monday to Friday without work days
+func NewBusinessCalendar(workdays []int, holidays []string) *BusinessCalendar {
+	calendar := &BusinessCalendar{WorkDays: map[time.Weekday]bool{}, Holidays: map[string]bool{}}
+
+	if len(workdays) == 0 {
+		workdays = []int{1, 2, 3, 4, 5}
+	}
+	for _, day := range workdays {
+		calendar.WorkDays[time.Weekday(day)] = true
+	}
+	for _, holiday := range holidays {
+		calendar.Holidays[strings.TrimSpace(holiday)] = true
+	}
+
+	return calendar
+}
+
+var (
+	businessCalendar     = NewBusinessCalendar(nil, nil)
+	businessCalendarLock sync.RWMutex
+)
+
+// ConfigureBusinessCalendar sets the business calendar of the workflow timers from the installation settings
+func ConfigureBusinessCalendar(workdays []int, holidays []string) {
+	businessCalendarLock.Lock()
+	defer businessCalendarLock.Unlock()
+	businessCalendar = NewBusinessCalendar(workdays, holidays)
+}
+
+// GetBusinessCalendar returns the business calendar of the workflow timers
+func GetBusinessCalendar() *BusinessCalendar {
+	businessCalendarLock.RLock()
+	defer businessCalendarLock.RUnlock()
+	return businessCalendar
+}
+
+// IsBusinessDay reports whether the day of the time is a work day and not a holiday
+func (c *BusinessCalendar) IsBusinessDay(t time.Time) bool {
+	return c.WorkDays[t.Weekday()] && !c.Holidays[t.Format("2006-01-02")]
+}
+
+// AddBusinessDays moves the time by the business days and keeps its time of day, a negative number moves it back
+func (c *BusinessCalendar) AddBusinessDays(t time.Time, days int) time.Time {
+	if len(c.WorkDays) == 0 {
+		return t.AddDate(0, 0, days)
+	}
+
+	step := 1
+	if days < 0 {
+		step, days = -1, -days
+	}
+	for days > 0 {
+		t = t.AddDate(0, 0, step)
+		if c.IsBusinessDay(t) {
+			days--
+		}
+	}
+	return t
+}
+
+// ParseTimerDuration parses a duration of a workflow timer: a Go duration like 30m or 4h, days like 2d
+// or business days like 3bd. It returns either the duration or the business days.
+func ParseTimerDuration(value string) (time.Duration, int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if strings.HasSuffix(value, "bd") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "bd"))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid business days %s: %w", value, err)
+		}
+		return 0, days, nil
+	}
+
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid days %s: %w", value, err)
+		}
+		return time.Duration(days) * 24 * time.Hour, 0, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid duration %s: %w", value, err)
+	}
+	return duration, 0, nil
+}
+
+// AddTimerDuration moves the time by the timer duration, backwards when before is set
+func (c *BusinessCalendar) AddTimerDuration(t time.Time, value string, before bool) (time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return t, nil
+	}
+
+	duration, days, err := ParseTimerDuration(value)
+	if err != nil {
+		return t, err
+	}
+	if before {
+		duration, days = -duration, -days
+	}
+
+	if days != 0 {
+		return c.AddBusinessDays(t, days), nil
+	}
+	return t.Add(duration), nil
+}
+
+// TimerDueDate returns the due date of the timer for a task created at the time, from the due data in the
+// process data or from the due duration
+func (c *BusinessCalendar) TimerDueDate(timer *wftype.Timer, created time.Time, ProcessData map[string]interface{}) (time.Time, error) {
+	if timer.DueData != "" {
+		value, ok := ProcessData[timer.DueData]
+		if !ok || value == nil {
+			return time.Time{}, fmt.Errorf("the process data has no due date %s", timer.DueData)
+		}
+		return parseDueDate(value)
+	}
+
+	if timer.Due == "" {
+		return time.Time{}, fmt.Errorf("the timer has no due date")
+	}
+	return c.AddTimerDuration(created, timer.Due, false)
+}
+
+// parseDueDate converts a due date of the process data to a time, dates without a zone are UTC
+func parseDueDate(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid due date %v", value)
+}
@@ -0,0 +1,62 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+func TestParseTimerDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		duration time.Duration
+		days     int
+	}{
+		{"30m", 30 * time.Minute, 0},
+		{"2d", 48 * time.Hour, 0},
+		{" 3BD ", 0, 3},
+	}
+	for _, tt := range tests {
+		duration, days, err := ParseTimerDuration(tt.value)
+		if err != nil || duration != tt.duration || days != tt.days {
+			t.Errorf("ParseTimerDuration(%q) = %v, %d, %v, want %v, %d", tt.value, duration, days, err, tt.duration, tt.days)
+		}
+	}
+
+	for _, value := range []string{"", "xbd", "soon"} {
+		if _, _, err := ParseTimerDuration(value); err == nil {
+			t.Errorf("ParseTimerDuration(%q) expected an error", value)
+		}
+	}
+}
+
+func TestBusinessCalendar_TimerDueDate(t *testing.T) {
+	calendar := NewBusinessCalendar(nil, []string{"2026-12-25"})
+	// Thursday before Christmas
+	created := time.Date(2026, 12, 24, 9, 30, 0, 0, time.UTC)
+
+	due, err := calendar.TimerDueDate(&wftype.Timer{Due: "2bd"}, created, nil)
+	if want := time.Date(2026, 12, 29, 9, 30, 0, 0, time.UTC); err != nil || !due.Equal(want) {
+		t.Errorf("TimerDueDate(2bd) = %v, %v, want %v over the holiday and the weekend", due, err, want)
+	}
+
+	remind, err := calendar.AddTimerDuration(due, "1bd", true)
+	if want := time.Date(2026, 12, 28, 9, 30, 0, 0, time.UTC); err != nil || !remind.Equal(want) {
+		t.Errorf("AddTimerDuration(1bd before) = %v, %v, want %v", remind, err, want)
+	}
+
+	due, err = calendar.TimerDueDate(&wftype.Timer{Due: "4h"}, created, nil)
+	if want := created.Add(4 * time.Hour); err != nil || !due.Equal(want) {
+		t.Errorf("TimerDueDate(4h) = %v, %v, want %v", due, err, want)
+	}
+
+	due, err = calendar.TimerDueDate(&wftype.Timer{DueData: "ShipDate"}, created, map[string]interface{}{"ShipDate": "2027-01-04"})
+	if want := time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC); err != nil || !due.Equal(want) {
+		t.Errorf("TimerDueDate(ShipDate) = %v, %v, want %v", due, err, want)
+	}
+
+	if _, err := calendar.TimerDueDate(&wftype.Timer{DueData: "ShipDate"}, created, map[string]interface{}{}); err == nil {
+		t.Errorf("TimerDueDate() without the due data expected an error")
+	}
+}
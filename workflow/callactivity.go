@@ -0,0 +1,358 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/logger"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+/*
+CALL ACTIVITY DESIGN:
+
+A call activity node starts a child workflow by name and version and waits for it:
+
+  - start: the task of the call activity waits (status 6) and the child workflow entity is exploded in the
+    same transaction with the parent process data mapped by the input mapping. The child entity records its
+    parent entity and task (workflow_entities.parententityid and parenttaskid).
+  - complete: when the child workflow completes, the process data of its completed tasks is mapped back by the
+    output mapping and the task of the call activity completes, the parent continues. This happens in the
+    transaction completing the end task of the child, so the parent continues exactly when the child commits.
+  - failure: a task of the child failing sets the waiting call activity to error, up to the top workflow.
+  - cancel: cancelling a workflow cancels the child workflows of its open tasks, and cancelling a child
+    workflow cancels its parent, so no part of the process keeps waiting on a cancelled one.
+
+The task of a call activity completing after its child was cancelled or restarted is ignored, only the
+waiting task continues.
+*/
+
+// startCallActivity starts the child workflow of the call activity task and makes the task wait for it
+func startCallActivity(workflowtaskid int64, NodeData wftype.Node, idbTx *sql.Tx, DocDBCon *documents.DocDB, UserName string) error {
+	iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow call activity"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("startCallActivity", elapsed)
+	}()
+
+	internaltransaction := false
+	err := error(nil)
+
+	if idbTx == nil {
+		idbTx, err = dbconn.DB.Begin()
+		if err != nil {
+			iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+			return err
+		}
+		internaltransaction = true
+		defer idbTx.Rollback()
+	}
+
+	dbop := dbconn.NewDBOperation(UserName, idbTx, logger.Framework)
+
+	childentityid, err := explodeChildWorkFlow(dbop, workflowtaskid, NodeData, idbTx, DocDBCon, UserName)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in starting the child workflow of the task %d: %s", workflowtaskid, err))
+
+		Where := fmt.Sprintf("%s = %d", dbop.QuoteIdentifier("id"), workflowtaskid)
+		if _, uerr := dbop.TableUpdate("workflow_tasks", []string{"status"}, []string{fmt.Sprintf("%d", wftype.TaskStatusError)}, []int{int(1)}, Where); uerr != nil {
+			iLog.Error(fmt.Sprintf("Error in updating workflow tasks: %s", uerr))
+		}
+		failCallActivities(workflowtaskid, idbTx, UserName, err)
+
+		if internaltransaction {
+			idbTx.Commit()
+		}
+		return err
+	}
+
+	iLog.Debug(fmt.Sprintf("the task %d started the child workflow entity %d", workflowtaskid, childentityid))
+
+	if internaltransaction {
+		return idbTx.Commit()
+	}
+	return nil
+}
+
+// explodeChildWorkFlow maps the process data of the task to the child data, makes the task wait and explodes the
+// child workflow
+func explodeChildWorkFlow(dbop *dbconn.DBOperation, workflowtaskid int64, NodeData wftype.Node, idbTx *sql.Tx, DocDBCon *documents.DocDB, UserName string) (int64, error) {
+	if NodeData.CallActivity == nil || NodeData.CallActivity.WorkFlow == "" {
+		return 0, fmt.Errorf("the call activity %s has no workflow to call", NodeData.ID)
+	}
+
+	rows, err := dbop.Query_Json(fmt.Sprintf(`select t.workflowentityid, t.pretaskdata, t.processdata, e.entity, e.typecode
+			from workflow_tasks t inner join workflow_entities e on e.id = t.workflowentityid where t.id = %d`, workflowtaskid))
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("the workflow task %d does not exist", workflowtaskid)
+	}
+	task := rows[0]
+
+	ProcessData := map[string]interface{}{}
+	for _, column := range []string{"pretaskdata", "processdata"} {
+		if value := stringValue(task[column]); value != "" {
+			data := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(value), &data); err != nil {
+				return 0, err
+			}
+			for key, value := range data {
+				ProcessData[key] = value
+			}
+		}
+	}
+
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	Columns := []string{"status", "startedDate"}
+	Values := []string{fmt.Sprintf("%d", wftype.TaskStatusWaiting), now}
+	datatypes := []int{int(1), int(0)}
+	Where := fmt.Sprintf("%s = %d", dbop.QuoteIdentifier("id"), workflowtaskid)
+	if _, err = dbop.TableUpdate("workflow_tasks", Columns, Values, datatypes, Where); err != nil {
+		return 0, err
+	}
+
+	child := NewExplosion(NodeData.CallActivity.WorkFlow, stringValue(task["entity"]), stringValue(task["typecode"]), UserName, "")
+	child.version = NodeData.CallActivity.Version
+	child.parentEntityID = toInt64(task["workflowentityid"])
+	child.parentTaskID = workflowtaskid
+	child.DBTx = idbTx
+	if DocDBCon != nil {
+		child.DocDBCon = DocDBCon
+	}
+
+	childentityid, err := child.Explode(fmt.Sprintf("called by the workflow task %d", workflowtaskid), mapCallActivityData(ProcessData, NodeData.CallActivity.Input))
+	if err != nil {
+		return 0, err
+	}
+	if childentityid == 0 {
+		return 0, fmt.Errorf("the child workflow %s is not started", NodeData.CallActivity.WorkFlow)
+	}
+
+	task["status"] = wftype.TaskStatusWaiting
+	if err = addTaskHistory(dbop, task, workflowtaskid, "call workflow", fmt.Sprintf("child workflow entity %d", childentityid), UserName); err != nil {
+		return 0, err
+	}
+
+	return childentityid, nil
+}
+
+// completeCallActivity completes the waiting call activity of the completed child workflow entity with the
+// process data of the child mapped back to the parent
+func completeCallActivity(dbop *dbconn.DBOperation, idbTx *sql.Tx, DocDBCon *documents.DocDB, workflowentityid int64, UserName string) error {
+	rows, err := dbop.Query_Json(fmt.Sprintf("select parenttaskid from workflow_entities where id = %d", workflowentityid))
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 || toInt64(rows[0]["parenttaskid"]) == 0 {
+		return nil
+	}
+	parenttaskid := toInt64(rows[0]["parenttaskid"])
+
+	rows, err = dbop.Query_Json(fmt.Sprintf(`select t.workflownodeid, t.status, e.workflow
+			from workflow_tasks t inner join workflow_entities e on e.id = t.workflowentityid where t.id = %d`, parenttaskid))
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 || toInt64(rows[0]["status"]) != wftype.TaskStatusWaiting {
+		// the call activity was cancelled or restarted meanwhile
+		return nil
+	}
+
+	// the parent entity keeps the workflow it was exploded with
+	var WorkFlow wftype.WorkFlow
+	if err = json.Unmarshal([]byte(stringValue(rows[0]["workflow"])), &WorkFlow); err != nil {
+		return err
+	}
+	node := (&ExplodionEngine{}).getNodeByID(stringValue(rows[0]["workflownodeid"]), WorkFlow.Nodes)
+
+	ChildData := map[string]interface{}{}
+	tasks, err := dbop.Query_Json(fmt.Sprintf("select processdata from workflow_tasks where workflowentityid = %d AND status = %d order by id", workflowentityid, wftype.TaskStatusCompleted))
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if value := stringValue(task["processdata"]); value != "" {
+			data := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(value), &data); err != nil {
+				return err
+			}
+			for key, value := range data {
+				ChildData[key] = value
+			}
+		}
+	}
+
+	var mapping map[string]string
+	if node.CallActivity != nil {
+		mapping = node.CallActivity.Output
+	}
+
+	wft := NewWorkFlowTaskType(parenttaskid, UserName)
+	wft.DBTx = idbTx
+	wft.DocDBCon = DocDBCon
+
+	if err = wft.UpdateProcessData(mapCallActivityData(ChildData, mapping)); err != nil {
+		return err
+	}
+	return continueCallActivity(wft)
+}
+
+// continueCallActivity completes the task of the call activity, the parent continues at its next nodes
+var continueCallActivity func(wft *WorkFlowTask) error
+
+func init() {
+	continueCallActivity = (*WorkFlowTask).CompleteTask
+}
+
+// failCallActivities sets the waiting call activities above the failed task to error, up to the top workflow
+func failCallActivities(workflowtaskid int64, idbTx *sql.Tx, UserName string, cause error) {
+	iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow call activity"}
+
+	internaltransaction := false
+	err := error(nil)
+
+	if idbTx == nil {
+		idbTx, err = dbconn.DB.Begin()
+		if err != nil {
+			iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+			return
+		}
+		internaltransaction = true
+		defer idbTx.Rollback()
+	}
+
+	dbop := dbconn.NewDBOperation(UserName, idbTx, logger.Framework)
+
+	for taskid := workflowtaskid; taskid != 0; {
+		rows, err := dbop.Query_Json(fmt.Sprintf(`select e.parententityid, e.parenttaskid
+				from workflow_tasks t inner join workflow_entities e on e.id = t.workflowentityid where t.id = %d`, taskid))
+		if err != nil {
+			iLog.Error(fmt.Sprintf("Error in getting the parent of the task %d: %s", taskid, err))
+			return
+		}
+		if len(rows) == 0 || toInt64(rows[0]["parenttaskid"]) == 0 {
+			break
+		}
+		parenttaskid := toInt64(rows[0]["parenttaskid"])
+
+		Where := fmt.Sprintf("%s = %d AND status = %d", dbop.QuoteIdentifier("id"), parenttaskid, wftype.TaskStatusWaiting)
+		count, err := dbop.TableUpdate("workflow_tasks", []string{"status"}, []string{fmt.Sprintf("%d", wftype.TaskStatusError)}, []int{int(1)}, Where)
+		if err != nil {
+			iLog.Error(fmt.Sprintf("Error in updating workflow tasks: %s", err))
+			return
+		}
+		if count == 0 {
+			break
+		}
+
+		parent := map[string]interface{}{"workflowentityid": rows[0]["parententityid"], "status": wftype.TaskStatusError}
+		if err = addTaskHistory(dbop, parent, parenttaskid, "child workflow failed", fmt.Sprint(cause), UserName); err != nil {
+			iLog.Error(fmt.Sprintf("Error in adding the history record: %s", err))
+			return
+		}
+
+		iLog.Debug(fmt.Sprintf("the call activity task %d failed with its child task %d", parenttaskid, taskid))
+		taskid = parenttaskid
+	}
+
+	if internaltransaction {
+		idbTx.Commit()
+	}
+}
+
+// cancelChildWorkFlows cancels the active child workflows of the open tasks of the workflow entity and returns the
+// notifications of their open tasks
+func cancelChildWorkFlows(dbop *dbconn.DBOperation, workflowentityid int64, UserName string) ([]string, error) {
+	rows, err := dbop.Query_Json(fmt.Sprintf(`select c.id from workflow_entities c inner join workflow_tasks t on t.id = c.parenttaskid
+			where t.workflowentityid = %d AND t.status NOT IN (%d, %d) AND c.status IN (%d, %d)`, workflowentityid,
+		wftype.TaskStatusCompleted, wftype.TaskStatusCancelled, wftype.EntityStatusActive, wftype.EntityStatusSuspended))
+	if err != nil {
+		return nil, err
+	}
+
+	uuids := []string{}
+	for _, row := range rows {
+		childentityid := toInt64(row["id"])
+		if err = setWorkFlowCancelled(dbop, childentityid, "parent cancelled", UserName); err != nil {
+			return nil, err
+		}
+
+		childuuids, err := cancelOpenTasks(dbop, childentityid, UserName)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, childuuids...)
+	}
+	return uuids, nil
+}
+
+// cancelParentWorkFlows cancels the active parent workflows of the cancelled child workflow entity and returns the
+// notifications of their open tasks
+func cancelParentWorkFlows(dbop *dbconn.DBOperation, entity map[string]interface{}, UserName string) ([]string, error) {
+	uuids := []string{}
+
+	for parententityid := toInt64(entity["parententityid"]); parententityid != 0; {
+		rows, err := dbop.Query_Json(fmt.Sprintf("select status, parententityid from workflow_entities where id = %d", parententityid))
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		status := toInt64(rows[0]["status"])
+		if status != wftype.EntityStatusActive && status != wftype.EntityStatusSuspended {
+			break
+		}
+
+		if err = setWorkFlowCancelled(dbop, parententityid, "child cancelled", UserName); err != nil {
+			return nil, err
+		}
+
+		parentuuids, err := cancelOpenTasks(dbop, parententityid, UserName)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, parentuuids...)
+
+		parententityid = toInt64(rows[0]["parententityid"])
+	}
+	return uuids, nil
+}
+
+// setWorkFlowCancelled cancels the workflow entity with a history record of the reason
+func setWorkFlowCancelled(dbop *dbconn.DBOperation, workflowentityid int64, ReasonCode string, UserName string) error {
+	Columns := []string{"status", "modifiedby", "modifiedon"}
+	Values := []string{fmt.Sprintf("%d", wftype.EntityStatusCancelled), UserName, time.Now().UTC().Format("2006-01-02 15:04:05")}
+	datatypes := []int{int(1), int(0), int(0)}
+	if _, err := dbop.TableUpdate("workflow_entities", Columns, Values, datatypes, fmt.Sprintf("%s = %d", dbop.QuoteIdentifier("id"), workflowentityid)); err != nil {
+		return err
+	}
+
+	return addWorkFlowHistory(dbop, workflowentityid, cancelOperation, ReasonCode, "", UserName)
+}
+
+// mapCallActivityData maps the data by the mapping of target keys to source keys, without a mapping the data is copied
+func mapCallActivityData(data map[string]interface{}, mapping map[string]string) map[string]interface{} {
+	result := map[string]interface{}{}
+
+	if len(mapping) == 0 {
+		for key, value := range data {
+			result[key] = value
+		}
+		return result
+	}
+
+	for target, source := range mapping {
+		if value, ok := data[source]; ok {
+			result[target] = value
+		}
+	}
+	return result
+}
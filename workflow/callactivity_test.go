@@ -0,0 +1,189 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+func TestMapCallActivityData(t *testing.T) {
+	data := map[string]interface{}{"partnumber": "P-100", "revision": "B", "approved": true}
+
+	tests := []struct {
+		name    string
+		mapping map[string]string
+		want    map[string]interface{}
+	}{
+		{"copy without a mapping", nil, map[string]interface{}{"partnumber": "P-100", "revision": "B", "approved": true}},
+		{"map the keys", map[string]string{"part": "partnumber", "ecnapproved": "approved"}, map[string]interface{}{"part": "P-100", "ecnapproved": true}},
+		{"skip the missing keys", map[string]string{"part": "partnumber", "owner": "owner"}, map[string]interface{}{"part": "P-100"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapCallActivityData(data, tt.mapping); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapCallActivityData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	result := mapCallActivityData(data, nil)
+	result["revision"] = "C"
+	if data["revision"] != "B" {
+		t.Errorf("mapCallActivityData() changed the source data")
+	}
+}
+
+// callActivityTestWorkFlow is a parent workflow with the call activity "review" mapping the child result back
+var callActivityTestWorkFlow = wftype.WorkFlow{
+	Name: "ECN",
+	UUID: "ecn-uuid",
+	Nodes: []wftype.Node{
+		{ID: "review", Type: wftype.NodeTypeCallActivity, CallActivity: &wftype.CallActivity{
+			WorkFlow: "Review",
+			Output:   map[string]string{"reviewresult": "result"},
+		}},
+	},
+}
+
+func TestCallActivity_CompletionContinuesParent(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	parent := tdb.addEntity(callActivityTestWorkFlow, "alice", 0, 0)
+	parenttask := tdb.addTask(parent, "review", wftype.TaskStatusWaiting, map[string]interface{}{"partnumber": "P-100"})
+	child := tdb.addEntity(wftype.WorkFlow{Name: "Review", UUID: "review-uuid"}, "alice", parent, parenttask)
+	tdb.addTask(child, "approve", wftype.TaskStatusCompleted, map[string]interface{}{"result": "approved", "reviewer": "bob"})
+
+	continued := []int64{}
+	previous := continueCallActivity
+	continueCallActivity = func(wft *WorkFlowTask) error {
+		if wft.DBTx == nil {
+			t.Errorf("the call activity continues outside the transaction of the child")
+		}
+		continued = append(continued, wft.WorkFlowTaskID)
+		return nil
+	}
+	defer func() { continueCallActivity = previous }()
+
+	err := tdb.inTx(func(tx *sql.Tx, dbop *dbconn.DBOperation) error {
+		completed, err := ValidateAndCompleteWorkFlow(child, tx, nil, "alice")
+		if err == nil && !completed {
+			t.Errorf("ValidateAndCompleteWorkFlow() did not complete the child workflow")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ValidateAndCompleteWorkFlow() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(continued, []int64{parenttask}) {
+		t.Errorf("continued tasks = %v, want [%d]", continued, parenttask)
+	}
+	if got := tdb.entityStatus(child); got != wftype.EntityStatusCompleted {
+		t.Errorf("child status = %d, want %d", got, wftype.EntityStatusCompleted)
+	}
+
+	ProcessData := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(tdb.value("SELECT processdata FROM workflow_tasks WHERE id = ?", parenttask).(string)), &ProcessData); err != nil {
+		t.Fatalf("parent process data: %v", err)
+	}
+	want := map[string]interface{}{"partnumber": "P-100", "reviewresult": "approved"}
+	if !reflect.DeepEqual(ProcessData, want) {
+		t.Errorf("parent process data = %v, want %v", ProcessData, want)
+	}
+}
+
+func TestCallActivity_CompletionIgnoresCancelledParent(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	parent := tdb.addEntity(callActivityTestWorkFlow, "alice", 0, 0)
+	parenttask := tdb.addTask(parent, "review", wftype.TaskStatusCancelled, nil)
+	child := tdb.addEntity(wftype.WorkFlow{Name: "Review", UUID: "review-uuid"}, "alice", parent, parenttask)
+
+	previous := continueCallActivity
+	continueCallActivity = func(wft *WorkFlowTask) error {
+		t.Errorf("the cancelled call activity task %d continued", wft.WorkFlowTaskID)
+		return nil
+	}
+	defer func() { continueCallActivity = previous }()
+
+	err := tdb.inTx(func(tx *sql.Tx, dbop *dbconn.DBOperation) error {
+		_, err := ValidateAndCompleteWorkFlow(child, tx, nil, "alice")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ValidateAndCompleteWorkFlow() error = %v", err)
+	}
+}
+
+func TestCallActivity_FailurePropagatesToTop(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	top := tdb.addEntity(callActivityTestWorkFlow, "alice", 0, 0)
+	toptask := tdb.addTask(top, "review", wftype.TaskStatusWaiting, nil)
+	middle := tdb.addEntity(callActivityTestWorkFlow, "alice", top, toptask)
+	middletask := tdb.addTask(middle, "review", wftype.TaskStatusWaiting, nil)
+	child := tdb.addEntity(wftype.WorkFlow{Name: "Review", UUID: "review-uuid"}, "alice", middle, middletask)
+	failed := tdb.addTask(child, "approve", wftype.TaskStatusError, nil)
+
+	failCallActivities(failed, nil, "alice", fmt.Errorf("the trancode failed"))
+
+	for _, taskid := range []int64{middletask, toptask} {
+		if got := tdb.taskStatus(taskid); got != wftype.TaskStatusError {
+			t.Errorf("task %d status = %d, want %d", taskid, got, wftype.TaskStatusError)
+		}
+	}
+	if got := toInt64(tdb.value("SELECT count(*) FROM workflow_task_histories WHERE typecode = 'child workflow failed'")); got != 2 {
+		t.Errorf("history records = %d, want 2", got)
+	}
+}
+
+func TestCallActivity_CancelPropagates(t *testing.T) {
+	t.Run("parent cancels the child", func(t *testing.T) {
+		tdb := newWorkflowTestDB(t)
+
+		parent := tdb.addEntity(callActivityTestWorkFlow, "alice", 0, 0)
+		parenttask := tdb.addTask(parent, "review", wftype.TaskStatusWaiting, nil)
+		child := tdb.addEntity(wftype.WorkFlow{Name: "Review", UUID: "review-uuid"}, "alice", parent, parenttask)
+		childtask := tdb.addTask(child, "approve", wftype.TaskStatusStarted, nil)
+
+		if err := CancelWorkFlow(parent, "obsolete", "", "alice"); err != nil {
+			t.Fatalf("CancelWorkFlow() error = %v", err)
+		}
+
+		for _, entityid := range []int64{parent, child} {
+			if got := tdb.entityStatus(entityid); got != wftype.EntityStatusCancelled {
+				t.Errorf("entity %d status = %d, want %d", entityid, got, wftype.EntityStatusCancelled)
+			}
+		}
+		for _, taskid := range []int64{parenttask, childtask} {
+			if got := tdb.taskStatus(taskid); got != wftype.TaskStatusCancelled {
+				t.Errorf("task %d status = %d, want %d", taskid, got, wftype.TaskStatusCancelled)
+			}
+		}
+	})
+
+	t.Run("child cancels the parent", func(t *testing.T) {
+		tdb := newWorkflowTestDB(t)
+
+		parent := tdb.addEntity(callActivityTestWorkFlow, "alice", 0, 0)
+		parenttask := tdb.addTask(parent, "review", wftype.TaskStatusWaiting, nil)
+		child := tdb.addEntity(wftype.WorkFlow{Name: "Review", UUID: "review-uuid"}, "alice", parent, parenttask)
+		tdb.addTask(child, "approve", wftype.TaskStatusStarted, nil)
+
+		if err := CancelWorkFlow(child, "obsolete", "", "alice"); err != nil {
+			t.Fatalf("CancelWorkFlow() error = %v", err)
+		}
+
+		if got := tdb.entityStatus(parent); got != wftype.EntityStatusCancelled {
+			t.Errorf("parent status = %d, want %d", got, wftype.EntityStatusCancelled)
+		}
+		if got := tdb.taskStatus(parenttask); got != wftype.TaskStatusCancelled {
+			t.Errorf("parent task status = %d, want %d", got, wftype.TaskStatusCancelled)
+		}
+	})
+}
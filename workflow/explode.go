@@ -33,6 +33,11 @@ type ExplodionEngine struct {
 	CtxCancel    context.CancelFunc
 	UserName     string
 	ClientID     string
+
+	// the child workflow of a call activity, see startCallActivity
+	version        string
+	parentEntityID int64
+	parentTaskID   int64
 }
 
 func NewExplosion(WorkFlowName string, EntityName string, Type string, UserName string, ClientID string) *ExplodionEngine {
@@ -108,7 +113,7 @@ func (e *ExplodionEngine) Explode(Description string, EntityData map[string]inte
 	startNode := wftype.Node{}
 
 	for _, node := range Nodes {
-		if node.Type == "start" {
+		if node.Type == wftype.NodeTypeStart || node.Type == wftype.NodeTypeTimerStart {
 			e.Log.Debug(fmt.Sprintf("Workflow %s start node %s is %s ", e.WorkflowName, node.Name, e.Type))
 			startNode = node
 			break
@@ -129,7 +134,7 @@ func (e *ExplodionEngine) Explode(Description string, EntityData map[string]inte
 		if link.Source == startNode.ID {
 			e.Log.Debug(fmt.Sprintf("Workflow %s start node %s link %s ", e.WorkflowName, startNode.ID, link.Target))
 			targetnode := e.getNodeByID(link.Target, Nodes)
-			if targetnode.Type != "end" {
+			if targetnode.Type != wftype.NodeTypeEnd {
 				firstNodes = append(firstNodes, targetnode)
 			}
 		}
@@ -141,12 +146,14 @@ func (e *ExplodionEngine) Explode(Description string, EntityData map[string]inte
 		return 0, err
 	}
 
+	internaltransaction := false
 	if e.DBTx == nil {
 		e.DBTx, err = dbconn.DB.Begin()
 		if err != nil {
 			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.Explode: %s", err))
 			return 0, err
 		}
+		internaltransaction = true
 		defer e.DBTx.Rollback()
 	}
 
@@ -164,6 +171,11 @@ func (e *ExplodionEngine) Explode(Description string, EntityData map[string]inte
 	columns := []string{"typecode", "entity", "status", "description", "data", "workflowuuid", "workflow", "createdby", "createdon", "modifiedby", "modifiedon"}
 	values := []string{e.Type, e.EntityName, "1", Description, string(jsonEntityData), workflow.UUID, string(jsonString), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05"), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05")}
 
+	if e.parentTaskID != 0 {
+		columns = append(columns, "parententityid", "parenttaskid")
+		values = append(values, fmt.Sprintf("%d", e.parentEntityID), fmt.Sprintf("%d", e.parentTaskID))
+	}
+
 	wfentityid, err := dbop.TableInsert("workflow_entities", columns, values)
 
 	if err != nil {
@@ -173,21 +185,35 @@ func (e *ExplodionEngine) Explode(Description string, EntityData map[string]inte
 
 	pretaskdata := make(map[string]interface{})
 
+	// the first tasks of a child workflow get the data mapped from the parent
+	if e.parentTaskID != 0 {
+		for key, value := range EntityData {
+			pretaskdata[key] = value
+		}
+	}
+
 	for _, node := range firstNodes {
 		e.Log.Debug(fmt.Sprintf("Workflow %s first node %s explode ", e.WorkflowName, node.ID))
-		e.explodeNode(node, wfentityid, e.DocDBCon, e.DBTx, pretaskdata)
+		if err := e.explodeNode(node, startNode.ID, wfentityid, e.DocDBCon, e.DBTx, pretaskdata); err != nil {
+			return 0, err
+		}
 	}
 
-	err = e.DBTx.Commit()
-	if err != nil {
-		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.Explode: %s", err))
-		return 0, err
+	if internaltransaction {
+		err = e.DBTx.Commit()
+		if err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.Explode: %s", err))
+			return 0, err
+		}
 	}
 	return wfentityid, nil
 
 }
 
-func (e *ExplodionEngine) explodeNode(node wftype.Node, workflowentityid int64, DBConn *documents.DocDB, DBTx *sql.Tx, PreTaskData map[string]interface{}) {
+// explodeNode creates the workflow task of the node reached from the source node and executes it.
+// A join gateway only records the arrival of the branch until every incoming branch has arrived.
+// It returns the error that kept the task from being created, the caller rolls back the transaction.
+func (e *ExplodionEngine) explodeNode(node wftype.Node, SourceNodeID string, workflowentityid int64, DBConn *documents.DocDB, DBTx *sql.Tx, PreTaskData map[string]interface{}) (err error) {
 	startTime := time.Now()
 	defer func() {
 		elapsed := time.Since(startTime)
@@ -197,20 +223,28 @@ func (e *ExplodionEngine) explodeNode(node wftype.Node, workflowentityid int64,
 	defer func() {
 		if r := recover(); r != nil {
 			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode: %s", r))
-			return
+			err = fmt.Errorf("failed to create the task of the node %s: %v", node.ID, r)
 		}
 	}()
 
+	if node.Type == wftype.NodeTypeJoinGateway {
+		if err := e.arriveAtJoin(node, SourceNodeID, workflowentityid, DBConn, DBTx, PreTaskData); err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode - arrive at the join: %s", err))
+			return err
+		}
+		return nil
+	}
+
 	jsonData, err := json.Marshal(node.ProcessData)
 	if err != nil {
 		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode - convert the node processdata: %s", err))
-		return
+		return err
 	}
 
 	PreTaskjsonData, err := json.Marshal(PreTaskData)
 	if err != nil {
 		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode - convert the pretaskdata: %s", err))
-		return
+		return err
 	}
 
 	dbop := dbconn.NewDBOperation(e.UserName, DBTx, "Workflow.Explosion")
@@ -225,77 +259,30 @@ func (e *ExplodionEngine) explodeNode(node wftype.Node, workflowentityid int64,
 
 	if err != nil {
 		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode - insert the data to database: %s", err))
-		return
+		return err
 	}
 
 	e.Log.Debug(fmt.Sprintf("Workflow %s node %s explode taskid %d ", e.WorkflowName, node.ID, taskid))
-	roleids := []int64{}
 
 	notification := make(map[string]interface{})
-	notroles := make(map[string]interface{})
-	notusers := make(map[string]interface{})
-
-	for _, role := range node.Roles {
-
-		if role != "" {
-			rows, err := dbop.Query_Json(fmt.Sprintf("select id from roles where name = '%s'", role))
-
-			if err != nil {
-				e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode to get the role assignment: %s", err))
-
-			} else if len(rows) == 0 {
-				e.Log.Error(fmt.Sprintf("System does not find the role: %s", role))
-			} else {
-				roleid := rows[0]["id"].(int64)
-				roleids = append(roleids, roleid)
-				//columns = []string{"WorkflowTaskID", "RoleID", "createdby", "createdon", "updatedby", "updatedon"}
-				columns = []string{"workflowtaskid", "roleid", "createdby", "createdon", "modifiedby", "modifiedon"}
-				values = []string{fmt.Sprintf("%d", taskid), fmt.Sprintf("%d", roleid), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05"), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05")}
-
-				_, err = dbop.TableInsert("workflow_task_assignments", columns, values)
 
-				if err != nil {
-					e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during adding assignment: %s", err))
-					return
-				}
-
-				notroles[role] = 1
-			}
-		}
+	roleids, userids, notroles, notusers, err := e.assignTask(dbop, taskid, node.Roles, node.Users)
+	if err != nil {
+		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during adding assignment: %s", err))
+		return err
 	}
+	node.Roleids = roleids
+	node.Userids = userids
 
-	userids := []int64{}
-
-	for _, user := range node.Users {
-		if user != "" {
-			rows, err := dbop.Query_Json(fmt.Sprintf("select id, loginname from users where loginname = '%s' OR name = '%s'", user, user))
-
-			if err != nil {
-				e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during gettign userid: %s", err))
-			} else if len(rows) == 0 {
-				e.Log.Error(fmt.Sprintf("System does not find the user: %s", user))
-			} else {
-				userid := rows[0]["id"].(int64)
-				loginname := rows[0]["loginname"].(string)
-				userids = append(userids, userid)
-				//columns = []string{"WorkflowTaskID", "UserID", "createdby", "createdon", "updatedby", "updatedon"}
-				columns = []string{"workflowtaskid", "userid", "createdby", "createdon", "modifiedby", "modifiedon"}
-				values = []string{fmt.Sprintf("%d", taskid), fmt.Sprintf("%d", userid), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05"), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05")}
-
-				_, err = dbop.TableInsert("workflow_task_assignments", columns, values)
-
-				if err != nil {
-					e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during adding assignment: %s", err))
-					return
-				}
-				notusers[loginname] = 1
-			}
+	if node.Approval != nil || node.FourEyes {
+		err = setTaskApproval(dbop, node, taskid)
+		if err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during setting the approval: %s", err))
+			return err
 		}
 	}
-	node.Roleids = roleids
-	node.Userids = userids
 
-	if (node.Type == "task" || node.Type == "gateway") && node.Page != "" {
+	if (node.Type == wftype.NodeTypeTask || node.IsGateway()) && node.Page != "" {
 
 		e.Log.Debug(fmt.Sprintf("Workflow %s node %s explode page %s and send notification", e.WorkflowName, node.ID, node.Page))
 		notification["type"] = "workflow"
@@ -339,11 +326,93 @@ func (e *ExplodionEngine) explodeNode(node wftype.Node, workflowentityid int64,
 
 	if err != nil {
 		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during adding history records: %s", err))
-		return
+		return err
+	}
+
+	if node.Timer != nil && node.Type != wftype.NodeTypeTimerStart {
+		ProcessData := map[string]interface{}{}
+		for key, value := range PreTaskData {
+			ProcessData[key] = value
+		}
+		for key, value := range node.ProcessData {
+			ProcessData[key] = value
+		}
+
+		err = e.scheduleTaskTimers(dbop, node, taskid, workflowentityid, time.Now().UTC(), ProcessData)
+		if err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during scheduling the timers: %s", err))
+			return err
+		}
 	}
 
 	ExecuteTask(taskid, node, DBTx, DBConn, e.UserName)
 
+	return nil
+}
+
+// assignTask assigns the task to the roles and users, it returns the assigned ids and the names for the notification
+func (e *ExplodionEngine) assignTask(dbop *dbconn.DBOperation, taskid int64, Roles []string, Users []string) ([]int64, []int64, map[string]interface{}, map[string]interface{}, error) {
+	roleids := []int64{}
+	notroles := make(map[string]interface{})
+
+	for _, role := range Roles {
+
+		if role != "" {
+			rows, err := dbop.Query_Json(fmt.Sprintf("select id from roles where name = '%s'", role))
+
+			if err != nil {
+				e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode to get the role assignment: %s", err))
+
+			} else if len(rows) == 0 {
+				e.Log.Error(fmt.Sprintf("System does not find the role: %s", role))
+			} else {
+				roleid := rows[0]["id"].(int64)
+				roleids = append(roleids, roleid)
+				//columns = []string{"WorkflowTaskID", "RoleID", "createdby", "createdon", "updatedby", "updatedon"}
+				columns := []string{"workflowtaskid", "roleid", "createdby", "createdon", "modifiedby", "modifiedon"}
+				values := []string{fmt.Sprintf("%d", taskid), fmt.Sprintf("%d", roleid), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05"), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05")}
+
+				_, err = dbop.TableInsert("workflow_task_assignments", columns, values)
+
+				if err != nil {
+					return nil, nil, nil, nil, err
+				}
+
+				notroles[role] = 1
+			}
+		}
+	}
+
+	userids := []int64{}
+	notusers := make(map[string]interface{})
+
+	for _, user := range Users {
+		if user != "" {
+			rows, err := dbop.Query_Json(fmt.Sprintf("select id, loginname from users where loginname = '%s' OR name = '%s'", user, user))
+
+			if err != nil {
+				e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.explodeNode during gettign userid: %s", err))
+			} else if len(rows) == 0 {
+				e.Log.Error(fmt.Sprintf("System does not find the user: %s", user))
+			} else {
+				userid := rows[0]["id"].(int64)
+				loginname := rows[0]["loginname"].(string)
+				userids = append(userids, userid)
+				//columns = []string{"WorkflowTaskID", "UserID", "createdby", "createdon", "updatedby", "updatedon"}
+				columns := []string{"workflowtaskid", "userid", "createdby", "createdon", "modifiedby", "modifiedon"}
+				values := []string{fmt.Sprintf("%d", taskid), fmt.Sprintf("%d", userid), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05"), e.UserName, time.Now().UTC().Format("2006-01-02 15:04:05")}
+
+				_, err = dbop.TableInsert("workflow_task_assignments", columns, values)
+
+				if err != nil {
+					return nil, nil, nil, nil, err
+				}
+				notusers[loginname] = 1
+			}
+		}
+	}
+
+	return roleids, userids, notroles, notusers, nil
 }
 
 func (e *ExplodionEngine) getNodeByID(ID string, Nodes []wftype.Node) wftype.Node {
@@ -376,12 +445,18 @@ func (e *ExplodionEngine) getWorkFlowbyName() (primitive.M, error) {
 	e.Log.Info(fmt.Sprintf("Start to get workflow data %s's %s", e.WorkflowName, "Retrieven"))
 
 	filter := bson.M{"name": e.WorkflowName, "isdefault": true}
+	if e.version != "" {
+		filter = bson.M{"name": e.WorkflowName, "version": e.version}
+	}
 
 	workflowM, err := e.DocDBCon.QueryCollection("WorkFlow", filter, nil)
 	if err != nil {
 		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.getWorkFlowbyName: %s", err))
 		return nil, err
 	}
+	if len(workflowM) == 0 {
+		return nil, fmt.Errorf("the workflow %s %s is not found", e.WorkflowName, e.version)
+	}
 	e.Log.Debug(fmt.Sprintf("Workflow %s data %v ", e.WorkflowName, workflowM))
 	e.Log.Info(fmt.Sprintf("End to get workflow data %s's %s", e.WorkflowName, "Retrieven"))
 	return workflowM[0], nil
@@ -0,0 +1,291 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+/*
+GATEWAY DESIGN:
+
+A completed node hands the workflow on to the nodes returned by NextNodes:
+
+  - exclusivegateway: the first routing by sequence whose condition holds, the default routing if none holds
+  - inclusivegateway: every routing whose condition holds, the default routing if none holds
+  - gateway: every routing whose condition holds with the default routing always taken, as before
+  - parallelgateway and every other node: the targets of the outgoing links
+
+A joingateway synchronizes the branches of one workflow entity. Each branch that reaches the join records
+its source node in the joinarrivals column of a single workflow_tasks row of the join, which waits in the
+status TaskStatusWaiting. The arrivals are serialized by updating the workflow_entities row first, so
+concurrent completions on any instance see each other's arrivals, and the state survives a restart
+because it is only in the database. The arrival of the last incoming link starts the join task, which
+completes like a gateway and follows its outgoing links. A branch that arrives after the join fired starts
+a new waiting join, the next round of a loop.
+
+Every incoming link of the join is expected, so a join should close the branches of a parallel split,
+not the alternatives of an exclusive gateway.
+*/
+
+// NextNodes returns the nodes the workflow continues with after the node completed with the process data
+func NextNodes(node wftype.Node, workflow wftype.WorkFlow, ProcessData map[string]interface{}) ([]wftype.Node, error) {
+	if node.Type == wftype.NodeTypeEnd {
+		return []wftype.Node{}, nil
+	}
+
+	targets := []string{}
+
+	switch node.Type {
+	case wftype.NodeTypeGateway, wftype.NodeTypeExclusiveGateway, wftype.NodeTypeInclusiveGateway:
+		for _, routing := range selectRoutings(node, ProcessData) {
+			targets = append(targets, routing.Target)
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no routing of the gateway %s matches the process data: %v", node.ID, node.RoutingTables)
+		}
+
+	default:
+		for _, link := range workflow.Links {
+			if link.Source == node.ID {
+				targets = append(targets, link.Target)
+			}
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("the node %s has no outgoing link", node.ID)
+		}
+	}
+
+	nextNodes := []wftype.Node{}
+	for _, target := range targets {
+		found := false
+		for _, next := range workflow.Nodes {
+			if next.ID == target {
+				nextNodes = append(nextNodes, next)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the target node %s of the node %s does not exist", target, node.ID)
+		}
+	}
+
+	return nextNodes, nil
+}
+
+// selectRoutings returns the routings of the gateway to follow, in the order of their sequence
+func selectRoutings(node wftype.Node, ProcessData map[string]interface{}) []wftype.RoutingTable {
+	routings := make([]wftype.RoutingTable, len(node.RoutingTables))
+	copy(routings, node.RoutingTables)
+	sort.SliceStable(routings, func(i, j int) bool {
+		return routings[i].Sequence < routings[j].Sequence
+	})
+
+	if node.Type == wftype.NodeTypeGateway {
+		selected := []wftype.RoutingTable{}
+		for _, routing := range routings {
+			if CheckRoutingCondition(routing, ProcessData) {
+				selected = append(selected, routing)
+			}
+		}
+		return selected
+	}
+
+	selected, defaults := []wftype.RoutingTable{}, []wftype.RoutingTable{}
+	for _, routing := range routings {
+		if routing.Default {
+			defaults = append(defaults, routing)
+			continue
+		}
+		if CheckRoutingCondition(routing, ProcessData) {
+			selected = append(selected, routing)
+			if node.Type == wftype.NodeTypeExclusiveGateway {
+				return selected
+			}
+		}
+	}
+
+	if len(selected) > 0 {
+		return selected
+	}
+	if node.Type == wftype.NodeTypeExclusiveGateway && len(defaults) > 1 {
+		return defaults[:1]
+	}
+	return defaults
+}
+
+// compareRoutingValues compares the process data value with the value of the routing,
+// as numbers when both are numbers and as strings otherwise
+func compareRoutingValues(data interface{}, value string) int {
+	datastr := fmt.Sprint(data)
+
+	datanum, err1 := strconv.ParseFloat(datastr, 64)
+	valuenum, err2 := strconv.ParseFloat(value, 64)
+	if err1 == nil && err2 == nil {
+		switch {
+		case datanum < valuenum:
+			return -1
+		case datanum > valuenum:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(datastr, value)
+}
+
+// incomingSources returns the distinct source nodes of the links into the node
+func incomingSources(nodeID string, workflow wftype.WorkFlow) []string {
+	sources := []string{}
+	for _, link := range workflow.Links {
+		if link.Target == nodeID && !containsString(sources, link.Source) {
+			sources = append(sources, link.Source)
+		}
+	}
+	return sources
+}
+
+// joinComplete reports whether every incoming branch of the join has arrived
+func joinComplete(node wftype.Node, workflow wftype.WorkFlow, arrivals []string) bool {
+	for _, source := range incomingSources(node.ID, workflow) {
+		if !containsString(arrivals, source) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// arriveAtJoin records the arrival of the branch from the source node at the join of the workflow entity and
+// starts the join once every incoming branch has arrived
+func (e *ExplodionEngine) arriveAtJoin(node wftype.Node, SourceNodeID string, workflowentityid int64, DBConn *documents.DocDB, DBTx *sql.Tx, PreTaskData map[string]interface{}) error {
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		e.Log.PerformanceWithDuration("WorkFlow.Explosion.arriveAtJoin", elapsed)
+	}()
+
+	e.Log.Debug(fmt.Sprintf("Workflow entity %d branch from %s arrives at the join %s", workflowentityid, SourceNodeID, node.ID))
+
+	dbop := dbconn.NewDBOperation(e.UserName, DBTx, "Workflow.Explosion")
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	idColumn := dbop.QuoteIdentifier("id")
+
+	// lock the workflow entity so the arrivals of concurrent branches are serialized
+	_, err := dbop.TableUpdate("workflow_entities", []string{"modifiedon"}, []string{now}, []int{int(0)}, fmt.Sprintf("%s = %d", idColumn, workflowentityid))
+	if err != nil {
+		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - lock the workflow entity: %s", err))
+		return err
+	}
+
+	rows, err := dbop.Query_Json(fmt.Sprintf("select id, joinarrivals, pretaskdata from workflow_tasks where workflowentityid = %d AND workflownodeid = '%s' AND status = %d",
+		workflowentityid, node.ID, wftype.TaskStatusWaiting))
+	if err != nil {
+		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - get the waiting join: %s", err))
+		return err
+	}
+
+	var taskid int64
+	arrivals := []string{}
+	JoinData := map[string]interface{}{}
+
+	if len(rows) > 0 {
+		taskid = rows[0]["id"].(int64)
+		if rows[0]["joinarrivals"] != nil {
+			if err := json.Unmarshal([]byte(rows[0]["joinarrivals"].(string)), &arrivals); err != nil {
+				e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - convert the join arrivals: %s", err))
+				return err
+			}
+		}
+		if rows[0]["pretaskdata"] != nil {
+			if err := json.Unmarshal([]byte(rows[0]["pretaskdata"].(string)), &JoinData); err != nil {
+				e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - convert the pretaskdata: %s", err))
+				return err
+			}
+		}
+	}
+
+	if containsString(arrivals, SourceNodeID) {
+		e.Log.Info(fmt.Sprintf("Workflow entity %d branch from %s already arrived at the join %s", workflowentityid, SourceNodeID, node.ID))
+	} else {
+		arrivals = append(arrivals, SourceNodeID)
+	}
+	for key, value := range PreTaskData {
+		JoinData[key] = value
+	}
+
+	jsonArrivals, err := json.Marshal(arrivals)
+	if err != nil {
+		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - convert the join arrivals: %s", err))
+		return err
+	}
+	jsonJoinData, err := json.Marshal(JoinData)
+	if err != nil {
+		e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - convert the pretaskdata: %s", err))
+		return err
+	}
+
+	complete := joinComplete(node, e.workflow, arrivals)
+	status := wftype.TaskStatusWaiting
+	if complete {
+		status = wftype.TaskStatusCreated
+	}
+
+	if taskid == 0 {
+		jsonData, err := json.Marshal(node.ProcessData)
+		if err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - convert the node processdata: %s", err))
+			return err
+		}
+
+		columns := []string{"workflowentityid", "type", "status", "workflownodeid", "pretaskdata", "processdata", "joinarrivals", "page", "trancode", "createdby", "createdon", "modifiedby", "modifiedon"}
+		values := []string{fmt.Sprintf("%d", workflowentityid), node.Type, fmt.Sprintf("%d", status), node.ID, string(jsonJoinData), string(jsonData), string(jsonArrivals), node.Page, node.TranCode, e.UserName, now, e.UserName, now}
+
+		taskid, err = dbop.TableInsert("workflow_tasks", columns, values)
+		if err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - insert the join task: %s", err))
+			return err
+		}
+
+		columns = []string{"workflowentityid", "workflowtaskid", "typecode", "status", "createdby", "createdon", "modifiedby", "modifiedon"}
+		values = []string{fmt.Sprintf("%d", workflowentityid), fmt.Sprintf("%d", taskid), "create task", "1", e.UserName, now, e.UserName, now}
+		if _, err = dbop.TableInsert("workflow_task_histories", columns, values); err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin during adding history records: %s", err))
+			return err
+		}
+	} else {
+		columns := []string{"status", "joinarrivals", "pretaskdata", "modifiedby", "modifiedon"}
+		values := []string{fmt.Sprintf("%d", status), string(jsonArrivals), string(jsonJoinData), e.UserName, now}
+		datatypes := []int{int(1), int(0), int(0), int(0), int(0)}
+		if _, err = dbop.TableUpdate("workflow_tasks", columns, values, datatypes, fmt.Sprintf("%s = %d", idColumn, taskid)); err != nil {
+			e.Log.Error(fmt.Sprintf("Error in WorkFlow.Explosion.arriveAtJoin - update the join task: %s", err))
+			return err
+		}
+	}
+
+	if !complete {
+		e.Log.Debug(fmt.Sprintf("Workflow entity %d join %s waits, arrived %v", workflowentityid, node.ID, arrivals))
+		return nil
+	}
+
+	e.Log.Debug(fmt.Sprintf("Workflow entity %d join %s fires with task %d", workflowentityid, node.ID, taskid))
+	_, err = ExecuteTask(taskid, node, DBTx, DBConn, e.UserName)
+	return err
+}
@@ -0,0 +1,116 @@
+package workflow
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mdaxf/iac/framework/logs"
+	"github.com/mdaxf/iac/logger"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+func initGatewayTestLogger() {
+	if logger.FrameworkLogger == nil {
+		logger.FrameworkLogger = logs.NewLogger()
+	}
+}
+
+func gatewayTestWorkFlow(gatewayType string) wftype.WorkFlow {
+	initGatewayTestLogger()
+	return wftype.WorkFlow{
+		Nodes: []wftype.Node{
+			{ID: "gw", Type: gatewayType, RoutingTables: []wftype.RoutingTable{
+				{Sequence: 30, Default: true, Target: "c"},
+				{Sequence: 20, Data: "Amount", Operator: "gt", Value: "100", Target: "b"},
+				{Sequence: 10, Data: "Status", Value: "Approved", Target: "a"},
+			}},
+			{ID: "a", Type: wftype.NodeTypeTask}, {ID: "b", Type: wftype.NodeTypeTask}, {ID: "c", Type: wftype.NodeTypeTask},
+			{ID: "join", Type: wftype.NodeTypeJoinGateway}, {ID: "end", Type: wftype.NodeTypeEnd},
+		},
+		Links: []wftype.Link{
+			{Source: "gw", Target: "a"}, {Source: "gw", Target: "b"},
+			{Source: "a", Target: "join"}, {Source: "b", Target: "join"}, {Source: "join", Target: "end"},
+		},
+	}
+}
+
+func nextNodeIDs(t *testing.T, workflow wftype.WorkFlow, nodeID string, data map[string]interface{}) []string {
+	node := (&ExplodionEngine{}).getNodeByID(nodeID, workflow.Nodes)
+	nodes, err := NextNodes(node, workflow, data)
+	if err != nil {
+		t.Fatalf("NextNodes(%s) error = %v", nodeID, err)
+	}
+	ids := []string{}
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	return ids
+}
+
+func TestNextNodes_Gateways(t *testing.T) {
+	both := map[string]interface{}{"Status": "Approved", "Amount": 250.0}
+	none := map[string]interface{}{"Status": "Rejected", "Amount": 50}
+
+	tests := []struct {
+		gatewayType string
+		data        map[string]interface{}
+		want        []string
+	}{
+		{wftype.NodeTypeExclusiveGateway, both, []string{"a"}},
+		{wftype.NodeTypeExclusiveGateway, none, []string{"c"}},
+		{wftype.NodeTypeInclusiveGateway, both, []string{"a", "b"}},
+		{wftype.NodeTypeInclusiveGateway, none, []string{"c"}},
+		{wftype.NodeTypeGateway, both, []string{"a", "b", "c"}},
+		{wftype.NodeTypeParallelGateway, none, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		if got := nextNodeIDs(t, gatewayTestWorkFlow(tt.gatewayType), "gw", tt.data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NextNodes(%s, %v) = %v, want %v", tt.gatewayType, tt.data, got, tt.want)
+		}
+	}
+
+	workflow := gatewayTestWorkFlow(wftype.NodeTypeParallelGateway)
+	if got := nextNodeIDs(t, workflow, "join", nil); !reflect.DeepEqual(got, []string{"end"}) {
+		t.Errorf("NextNodes(join) = %v, want the outgoing links", got)
+	}
+	if got := nextNodeIDs(t, workflow, "end", nil); len(got) != 0 {
+		t.Errorf("NextNodes(end) = %v, want none", got)
+	}
+}
+
+func TestJoinComplete(t *testing.T) {
+	workflow := gatewayTestWorkFlow(wftype.NodeTypeParallelGateway)
+	join := workflow.Nodes[4]
+
+	if joinComplete(join, workflow, []string{"a"}) {
+		t.Errorf("joinComplete() with one of two branches = true")
+	}
+	if !joinComplete(join, workflow, []string{"b", "a"}) {
+		t.Errorf("joinComplete() with both branches = false")
+	}
+}
+
+func TestCheckRoutingCondition_Operators(t *testing.T) {
+	initGatewayTestLogger()
+	data := map[string]interface{}{"Status": "Approved", "Amount": 250.0, "Qty": "9"}
+
+	tests := []struct {
+		routing wftype.RoutingTable
+		want    bool
+	}{
+		{wftype.RoutingTable{Data: "Status", Value: "Approved"}, true},
+		{wftype.RoutingTable{Data: "Status", Operator: "ne", Value: "Approved"}, false},
+		{wftype.RoutingTable{Data: "Amount", Operator: "ge", Value: "250"}, true},
+		{wftype.RoutingTable{Data: "Qty", Operator: "lt", Value: "10"}, true},
+		{wftype.RoutingTable{Data: "Status", Operator: "in", Value: "Rejected, Approved"}, true},
+		{wftype.RoutingTable{Data: "Status", Operator: "contains", Value: "prov"}, true},
+		{wftype.RoutingTable{Data: "Missing", Operator: "exists"}, false},
+		{wftype.RoutingTable{Data: "Missing", Operator: "ne", Value: "x"}, true},
+		{wftype.RoutingTable{Default: true}, true},
+	}
+	for _, tt := range tests {
+		if got := CheckRoutingCondition(tt.routing, data); got != tt.want {
+			t.Errorf("CheckRoutingCondition(%+v) = %v, want %v", tt.routing, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/mdaxf/iac/com"
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/logger"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+// workflowTestSchema holds the columns of the workflow tables the engine reads and writes
+var workflowTestSchema = []string{
+	`CREATE TABLE workflow_entities (id INTEGER PRIMARY KEY AUTOINCREMENT, typecode TEXT, entity TEXT, status INTEGER,
+		description TEXT, data TEXT, workflowuuid TEXT, workflow TEXT, parententityid INTEGER NOT NULL DEFAULT 0,
+		parenttaskid INTEGER NOT NULL DEFAULT 0, completeddate TEXT, createdby TEXT, createdon TEXT, modifiedby TEXT, modifiedon TEXT)`,
+	`CREATE TABLE workflow_tasks (id INTEGER PRIMARY KEY AUTOINCREMENT, workflowentityid INTEGER, type TEXT, status INTEGER,
+		workflownodeid TEXT, pretaskdata TEXT, processdata TEXT, page TEXT, trancode TEXT, notificationuuid TEXT,
+		joinarrivals TEXT, duedate TEXT, claimedby TEXT, claimedon TEXT, version INTEGER NOT NULL DEFAULT 0,
+		requiredapprovals INTEGER NOT NULL DEFAULT 0, foureyes INTEGER NOT NULL DEFAULT 0,
+		starteddate TEXT, completeddate TEXT, createdby TEXT, createdon TEXT, modifiedby TEXT, modifiedon TEXT)`,
+	`CREATE TABLE workflow_task_histories (id INTEGER PRIMARY KEY AUTOINCREMENT, workflowentityid INTEGER, workflowtaskid INTEGER,
+		typecode TEXT, status INTEGER, reasoncode TEXT, comments TEXT, createdby TEXT, createdon TEXT, modifiedby TEXT, modifiedon TEXT)`,
+	`CREATE TABLE workflow_task_assignments (id INTEGER PRIMARY KEY AUTOINCREMENT, workflowtaskid INTEGER, roleid INTEGER,
+		userid INTEGER, delegatedby TEXT, createdby TEXT, createdon TEXT, modifiedby TEXT, modifiedon TEXT)`,
+	`CREATE TABLE workflow_task_approvals (id INTEGER PRIMARY KEY AUTOINCREMENT, workflowtaskid INTEGER, loginname TEXT,
+		onbehalfof TEXT, approved INTEGER, comments TEXT, createdby TEXT, createdon TEXT, UNIQUE (workflowtaskid, onbehalfof))`,
+	`CREATE TABLE workflow_substitutes (id INTEGER PRIMARY KEY AUTOINCREMENT, userid INTEGER, substituteid INTEGER,
+		startdate TEXT, enddate TEXT, createdby TEXT, createdon TEXT, modifiedby TEXT, modifiedon TEXT)`,
+	`CREATE TABLE workflow_timers (id INTEGER PRIMARY KEY AUTOINCREMENT, timerkey TEXT UNIQUE, timerkind TEXT,
+		workflowentityid INTEGER NOT NULL DEFAULT 0, workflowtaskid INTEGER NOT NULL DEFAULT 0, workflowuuid TEXT,
+		workflownodeid TEXT, dueat TEXT, statusid INTEGER NOT NULL DEFAULT 0, attempts INTEGER NOT NULL DEFAULT 0,
+		nextattemptat TEXT, lockedby TEXT, lockeduntil TEXT, lasterror TEXT, firedat TEXT, createdby TEXT,
+		createdon TEXT, modifiedon TEXT)`,
+	`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, loginname TEXT, name TEXT)`,
+	`CREATE TABLE roles (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)`,
+	`CREATE TABLE user_roles (id INTEGER PRIMARY KEY AUTOINCREMENT, userid INTEGER, roleid INTEGER)`,
+}
+
+// workflowTestDB is an in-memory database with the workflow tables, installed as the database of the engine
+type workflowTestDB struct {
+	t  *testing.T
+	db *sql.DB
+}
+
+func newWorkflowTestDB(t *testing.T) *workflowTestDB {
+	t.Helper()
+	initGatewayTestLogger()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open the test database: %v", err)
+	}
+	// one connection, every connection of an in-memory database is a database of its own
+	db.SetMaxOpenConns(1)
+
+	for _, statement := range workflowTestSchema {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("failed to create the test schema: %v", err)
+		}
+	}
+
+	previous, previousTimeout := dbconn.DB, com.DBTransactionTimeout
+	dbconn.DB, com.DBTransactionTimeout = db, 5
+	t.Cleanup(func() {
+		dbconn.DB, com.DBTransactionTimeout = previous, previousTimeout
+		db.Close()
+	})
+
+	return &workflowTestDB{t: t, db: db}
+}
+
+// exec runs a statement and returns the id of the inserted row
+func (tdb *workflowTestDB) exec(query string, args ...interface{}) int64 {
+	tdb.t.Helper()
+	result, err := tdb.db.Exec(query, args...)
+	if err != nil {
+		tdb.t.Fatalf("failed to execute %s: %v", query, err)
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// value returns the single value of a query
+func (tdb *workflowTestDB) value(query string, args ...interface{}) interface{} {
+	tdb.t.Helper()
+	var value interface{}
+	if err := tdb.db.QueryRow(query, args...).Scan(&value); err != nil {
+		tdb.t.Fatalf("failed to query %s: %v", query, err)
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+// addEntity adds an active workflow entity of the workflow, a child when the parent task is set
+func (tdb *workflowTestDB) addEntity(workflow wftype.WorkFlow, initiator string, parententityid int64, parenttaskid int64) int64 {
+	tdb.t.Helper()
+	definition, _ := json.Marshal(workflow)
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	return tdb.exec(`INSERT INTO workflow_entities (typecode, entity, status, workflowuuid, workflow, parententityid, parenttaskid, createdby, createdon)
+		VALUES ('ECN', 'ECN', ?, ?, ?, ?, ?, ?, ?)`, wftype.EntityStatusActive, workflow.UUID, string(definition), parententityid, parenttaskid, initiator, now)
+}
+
+// addTask adds a task of the node to the workflow entity
+func (tdb *workflowTestDB) addTask(entityid int64, nodeid string, status int, processdata map[string]interface{}) int64 {
+	tdb.t.Helper()
+	data, _ := json.Marshal(processdata)
+	return tdb.exec(`INSERT INTO workflow_tasks (workflowentityid, workflownodeid, status, processdata, createdon) VALUES (?, ?, ?, ?, ?)`,
+		entityid, nodeid, status, string(data), time.Now().UTC().Format("2006-01-02 15:04:05"))
+}
+
+// addUser adds a user with the roles, the roles are created when missing
+func (tdb *workflowTestDB) addUser(loginname string, roles ...string) int64 {
+	tdb.t.Helper()
+	userid := tdb.exec(`INSERT INTO users (loginname, name) VALUES (?, ?)`, loginname, loginname)
+	for _, role := range roles {
+		var roleid int64
+		if err := tdb.db.QueryRow(`SELECT id FROM roles WHERE name = ?`, role).Scan(&roleid); err != nil {
+			roleid = tdb.exec(`INSERT INTO roles (name) VALUES (?)`, role)
+		}
+		tdb.exec(`INSERT INTO user_roles (userid, roleid) VALUES (?, ?)`, userid, roleid)
+	}
+	return userid
+}
+
+// assign assigns the task to the user
+func (tdb *workflowTestDB) assign(taskid int64, userid int64) {
+	tdb.t.Helper()
+	tdb.exec(`INSERT INTO workflow_task_assignments (workflowtaskid, userid) VALUES (?, ?)`, taskid, userid)
+}
+
+// taskStatus returns the status of the task
+func (tdb *workflowTestDB) taskStatus(taskid int64) int64 {
+	tdb.t.Helper()
+	return toInt64(tdb.value(`SELECT status FROM workflow_tasks WHERE id = ?`, taskid))
+}
+
+// entityStatus returns the status of the workflow entity
+func (tdb *workflowTestDB) entityStatus(entityid int64) int64 {
+	tdb.t.Helper()
+	return toInt64(tdb.value(`SELECT status FROM workflow_entities WHERE id = ?`, entityid))
+}
+
+// inTx runs the function in a transaction of the engine database and commits it
+func (tdb *workflowTestDB) inTx(run func(tx *sql.Tx, dbop *dbconn.DBOperation) error) error {
+	tdb.t.Helper()
+	tx, err := dbconn.DB.Begin()
+	if err != nil {
+		tdb.t.Fatalf("failed to begin a transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := run(tx, dbconn.NewDBOperation("test", tx, logger.Framework)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
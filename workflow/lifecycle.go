@@ -0,0 +1,346 @@
+package workflow
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/logger"
+	"github.com/mdaxf/iac/models"
+	"github.com/mdaxf/iac/notifications"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+/*
+LIFECYCLE DESIGN:
+
+A workflow entity is active, suspended, completed or cancelled (workflow_entities.status):
+
+  - cancel: an active or suspended entity is cancelled with its open tasks
+  - suspend: an active entity stops, its tasks can not be started or completed and its timers hold
+  - resume: a suspended entity is active again and its held timers are due at their due dates
+  - restart from node: the open tasks are cancelled and the entity continues at the node with new process data
+
+The initiator of the entity and the workflow administrators may cancel, suspend and resume it, only the
+administrators may restart it from a node. Every operation requires a reason code and writes a history
+record with the reason code and comments. Cancelling the open tasks cancels their pending timers and updates
+their notifications, so no task of a cancelled path remains assigned. Cancelling also reaches the child and
+parent workflows of call activities, see CALL ACTIVITY DESIGN.
+*/
+
+var (
+	adminRoles     = []string{"Administrator"}
+	adminRolesLock sync.RWMutex
+)
+
+// ConfigureAdminRoles sets the roles of the workflow administrators, Administrator without roles
+func ConfigureAdminRoles(roles []string) {
+	adminRolesLock.Lock()
+	defer adminRolesLock.Unlock()
+
+	if len(roles) == 0 {
+		roles = []string{"Administrator"}
+	}
+	adminRoles = roles
+}
+
+// getAdminRoles returns the roles of the workflow administrators
+func getAdminRoles() []string {
+	adminRolesLock.RLock()
+	defer adminRolesLock.RUnlock()
+	return adminRoles
+}
+
+// lifecycleOperation is a change of the status of a workflow entity
+type lifecycleOperation struct {
+	name       string // history type code
+	adminOnly  bool
+	fromStatus []int
+	toStatus   int
+}
+
+var (
+	cancelOperation  = lifecycleOperation{name: "cancel workflow", fromStatus: []int{wftype.EntityStatusActive, wftype.EntityStatusSuspended}, toStatus: wftype.EntityStatusCancelled}
+	suspendOperation = lifecycleOperation{name: "suspend workflow", fromStatus: []int{wftype.EntityStatusActive}, toStatus: wftype.EntityStatusSuspended}
+	resumeOperation  = lifecycleOperation{name: "resume workflow", fromStatus: []int{wftype.EntityStatusSuspended}, toStatus: wftype.EntityStatusActive}
+	restartOperation = lifecycleOperation{name: "restart workflow", adminOnly: true,
+		fromStatus: []int{wftype.EntityStatusActive, wftype.EntityStatusSuspended, wftype.EntityStatusCompleted}, toStatus: wftype.EntityStatusActive}
+)
+
+// CancelWorkFlow cancels the workflow entity with its open tasks, its child workflows and its parent workflows
+func CancelWorkFlow(workflowentityid int64, ReasonCode string, Comments string, UserName string) error {
+	return changeWorkFlowStatus(workflowentityid, cancelOperation, ReasonCode, Comments, UserName, func(dbop *dbconn.DBOperation, DBTx *sql.Tx, entity map[string]interface{}) ([]string, error) {
+		uuids, err := cancelOpenTasks(dbop, workflowentityid, UserName)
+		if err != nil {
+			return nil, err
+		}
+
+		parentuuids, err := cancelParentWorkFlows(dbop, entity, UserName)
+		if err != nil {
+			return nil, err
+		}
+		return append(uuids, parentuuids...), nil
+	}, "Task Cancelled: "+ReasonCode)
+}
+
+// SuspendWorkFlow suspends the workflow entity, its tasks can not be started or completed and its timers hold
+func SuspendWorkFlow(workflowentityid int64, ReasonCode string, Comments string, UserName string) error {
+	return changeWorkFlowStatus(workflowentityid, suspendOperation, ReasonCode, Comments, UserName, func(dbop *dbconn.DBOperation, DBTx *sql.Tx, entity map[string]interface{}) ([]string, error) {
+		return openTaskNotifications(dbop, workflowentityid), updateEntityTimers(dbop, workflowentityid, models.WorkflowTimerStatusPending, models.WorkflowTimerStatusSuspended)
+	}, "Workflow Suspended: "+ReasonCode)
+}
+
+// ResumeWorkFlow resumes the suspended workflow entity, the timers that became due meanwhile fire at once
+func ResumeWorkFlow(workflowentityid int64, ReasonCode string, Comments string, UserName string) error {
+	return changeWorkFlowStatus(workflowentityid, resumeOperation, ReasonCode, Comments, UserName, func(dbop *dbconn.DBOperation, DBTx *sql.Tx, entity map[string]interface{}) ([]string, error) {
+		return openTaskNotifications(dbop, workflowentityid), updateEntityTimers(dbop, workflowentityid, models.WorkflowTimerStatusSuspended, models.WorkflowTimerStatusPending)
+	}, "Workflow Resumed: "+ReasonCode)
+}
+
+// RestartWorkFlowFromNode cancels the open tasks of the workflow entity and continues it at the node
+// with the process data. Only the workflow administrators may restart a workflow entity.
+func RestartWorkFlowFromNode(workflowentityid int64, NodeID string, ProcessData map[string]interface{}, ReasonCode string, Comments string, UserName string) error {
+	return changeWorkFlowStatus(workflowentityid, restartOperation, ReasonCode, Comments, UserName, func(dbop *dbconn.DBOperation, DBTx *sql.Tx, entity map[string]interface{}) ([]string, error) {
+		WorkFlow, _, err := GetWorkFlowbyUUID(fmt.Sprint(entity["workflowuuid"]), UserName, *documents.DocDBCon)
+		if err != nil {
+			return nil, err
+		}
+
+		node := (&ExplodionEngine{}).getNodeByID(NodeID, WorkFlow.Nodes)
+		switch node.Type {
+		case "":
+			return nil, fmt.Errorf("the node %s does not exist in the workflow %s", NodeID, WorkFlow.Name)
+		case wftype.NodeTypeStart, wftype.NodeTypeTimerStart, wftype.NodeTypeJoinGateway:
+			return nil, fmt.Errorf("the workflow can not restart from the %s node %s", node.Type, NodeID)
+		}
+
+		uuids, err := cancelOpenTasks(dbop, workflowentityid, UserName)
+		if err != nil {
+			return nil, err
+		}
+
+		NodeData := map[string]interface{}{}
+		for key, value := range node.ProcessData {
+			NodeData[key] = value
+		}
+		for key, value := range ProcessData {
+			NodeData[key] = value
+		}
+		node.ProcessData = NodeData
+
+		e := NewExplosion(WorkFlow.Name, fmt.Sprint(entity["entity"]), fmt.Sprint(entity["typecode"]), UserName, "")
+		e.workflow = WorkFlow
+		if err := e.explodeNode(node, "", workflowentityid, documents.DocDBCon, DBTx, ProcessData); err != nil {
+			return nil, err
+		}
+
+		return uuids, nil
+	}, "Task Cancelled: restarted from "+NodeID)
+}
+
+// changeWorkFlowStatus checks the permission and the status of the workflow entity, runs the operation and
+// records the new status with the history in one transaction. The notifications returned by the operation
+// are updated with the message after the commit.
+func changeWorkFlowStatus(workflowentityid int64, operation lifecycleOperation, ReasonCode string, Comments string, UserName string,
+	apply func(dbop *dbconn.DBOperation, DBTx *sql.Tx, entity map[string]interface{}) ([]string, error), message string) error {
+	iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow lifecycle"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("changeWorkFlowStatus", elapsed)
+	}()
+
+	iLog.Debug(fmt.Sprintf("%s %d with the reason %s", operation.name, workflowentityid, ReasonCode))
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+		return err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
+	idColumn := dbop.QuoteIdentifier("id")
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	// lock the workflow entity against concurrent operations and task completions
+	_, err = dbop.TableUpdate("workflow_entities", []string{"modifiedon"}, []string{now}, []int{int(0)}, fmt.Sprintf("%s = %d", idColumn, workflowentityid))
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in locking the workflow entity: %s", err))
+		return err
+	}
+
+	rows, err := dbop.Query_Json(fmt.Sprintf("select id, status, createdby, workflowuuid, entity, typecode, parententityid from workflow_entities where id = %d", workflowentityid))
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("the workflow entity %d does not exist", workflowentityid)
+	}
+	entity := rows[0]
+
+	isAdmin, err := isWorkFlowAdmin(dbop, UserName)
+	if err != nil {
+		return err
+	}
+	if err = checkLifecycleOperation(operation, workflowentityid, int(toInt64(entity["status"])), fmt.Sprint(entity["createdby"]), UserName, isAdmin, ReasonCode); err != nil {
+		iLog.Error(err.Error())
+		return err
+	}
+
+	Columns := []string{"status", "modifiedby", "modifiedon"}
+	Values := []string{fmt.Sprintf("%d", operation.toStatus), UserName, now}
+	datatypes := []int{int(1), int(0), int(0)}
+	if _, err = dbop.TableUpdate("workflow_entities", Columns, Values, datatypes, fmt.Sprintf("%s = %d", idColumn, workflowentityid)); err != nil {
+		iLog.Error(fmt.Sprintf("Error in updating the workflow entity: %s", err))
+		return err
+	}
+
+	uuids, err := apply(dbop, DBTx, entity)
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in %s %d: %s", operation.name, workflowentityid, err))
+		return err
+	}
+
+	if err = addWorkFlowHistory(dbop, workflowentityid, operation, ReasonCode, Comments, UserName); err != nil {
+		iLog.Error(fmt.Sprintf("Error in adding the history record: %s", err))
+		return err
+	}
+
+	if err = DBTx.Commit(); err != nil {
+		return err
+	}
+
+	go func() {
+		for _, uuid := range uuids {
+			notifications.UpdateNotificationbyUUID(uuid, UserName, message)
+		}
+	}()
+
+	return nil
+}
+
+// checkLifecycleOperation returns the error that keeps the user from the operation on the workflow entity in the
+// status, started by the initiator. The operation needs a reason code and is allowed to the workflow administrators
+// and, unless it is for the administrators only, to the initiator.
+func checkLifecycleOperation(operation lifecycleOperation, workflowentityid int64, status int, initiator string, UserName string, isAdmin bool, ReasonCode string) error {
+	if strings.TrimSpace(ReasonCode) == "" {
+		return fmt.Errorf("a reason code is required to %s", operation.name)
+	}
+	if !isAdmin && (operation.adminOnly || initiator != UserName) {
+		return fmt.Errorf("the user %s is not allowed to %s %d", UserName, operation.name, workflowentityid)
+	}
+	for _, from := range operation.fromStatus {
+		if status == from {
+			return nil
+		}
+	}
+	return fmt.Errorf("can not %s %d in the status %d", operation.name, workflowentityid, status)
+}
+
+// addWorkFlowHistory records a lifecycle operation on the workflow entity with the reason
+func addWorkFlowHistory(dbop *dbconn.DBOperation, workflowentityid int64, operation lifecycleOperation, ReasonCode string, Comments string, UserName string) error {
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+	columns := []string{"workflowentityid", "workflowtaskid", "typecode", "status", "reasoncode", "comments", "createdby", "createdon", "modifiedby", "modifiedon"}
+	values := []string{fmt.Sprintf("%d", workflowentityid), "0", operation.name, fmt.Sprintf("%d", operation.toStatus), ReasonCode, Comments, UserName, now, UserName, now}
+	_, err := dbop.TableInsert("workflow_task_histories", columns, values)
+	return err
+}
+
+// isWorkFlowAdmin reports whether the user has a role of the workflow administrators
+func isWorkFlowAdmin(dbop *dbconn.DBOperation, UserName string) (bool, error) {
+	roles := []string{}
+	for _, role := range getAdminRoles() {
+		roles = append(roles, fmt.Sprintf("'%s'", strings.ReplaceAll(role, "'", "''")))
+	}
+
+	rows, err := dbop.Query_Json(fmt.Sprintf(`select r.id from user_roles ur
+			INNER JOIN roles r on r.id = ur.roleid
+			INNER JOIN users u on u.id = ur.userid
+			where u.loginname = '%s' AND r.name IN (%s)`, strings.ReplaceAll(UserName, "'", "''"), strings.Join(roles, ", ")))
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+// openTaskNotifications returns the notifications of the open tasks of the workflow entity
+func openTaskNotifications(dbop *dbconn.DBOperation, workflowentityid int64) []string {
+	rows, err := dbop.Query_Json(fmt.Sprintf("select notificationuuid from workflow_tasks where workflowentityid = %d AND status NOT IN (%d, %d) AND notificationuuid IS NOT NULL",
+		workflowentityid, wftype.TaskStatusCompleted, wftype.TaskStatusCancelled))
+	if err != nil {
+		return []string{}
+	}
+
+	uuids := []string{}
+	for _, row := range rows {
+		if uuid := fmt.Sprint(row["notificationuuid"]); uuid != "" {
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids
+}
+
+// cancelOpenTasks cancels the open tasks of the workflow entity with their timers and child workflows and returns
+// their notifications
+func cancelOpenTasks(dbop *dbconn.DBOperation, workflowentityid int64, UserName string) ([]string, error) {
+	childuuids, err := cancelChildWorkFlows(dbop, workflowentityid, UserName)
+	if err != nil {
+		return nil, err
+	}
+	uuids := append(openTaskNotifications(dbop, workflowentityid), childuuids...)
+
+	Columns := []string{"status", "modifiedby", "modifiedon"}
+	Values := []string{fmt.Sprintf("%d", wftype.TaskStatusCancelled), UserName, time.Now().UTC().Format("2006-01-02 15:04:05")}
+	datatypes := []int{int(1), int(0), int(0)}
+	Where := fmt.Sprintf("workflowentityid = %d AND status NOT IN (%d, %d)", workflowentityid, wftype.TaskStatusCompleted, wftype.TaskStatusCancelled)
+	if _, err := dbop.TableUpdate("workflow_tasks", Columns, Values, datatypes, Where); err != nil {
+		return nil, err
+	}
+
+	if err := updateEntityTimers(dbop, workflowentityid, models.WorkflowTimerStatusPending, models.WorkflowTimerStatusCancelled); err != nil {
+		return nil, err
+	}
+	if err := updateEntityTimers(dbop, workflowentityid, models.WorkflowTimerStatusSuspended, models.WorkflowTimerStatusCancelled); err != nil {
+		return nil, err
+	}
+
+	return uuids, nil
+}
+
+// updateEntityTimers moves the timers of the workflow entity from one status to another
+func updateEntityTimers(dbop *dbconn.DBOperation, workflowentityid int64, from models.WorkflowTimerStatus, to models.WorkflowTimerStatus) error {
+	Columns := []string{"statusid", "modifiedon"}
+	Values := []string{fmt.Sprintf("%d", to), time.Now().UTC().Format("2006-01-02 15:04:05")}
+	datatypes := []int{int(1), int(0)}
+	Where := fmt.Sprintf("workflowentityid = %d AND statusid = %d", workflowentityid, from)
+
+	_, err := dbop.TableUpdate("workflow_timers", Columns, Values, datatypes, Where)
+	return err
+}
+
+// checkTaskActive returns an error when the task is cancelled or its workflow entity is not active
+func checkTaskActive(dbop *dbconn.DBOperation, taskid int64) error {
+	rows, err := dbop.Query_Json(fmt.Sprintf("select t.status as taskstatus, e.status as entitystatus from workflow_tasks t inner join workflow_entities e on e.id = t.workflowentityid where t.id = %d", taskid))
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("the workflow task %d does not exist", taskid)
+	}
+
+	if fmt.Sprint(rows[0]["taskstatus"]) == fmt.Sprint(wftype.TaskStatusCancelled) {
+		return fmt.Errorf("the workflow task %d is cancelled", taskid)
+	}
+	switch fmt.Sprint(rows[0]["entitystatus"]) {
+	case fmt.Sprint(wftype.EntityStatusSuspended):
+		return fmt.Errorf("the workflow of the task %d is suspended", taskid)
+	case fmt.Sprint(wftype.EntityStatusCancelled):
+		return fmt.Errorf("the workflow of the task %d is cancelled", taskid)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/mdaxf/iac/models"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+func TestCheckLifecycleOperation(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation lifecycleOperation
+		status    int
+		user      string
+		isAdmin   bool
+		reason    string
+		allowed   bool
+	}{
+		{"initiator cancels an active entity", cancelOperation, wftype.EntityStatusActive, "alice", false, "obsolete", true},
+		{"initiator cancels a suspended entity", cancelOperation, wftype.EntityStatusSuspended, "alice", false, "obsolete", true},
+		{"cancel a completed entity", cancelOperation, wftype.EntityStatusCompleted, "alice", false, "obsolete", false},
+		{"cancel a cancelled entity", cancelOperation, wftype.EntityStatusCancelled, "admin", true, "obsolete", false},
+		{"another user cancels", cancelOperation, wftype.EntityStatusActive, "bob", false, "obsolete", false},
+		{"administrator cancels", cancelOperation, wftype.EntityStatusActive, "admin", true, "obsolete", true},
+		{"cancel without a reason", cancelOperation, wftype.EntityStatusActive, "alice", false, " ", false},
+		{"initiator suspends an active entity", suspendOperation, wftype.EntityStatusActive, "alice", false, "on hold", true},
+		{"suspend a suspended entity", suspendOperation, wftype.EntityStatusSuspended, "alice", false, "on hold", false},
+		{"another user suspends", suspendOperation, wftype.EntityStatusActive, "bob", false, "on hold", false},
+		{"initiator resumes a suspended entity", resumeOperation, wftype.EntityStatusSuspended, "alice", false, "released", true},
+		{"resume an active entity", resumeOperation, wftype.EntityStatusActive, "alice", false, "released", false},
+		{"resume without a reason", resumeOperation, wftype.EntityStatusSuspended, "admin", true, "", false},
+		{"initiator restarts", restartOperation, wftype.EntityStatusActive, "alice", false, "rework", false},
+		{"administrator restarts an active entity", restartOperation, wftype.EntityStatusActive, "admin", true, "rework", true},
+		{"administrator restarts a completed entity", restartOperation, wftype.EntityStatusCompleted, "admin", true, "rework", true},
+		{"administrator restarts a cancelled entity", restartOperation, wftype.EntityStatusCancelled, "admin", true, "rework", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLifecycleOperation(tt.operation, 1, tt.status, "alice", tt.user, tt.isAdmin, tt.reason)
+			if (err == nil) != tt.allowed {
+				t.Errorf("checkLifecycleOperation() = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestFireTimer_InactiveEntity(t *testing.T) {
+	tdb := newWorkflowTestDB(t)
+
+	for _, status := range []int{wftype.EntityStatusSuspended, wftype.EntityStatusCancelled, wftype.EntityStatusCompleted} {
+		entity := tdb.addEntity(wftype.WorkFlow{Name: "ECN", UUID: "ecn-uuid"}, "alice", 0, 0)
+		tdb.exec(`UPDATE workflow_entities SET status = ? WHERE id = ?`, status, entity)
+		task := tdb.addTask(entity, "review", wftype.TaskStatusCreated, nil)
+
+		// an escalation of an active entity would load the workflow and reassign the task
+		timer := &models.WorkflowTimer{TimerKey: "escalation", TimerKind: wftype.TimerKindEscalation, WorkflowEntityID: entity,
+			WorkflowTaskID: task, WorkflowUUID: "ecn-uuid", WorkflowNodeID: "review"}
+		if err := FireTimer(timer, "System"); err != nil {
+			t.Errorf("FireTimer() for the entity in the status %d = %v, want the timer skipped", status, err)
+		}
+		if histories := toInt64(tdb.value(`SELECT count(*) FROM workflow_task_histories WHERE workflowtaskid = ?`, task)); histories != 0 {
+			t.Errorf("FireTimer() for the entity in the status %d wrote %d histories, want none", status, histories)
+		}
+	}
+}
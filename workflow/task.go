@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +31,8 @@ type WorkFlowTask struct {
 	UserName       string
 	ClientID       string
 	iLog           logger.Log
+
+	approvalDecided bool // the approval task completes with its decided outcome, see ApproveTask
 }
 
 // NewWorkFlowTaskType creates a new instance of WorkFlowTask with the specified parameters.
@@ -140,6 +143,16 @@ func (wft *WorkFlowTask) StartTask() error {
 	}
 	dbop := dbconn.NewDBOperation(wft.UserName, DBTx, logger.Framework)
 
+	if err = checkTaskActive(dbop, wft.WorkFlowTaskID); err != nil {
+		wft.iLog.Error(fmt.Sprintf("The task can not be started: %s", err))
+		return err
+	}
+
+	if _, err = checkTaskActor(dbop, wft.WorkFlowTaskID, wft.UserName); err != nil {
+		wft.iLog.Error(fmt.Sprintf("The task can not be started: %s", err))
+		return err
+	}
+
 	//rows, err := dbop.Query_Json(fmt.Sprintf("select WorkflowEntityID, WorkflowNodeID, NotificationUUID from workflow_tasks where ID = %d", wft.WorkFlowTaskID))
 	rows, err := dbop.Query_Json(fmt.Sprintf("select workflowentityid, workflownodeid, notificationuuid from workflow_tasks where id = %d", wft.WorkFlowTaskID))
 
@@ -285,6 +298,16 @@ func (wft *WorkFlowTask) ExecuteTaskTranCode(TranCode string) error {
 	}
 	dbop := dbconn.NewDBOperation(wft.UserName, DBTx, logger.Framework)
 
+	if err = checkTaskActive(dbop, wft.WorkFlowTaskID); err != nil {
+		wft.iLog.Error(fmt.Sprintf("The trancode of the task can not be executed: %s", err))
+		return err
+	}
+
+	if _, err = checkTaskActor(dbop, wft.WorkFlowTaskID, wft.UserName); err != nil {
+		wft.iLog.Error(fmt.Sprintf("The trancode of the task can not be executed: %s", err))
+		return err
+	}
+
 	//rows, err := dbop.Query_Json(fmt.Sprintf("select WorkflowEntityID, WorkflowNodeID, ProcessData, NotificationUUID from workflow_tasks where ID = %d", wft.WorkFlowTaskID))
 	rows, err := dbop.Query_Json(fmt.Sprintf("select workflowentityid, workflownodeid, processdata, notificationuuid from workflow_tasks where id = %d", wft.WorkFlowTaskID))
 
@@ -334,8 +357,7 @@ func (wft *WorkFlowTask) ExecuteTaskTranCode(TranCode string) error {
 
 // CompleteTask completes the workflow task.
 // It updates the status and completed date of the task in the database.
-// If the task is a gateway, it checks the routing table to determine the next nodes.
-// If the task is a task node, it follows the links to find the next nodes.
+// The next nodes are determined by NextNodes, a gateway checks its routing table and other nodes follow their links.
 // If the task is an end node, it marks the workflow as completed and triggers the validation and completion process.
 // After completing the task, it updates the notification associated with the task.
 // If the task is part of an internal transaction, it commits the transaction.
@@ -368,17 +390,47 @@ func (wft *WorkFlowTask) CompleteTask() error {
 	}
 	dbop := dbconn.NewDBOperation(wft.UserName, DBTx, logger.Framework)
 
-	Columns := []string{"status", "completedDate"}
-	Values := []string{fmt.Sprintf("%d", 5), time.Now().UTC().Format("2006-01-02 15:04:05")}
-	datatypes := []int{int(1), int(0)}
+	if err = checkTaskActive(dbop, wft.WorkFlowTaskID); err != nil {
+		wft.iLog.Error(fmt.Sprintf("The task can not be completed: %s", err))
+		return err
+	}
+
+	task, err := checkTaskActor(dbop, wft.WorkFlowTaskID, wft.UserName)
+	if err != nil {
+		wft.iLog.Error(fmt.Sprintf("The task can not be completed: %s", err))
+		return err
+	}
+	if toInt64(task["requiredapprovals"]) > 0 && !wft.approvalDecided {
+		err = fmt.Errorf("the approval task %d completes when its assignees approve or reject it", wft.WorkFlowTaskID)
+		wft.iLog.Error(fmt.Sprintf("The task can not be completed: %s", err))
+		return err
+	}
+
+	// the update only succeeds for the version the task was read in while it is open, so the second of two
+	// concurrent completions fails instead of routing the workflow twice
+	Version := toInt64(task["version"])
+	Columns := []string{"status", "completedDate", "version"}
+	Values := []interface{}{wftype.TaskStatusCompleted, time.Now().UTC().Format("2006-01-02 15:04:05"), Version + 1}
+	datatypes := []int{int(1), int(0), int(1)}
 	// Use dialect-specific identifier quoting for database portability
 	idColumn := dbop.QuoteIdentifier("id")
-	Where := fmt.Sprintf("%s = %d", idColumn, wft.WorkFlowTaskID)
-	_, err = dbop.TableUpdate("workflow_tasks", Columns, Values, datatypes, Where)
+	Where := fmt.Sprintf("%s = %d AND version = %d AND status IN (%d, %d)", idColumn, wft.WorkFlowTaskID, Version, wftype.TaskStatusCreated, wftype.TaskStatusStarted)
+	count, err := dbop.TableUpdate_v2("workflow_tasks", Columns, Values, datatypes, Where)
 	if err != nil {
 		wft.iLog.Error(fmt.Sprintf("Error in updating workflow tasks: %s", err))
 		return err
 	}
+	if count == 0 {
+		err = fmt.Errorf("the task %d was completed or changed by another user", wft.WorkFlowTaskID)
+		wft.iLog.Error(fmt.Sprintf("The task can not be completed: %s", err))
+		return err
+	}
+
+	err = cancelTaskTimers(dbop, wft.WorkFlowTaskID)
+	if err != nil {
+		wft.iLog.Error(fmt.Sprintf("Error in cancelling the timers of the workflow task: %s", err))
+		return err
+	}
 
 	rows, err := dbop.Query_Json(fmt.Sprintf("select workflowentityid, workflownodeid, processdata, notificationuuid from workflow_tasks where id = %d", wft.WorkFlowTaskID))
 	if err != nil {
@@ -455,7 +507,6 @@ func (wft *WorkFlowTask) CompleteTask() error {
 	}
 
 	Nodes := WorkFlow.Nodes
-	Links := WorkFlow.Links
 
 	// Get the current node routing table
 	currentNode := wftype.Node{}
@@ -468,65 +519,22 @@ func (wft *WorkFlowTask) CompleteTask() error {
 
 	nextNodes := []wftype.Node{}
 
-	//RoutingTable := currentNode.RoutingTable
-	/*
-		{
-			"sequence": 10,
-			"data": "Status",
-			"operator": "eq",
-			"value": "Approved",
-			"target": "nodeid"
-		}
-
-
-	*/
-	if currentNode.Type == "gateway" {
-		// check the routign table
-		RoutingTables := currentNode.RoutingTables
-		for _, routing := range RoutingTables {
-			if CheckRoutingCondition(routing, ProcessData) {
-				targetNodeID := routing.Target
-				for _, node := range Nodes {
-					if node.ID == targetNodeID {
-						nextNodes = append(nextNodes, node)
-						break
-					}
-				}
-				if len(nextNodes) == 0 {
-					err = fmt.Errorf("Error in getting next node with routing: %v", routing)
-					wft.iLog.Error(fmt.Sprintf("Error in getting next node: %s", err))
-					return err
-				}
-			}
-		}
+	if currentNode.Type == wftype.NodeTypeEnd {
+		wft.iLog.Debug(fmt.Sprintf("Workflow completed for workflowtaskid: %d", wft.WorkFlowTaskID))
 
-		if len(nextNodes) == 0 {
-			err = fmt.Errorf("Error in getting next node with routing table: %v", RoutingTables)
-			wft.iLog.Error(fmt.Sprintf("Error in getting next node: %s", err))
+		// the entity completes in the transaction of its end task, so a parent waiting in a call activity
+		// continues with the commit of the child instead of racing it
+		_, err = ValidateAndCompleteWorkFlow(WorkflowEntityID, DBTx, wft.DocDBCon, wft.UserName)
+		if err != nil {
+			wft.iLog.Error(fmt.Sprintf("Error in completing the workflow entity %d: %s", WorkflowEntityID, err))
 			return err
 		}
-	} else if currentNode.Type == "task" {
-		for _, link := range Links {
-			if link.Source == WorkflowNodeID {
-				for _, node := range Nodes {
-					if node.ID == link.Target {
-						nextNodes = append(nextNodes, node)
-					}
-				}
-			}
-		}
-		if len(nextNodes) == 0 {
-			err = fmt.Errorf("Error in getting next node for the task: %v", currentNode)
+	} else {
+		nextNodes, err = NextNodes(currentNode, WorkFlow, ProcessData)
+		if err != nil {
 			wft.iLog.Error(fmt.Sprintf("Error in getting next node: %s", err))
 			return err
 		}
-
-	} else if currentNode.Type == "end" {
-		wft.iLog.Debug(fmt.Sprintf("Workflow completed for workflowtaskid: %d", wft.WorkFlowTaskID))
-
-		go func() {
-			ValidateAndCompleteWorkFlow(WorkflowEntityID, DBTx, wft.DocDBCon, wft.UserName)
-		}()
 	}
 
 	go func() {
@@ -540,7 +548,7 @@ func (wft *WorkFlowTask) CompleteTask() error {
 		// Add 1 to the wait group
 		wg.Add(1)
 
-		go ExplodeNextNodes(&wg, WorkflowEntityID, nextNodes, wft.UserName, ProcessData)
+		go ExplodeNextNodes(&wg, WorkflowEntityID, WorkFlow, WorkflowNodeID, nextNodes, wft.UserName, ProcessData)
 
 		wg.Wait()
 	}
@@ -560,12 +568,14 @@ func (wft *WorkFlowTask) CompleteTask() error {
 // Parameters:
 // - wg: A pointer to a sync.WaitGroup used to wait for all tasks to complete.
 // - WorkflowEntityID: The ID of the workflow entity.
+// - WorkFlow: The workflow of the entity.
+// - SourceNodeID: The ID of the completed node the next nodes follow.
 // - nextNodes: A slice of wftype.Node representing the next nodes in the workflow.
 // - UserName: The name of the user performing the operation.
 //
 // Returns:
 // - An error if any error occurs during the process, otherwise nil.
-func ExplodeNextNodes(wg *sync.WaitGroup, WorkflowEntityID int64, nextNodes []wftype.Node, UserName string, ProcessData map[string]interface{}) error {
+func ExplodeNextNodes(wg *sync.WaitGroup, WorkflowEntityID int64, WorkFlow wftype.WorkFlow, SourceNodeID string, nextNodes []wftype.Node, UserName string, ProcessData map[string]interface{}) error {
 
 	defer wg.Done()
 
@@ -591,10 +601,13 @@ func ExplodeNextNodes(wg *sync.WaitGroup, WorkflowEntityID int64, nextNodes []wf
 	DocDBCon := documents.DocDBCon
 	//	defer DocDBCon.MongoDBClient.Disconnect(context.Background())
 
-	wfexplode := NewExplosion("", "", "", UserName, "")
+	wfexplode := NewExplosion(WorkFlow.Name, "", "", UserName, "")
+	wfexplode.workflow = WorkFlow
 	for _, node := range nextNodes {
 		// create new workflow task
-		wfexplode.explodeNode(node, WorkflowEntityID, DocDBCon, idbTx, ProcessData)
+		if err := wfexplode.explodeNode(node, SourceNodeID, WorkflowEntityID, DocDBCon, idbTx, ProcessData); err != nil {
+			return err
+		}
 	}
 
 	idbTx.Commit()
@@ -641,7 +654,7 @@ func ValidateAndCompleteWorkFlow(WorkFlowEntityID int64, idbTx *sql.Tx, DocDBCon
 
 	dbop := dbconn.NewDBOperation(UserName, idbTx, logger.Framework)
 
-	tasks, err := dbop.Query_Json(fmt.Sprintf("select * from workflow_tasks where workflowentityid = %d AND status != %d", WorkFlowEntityID, 5))
+	tasks, err := dbop.Query_Json(fmt.Sprintf("select * from workflow_tasks where workflowentityid = %d AND status NOT IN (%d, %d)", WorkFlowEntityID, wftype.TaskStatusCompleted, wftype.TaskStatusCancelled))
 	if err != nil {
 		iLog.Error(fmt.Sprintf("Error in getting workflow tasks: %s", err))
 
@@ -667,6 +680,14 @@ func ValidateAndCompleteWorkFlow(WorkFlowEntityID int64, idbTx *sql.Tx, DocDBCon
 			iLog.Error(fmt.Sprintf("Error in updating workflow entities: %s", err))
 			return false, err
 		}
+
+		// a child workflow continues the call activity of its parent
+		err = completeCallActivity(dbop, idbTx, DocDBCon, WorkFlowEntityID, UserName)
+		if err != nil {
+			iLog.Error(fmt.Sprintf("Error in completing the call activity of the workflow entity %d: %s", WorkFlowEntityID, err))
+			return false, err
+		}
+
 		if internaltransaction {
 			idbTx.Commit()
 		}
@@ -685,9 +706,11 @@ func ValidateAndCompleteWorkFlow(WorkFlowEntityID int64, idbTx *sql.Tx, DocDBCon
 
 // CheckRoutingCondition checks the routing condition based on the provided RoutingTable and ProcessData.
 // It returns true if the routing condition is met, otherwise false.
-// The routing condition is met if the ProcessData contains the data specified in the RoutingTable and the value of the data is equal to the value specified in the RoutingTable.
+// The routing condition compares the data specified in the RoutingTable with the value of the RoutingTable using its operator:
+// eq (the default), ne, gt, ge, lt, le, in (a comma separated list of values), contains and exists.
+// Values that are both numbers are compared as numbers, other values as strings.
 // If the RoutingTable is the default routing table, it returns true.
-// If the ProcessData does not contain the data specified in the RoutingTable, it returns false.
+// If the ProcessData does not contain the data specified in the RoutingTable, it returns false, except for the ne operator.
 func CheckRoutingCondition(Routing wftype.RoutingTable, ProcessData map[string]interface{}) bool {
 	iLog := logger.Log{ModuleName: logger.Framework, ControllerName: "workflow tasks check routing condition"}
 	startTime := time.Now()
@@ -702,14 +725,41 @@ func CheckRoutingCondition(Routing wftype.RoutingTable, ProcessData map[string]i
 		return true
 	}
 
-	if ProcessData[Routing.Data] == nil {
-		return false
-	} else if ProcessData[Routing.Data] == Routing.Value {
-		return true
-	} else {
+	data, exists := ProcessData[Routing.Data]
+	operator := strings.ToLower(Routing.Operator)
+
+	if data == nil {
+		return operator == "ne"
+	}
+
+	switch operator {
+	case "", "eq", "=", "==":
+		return compareRoutingValues(data, Routing.Value) == 0
+	case "ne", "!=", "<>":
+		return compareRoutingValues(data, Routing.Value) != 0
+	case "gt", ">":
+		return compareRoutingValues(data, Routing.Value) > 0
+	case "ge", ">=":
+		return compareRoutingValues(data, Routing.Value) >= 0
+	case "lt", "<":
+		return compareRoutingValues(data, Routing.Value) < 0
+	case "le", "<=":
+		return compareRoutingValues(data, Routing.Value) <= 0
+	case "in":
+		for _, value := range strings.Split(Routing.Value, ",") {
+			if compareRoutingValues(data, strings.TrimSpace(value)) == 0 {
+				return true
+			}
+		}
 		return false
+	case "contains":
+		return strings.Contains(fmt.Sprint(data), Routing.Value)
+	case "exists":
+		return exists
 	}
 
+	iLog.Error(fmt.Sprintf("Unknown operator %s in the routing: %v", Routing.Operator, Routing))
+	return false
 }
 
 // ExecuteTask executes a workflow task.
@@ -731,20 +781,26 @@ func ExecuteTask(workflowtaskid int64, NodeData wftype.Node, idbTx *sql.Tx, DocD
 
 	iLog.Debug(fmt.Sprintf("ExecuteTask by workflowtaskid: %d", workflowtaskid))
 
-	if NodeData.Type == "start" {
+	if NodeData.Type == wftype.NodeTypeStart {
 		wft := NewWorkFlowTaskType(workflowtaskid, UserName)
 		wft.DBTx = idbTx
 		wft.DocDBCon = DocDBCon
 		wft.UpdateTaskStatus(2) // In Progress / started
 		wft.CompleteTask()
 		return nil, nil
-	} else if NodeData.Type == "end" {
+	} else if NodeData.Type == wftype.NodeTypeEnd {
 		wft := NewWorkFlowTaskType(workflowtaskid, UserName)
 		wft.DBTx = idbTx
 		wft.DocDBCon = DocDBCon
 		wft.UpdateTaskStatus(2) // In Progress / started
 		wft.CompleteTask()
 		return nil, nil
+	} else if NodeData.Type == wftype.NodeTypeTimer {
+		// the task of a timer node waits for its timer, see FireTimer
+		return nil, nil
+	} else if NodeData.Type == wftype.NodeTypeCallActivity {
+		// the task of a call activity waits for its child workflow, see completeCallActivity
+		return nil, startCallActivity(workflowtaskid, NodeData, idbTx, DocDBCon, UserName)
 	}
 
 	if NodeData.Page == "" {
@@ -783,6 +839,14 @@ func ExecuteTask(workflowtaskid int64, NodeData wftype.Node, idbTx *sql.Tx, DocD
 
 			if err != nil {
 				wft.UpdateTaskStatus(4) // executed with Error
+
+				// the error reaches the call activities waiting for the workflow of the task
+				if internaltransaction {
+					idbTx.Rollback()
+					failCallActivities(workflowtaskid, nil, UserName, err)
+				} else {
+					failCallActivities(workflowtaskid, idbTx, UserName, err)
+				}
 				return nil, err
 			}
 		}
@@ -903,6 +967,8 @@ func ExecuteTaskTranCode(workflowtaskid int64, TranCodeName string, data map[str
 // - StartedDate: the date and time when the workflow task was started
 // - CompletedDate: the date and time when the workflow task was completed
 // - NotificationUUID: the UUID of the notification associated with the workflow task
+// - ParentEntityID, ParentTaskID: the parent workflow entity and call activity task of a child workflow
+// - ChildEntityID: the last child workflow entity started by a call activity task
 
 func GetWorkFlowTasks(workflowentityid int64, UserName string) ([]map[string]interface{}, error) {
 	iLog := logger.Log{ModuleName: logger.Framework, ControllerName: "workflow tasks"}
@@ -926,7 +992,10 @@ func GetWorkFlowTasks(workflowentityid int64, UserName string) ([]map[string]int
 	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
 
 	// Get workflow entity
-	result, err := dbop.Query_Json(fmt.Sprintf("select * from workflow_tasks where workflowentityid = %d", workflowentityid))
+	result, err := dbop.Query_Json(fmt.Sprintf(`select t.*, e.parententityid, e.parenttaskid,
+			(select max(c.id) from workflow_entities c where c.parenttaskid = t.id) as childentityid
+			from workflow_tasks t inner join workflow_entities e on e.id = t.workflowentityid
+			where t.workflowentityid = %d`, workflowentityid))
 
 	if err != nil {
 		iLog.Error(fmt.Sprintf("Error in getting workflow tasks: %s", err))
@@ -959,14 +1028,14 @@ func GetTasksbyUser(UserName string) ([]map[string]interface{}, error) {
 
 	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
 
-	// Get workflow entity
+	// Get the tasks assigned to the user or to a user the user substitutes, without the tasks claimed by
+	// another user and the approvals in which every assignee the user acts for has decided. An empty claim is no claim.
 	querytemp := `SELECT * FROM workflow_tasks wt 
-			WHERE exists (Select 1 FROM workflow_task_assignments wts 
-				LEFT JOIN user_roles ur on ur.roleid = wts.roleid 
-				INNER JOIN users u on u.ID = wts.userid OR ur.userid = u.id 
-				where wts.workflowtaskid = wt.id AND u.loginname = '%s')`
+			WHERE %s
+			AND (wt.claimedby IS NULL OR wt.claimedby = '' OR wt.claimedby = '%s')
+			AND (COALESCE(wt.requiredapprovals, 0) = 0 OR exists (%s))`
 
-	result, err := dbop.Query_Json(fmt.Sprintf(querytemp, UserName))
+	result, err := dbop.Query_Json(fmt.Sprintf(querytemp, assigneeCondition(UserName), sqlString(UserName), undecidedSeats("wt.id", UserName)))
 
 	if err != nil {
 		iLog.Error(fmt.Sprintf("Error in getting workflow tasks: %s", err))
@@ -0,0 +1,345 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"go.mongodb.org/mongo-driver/bson"
+
+	dbconn "github.com/mdaxf/iac/databases"
+	"github.com/mdaxf/iac/documents"
+	"github.com/mdaxf/iac/logger"
+	"github.com/mdaxf/iac/models"
+	"github.com/mdaxf/iac/notifications"
+	wftype "github.com/mdaxf/iac/workflow/types"
+)
+
+/*
+TIMER DESIGN:
+
+A node with a timer gets its due date when its task is exploded, from the due data of the process data or
+from the due duration counted in the business calendar. The due date is kept in workflow_tasks.duedate and
+every action of the timer is a row in workflow_timers, written in the transaction of the task:
+
+  - reminder: a notification to the assignees, one per reminder duration before the due date
+  - escalation: the task is assigned to the escalation roles and users after the due date
+  - timer: the task of a timer node completes at the due date and the workflow follows its links
+
+A timer start node has a cron schedule. ScheduleTimerStart keeps one pending timerstart row with the next
+occurrence for the default workflow, the timer key of the occurrence is unique so the instances can not
+schedule it twice.
+
+The WorkflowTimerScheduler of framework/jobqueue claims the due rows with a lock in the database, so a timer
+fires on one instance only, and calls FireTimer. Completing a task cancels its pending timers.
+*/
+
+const timerDateFormat = "2006-01-02 15:04:05"
+
+// scheduleTaskTimers writes the due date and the timers of the task of the node in the transaction of the task
+func (e *ExplodionEngine) scheduleTaskTimers(dbop *dbconn.DBOperation, node wftype.Node, taskid int64, workflowentityid int64, created time.Time, ProcessData map[string]interface{}) error {
+	calendar := GetBusinessCalendar()
+
+	dueAt, err := calendar.TimerDueDate(node.Timer, created, ProcessData)
+	if err != nil {
+		return err
+	}
+
+	idColumn := dbop.QuoteIdentifier("id")
+	_, err = dbop.TableUpdate("workflow_tasks", []string{"duedate"}, []string{dueAt.UTC().Format(timerDateFormat)}, []int{int(0)}, fmt.Sprintf("%s = %d", idColumn, taskid))
+	if err != nil {
+		return err
+	}
+
+	if node.Type == wftype.NodeTypeTimer {
+		return e.insertTimer(dbop, fmt.Sprintf("task:%d:%s", taskid, wftype.TimerKindTimer), wftype.TimerKindTimer, workflowentityid, taskid, node.ID, dueAt)
+	}
+
+	for i, reminder := range node.Timer.Reminders {
+		remindAt, err := calendar.AddTimerDuration(dueAt, reminder, true)
+		if err != nil {
+			return err
+		}
+		if err := e.insertTimer(dbop, fmt.Sprintf("task:%d:%s:%d", taskid, wftype.TimerKindReminder, i), wftype.TimerKindReminder, workflowentityid, taskid, node.ID, remindAt); err != nil {
+			return err
+		}
+	}
+
+	if node.Timer.Escalation != nil {
+		escalateAt, err := calendar.AddTimerDuration(dueAt, node.Timer.Escalation.After, false)
+		if err != nil {
+			return err
+		}
+		return e.insertTimer(dbop, fmt.Sprintf("task:%d:%s", taskid, wftype.TimerKindEscalation), wftype.TimerKindEscalation, workflowentityid, taskid, node.ID, escalateAt)
+	}
+
+	return nil
+}
+
+// insertTimer writes a pending timer
+func (e *ExplodionEngine) insertTimer(dbop *dbconn.DBOperation, timerkey string, kind string, workflowentityid int64, taskid int64, nodeid string, dueAt time.Time) error {
+	now := time.Now().UTC().Format(timerDateFormat)
+
+	columns := []string{"timerkey", "timerkind", "workflowentityid", "workflowtaskid", "workflowuuid", "workflownodeid", "dueat", "statusid", "attempts", "createdby", "createdon", "modifiedon"}
+	values := []string{timerkey, kind, fmt.Sprintf("%d", workflowentityid), fmt.Sprintf("%d", taskid), e.workflow.UUID, nodeid, dueAt.UTC().Format(timerDateFormat),
+		fmt.Sprintf("%d", models.WorkflowTimerStatusPending), "0", e.UserName, now, now}
+
+	_, err := dbop.TableInsert("workflow_timers", columns, values)
+	if err != nil {
+		return err
+	}
+
+	e.Log.Debug(fmt.Sprintf("Scheduled the %s timer of the task %d at %v", kind, taskid, dueAt))
+	return nil
+}
+
+// cancelTaskTimers cancels the pending timers of a task that is done
+func cancelTaskTimers(dbop *dbconn.DBOperation, taskid int64) error {
+	Columns := []string{"statusid", "modifiedon"}
+	Values := []string{fmt.Sprintf("%d", models.WorkflowTimerStatusCancelled), time.Now().UTC().Format(timerDateFormat)}
+	datatypes := []int{int(1), int(0)}
+	Where := fmt.Sprintf("workflowtaskid = %d AND statusid = %d", taskid, models.WorkflowTimerStatusPending)
+
+	_, err := dbop.TableUpdate("workflow_timers", Columns, Values, datatypes, Where)
+	return err
+}
+
+// FireTimer runs the action of a due timer. The timers of a task that is already completed or cancelled do nothing.
+func FireTimer(timer *models.WorkflowTimer, UserName string) error {
+	iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow timers"}
+	startTime := time.Now()
+	defer func() {
+		elapsed := time.Since(startTime)
+		iLog.PerformanceWithDuration("FireTimer", elapsed)
+	}()
+
+	iLog.Debug(fmt.Sprintf("Fire the %s timer %s of the workflow entity %d", timer.TimerKind, timer.TimerKey, timer.WorkflowEntityID))
+
+	if timer.TimerKind == wftype.TimerKindTimerStart {
+		return startTimerWorkFlow(timer, UserName)
+	}
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		iLog.Error(fmt.Sprintf("Error in creating DB connection: %s", err))
+		return err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
+
+	rows, err := dbop.Query_Json(fmt.Sprintf("select t.status as taskstatus, e.status as entitystatus, e.entity from workflow_tasks t inner join workflow_entities e on e.id = t.workflowentityid where t.id = %d", timer.WorkflowTaskID))
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("the workflow task %d of the timer %s does not exist", timer.WorkflowTaskID, timer.TimerKey)
+	}
+	if status := toInt64(rows[0]["taskstatus"]); status == wftype.TaskStatusCompleted || status == wftype.TaskStatusCancelled {
+		iLog.Debug(fmt.Sprintf("The workflow task %d is done, skip its %s timer", timer.WorkflowTaskID, timer.TimerKind))
+		return nil
+	}
+	// the timers of a suspended entity hold until it is resumed, the timers of a cancelled or completed entity are done
+	if status := toInt64(rows[0]["entitystatus"]); status != wftype.EntityStatusActive {
+		iLog.Debug(fmt.Sprintf("The workflow entity %d is not active, skip the %s timer of the task %d", timer.WorkflowEntityID, timer.TimerKind, timer.WorkflowTaskID))
+		return nil
+	}
+	EntityName := fmt.Sprint(rows[0]["entity"])
+
+	// the notification goes out once the changes of the timer are committed
+	var notify func()
+
+	switch timer.TimerKind {
+	case wftype.TimerKindReminder:
+		notroles, notusers, err := getTaskAssignees(dbop, timer.WorkflowTaskID)
+		if err != nil {
+			return err
+		}
+		notify = func() {
+			notifyTask(timer, EntityName, notroles, notusers, "workflow task reminder for "+EntityName, UserName)
+		}
+
+	case wftype.TimerKindEscalation:
+		WorkFlow, _, err := GetWorkFlowbyUUID(timer.WorkflowUUID, UserName, *documents.DocDBCon)
+		if err != nil {
+			return err
+		}
+		node := (&ExplodionEngine{}).getNodeByID(timer.WorkflowNodeID, WorkFlow.Nodes)
+		if node.Timer == nil || node.Timer.Escalation == nil {
+			return fmt.Errorf("the node %s has no escalation", timer.WorkflowNodeID)
+		}
+
+		e := &ExplodionEngine{WorkflowName: WorkFlow.Name, EntityName: EntityName, Log: iLog, UserName: UserName, workflow: WorkFlow}
+		_, _, notroles, notusers, err := e.assignTask(dbop, timer.WorkflowTaskID, node.Timer.Escalation.Roles, node.Timer.Escalation.Users)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC().Format(timerDateFormat)
+		columns := []string{"workflowentityid", "workflowtaskid", "typecode", "status", "createdby", "createdon", "modifiedby", "modifiedon"}
+		values := []string{fmt.Sprintf("%d", timer.WorkflowEntityID), fmt.Sprintf("%d", timer.WorkflowTaskID), "escalate task", "1", UserName, now, UserName, now}
+		if _, err = dbop.TableInsert("workflow_task_histories", columns, values); err != nil {
+			return err
+		}
+
+		notify = func() {
+			notifyTask(timer, EntityName, notroles, notusers, "workflow task escalated for "+EntityName, UserName)
+		}
+
+	case wftype.TimerKindTimer:
+		if err := DBTx.Commit(); err != nil {
+			return err
+		}
+
+		wft := NewWorkFlowTaskType(timer.WorkflowTaskID, UserName)
+		wft.UpdateTaskStatus(wftype.TaskStatusStarted)
+		return wft.CompleteTask()
+
+	default:
+		return fmt.Errorf("unknown timer kind %s", timer.TimerKind)
+	}
+
+	if err := DBTx.Commit(); err != nil {
+		return err
+	}
+	notify()
+	return nil
+}
+
+// getTaskAssignees returns the names of the roles and users the task is assigned to
+func getTaskAssignees(dbop *dbconn.DBOperation, taskid int64) (map[string]interface{}, map[string]interface{}, error) {
+	notroles := make(map[string]interface{})
+	notusers := make(map[string]interface{})
+
+	rows, err := dbop.Query_Json(fmt.Sprintf("select r.name from workflow_task_assignments a inner join roles r on r.id = a.roleid where a.workflowtaskid = %d", taskid))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		notroles[fmt.Sprint(row["name"])] = 1
+	}
+
+	rows, err = dbop.Query_Json(fmt.Sprintf("select u.loginname from workflow_task_assignments a inner join users u on u.id = a.userid where a.workflowtaskid = %d", taskid))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range rows {
+		notusers[fmt.Sprint(row["loginname"])] = 1
+	}
+
+	return notroles, notusers, nil
+}
+
+// notifyTask sends a notification about the task of the timer to the roles and users
+func notifyTask(timer *models.WorkflowTimer, EntityName string, notroles map[string]interface{}, notusers map[string]interface{}, message string, UserName string) {
+	notification := make(map[string]interface{})
+	notification["type"] = "workflow"
+	notification["entity"] = EntityName
+	notification["workflownodeid"] = timer.WorkflowNodeID
+	notification["workflowtaskid"] = timer.WorkflowTaskID
+	notification["workflowentityid"] = timer.WorkflowEntityID
+	notification["status"] = "1"
+	notification["roles"] = notroles
+	notification["receipts"] = notusers
+	notification["sender"] = UserName
+	notification["topic"] = message
+	notification["message"] = message
+	notification["uuid"] = uuid.New().String()
+
+	if err := notifications.CreateNewNotification(notification, UserName); err != nil {
+		iLog := logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow timers"}
+		iLog.Error(fmt.Sprintf("Error in creating the notification of the %s timer %s: %s", timer.TimerKind, timer.TimerKey, err))
+	}
+}
+
+// startTimerWorkFlow starts a new entity of the workflow of a timer start and schedules its next occurrence
+func startTimerWorkFlow(timer *models.WorkflowTimer, UserName string) error {
+	WorkFlow, _, err := GetWorkFlowbyUUID(timer.WorkflowUUID, UserName, *documents.DocDBCon)
+	if err != nil {
+		return err
+	}
+
+	EntityName := fmt.Sprintf("%s %s", WorkFlow.Name, timer.DueAt.UTC().Format(timerDateFormat))
+	Data := map[string]interface{}{"TimerDueAt": timer.DueAt.UTC().Format(timerDateFormat)}
+
+	wfe := NewExplosion(WorkFlow.Name, EntityName, WorkFlow.Type, UserName, "")
+	if _, err := wfe.Explode("started by the timer "+timer.TimerKey, Data); err != nil {
+		return err
+	}
+
+	return ScheduleTimerStart(WorkFlow, timer.DueAt, UserName)
+}
+
+// GetTimerStartWorkFlows returns the default workflows with a timer start node
+func GetTimerStartWorkFlows(UserName string) ([]wftype.WorkFlow, error) {
+	filter := bson.M{"isdefault": true, "nodes.type": wftype.NodeTypeTimerStart}
+
+	workflowsM, err := documents.DocDBCon.QueryCollection("WorkFlow", filter, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	workflows := []wftype.WorkFlow{}
+	for _, workflowM := range workflowsM {
+		jsonString, err := json.Marshal(workflowM)
+		if err != nil {
+			return nil, err
+		}
+
+		var workflow wftype.WorkFlow
+		if err := json.Unmarshal(jsonString, &workflow); err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, workflow)
+	}
+
+	return workflows, nil
+}
+
+// ScheduleTimerStart schedules the next occurrence after the time of the timer start node of the workflow,
+// unless an occurrence is pending already
+func ScheduleTimerStart(WorkFlow wftype.WorkFlow, after time.Time, UserName string) error {
+	node := wftype.Node{}
+	for _, n := range WorkFlow.Nodes {
+		if n.Type == wftype.NodeTypeTimerStart {
+			node = n
+			break
+		}
+	}
+	if node.Timer == nil || node.Timer.Cron == "" {
+		return fmt.Errorf("the workflow %s has no timer start schedule", WorkFlow.Name)
+	}
+
+	schedule, err := cron.ParseStandard(node.Timer.Cron)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %s of the workflow %s: %w", node.Timer.Cron, WorkFlow.Name, err)
+	}
+
+	DBTx, err := dbconn.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer DBTx.Rollback()
+
+	dbop := dbconn.NewDBOperation(UserName, DBTx, logger.Framework)
+
+	rows, err := dbop.Query_Json(fmt.Sprintf("select id from workflow_timers where workflowuuid = '%s' AND timerkind = '%s' AND statusid = %d",
+		WorkFlow.UUID, wftype.TimerKindTimerStart, models.WorkflowTimerStatusPending))
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		return nil
+	}
+
+	next := schedule.Next(after)
+	e := &ExplodionEngine{WorkflowName: WorkFlow.Name, UserName: UserName, workflow: WorkFlow,
+		Log: logger.Log{ModuleName: logger.Framework, User: UserName, ControllerName: "workflow timers"}}
+	if err := e.insertTimer(dbop, fmt.Sprintf("start:%s:%d", WorkFlow.UUID, next.Unix()), wftype.TimerKindTimerStart, 0, 0, node.ID, next); err != nil {
+		return err
+	}
+
+	return DBTx.Commit()
+}
@@ -2,6 +2,55 @@ package types
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+// Node types of a workflow
+const (
+	NodeTypeStart = "start"
+	NodeTypeEnd   = "end"
+	NodeTypeTask  = "task"
+	// NodeTypeGateway follows every routing whose condition holds, the default routing included
+	NodeTypeGateway = "gateway"
+	// NodeTypeExclusiveGateway follows the first matching routing by sequence, the default routing if none matches
+	NodeTypeExclusiveGateway = "exclusivegateway"
+	// NodeTypeInclusiveGateway follows every matching routing, the default routing if none matches
+	NodeTypeInclusiveGateway = "inclusivegateway"
+	// NodeTypeParallelGateway follows every outgoing link
+	NodeTypeParallelGateway = "parallelgateway"
+	// NodeTypeJoinGateway waits until every incoming branch has arrived, then follows its outgoing links
+	NodeTypeJoinGateway = "joingateway"
+	// NodeTypeTimerStart starts a new workflow entity on the cron schedule of its timer
+	NodeTypeTimerStart = "timerstart"
+	// NodeTypeTimer waits until the due date of its timer, then follows its outgoing links
+	NodeTypeTimer = "timer"
+	// NodeTypeCallActivity starts a child workflow and waits until it completes, then follows its outgoing links
+	NodeTypeCallActivity = "callactivity"
+)
+
+// Kinds of the workflow timers
+const (
+	TimerKindReminder   = "reminder"   // reminds the assignees of a task before its due date
+	TimerKindEscalation = "escalation" // assigns an overdue task to the escalation roles and users
+	TimerKindTimer      = "timer"      // completes the task of a timer node
+	TimerKindTimerStart = "timerstart" // starts a workflow with a timer start node
+)
+
+// Statuses of a workflow task
+const (
+	TaskStatusCreated   = 1
+	TaskStatusStarted   = 2
+	TaskStatusError     = 4
+	TaskStatusCompleted = 5
+	TaskStatusWaiting   = 6 // a join gateway waiting for its incoming branches or a call activity for its child workflow
+	TaskStatusCancelled = 8 // the workflow was cancelled or restarted from another node
+)
+
+// Statuses of a workflow entity
+const (
+	EntityStatusActive    = 1
+	EntityStatusCompleted = 5
+	EntityStatusSuspended = 7
+	EntityStatusCancelled = 8
+)
+
 type WorkFlow struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty"`
 	Name        string             `json:"name"`
@@ -29,6 +78,58 @@ type Node struct {
 	PostCondition map[string]interface{} `json:"postcondition"`
 	ProcessData   map[string]interface{} `json:"processdata"`
 	RoutingTables []RoutingTable         `json:"routingtables"`
+	Timer         *Timer                 `json:"timer,omitempty"`
+	Approval      *Approval              `json:"approval,omitempty"`
+	FourEyes      bool                   `json:"foureyes"` // the initiator of the workflow can not complete the task
+	CallActivity  *CallActivity          `json:"callactivity,omitempty"`
+}
+
+// IsGateway reports whether the node routes the workflow instead of doing work
+func (n Node) IsGateway() bool {
+	switch n.Type {
+	case NodeTypeGateway, NodeTypeExclusiveGateway, NodeTypeInclusiveGateway, NodeTypeParallelGateway, NodeTypeJoinGateway:
+		return true
+	}
+	return false
+}
+
+// Timer defines the due date, reminders and escalation of a task, the wait of a timer node
+// and the schedule of a timer start node. Durations are Go durations like 30m or 4h, days like 2d
+// or business days like 3bd, see workflow.ParseTimerDuration.
+type Timer struct {
+	Due        string      `json:"due"`       // duration from the creation of the task to its due date
+	DueData    string      `json:"duedata"`   // process data holding the due date, instead of the duration
+	Reminders  []string    `json:"reminders"` // durations before the due date to remind the assignees
+	Escalation *Escalation `json:"escalation"`
+	Cron       string      `json:"cron"` // schedule of a timer start node
+}
+
+// Escalation assigns an overdue task to more roles and users
+type Escalation struct {
+	After string   `json:"after"` // duration after the due date, at the due date without a value
+	Roles []string `json:"roles"`
+	Users []string `json:"users"`
+}
+
+// Approval makes the task a multi-instance approval, the assignees approve or reject it one by one.
+// The outcome is written to the process data key approval, approved or rejected, for the routing.
+type Approval struct {
+	Required int `json:"required"` // approvals completing the task, every assignee without a value
+}
+
+// Outcomes of a multi-instance approval
+const (
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// CallActivity defines the child workflow a call activity node starts and the mapping of the process data.
+// A mapping maps the target keys to the source keys, without a mapping all the process data is passed.
+type CallActivity struct {
+	WorkFlow string            `json:"workflow"` // name of the child workflow
+	Version  string            `json:"version"`  // version of the child workflow, the default workflow without a value
+	Input    map[string]string `json:"input"`    // child data key to parent process data key
+	Output   map[string]string `json:"output"`   // parent process data key to child process data key
 }
 
 type Link struct {
@@ -44,6 +145,7 @@ type RoutingTable struct {
 	Default  bool   `json:"default"`
 	Sequence int    `json:"sequence"`
 	Data     string `json:"data"`
+	Operator string `json:"operator"` // eq without a value, see workflow.CheckRoutingCondition
 	Value    string `json:"value"`
 	Target   string `json:"target"`
 }